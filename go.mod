@@ -4,12 +4,14 @@ go 1.12
 
 require (
 	github.com/appscode/go v0.0.0-20190808133642-1d4ef1f1c1e0
+	github.com/appscode/osm v0.12.0
 	github.com/codeskyblue/go-sh v0.0.0-20190412065543-76bd3d59ff27
 	github.com/coreos/prometheus-operator v0.30.1
 	github.com/fatih/structs v1.1.0
 	github.com/onsi/ginkgo v1.8.0
 	github.com/onsi/gomega v1.5.0
 	github.com/pkg/errors v0.8.1
+	github.com/prometheus/client_golang v0.9.3-0.20190127221311-3c4408c8b829
 	github.com/spf13/cobra v0.0.5
 	github.com/spf13/pflag v1.0.3
 	github.com/tidwall/pretty v1.0.0 // indirect
@@ -18,6 +20,7 @@ require (
 	go.mongodb.org/mongo-driver v1.0.4
 	gomodules.xyz/cert v1.0.0
 	gomodules.xyz/stow v0.2.0
+	gopkg.in/yaml.v2 v2.2.2
 	k8s.io/api v0.0.0-20190503110853-61630f889b3c
 	k8s.io/apiextensions-apiserver v0.0.0-20190516231611-bf6753f2aa24
 	k8s.io/apimachinery v0.0.0-20190508063446-a3da69d3723c
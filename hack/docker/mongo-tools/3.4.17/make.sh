@@ -9,9 +9,10 @@ source "$REPO_ROOT/hack/libbuild/common/kubedb_image.sh"
 
 DOCKER_REGISTRY=${DOCKER_REGISTRY:-kubedb}
 IMG=mongo-tools
+SUFFIX=v1
 
 DB_VERSION=3.4.17
-TAG="$DB_VERSION"
+TAG="$DB_VERSION-$SUFFIX"
 OSM_VER=${OSM_VER:-0.9.1}
 
 DIST=$REPO_ROOT/dist
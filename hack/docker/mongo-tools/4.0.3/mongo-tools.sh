@@ -19,6 +19,8 @@ show_help() {
   echo "    --folder=FOLDER                name of folder in bucket"
   echo "    --snapshot=SNAPSHOT            name of snapshot"
   echo "    --skip-config=true/false       skip config db of sharded cluster dump (default: false)"
+  echo "    --compression=none/gzip/zstd   store the dump as an archive compressed with it (default: none)"
+  echo "    --encryption-key-file=FILE     store the dump as an archive encrypted with the passphrase in FILE"
   echo "    --enable-analytics=ENABLE_ANALYTICS   send analytical events to Google Analytics (default true)"
 }
 
@@ -33,6 +35,9 @@ DB_FOLDER=${DB_FOLDER:-}
 DB_SNAPSHOT=${DB_SNAPSHOT:-}
 DB_SKIP_CONFIG=${DB_SKIP_CONFIG:-'false'}
 DB_DATA_DIR=${DB_DATA_DIR:-/var/data}
+DB_COMPRESSION=${DB_COMPRESSION:-none}
+DB_ENCRYPTION_KEY_FILE=${DB_ENCRYPTION_KEY_FILE:-}
+ARCHIVE_FILE=dump.archive
 OSM_CONFIG_FILE=/etc/osm/config
 ENABLE_ANALYTICS=${ENABLE_ANALYTICS:-true}
 
@@ -73,6 +78,14 @@ while test $# -gt 0; do
       export DB_SKIP_CONFIG=$(echo $1 | sed -e 's/^[^=]*=//g')
       shift
       ;;
+    --compression*)
+      export DB_COMPRESSION=$(echo $1 | sed -e 's/^[^=]*=//g')
+      shift
+      ;;
+    --encryption-key-file*)
+      export DB_ENCRYPTION_KEY_FILE=$(echo $1 | sed -e 's/^[^=]*=//g')
+      shift
+      ;;
     --analytics* | --enable-analytics*)
       export ENABLE_ANALYTICS=$(echo $1 | sed -e 's/^[^=]*=//g')
       shift
@@ -93,6 +106,41 @@ if [ -n "$DEBUG" ]; then
   echo ""
 fi
 
+# The dump is stored as a single archive if it is compressed or encrypted. gzip is done by mongodump itself,
+# zstd and the encryption filter the archive stream.
+use_archive() {
+  [[ "$DB_COMPRESSION" != "none" || -n "$DB_ENCRYPTION_KEY_FILE" ]]
+}
+
+compress() {
+  if [[ "$DB_COMPRESSION" == "zstd" ]]; then zstd -q -c; else cat; fi
+}
+
+decompress() {
+  if [[ "$DB_COMPRESSION" == "zstd" ]]; then zstd -d -q -c; else cat; fi
+}
+
+encrypt() {
+  if [[ -n "$DB_ENCRYPTION_KEY_FILE" ]]; then
+    openssl enc -aes-256-cbc -md sha256 -salt -pass "file:$DB_ENCRYPTION_KEY_FILE"
+  else
+    cat
+  fi
+}
+
+decrypt() {
+  if [[ -n "$DB_ENCRYPTION_KEY_FILE" ]]; then
+    openssl enc -d -aes-256-cbc -md sha256 -pass "file:$DB_ENCRYPTION_KEY_FILE"
+  else
+    cat
+  fi
+}
+
+restore_archive() {
+  decrypt <"$DB_DATA_DIR/$ARCHIVE_FILE" | decompress |
+    mongorestore --host "$DB_HOST" --port $DB_PORT --username "$DB_USER" --password "$DB_PASSWORD" --archive "$@"
+}
+
 # Wait for mongodb to start
 # ref: http://unix.stackexchange.com/a/5279
 while ! mongo --host "$DB_HOST" --port $DB_PORT --eval "db.adminCommand('ping')"; do
@@ -107,19 +155,37 @@ rm -rf *
 
 case "$op" in
   backup)
-    mongodump --host "$DB_HOST" --port $DB_PORT --username "$DB_USER" --password "$DB_PASSWORD" --out "$DB_DATA_DIR" "$@"
+    if use_archive; then
+      if [[ "$DB_COMPRESSION" == "gzip" ]]; then
+        set -- --gzip "$@"
+      fi
+      mongodump --host "$DB_HOST" --port $DB_PORT --username "$DB_USER" --password "$DB_PASSWORD" --archive "$@" |
+        compress | encrypt >"$DB_DATA_DIR/$ARCHIVE_FILE"
+    else
+      mongodump --host "$DB_HOST" --port $DB_PORT --username "$DB_USER" --password "$DB_PASSWORD" --out "$DB_DATA_DIR" "$@"
+    fi
     osm push --enable-analytics="$ENABLE_ANALYTICS" --osmconfig="$OSM_CONFIG_FILE" -c "$DB_BUCKET" "$DB_DATA_DIR" "$DB_FOLDER/$DB_SNAPSHOT"
     ;;
   restore)
     osm pull --enable-analytics="$ENABLE_ANALYTICS" --osmconfig="$OSM_CONFIG_FILE" -c "$DB_BUCKET" "$DB_FOLDER/$DB_SNAPSHOT" "$DB_DATA_DIR"
-    if [[ -d "$DB_DATA_DIR/config" ]]; then
+    if use_archive; then
+      if [[ "$DB_COMPRESSION" == "gzip" ]]; then
+        set -- --gzip "$@"
+      fi
       if [[ "$DB_SKIP_CONFIG" == "false" ]]; then
-        mongorestore --host "$DB_HOST" --port $DB_PORT --username "$DB_USER" --password "$DB_PASSWORD" \
-        --authenticationDatabase admin -d config "$DB_DATA_DIR/config" "$@"
+        restore_archive --authenticationDatabase admin --nsInclude='config.*' "$@"
+      fi
+      restore_archive --nsExclude='config.*' "$@"
+    else
+      if [[ -d "$DB_DATA_DIR/config" ]]; then
+        if [[ "$DB_SKIP_CONFIG" == "false" ]]; then
+          mongorestore --host "$DB_HOST" --port $DB_PORT --username "$DB_USER" --password "$DB_PASSWORD" \
+          --authenticationDatabase admin -d config "$DB_DATA_DIR/config" "$@"
+        fi
+        rm -rf "$DB_DATA_DIR/config"
       fi
-      rm -rf "$DB_DATA_DIR/config"
+      mongorestore --host "$DB_HOST" --port $DB_PORT --username "$DB_USER" --password "$DB_PASSWORD" "$DB_DATA_DIR" "$@"
     fi
-    mongorestore --host "$DB_HOST" --port $DB_PORT --username "$DB_USER" --password "$DB_PASSWORD" "$DB_DATA_DIR" "$@"
     ;;
   *)
     (10)
@@ -4,10 +4,10 @@ set -xeou pipefail
 DOCKER_REGISTRY=${DOCKER_REGISTRY:-kubedb}
 
 IMG=mongo-tools
-SUFFIX=v2
+SUFFIX=v3
 
 DB_VERSION=4.0
-PATCH=4.0.11
+PATCH=4.0.11-v1
 
 TAG="$DB_VERSION-$SUFFIX"
 BASE_TAG="$PATCH"
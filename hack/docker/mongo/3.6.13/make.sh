@@ -9,9 +9,10 @@ source "$REPO_ROOT/hack/libbuild/common/kubedb_image.sh"
 
 DOCKER_REGISTRY=${DOCKER_REGISTRY:-kubedb}
 IMG=mongo
+SUFFIX=v1
 
 DB_VERSION=3.6.13
-TAG="$DB_VERSION"
+TAG="$DB_VERSION-$SUFFIX"
 
 DIST=$REPO_ROOT/dist
 mkdir -p $DIST
@@ -4,10 +4,10 @@ set -xeou pipefail
 DOCKER_REGISTRY=${DOCKER_REGISTRY:-kubedb}
 
 IMG=mongo
-SUFFIX=v4
+SUFFIX=v5
 
 DB_VERSION=3.6
-PATCH=3.6.13
+PATCH=3.6.13-v1
 
 TAG="$DB_VERSION-$SUFFIX"
 BASE_TAG="$PATCH"
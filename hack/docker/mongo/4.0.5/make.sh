@@ -9,7 +9,7 @@ source "$REPO_ROOT/hack/libbuild/common/kubedb_image.sh"
 
 DOCKER_REGISTRY=${DOCKER_REGISTRY:-kubedb}
 IMG=mongo
-SUFFIX=v2
+SUFFIX=v3
 DB_VERSION=4.0.5
 TAG="$DB_VERSION-$SUFFIX"
 
@@ -41,24 +41,32 @@ function shutdown_mongo() {
 if [[ ${SSL_MODE} != "disabled" ]]; then
   ca_crt=/data/configdb/ca.cert
   ca_key=/data/configdb/ca.key
-  if [[ ! -f "$ca_crt" ]] || [[ ! -f "$ca_key" ]]; then
-    log "ENABLE_SSL is set to true, but $ca_crt or $ca_key file does not exists "
-    exit 1
-  fi
-
-  log "Generating certificate"
-
   pem=/data/configdb/mongo.pem
   ssl_args=(--ssl --sslCAFile "$ca_crt" --sslPEMKeyFile "$pem")
   auth_args=(--clusterAuthMode ${CLUSTER_AUTH_MODE} --sslMode ${SSL_MODE} --sslCAFile "$ca_crt" --sslPEMKeyFile "$pem" --keyFile=/data/configdb/key.txt)
 
-  # extract svc-name.namespace.svc from service_name, which is pod-name.gvr-svc-name.namespace.svc.cluster.local
-  svc_name="$(echo "${FULL_SVC%.svc.*}").svc"
+  if [[ ! -f "$ca_crt" ]]; then
+    log "ENABLE_SSL is set to true, but $ca_crt file does not exists "
+    exit 1
+  fi
 
   # Move into /work-dir
   pushd /work-dir
 
-  cat >openssl.cnf <<EOL
+  # mongo.pem is issued by the operator, which keeps ca.key to itself.
+  # It is generated here for the pods of older operators only, that ship ca.key instead.
+  if [[ ! -f "$pem" ]]; then
+    if [[ ! -f "$ca_crt" ]] || [[ ! -f "$ca_key" ]]; then
+      log "ENABLE_SSL is set to true, but $ca_crt or $ca_key file does not exists "
+      exit 1
+    fi
+
+    log "Generating certificate"
+
+    # extract svc-name.namespace.svc from service_name, which is pod-name.gvr-svc-name.namespace.svc.cluster.local
+    svc_name="$(echo "${FULL_SVC%.svc.*}").svc"
+
+    cat >openssl.cnf <<EOL
 [req]
 req_extensions = v3_req
 distinguished_name = req_distinguished_name
@@ -76,17 +84,18 @@ DNS.5 = localhost
 DNS.6 = 127.0.0.1
 EOL
 
-  # Generate the certs
-  export RANDFILE=/work-dir/.rnd
-  openssl genrsa -out mongo.key 2048
-  openssl req -new -key mongo.key -out mongo.csr -subj "/OU=MongoDB/CN=$SERVICE_NAME" -config openssl.cnf
-  openssl x509 -req -in mongo.csr \
-    -CA "$ca_crt" -CAkey "$ca_key" -CAcreateserial \
-    -out mongo.crt -days 3650 -extensions v3_req -extfile openssl.cnf
-
-  rm mongo.csr
-  cat mongo.crt mongo.key >$pem
-  rm mongo.key mongo.crt
+    # Generate the certs
+    export RANDFILE=/work-dir/.rnd
+    openssl genrsa -out mongo.key 2048
+    openssl req -new -key mongo.key -out mongo.csr -subj "/OU=MongoDB/CN=$SERVICE_NAME" -config openssl.cnf
+    openssl x509 -req -in mongo.csr \
+      -CA "$ca_crt" -CAkey "$ca_key" -CAcreateserial \
+      -out mongo.crt -days 3650 -extensions v3_req -extfile openssl.cnf
+
+    rm mongo.csr
+    cat mongo.crt mongo.key >$pem
+    rm mongo.key mongo.crt
+  fi
 fi
 
 log "Ping Config Server replicaset : $CONFIGDB_REPSET"
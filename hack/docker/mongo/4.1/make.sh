@@ -4,11 +4,12 @@ set -xeou pipefail
 DOCKER_REGISTRY=${DOCKER_REGISTRY:-kubedb}
 
 IMG=mongo
+SUFFIX=v1
 
 DB_VERSION=4.1
-PATCH=4.1.13
+PATCH=4.1.13-v1
 
-TAG="$DB_VERSION"
+TAG="$DB_VERSION-$SUFFIX"
 BASE_TAG="$PATCH"
 
 docker pull "$DOCKER_REGISTRY/$IMG:$BASE_TAG"
@@ -0,0 +1,38 @@
+package admission
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	mona "kmodules.xyz/monitoring-agent-api/api/v1"
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+)
+
+// validateAlerting validates spec.alerting of mongodb, that needs a PrometheusRule of the CoreOS Prometheus operator.
+func validateAlerting(mongodb *api.MongoDB) error {
+	alerting := mongodb.Spec.Alerting
+	if alerting == nil {
+		return nil
+	}
+	if mongodb.Spec.Monitor == nil || mongodb.Spec.Monitor.Agent != mona.AgentCoreOSPrometheus {
+		return fmt.Errorf("spec.alerting requires spec.monitor.agent %v", mona.AgentCoreOSPrometheus)
+	}
+	for field, d := range map[string]*metav1.Duration{
+		"for":                     alerting.For,
+		"replicationLagThreshold": alerting.ReplicationLagThreshold,
+		"snapshotAgeThreshold":    alerting.SnapshotAgeThreshold,
+	} {
+		if d != nil && d.Duration <= 0 {
+			return fmt.Errorf("spec.alerting.%v %v invalid. Must be greater than zero", field, d.Duration)
+		}
+	}
+	for field, p := range map[string]*int32{
+		"connectionUsagePercent": alerting.ConnectionUsagePercent,
+		"diskUsagePercent":       alerting.DiskUsagePercent,
+	} {
+		if p != nil && (*p <= 0 || *p > 100) {
+			return fmt.Errorf("spec.alerting.%v %v invalid. Must be between 1 and 100", field, *p)
+		}
+	}
+	return nil
+}
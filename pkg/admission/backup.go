@@ -0,0 +1,93 @@
+package admission
+
+import (
+	"fmt"
+
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+)
+
+// PreserveUUIDRelease is the first release series whose mongorestore keeps the UUIDs of the collections, that
+// the sharding metadata restored from a consistent snapshot refers to.
+var PreserveUUIDRelease = ReleaseSeries{Major: 4, Minor: 0}
+
+// validateShardedBackup validates spec.shardTopology.backupMode of mongodb. version is the MongoDB version of
+// spec.version.
+func validateShardedBackup(mongodb *api.MongoDB, version string) error {
+	if mongodb.Spec.ShardTopology == nil {
+		return nil
+	}
+	switch mode := mongodb.Spec.ShardTopology.BackupMode; mode {
+	case "", api.MongoDBShardedBackupModeMongos:
+		return nil
+	case api.MongoDBShardedBackupModeConsistent:
+	default:
+		return fmt.Errorf("spec.shardTopology.backupMode %q invalid. Must be %v or %v", mode,
+			api.MongoDBShardedBackupModeMongos, api.MongoDBShardedBackupModeConsistent)
+	}
+
+	series, err := ParseReleaseSeries(version)
+	if err != nil {
+		return err
+	}
+	if series.Less(PreserveUUIDRelease) {
+		return fmt.Errorf("spec.shardTopology.backupMode %v is not supported for MongoDB %v. Requires %v or later",
+			api.MongoDBShardedBackupModeConsistent, series, PreserveUUIDRelease)
+	}
+	return nil
+}
+
+// validateRetentionPolicy validates spec.backupSchedule.retentionPolicy.
+func validateRetentionPolicy(policy *api.SnapshotRetentionPolicy) error {
+	if policy == nil {
+		return nil
+	}
+	keeps := []struct {
+		field string
+		n     *int32
+	}{
+		{"keepLast", policy.KeepLast},
+		{"keepDaily", policy.KeepDaily},
+		{"keepWeekly", policy.KeepWeekly},
+		{"keepMonthly", policy.KeepMonthly},
+	}
+	for _, keep := range keeps {
+		if keep.n != nil && *keep.n < 0 {
+			return fmt.Errorf("spec.backupSchedule.retentionPolicy.%v %v invalid. Must not be negative", keep.field, *keep.n)
+		}
+	}
+	if policy.MaxAge != nil && policy.MaxAge.Duration <= 0 {
+		return fmt.Errorf("spec.backupSchedule.retentionPolicy.maxAge %v invalid. Must be greater than zero", policy.MaxAge.Duration)
+	}
+	return nil
+}
+
+// validateSnapshotVerification validates spec.snapshotVerification.
+func validateSnapshotVerification(verification *api.MongoDBSnapshotVerificationSpec) error {
+	if verification == nil {
+		return nil
+	}
+	if verification.Timeout != nil && verification.Timeout.Duration <= 0 {
+		return fmt.Errorf("spec.snapshotVerification.timeout %v invalid. Must be greater than zero", verification.Timeout.Duration)
+	}
+	if tolerance := verification.DocumentCountTolerance; tolerance != nil && (*tolerance < 0 || *tolerance > 100) {
+		return fmt.Errorf("spec.snapshotVerification.documentCountTolerance %v invalid. Must be between 0 and 100", *tolerance)
+	}
+	return nil
+}
+
+// ValidateSnapshotArchive validates the archive options of a snapshot or of the backup schedule.
+func ValidateSnapshotArchive(archive *api.SnapshotArchiveSpec) error {
+	if archive == nil {
+		return nil
+	}
+	switch archive.Compression {
+	case "", api.SnapshotCompressionNone, api.SnapshotCompressionGzip, api.SnapshotCompressionZstd:
+	default:
+		return fmt.Errorf("archive.compression %q invalid. Must be %v, %v or %v", archive.Compression,
+			api.SnapshotCompressionNone, api.SnapshotCompressionGzip, api.SnapshotCompressionZstd)
+	}
+	if encryption := archive.Encryption; encryption != nil && (encryption.KeySecret.Name == "" || encryption.KeySecret.Key == "") {
+		return fmt.Errorf("archive.encryption.keySecret requires the name of the secret and the key of the passphrase")
+	}
+	return nil
+}
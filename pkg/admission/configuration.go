@@ -0,0 +1,88 @@
+package admission
+
+import (
+	"fmt"
+	"strings"
+
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+)
+
+// operatorOwnedOptions are set by the operator through the command line of mongod and mongos,
+// which overrides the configuration file. They are rejected in spec.configuration, along with their sub-options.
+var operatorOwnedOptions = []string{
+	"net.port",
+	"net.bindIp",
+	"net.bindIpAll",
+	"net.ssl",
+	"net.tls",
+	"replication.replSetName",
+	"security.authorization",
+	"security.clusterAuthMode",
+	"security.keyFile",
+	"sharding.clusterRole",
+	"sharding.configDB",
+	"storage.dbPath",
+	"processManagement.fork",
+}
+
+var profilingModes = []string{"off", "slowOp", "all"}
+
+// mongod refuses wiredTiger cache smaller than 0.25GB.
+var minCacheSize = resource.MustParse("256Mi")
+
+// validateConfiguration validates the configuration of a mongod, or a mongos if mongos is true, at field.
+// As the configuration is merged into the file of configSource, configSource must be a ConfigMap or a Secret.
+func validateConfiguration(cfg *api.MongoDBConfiguration, configSource *core.VolumeSource, mongos bool, field string) error {
+	if cfg == nil {
+		return nil
+	}
+	if configSource != nil && configSource.ConfigMap == nil && configSource.Secret == nil {
+		return fmt.Errorf("%v can't be merged into a configSource other than ConfigMap or Secret", field)
+	}
+
+	if cfg.Storage != nil {
+		if mongos {
+			return fmt.Errorf("%v.storage is not supported by mongos", field)
+		}
+		if wt := cfg.Storage.WiredTiger; wt != nil && wt.CacheSize != nil && wt.CacheSize.Cmp(minCacheSize) < 0 {
+			return fmt.Errorf("%v.storage.wiredTiger.cacheSize %v is invalid. Must be at least %v", field, wt.CacheSize.String(), minCacheSize.String())
+		}
+	}
+	if net := cfg.Net; net != nil && net.MaxIncomingConnections != nil && *net.MaxIncomingConnections < 1 {
+		return fmt.Errorf("%v.net.maxIncomingConnections %v is invalid. Must be greater than zero", field, *net.MaxIncomingConnections)
+	}
+	if p := cfg.OperationProfiling; p != nil {
+		if p.Mode != "" && !contains(profilingModes, p.Mode) {
+			return fmt.Errorf("%v.operationProfiling.mode %v is invalid. Must be one of %v", field, p.Mode, profilingModes)
+		}
+		if p.SlowOpThresholdMs != nil && *p.SlowOpThresholdMs < 0 {
+			return fmt.Errorf("%v.operationProfiling.slowOpThresholdMs %v is invalid. Must not be negative", field, *p.SlowOpThresholdMs)
+		}
+	}
+
+	for option := range cfg.Options {
+		if option == "" || strings.HasPrefix(option, ".") || strings.HasSuffix(option, ".") || strings.Contains(option, "..") {
+			return fmt.Errorf("%v.options has invalid option %q", field, option)
+		}
+		if option == "setParameter" || strings.HasPrefix(option, "setParameter.") {
+			return fmt.Errorf("%v.options can't set %v. Use %v.setParameter instead", field, option, field)
+		}
+		for _, owned := range operatorOwnedOptions {
+			if option == owned || strings.HasPrefix(option, owned+".") {
+				return fmt.Errorf("%v.options can't set %v. It is managed by KubeDB operator", field, option)
+			}
+		}
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package admission
+
+import (
+	"fmt"
+	"strings"
+
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+)
+
+// MultiKeyFileRelease is the first release series that accepts a keyfile with more than one key.
+var MultiKeyFileRelease = ReleaseSeries{Major: 4, Minor: 2}
+
+// validateCredentialRotation validates spec.credentialRotation and the rotate-credentials annotation of mongodb.
+// version is the MongoDB version of spec.version.
+func validateCredentialRotation(mongodb *api.MongoDB, version string) error {
+	keyFile := false
+	for _, credential := range strings.Split(mongodb.Annotations[api.MongoDBRotateCredentialsAnnotation], ",") {
+		switch credential = strings.TrimSpace(credential); credential {
+		case "", api.MongoDBCredentialRootPassword:
+		case api.MongoDBCredentialKeyFile:
+			keyFile = true
+		default:
+			return fmt.Errorf("annotation %v has invalid credential %q. Must be %v or %v", api.MongoDBRotateCredentialsAnnotation,
+				credential, api.MongoDBCredentialRootPassword, api.MongoDBCredentialKeyFile)
+		}
+	}
+
+	if rotation := mongodb.Spec.CredentialRotation; rotation != nil {
+		if rotation.RootPasswordInterval != nil && rotation.RootPasswordInterval.Duration <= 0 {
+			return fmt.Errorf("spec.credentialRotation.rootPasswordInterval %v invalid. Must be greater than zero", rotation.RootPasswordInterval.Duration)
+		}
+		if rotation.KeyFileInterval != nil {
+			if rotation.KeyFileInterval.Duration <= 0 {
+				return fmt.Errorf("spec.credentialRotation.keyFileInterval %v invalid. Must be greater than zero", rotation.KeyFileInterval.Duration)
+			}
+			keyFile = true
+		}
+	}
+	if !keyFile {
+		return nil
+	}
+
+	if mongodb.Spec.ReplicaSet == nil && mongodb.Spec.ShardTopology == nil {
+		return fmt.Errorf("keyfile can't be rotated for standalone MongoDB, that does not use a keyfile")
+	}
+	series, err := ParseReleaseSeries(version)
+	if err != nil {
+		return err
+	}
+	if series.Less(MultiKeyFileRelease) {
+		return fmt.Errorf("keyfile can't be rotated for MongoDB %v. Requires %v or later", series, MultiKeyFileRelease)
+	}
+	return nil
+}
@@ -164,6 +164,22 @@ func setDefaultsFromDormantDB(extClient cs.Interface, mongodb *api.MongoDB, op a
 		ddbOriginSpec.Monitor = mongodb.Spec.Monitor
 	}
 
+	// If Alerting Spec of new object is not given,
+	// Take Alerting Settings from Dormant
+	if mongodb.Spec.Alerting == nil {
+		mongodb.Spec.Alerting = ddbOriginSpec.Alerting
+	} else {
+		ddbOriginSpec.Alerting = mongodb.Spec.Alerting
+	}
+
+	// If Dashboards Spec of new object is not given,
+	// Take Dashboards Settings from Dormant
+	if mongodb.Spec.Dashboards == nil {
+		mongodb.Spec.Dashboards = ddbOriginSpec.Dashboards
+	} else {
+		ddbOriginSpec.Dashboards = mongodb.Spec.Dashboards
+	}
+
 	// If SecurityContext of new object is not given,
 	// Take dormantDatabase's Security Context
 	setSecurityContextFromDormantDB(mongodb, ddbOriginSpec)
@@ -0,0 +1,44 @@
+package admission
+
+import (
+	"fmt"
+
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+	amv "kubedb.dev/apimachinery/pkg/validator"
+)
+
+// validateOplog validates spec.oplogArchive and spec.init.mongodbOplog of mongodb.
+func validateOplog(mongodb *api.MongoDB) error {
+	if archive := mongodb.Spec.OplogArchive; archive != nil {
+		if mongodb.Spec.ReplicaSet == nil && mongodb.Spec.ShardTopology == nil {
+			return fmt.Errorf("spec.oplogArchive can't be set for standalone MongoDB, that has no oplog")
+		}
+		if err := amv.ValidateSnapshotSpec(archive.Backend); err != nil {
+			return fmt.Errorf("spec.oplogArchive invalid. Reason: %v", err)
+		}
+		if archive.ChunkInterval != nil && archive.ChunkInterval.Duration <= 0 {
+			return fmt.Errorf("spec.oplogArchive.chunkInterval %v invalid. Must be greater than zero", archive.ChunkInterval.Duration)
+		}
+	}
+
+	if mongodb.Spec.Init == nil || mongodb.Spec.Init.MongoDBOplog == nil {
+		return nil
+	}
+	source := mongodb.Spec.Init.MongoDBOplog
+	if mongodb.Spec.Init.SnapshotSource == nil {
+		return fmt.Errorf("spec.init.mongodbOplog requires spec.init.snapshotSource, the snapshot the oplog is replayed on")
+	}
+	if mongodb.Spec.ShardTopology != nil {
+		return fmt.Errorf("spec.init.mongodbOplog can't be set for sharded MongoDB. The oplog is replayed into a replicaset or standalone")
+	}
+	if source.DatabaseName == "" {
+		return fmt.Errorf("spec.init.mongodbOplog.databaseName is missing")
+	}
+	if source.TargetTime.IsZero() {
+		return fmt.Errorf("spec.init.mongodbOplog.targetTime is missing")
+	}
+	if err := amv.ValidateSnapshotSpec(source.Backend); err != nil {
+		return fmt.Errorf("spec.init.mongodbOplog invalid. Reason: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package admission
+
+import (
+	"fmt"
+
+	core "k8s.io/api/core/v1"
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+)
+
+// validateProfiling validates the profiling of a mongod or a mongos at field. As the profiling options are
+// merged into the file of configSource, configSource must be a ConfigMap or a Secret.
+func validateProfiling(p *api.MongoDBProfilingSpec, configSource *core.VolumeSource, field string) error {
+	if p == nil {
+		return nil
+	}
+	if configSource != nil && configSource.ConfigMap == nil && configSource.Secret == nil {
+		return fmt.Errorf("%v can't be merged into a configSource other than ConfigMap or Secret", field)
+	}
+	if p.Level != nil && (*p.Level < 0 || int(*p.Level) >= len(profilingModes)) {
+		return fmt.Errorf("%v.level %v is invalid. Must be 0, 1 or 2", field, *p.Level)
+	}
+	if p.SlowMs != nil && *p.SlowMs < 0 {
+		return fmt.Errorf("%v.slowms %v is invalid. Must not be negative", field, *p.SlowMs)
+	}
+	if p.SampleRate != nil && (*p.SampleRate < 0 || *p.SampleRate > 1) {
+		return fmt.Errorf("%v.sampleRate %v is invalid. Must be between 0 and 1", field, *p.SampleRate)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package admission
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"
+	admission "k8s.io/api/admission/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	meta_util "kmodules.xyz/client-go/meta"
+	hookapi "kmodules.xyz/webhook-runtime/admission/v1beta1"
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+)
+
+// MongoDBRoleValidator rejects changes to the fields that identify the role in MongoDB, like MongoDBUserValidator.
+type MongoDBRoleValidator struct {
+	lock        sync.RWMutex
+	initialized bool
+}
+
+var _ hookapi.AdmissionHook = &MongoDBRoleValidator{}
+
+func (a *MongoDBRoleValidator) Resource() (plural schema.GroupVersionResource, singular string) {
+	return schema.GroupVersionResource{
+			Group:    "validators.kubedb.com",
+			Version:  "v1alpha1",
+			Resource: "mongodbrolevalidators",
+		},
+		"mongodbrolevalidator"
+}
+
+func (a *MongoDBRoleValidator) Initialize(config *rest.Config, stopCh <-chan struct{}) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.initialized = true
+	return nil
+}
+
+func (a *MongoDBRoleValidator) Admit(req *admission.AdmissionRequest) *admission.AdmissionResponse {
+	status := &admission.AdmissionResponse{}
+
+	if (req.Operation != admission.Create && req.Operation != admission.Update) ||
+		len(req.SubResource) != 0 ||
+		req.Kind.Group != api.SchemeGroupVersion.Group ||
+		req.Kind.Kind != api.ResourceKindMongoDBRole {
+		status.Allowed = true
+		return status
+	}
+
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	if !a.initialized {
+		return hookapi.StatusUninitialized()
+	}
+
+	obj, err := meta_util.UnmarshalFromJSON(req.Object.Raw, api.SchemeGroupVersion)
+	if err != nil {
+		return hookapi.StatusBadRequest(err)
+	}
+	role := obj.(*api.MongoDBRole)
+	if role.Spec.DatabaseName == "" {
+		return hookapi.StatusForbidden(errors.New(`'spec.databaseName' is missing`))
+	}
+	if req.Operation == admission.Update {
+		oldObj, err := meta_util.UnmarshalFromJSON(req.OldObject.Raw, api.SchemeGroupVersion)
+		if err != nil {
+			return hookapi.StatusBadRequest(err)
+		}
+		if err := validateRoleUpdate(role, oldObj.(*api.MongoDBRole)); err != nil {
+			return hookapi.StatusForbidden(err)
+		}
+	}
+	status.Allowed = true
+	return status
+}
+
+func validateRoleUpdate(role, oldRole *api.MongoDBRole) error {
+	if role.Spec.DatabaseName != oldRole.Spec.DatabaseName {
+		return immutableFieldError("spec.databaseName", oldRole.Spec.DatabaseName, role.Spec.DatabaseName)
+	}
+	if role.RoleNameOrDefault() != oldRole.RoleNameOrDefault() {
+		return immutableFieldError("spec.roleName", oldRole.RoleNameOrDefault(), role.RoleNameOrDefault())
+	}
+	if role.DatabaseOrDefault() != oldRole.DatabaseOrDefault() {
+		return immutableFieldError("spec.database", oldRole.DatabaseOrDefault(), role.DatabaseOrDefault())
+	}
+	return nil
+}
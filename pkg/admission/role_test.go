@@ -0,0 +1,60 @@
+package admission
+
+import (
+	"testing"
+
+	admission "k8s.io/api/admission/v1beta1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+)
+
+func sampleMongoDBRole() *api.MongoDBRole {
+	return &api.MongoDBRole{
+		TypeMeta: metaV1.TypeMeta{
+			Kind:       api.ResourceKindMongoDBRole,
+			APIVersion: api.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      "reporting",
+			Namespace: "default",
+		},
+		Spec: api.MongoDBRoleSpec{
+			DatabaseName: "foo",
+			Roles:        []api.MongoDBRoleRef{{Name: "read", Database: "app"}},
+		},
+	}
+}
+
+func TestMongoDBRoleValidator_Admit(t *testing.T) {
+	cases := []struct {
+		name    string
+		edit    func(role *api.MongoDBRole)
+		create  bool
+		allowed bool
+	}{
+		{"create", func(role *api.MongoDBRole) {}, true, true},
+		{"create without spec.databaseName", func(role *api.MongoDBRole) { role.Spec.DatabaseName = "" }, true, false},
+		{"edit spec.roles", func(role *api.MongoDBRole) { role.Spec.Roles[0].Database = "reports" }, false, true},
+		{"set spec.roleName to its default", func(role *api.MongoDBRole) { role.Spec.RoleName = "reporting" }, false, true},
+		{"edit spec.databaseName", func(role *api.MongoDBRole) { role.Spec.DatabaseName = "bar" }, false, false},
+		{"edit spec.roleName", func(role *api.MongoDBRole) { role.Spec.RoleName = "audit" }, false, false},
+		{"edit spec.database", func(role *api.MongoDBRole) { role.Spec.Database = "app" }, false, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			validator := &MongoDBRoleValidator{initialized: true}
+			role := sampleMongoDBRole()
+			c.edit(role)
+
+			var resp *admission.AdmissionResponse
+			if c.create {
+				resp = admit(t, validator, api.ResourceKindMongoDBRole, role, nil)
+			} else {
+				resp = admit(t, validator, api.ResourceKindMongoDBRole, role, sampleMongoDBRole())
+			}
+			if resp.Allowed != c.allowed {
+				t.Errorf("expected allowed %v, got %v: %v", c.allowed, resp.Allowed, resp.Result)
+			}
+		})
+	}
+}
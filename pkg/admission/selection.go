@@ -0,0 +1,126 @@
+package admission
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+)
+
+// namespacePattern matches <database> and <database>.<collection>, with the characters MongoDB allows in the names.
+var namespacePattern = regexp.MustCompile(`^[^/\\. "$]+(\.[^$]+)?$`)
+
+// SplitNamespace splits a namespace of a selection into its database and its collection, that is * if the
+// namespace is a database.
+func SplitNamespace(ns string) (string, string) {
+	parts := strings.SplitN(ns, ".", 2)
+	if len(parts) == 1 {
+		return parts[0], "*"
+	}
+	return parts[0], parts[1]
+}
+
+// QualifiedNamespace returns ns as <database>.<collection>, for mongorestore.
+func QualifiedNamespace(ns string) string {
+	database, collection := SplitNamespace(ns)
+	return database + "." + collection
+}
+
+func validateNamespaces(field string, namespaces ...string) error {
+	for _, ns := range namespaces {
+		if !namespacePattern.MatchString(ns) {
+			return fmt.Errorf("%v %q invalid. Must be <database> or <database>.<collection>", field, ns)
+		}
+	}
+	return nil
+}
+
+// ValidateSnapshotSelection validates the selection of a snapshot. mongodump dumps either every database or a single
+// one, so the selection includes a single database or collection, and excludes collections of that database only.
+func ValidateSnapshotSelection(selection *api.SnapshotSelection) error {
+	if selection == nil {
+		return nil
+	}
+	if err := validateNamespaces("selection.include", selection.Include...); err != nil {
+		return err
+	}
+	if err := validateNamespaces("selection.exclude", selection.Exclude...); err != nil {
+		return err
+	}
+	if len(selection.Include) > 1 {
+		return fmt.Errorf("selection.include %v invalid. A snapshot dumps a single database or collection", selection.Include)
+	}
+
+	var database, collection string
+	if len(selection.Include) == 1 {
+		database, collection = SplitNamespace(selection.Include[0])
+		if strings.Contains(database, "*") || (collection != "*" && strings.Contains(collection, "*")) {
+			return fmt.Errorf("selection.include %q invalid. Wildcards are not supported on dump, but for all collections of a database",
+				selection.Include[0])
+		}
+	}
+	if len(selection.Exclude) > 0 && (database == "" || collection != "*") {
+		return fmt.Errorf("selection.exclude requires selection.include of a single database")
+	}
+	for _, ns := range selection.Exclude {
+		db, coll := SplitNamespace(ns)
+		if db != database || coll == "*" {
+			return fmt.Errorf("selection.exclude %q invalid. Must be a collection of database %v", ns, database)
+		}
+		if i := strings.Index(coll, "*"); i >= 0 && i != len(coll)-1 {
+			return fmt.Errorf("selection.exclude %q invalid. Only a trailing wildcard, for a prefix of collections, is supported on dump", ns)
+		}
+	}
+
+	if selection.Query != "" {
+		if database == "" || collection == "*" {
+			return fmt.Errorf("selection.query requires selection.include of a single collection")
+		}
+		var query bson.D
+		if err := bson.UnmarshalExtJSON([]byte(selection.Query), false, &query); err != nil {
+			return fmt.Errorf("selection.query invalid. Reason: %v", err)
+		}
+	}
+	return nil
+}
+
+// validateRestoreSelection validates spec.init.snapshotSource.selection and spec.init.snapshotSource.rename of mongodb.
+func validateRestoreSelection(mongodb *api.MongoDB) error {
+	if mongodb.Spec.Init == nil || mongodb.Spec.Init.SnapshotSource == nil {
+		return nil
+	}
+	source := mongodb.Spec.Init.SnapshotSource
+	if source.Selection == nil && len(source.Rename) == 0 {
+		return nil
+	}
+	if mongodb.Spec.Init.MongoDBOplog != nil {
+		return fmt.Errorf("spec.init.snapshotSource.selection and rename can't be used with spec.init.mongodbOplog, that replays every namespace")
+	}
+	return ValidateRestoreSelection("spec.init.snapshotSource", source)
+}
+
+// ValidateRestoreSelection validates the selection and the renames of source, the snapshot restored at field.
+func ValidateRestoreSelection(field string, source *api.SnapshotSourceSpec) error {
+	if selection := source.Selection; selection != nil {
+		if selection.Query != "" {
+			return fmt.Errorf("%v.selection.query is not supported on restore", field)
+		}
+		if err := validateNamespaces(field+".selection.include", selection.Include...); err != nil {
+			return err
+		}
+		if err := validateNamespaces(field+".selection.exclude", selection.Exclude...); err != nil {
+			return err
+		}
+	}
+	for _, rename := range source.Rename {
+		if err := validateNamespaces(field+".rename", rename.From, rename.To); err != nil {
+			return err
+		}
+		if strings.Count(QualifiedNamespace(rename.From), "*") != strings.Count(QualifiedNamespace(rename.To), "*") {
+			return fmt.Errorf("%v.rename %v to %v invalid. Must have as many wildcards in both", field, rename.From, rename.To)
+		}
+	}
+	return nil
+}
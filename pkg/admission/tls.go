@@ -0,0 +1,72 @@
+package admission
+
+import (
+	"fmt"
+	"net"
+
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+)
+
+var keySizes = map[api.MongoDBKeyAlgorithm][]int{
+	api.MongoDBKeyAlgorithmRSA:   {2048, 3072, 4096},
+	api.MongoDBKeyAlgorithmECDSA: {256, 384, 521},
+}
+
+// validateTLS validates spec.tls of mongodb. Database images without memberCertificates in their catalog sign their
+// own server certificates, with the CA key of the operator, so that no other issuer can be used.
+func validateTLS(mongodb *api.MongoDB, memberCertificates bool) error {
+	tls := mongodb.Spec.TLS
+	if tls == nil {
+		return nil
+	}
+	if mongodb.Spec.SSLMode == api.SSLModeDisabled || mongodb.Spec.SSLMode == "" {
+		return fmt.Errorf("spec.tls can't be set when spec.sslMode is %v", api.SSLModeDisabled)
+	}
+
+	if ref := tls.IssuerRef; ref != nil {
+		switch ref.Kind {
+		case api.MongoDBTLSIssuerKindCASecret, api.MongoDBTLSIssuerKindIssuer, api.MongoDBTLSIssuerKindClusterIssuer:
+			if ref.Name == "" {
+				return fmt.Errorf("spec.tls.issuerRef.name is missing for issuer kind %v", ref.Kind)
+			}
+		case api.MongoDBTLSIssuerKindOperatorCA:
+		default:
+			return fmt.Errorf("spec.tls.issuerRef.kind %v is invalid. Must be one of %v, %v, %v or %v", ref.Kind,
+				api.MongoDBTLSIssuerKindCASecret, api.MongoDBTLSIssuerKindOperatorCA, api.MongoDBTLSIssuerKindIssuer, api.MongoDBTLSIssuerKindClusterIssuer)
+		}
+		if ref.Kind != api.MongoDBTLSIssuerKindOperatorCA && !memberCertificates {
+			return fmt.Errorf("spec.tls.issuerRef.kind %v is not supported by the database image of version %v. Its pods sign their own certificates",
+				ref.Kind, mongodb.Spec.Version)
+		}
+	}
+
+	for _, ip := range tls.IPAddresses {
+		if net.ParseIP(ip) == nil {
+			return fmt.Errorf("spec.tls.ipAddresses %v is not an IP address", ip)
+		}
+	}
+
+	if key := tls.PrivateKey; key != nil {
+		algorithm := key.Algorithm
+		if algorithm == "" {
+			algorithm = api.MongoDBKeyAlgorithmRSA
+		}
+		sizes, ok := keySizes[algorithm]
+		if !ok {
+			return fmt.Errorf("spec.tls.privateKey.algorithm %v is invalid. Must be %v or %v", key.Algorithm, api.MongoDBKeyAlgorithmRSA, api.MongoDBKeyAlgorithmECDSA)
+		}
+		if key.Size != 0 && !containsInt(sizes, key.Size) {
+			return fmt.Errorf("spec.tls.privateKey.size %v is invalid for %v keys. Must be one of %v", key.Size, algorithm, sizes)
+		}
+	}
+	return nil
+}
+
+func containsInt(list []int, v int) bool {
+	for _, e := range list {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package admission
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+	cs "kubedb.dev/apimachinery/client/clientset/versioned"
+)
+
+// ReleaseSeries is the major.minor version of MongoDB, eg, 3.6 for 3.6.8.
+// MongoDB can be upgraded one release series at a time only.
+type ReleaseSeries struct {
+	Major int
+	Minor int
+}
+
+// ParseReleaseSeries returns the release series of a MongoDB version, like 3.6 or 3.6.8.
+func ParseReleaseSeries(version string) (ReleaseSeries, error) {
+	parts := strings.SplitN(strings.TrimPrefix(version, "v"), ".", 3)
+	if len(parts) < 2 {
+		return ReleaseSeries{}, fmt.Errorf("invalid MongoDB version %q", version)
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return ReleaseSeries{}, fmt.Errorf("invalid MongoDB version %q", version)
+	}
+	// drop pre-release suffix, eg, 4.2-rc0
+	minor, err := strconv.Atoi(strings.SplitN(parts[1], "-", 2)[0])
+	if err != nil {
+		return ReleaseSeries{}, fmt.Errorf("invalid MongoDB version %q", version)
+	}
+	return ReleaseSeries{Major: major, Minor: minor}, nil
+}
+
+func (s ReleaseSeries) String() string {
+	return fmt.Sprintf("%v.%v", s.Major, s.Minor)
+}
+
+// Less reports whether s is an earlier release series than o.
+func (s ReleaseSeries) Less(o ReleaseSeries) bool {
+	return s.Major < o.Major || (s.Major == o.Major && s.Minor < o.Minor)
+}
+
+// validateVersionUpgrade allows changing spec.version within the same release series, or to the next
+// release series known to the MongoDBVersion catalog. Downgrades to an earlier release series, skipping
+// a release series, and changing spec.version while an upgrade is running are rejected.
+func validateVersionUpgrade(extClient cs.Interface, mongodb, oldMongoDB *api.MongoDB) error {
+	if mongodb.Spec.Version == oldMongoDB.Spec.Version {
+		return nil
+	}
+	if up := oldMongoDB.Status.Upgrade; up != nil && up.Phase == api.MongoDBUpgradePhaseRunning {
+		return fmt.Errorf("upgrade from %v to %v is running. Can't change spec.version until it succeeds", up.FromVersion, up.ToVersion)
+	}
+
+	from, err := catalogReleaseSeries(extClient, string(oldMongoDB.Spec.Version))
+	if err != nil {
+		return err
+	}
+	to, err := catalogReleaseSeries(extClient, string(mongodb.Spec.Version))
+	if err != nil {
+		return err
+	}
+	if from == to {
+		return nil
+	}
+	if to.Less(from) {
+		return fmt.Errorf("can't downgrade MongoDB from %v to %v. Downgrading release series %v to %v is not supported",
+			oldMongoDB.Spec.Version, mongodb.Spec.Version, from, to)
+	}
+
+	next, err := nextReleaseSeries(extClient, from)
+	if err != nil {
+		return err
+	}
+	if to != next {
+		return fmt.Errorf("can't upgrade MongoDB from %v to %v. Upgrade to a %v release first",
+			oldMongoDB.Spec.Version, mongodb.Spec.Version, next)
+	}
+	return nil
+}
+
+// catalogReleaseSeries returns the release series of MongoDBVersion name.
+func catalogReleaseSeries(extClient cs.Interface, name string) (ReleaseSeries, error) {
+	mongodbVersion, err := extClient.CatalogV1alpha1().MongoDBVersions().Get(name, metav1.GetOptions{})
+	if err != nil {
+		return ReleaseSeries{}, errors.Wrapf(err, "failed to get MongoDBVersion %v", name)
+	}
+	return ParseReleaseSeries(mongodbVersion.Spec.Version)
+}
+
+// nextReleaseSeries returns the earliest release series after from in the MongoDBVersion catalog.
+func nextReleaseSeries(extClient cs.Interface, from ReleaseSeries) (ReleaseSeries, error) {
+	versions, err := extClient.CatalogV1alpha1().MongoDBVersions().List(metav1.ListOptions{})
+	if err != nil {
+		return ReleaseSeries{}, errors.Wrap(err, "failed to list MongoDBVersions")
+	}
+	var later []ReleaseSeries
+	for _, v := range versions.Items {
+		series, err := ParseReleaseSeries(v.Spec.Version)
+		if err != nil {
+			continue
+		}
+		if from.Less(series) {
+			later = append(later, series)
+		}
+	}
+	if len(later) == 0 {
+		return ReleaseSeries{}, fmt.Errorf("no release series after %v in MongoDBVersion catalog", from)
+	}
+	sort.Slice(later, func(i, j int) bool { return later[i].Less(later[j]) })
+	return later[0], nil
+}
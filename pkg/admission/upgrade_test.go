@@ -0,0 +1,93 @@
+package admission
+
+import (
+	"testing"
+
+	types2 "github.com/appscode/go/encoding/json/types"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	catalog "kubedb.dev/apimachinery/apis/catalog/v1alpha1"
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+	extFake "kubedb.dev/apimachinery/client/clientset/versioned/fake"
+)
+
+func TestParseReleaseSeries(t *testing.T) {
+	cases := []struct {
+		version string
+		want    ReleaseSeries
+		err     bool
+	}{
+		{"3.6", ReleaseSeries{3, 6}, false},
+		{"3.6.8", ReleaseSeries{3, 6}, false},
+		{"v4.0.5", ReleaseSeries{4, 0}, false},
+		{"4.2-rc0", ReleaseSeries{4, 2}, false},
+		{"4", ReleaseSeries{}, true},
+		{"latest", ReleaseSeries{}, true},
+	}
+	for _, c := range cases {
+		t.Run(c.version, func(t *testing.T) {
+			got, err := ParseReleaseSeries(c.version)
+			if (err != nil) != c.err {
+				t.Fatalf("expected error %v, got %v", c.err, err)
+			}
+			if got != c.want {
+				t.Errorf("expected %v, got %v", c.want, got)
+			}
+		})
+	}
+}
+
+func mongoDBVersion(name, version string) *catalog.MongoDBVersion {
+	return &catalog.MongoDBVersion{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name: name,
+		},
+		Spec: catalog.MongoDBVersionSpec{
+			Version: version,
+		},
+	}
+}
+
+func TestValidateVersionUpgrade(t *testing.T) {
+	extClient := extFake.NewSimpleClientset(
+		mongoDBVersion("3.4", "3.4"),
+		mongoDBVersion("3.6", "3.6"),
+		mongoDBVersion("3.6-v1", "3.6.8"),
+		mongoDBVersion("4.0", "4.0"),
+	)
+	withVersion := func(version string) *api.MongoDB {
+		mongodb := sampleMongoDB()
+		mongodb.Spec.Version = types2.StrYo(version)
+		return &mongodb
+	}
+	running := withVersion("3.6")
+	running.Status.Upgrade = &api.MongoDBUpgradeStatus{
+		FromVersion: "3.4",
+		ToVersion:   "3.6",
+		Phase:       api.MongoDBUpgradePhaseRunning,
+	}
+
+	cases := []struct {
+		name    string
+		old     *api.MongoDB
+		version string
+		allowed bool
+	}{
+		{"unchanged", withVersion("3.4"), "3.4", true},
+		{"same release series", withVersion("3.6"), "3.6-v1", true},
+		{"next release series", withVersion("3.4"), "3.6", true},
+		{"skipping a release series", withVersion("3.4"), "4.0", false},
+		{"downgrade", withVersion("3.6-v1"), "3.4", false},
+		{"while an upgrade is running", running, "4.0", false},
+		{"unknown version", withVersion("3.6"), "4.2", false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := validateVersionUpgrade(extClient, withVersion(c.version), c.old)
+			if c.allowed && err != nil {
+				t.Errorf("expected upgrade to be allowed, got %v", err)
+			} else if !c.allowed && err == nil {
+				t.Error("expected upgrade to be rejected")
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package admission
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/pkg/errors"
+	admission "k8s.io/api/admission/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	meta_util "kmodules.xyz/client-go/meta"
+	hookapi "kmodules.xyz/webhook-runtime/admission/v1beta1"
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+)
+
+// MongoDBUserValidator rejects changes to the fields that identify the user in MongoDB. The user is synced
+// by those fields, so the user created with the old ones would be left behind in the database.
+type MongoDBUserValidator struct {
+	lock        sync.RWMutex
+	initialized bool
+}
+
+var _ hookapi.AdmissionHook = &MongoDBUserValidator{}
+
+func (a *MongoDBUserValidator) Resource() (plural schema.GroupVersionResource, singular string) {
+	return schema.GroupVersionResource{
+			Group:    "validators.kubedb.com",
+			Version:  "v1alpha1",
+			Resource: "mongodbuservalidators",
+		},
+		"mongodbuservalidator"
+}
+
+func (a *MongoDBUserValidator) Initialize(config *rest.Config, stopCh <-chan struct{}) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.initialized = true
+	return nil
+}
+
+func (a *MongoDBUserValidator) Admit(req *admission.AdmissionRequest) *admission.AdmissionResponse {
+	status := &admission.AdmissionResponse{}
+
+	if (req.Operation != admission.Create && req.Operation != admission.Update) ||
+		len(req.SubResource) != 0 ||
+		req.Kind.Group != api.SchemeGroupVersion.Group ||
+		req.Kind.Kind != api.ResourceKindMongoDBUser {
+		status.Allowed = true
+		return status
+	}
+
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	if !a.initialized {
+		return hookapi.StatusUninitialized()
+	}
+
+	obj, err := meta_util.UnmarshalFromJSON(req.Object.Raw, api.SchemeGroupVersion)
+	if err != nil {
+		return hookapi.StatusBadRequest(err)
+	}
+	user := obj.(*api.MongoDBUser)
+	if user.Spec.DatabaseName == "" {
+		return hookapi.StatusForbidden(errors.New(`'spec.databaseName' is missing`))
+	}
+	if req.Operation == admission.Update {
+		oldObj, err := meta_util.UnmarshalFromJSON(req.OldObject.Raw, api.SchemeGroupVersion)
+		if err != nil {
+			return hookapi.StatusBadRequest(err)
+		}
+		if err := validateUserUpdate(user, oldObj.(*api.MongoDBUser)); err != nil {
+			return hookapi.StatusForbidden(err)
+		}
+	}
+	status.Allowed = true
+	return status
+}
+
+// validateUserUpdate compares the defaulted values, so that setting a field to its default is not a change.
+func validateUserUpdate(user, oldUser *api.MongoDBUser) error {
+	if user.Spec.DatabaseName != oldUser.Spec.DatabaseName {
+		return immutableFieldError("spec.databaseName", oldUser.Spec.DatabaseName, user.Spec.DatabaseName)
+	}
+	if user.UsernameOrDefault() != oldUser.UsernameOrDefault() {
+		return immutableFieldError("spec.username", oldUser.UsernameOrDefault(), user.UsernameOrDefault())
+	}
+	if user.DatabaseOrDefault() != oldUser.DatabaseOrDefault() {
+		return immutableFieldError("spec.database", oldUser.DatabaseOrDefault(), user.DatabaseOrDefault())
+	}
+	return nil
+}
+
+func immutableFieldError(field, old, cur string) error {
+	return fmt.Errorf("'%v' is immutable, can't be changed from %q to %q. To change it, delete and recreate the object", field, old, cur)
+}
@@ -0,0 +1,87 @@
+package admission
+
+import (
+	"testing"
+
+	admission "k8s.io/api/admission/v1beta1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"kmodules.xyz/client-go/meta"
+	hookapi "kmodules.xyz/webhook-runtime/admission/v1beta1"
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+)
+
+// admit sends obj, replacing oldObj if it is not nil, to hook.
+func admit(t *testing.T, hook hookapi.AdmissionHook, kind string, obj, oldObj runtime.Object) *admission.AdmissionResponse {
+	req := &admission.AdmissionRequest{
+		Kind: metaV1.GroupVersionKind{
+			Group:   api.SchemeGroupVersion.Group,
+			Version: api.SchemeGroupVersion.Version,
+			Kind:    kind,
+		},
+		Operation: admission.Create,
+	}
+	var err error
+	if req.Object.Raw, err = meta.MarshalToJson(obj, api.SchemeGroupVersion); err != nil {
+		t.Fatal(err)
+	}
+	if oldObj != nil {
+		req.Operation = admission.Update
+		if req.OldObject.Raw, err = meta.MarshalToJson(oldObj, api.SchemeGroupVersion); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return hook.Admit(req)
+}
+
+func sampleMongoDBUser() *api.MongoDBUser {
+	return &api.MongoDBUser{
+		TypeMeta: metaV1.TypeMeta{
+			Kind:       api.ResourceKindMongoDBUser,
+			APIVersion: api.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      "reader",
+			Namespace: "default",
+		},
+		Spec: api.MongoDBUserSpec{
+			DatabaseName: "foo",
+			Roles:        []api.MongoDBRoleRef{{Name: "read", Database: "app"}},
+		},
+	}
+}
+
+func TestMongoDBUserValidator_Admit(t *testing.T) {
+	cases := []struct {
+		name    string
+		edit    func(user *api.MongoDBUser)
+		create  bool
+		allowed bool
+	}{
+		{"create", func(user *api.MongoDBUser) {}, true, true},
+		{"create without spec.databaseName", func(user *api.MongoDBUser) { user.Spec.DatabaseName = "" }, true, false},
+		{"edit spec.roles", func(user *api.MongoDBUser) { user.Spec.Roles[0].Name = "readWrite" }, false, true},
+		{"set spec.username to its default", func(user *api.MongoDBUser) { user.Spec.Username = "reader" }, false, true},
+		{"set spec.database to its default", func(user *api.MongoDBUser) { user.Spec.Database = "admin" }, false, true},
+		{"edit spec.databaseName", func(user *api.MongoDBUser) { user.Spec.DatabaseName = "bar" }, false, false},
+		{"edit spec.username", func(user *api.MongoDBUser) { user.Spec.Username = "writer" }, false, false},
+		{"edit spec.database", func(user *api.MongoDBUser) { user.Spec.Database = "app" }, false, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			validator := &MongoDBUserValidator{initialized: true}
+			user := sampleMongoDBUser()
+			c.edit(user)
+
+			var resp *admission.AdmissionResponse
+			if c.create {
+				resp = admit(t, validator, api.ResourceKindMongoDBUser, user, nil)
+			} else {
+				resp = admit(t, validator, api.ResourceKindMongoDBUser, user, sampleMongoDBUser())
+			}
+			if resp.Allowed != c.allowed {
+				t.Errorf("expected allowed %v, got %v: %v", c.allowed, resp.Allowed, resp.Result)
+			}
+		})
+	}
+}
@@ -36,6 +36,10 @@ var forbiddenEnvVars = []string{
 	"MONGO_INITDB_ROOT_PASSWORD",
 }
 
+// MaxReplicaSetMembers is the maximum number of members of a replicaset.
+// ref: https://docs.mongodb.com/manual/reference/limits/#Number-of-Members-of-a-Replica-Set
+const MaxReplicaSetMembers = 50
+
 func (a *MongoDBValidator) Resource() (plural schema.GroupVersionResource, singular string) {
 	return schema.GroupVersionResource{
 			Group:    "validators.kubedb.com",
@@ -117,6 +121,9 @@ func (a *MongoDBValidator) Admit(req *admission.AdmissionRequest) *admission.Adm
 			if err := validateUpdate(mongodb, oldMongoDB); err != nil {
 				return hookapi.StatusBadRequest(fmt.Errorf("%v", err))
 			}
+			if err := validateVersionUpgrade(a.extClient, mongodb, oldMongoDB); err != nil {
+				return hookapi.StatusForbidden(err)
+			}
 		}
 		// validate database specs
 		if err = ValidateMongoDB(a.client, a.extClient, obj.(*api.MongoDB), false); err != nil {
@@ -133,7 +140,8 @@ func ValidateMongoDB(client kubernetes.Interface, extClient cs.Interface, mongod
 	if mongodb.Spec.Version == "" {
 		return errors.New(`'spec.version' is missing`)
 	}
-	if _, err := extClient.CatalogV1alpha1().MongoDBVersions().Get(string(mongodb.Spec.Version), metav1.GetOptions{}); err != nil {
+	catalog, err := extClient.CatalogV1alpha1().MongoDBVersions().Get(string(mongodb.Spec.Version), metav1.GetOptions{})
+	if err != nil {
 		return err
 	}
 
@@ -148,6 +156,12 @@ func ValidateMongoDB(client kubernetes.Interface, extClient cs.Interface, mongod
 		if mongodb.Spec.ConfigSource != nil {
 			return fmt.Errorf(`doesn't support 'spec.configSource' when spec.shardTopology is set`)
 		}
+		if mongodb.Spec.Configuration != nil {
+			return fmt.Errorf(`doesn't support 'spec.configuration' when spec.shardTopology is set`)
+		}
+		if mongodb.Spec.Profiling != nil {
+			return fmt.Errorf(`doesn't support 'spec.profiling' when spec.shardTopology is set`)
+		}
 
 		// Validate Topology Replicas values
 		if top.Shard.Shards < 1 {
@@ -159,6 +173,12 @@ func ValidateMongoDB(client kubernetes.Interface, extClient cs.Interface, mongod
 		if top.ConfigServer.Replicas < 1 {
 			return fmt.Errorf(`spec.shardTopology.configServer.replicas %v invalid. Must be greater than zero when spec.shardTopology is set`, top.ConfigServer.Replicas)
 		}
+		if top.Shard.Replicas > MaxReplicaSetMembers {
+			return fmt.Errorf(`spec.shardTopology.shard.replicas %v invalid. A replicaset can't have more than %v members`, top.Shard.Replicas, MaxReplicaSetMembers)
+		}
+		if top.ConfigServer.Replicas > MaxReplicaSetMembers {
+			return fmt.Errorf(`spec.shardTopology.configServer.replicas %v invalid. A replicaset can't have more than %v members`, top.ConfigServer.Replicas, MaxReplicaSetMembers)
+		}
 		if top.Mongos.Replicas < 1 {
 			return fmt.Errorf(`spec.shardTopology.mongos.replicas %v invalid. Must be greater than zero when spec.shardTopology is set`, top.Mongos.Replicas)
 		}
@@ -168,6 +188,26 @@ func ValidateMongoDB(client kubernetes.Interface, extClient cs.Interface, mongod
 			return fmt.Errorf(`spec.shardTopology.mongos.strategy.type is missing`)
 		}
 
+		// Validate configurations
+		if err := validateConfiguration(top.Shard.Configuration, top.Shard.ConfigSource, false, "spec.shardTopology.shard.configuration"); err != nil {
+			return err
+		}
+		if err := validateConfiguration(top.ConfigServer.Configuration, top.ConfigServer.ConfigSource, false, "spec.shardTopology.configServer.configuration"); err != nil {
+			return err
+		}
+		if err := validateConfiguration(top.Mongos.Configuration, top.Mongos.ConfigSource, true, "spec.shardTopology.mongos.configuration"); err != nil {
+			return err
+		}
+		if err := validateProfiling(top.Shard.Profiling, top.Shard.ConfigSource, "spec.shardTopology.shard.profiling"); err != nil {
+			return err
+		}
+		if err := validateProfiling(top.ConfigServer.Profiling, top.ConfigServer.ConfigSource, "spec.shardTopology.configServer.profiling"); err != nil {
+			return err
+		}
+		if err := validateProfiling(top.Mongos.Profiling, top.Mongos.ConfigSource, "spec.shardTopology.mongos.profiling"); err != nil {
+			return err
+		}
+
 		// Validate Envs
 		if err := amv.ValidateEnvVar(top.Shard.PodTemplate.Spec.Env, forbiddenEnvVars, api.ResourceKindMongoDB); err != nil {
 			return err
@@ -187,11 +227,22 @@ func ValidateMongoDB(client kubernetes.Interface, extClient cs.Interface, mongod
 			return fmt.Errorf(`spec.replicas "%v" invalid for 'MongoDB Standalone' instance. Value must be one`, mongodb.Spec.Replicas)
 		}
 
+		if *mongodb.Spec.Replicas > MaxReplicaSetMembers {
+			return fmt.Errorf(`spec.replicas "%v" invalid. A replicaset can't have more than %v members`, *mongodb.Spec.Replicas, MaxReplicaSetMembers)
+		}
+
 		if mongodb.Spec.PodTemplate != nil {
 			if err := amv.ValidateEnvVar(mongodb.Spec.PodTemplate.Spec.Env, forbiddenEnvVars, api.ResourceKindMongoDB); err != nil {
 				return err
 			}
 		}
+
+		if err := validateConfiguration(mongodb.Spec.Configuration, mongodb.Spec.ConfigSource, false, "spec.configuration"); err != nil {
+			return err
+		}
+		if err := validateProfiling(mongodb.Spec.Profiling, mongodb.Spec.ConfigSource, "spec.profiling"); err != nil {
+			return err
+		}
 	}
 
 	if mongodb.Spec.StorageType == "" {
@@ -228,6 +279,30 @@ func ValidateMongoDB(client kubernetes.Interface, extClient cs.Interface, mongod
 			mongodb.Spec.SSLMode, mongodb.Spec.ClusterAuthMode)
 	}
 
+	if err := validateTLS(mongodb, catalog.Spec.DB.MemberCertificates); err != nil {
+		return err
+	}
+
+	if err := validateCredentialRotation(mongodb, catalog.Spec.Version); err != nil {
+		return err
+	}
+
+	if err := validateOplog(mongodb); err != nil {
+		return err
+	}
+
+	if err := validateShardedBackup(mongodb, catalog.Spec.Version); err != nil {
+		return err
+	}
+
+	if err := validateSnapshotVerification(mongodb.Spec.SnapshotVerification); err != nil {
+		return err
+	}
+
+	if err := validateRestoreSelection(mongodb); err != nil {
+		return err
+	}
+
 	if strictValidation {
 		databaseSecret := mongodb.Spec.DatabaseSecret
 		if databaseSecret != nil {
@@ -260,6 +335,20 @@ func ValidateMongoDB(client kubernetes.Interface, extClient cs.Interface, mongod
 		if err := amv.ValidateBackupSchedule(client, backupScheduleSpec, mongodb.Namespace); err != nil {
 			return err
 		}
+		if err := validateRetentionPolicy(backupScheduleSpec.RetentionPolicy); err != nil {
+			return err
+		}
+		if err := ValidateSnapshotArchive(backupScheduleSpec.Archive); err != nil {
+			return fmt.Errorf("spec.backupSchedule.%v", err)
+		}
+		if backupScheduleSpec.Archive != nil && !catalog.Spec.Tools.Archive {
+			return fmt.Errorf("spec.backupSchedule.archive is not supported by the tools image of version %v", catalog.Name)
+		}
+		if backupScheduleSpec.Archive != nil && mongodb.Spec.ShardTopology != nil &&
+			mongodb.Spec.ShardTopology.BackupMode == api.MongoDBShardedBackupModeConsistent {
+			return fmt.Errorf("spec.backupSchedule.archive is not supported with spec.shardTopology.backupMode %v",
+				api.MongoDBShardedBackupModeConsistent)
+		}
 	}
 
 	if mongodb.Spec.UpdateStrategy.Type == "" {
@@ -281,6 +370,13 @@ func ValidateMongoDB(client kubernetes.Interface, extClient cs.Interface, mongod
 		}
 	}
 
+	if err := validateAlerting(mongodb); err != nil {
+		return err
+	}
+	if mongodb.Spec.Dashboards != nil && monitorSpec == nil {
+		return fmt.Errorf("spec.dashboards requires spec.monitor")
+	}
+
 	if err := matchWithDormantDatabase(extClient, mongodb); err != nil {
 		return err
 	}
@@ -316,6 +412,12 @@ func matchWithDormantDatabase(extClient cs.Interface, mongodb *api.MongoDB) erro
 	// Skip checking Monitoring
 	drmnOriginSpec.Monitor = originalSpec.Monitor
 
+	// Skip checking Alerting
+	drmnOriginSpec.Alerting = originalSpec.Alerting
+
+	// Skip checking Dashboards
+	drmnOriginSpec.Dashboards = originalSpec.Dashboards
+
 	// Skip Checking BackUP Scheduler
 	drmnOriginSpec.BackupSchedule = originalSpec.BackupSchedule
 
@@ -377,6 +479,7 @@ var preconditionSpecFields = []string{
 	"spec.storage",
 	"spec.databaseSecret",
 	"spec.certificateSecret",
+	"spec.tls.issuerRef",
 	"spec.init",
 	"spec.replicaSet.name",
 	"spec.shardTopology.*.storage",
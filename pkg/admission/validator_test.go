@@ -48,6 +48,26 @@ func TestMongoDBValidator_Admit(t *testing.T) {
 					ObjectMeta: metaV1.ObjectMeta{
 						Name: "3.4",
 					},
+					Spec: catalog.MongoDBVersionSpec{
+						Version: "3.4",
+						DB:      catalog.MongoDBVersionDatabase{MemberCertificates: true},
+					},
+				},
+				&catalog.MongoDBVersion{
+					ObjectMeta: metaV1.ObjectMeta{
+						Name: "3.6",
+					},
+					Spec: catalog.MongoDBVersionSpec{
+						Version: "3.6",
+					},
+				},
+				&catalog.MongoDBVersion{
+					ObjectMeta: metaV1.ObjectMeta{
+						Name: "4.0",
+					},
+					Spec: catalog.MongoDBVersionSpec{
+						Version: "4.0",
+					},
 				},
 			)
 			validator.client = fake.NewSimpleClientset(
@@ -141,6 +161,66 @@ var cases = []struct {
 		false,
 		false,
 	},
+	{"Create MongoDB with Spec.Configuration",
+		requestKind,
+		"foo",
+		"default",
+		admission.Create,
+		withConfiguration(sampleMongoDB(), "storage.journal.commitIntervalMs"),
+		api.MongoDB{},
+		false,
+		true,
+	},
+	{"Create MongoDB with operator owned option in Spec.Configuration",
+		requestKind,
+		"foo",
+		"default",
+		admission.Create,
+		withConfiguration(sampleMongoDB(), "net.port"),
+		api.MongoDB{},
+		false,
+		false,
+	},
+	{"Create MongoDB with Spec.TLS issued by cert-manager",
+		requestKind,
+		"foo",
+		"default",
+		admission.Create,
+		withTLS(sampleMongoDB(), api.MongoDBTLSIssuerKindClusterIssuer, api.MongoDBKeyAlgorithmECDSA, 384),
+		api.MongoDB{},
+		false,
+		true,
+	},
+	{"Create MongoDB with Spec.TLS issued by cert-manager for a database image that signs its own certificates",
+		requestKind,
+		"foo",
+		"default",
+		admission.Create,
+		withTLS(upgradeVersion(sampleMongoDB(), "3.6"), api.MongoDBTLSIssuerKindClusterIssuer, api.MongoDBKeyAlgorithmECDSA, 384),
+		api.MongoDB{},
+		false,
+		false,
+	},
+	{"Create MongoDB with invalid key size in Spec.TLS",
+		requestKind,
+		"foo",
+		"default",
+		admission.Create,
+		withTLS(sampleMongoDB(), api.MongoDBTLSIssuerKindClusterIssuer, api.MongoDBKeyAlgorithmRSA, 384),
+		api.MongoDB{},
+		false,
+		false,
+	},
+	{"Create MongoDB ReplicaSet with more members than allowed",
+		requestKind,
+		"foo",
+		"default",
+		admission.Create,
+		withReplicaSet(sampleMongoDB(), MaxReplicaSetMembers+1),
+		api.MongoDB{},
+		false,
+		false,
+	},
 	{"Edit MongoDB Spec.DatabaseSecret with Existing Secret",
 		requestKind,
 		"foo",
@@ -221,6 +301,36 @@ var cases = []struct {
 		false,
 		true,
 	},
+	{"Upgrade MongoDB to next release series",
+		requestKind,
+		"foo",
+		"default",
+		admission.Update,
+		upgradeVersion(sampleMongoDB(), "3.6"),
+		sampleMongoDB(),
+		false,
+		true,
+	},
+	{"Upgrade MongoDB skipping a release series",
+		requestKind,
+		"foo",
+		"default",
+		admission.Update,
+		upgradeVersion(sampleMongoDB(), "4.0"),
+		sampleMongoDB(),
+		false,
+		false,
+	},
+	{"Downgrade MongoDB",
+		requestKind,
+		"foo",
+		"default",
+		admission.Update,
+		sampleMongoDB(),
+		upgradeVersion(sampleMongoDB(), "3.6"),
+		false,
+		false,
+	},
 	{"Delete MongoDB when Spec.TerminationPolicy=DoNotTerminate",
 		requestKind,
 		"foo",
@@ -399,3 +509,43 @@ func editShardPrefix(old api.MongoDB) api.MongoDB {
 	old.Spec.ShardTopology.Shard.Prefix = "demo-prefix"
 	return old
 }
+
+func upgradeVersion(old api.MongoDB, version string) api.MongoDB {
+	old.Spec.Version = types2.StrYo(version)
+	return old
+}
+
+func withConfiguration(old api.MongoDB, option string) api.MongoDB {
+	old.Spec.Configuration = &api.MongoDBConfiguration{
+		SetParameter: map[string]string{
+			"cursorTimeoutMillis": "600000",
+		},
+		Options: map[string]string{
+			option: "100",
+		},
+	}
+	return old
+}
+
+func withReplicaSet(old api.MongoDB, replicas int32) api.MongoDB {
+	old.Spec.Replicas = types.Int32P(replicas)
+	old.Spec.ReplicaSet = &api.MongoDBReplicaSet{Name: "rs0"}
+	return old
+}
+
+func withTLS(old api.MongoDB, kind api.MongoDBTLSIssuerKind, algorithm api.MongoDBKeyAlgorithm, size int) api.MongoDB {
+	old.Spec.SSLMode = api.SSLModeRequireSSL
+	old.Spec.TLS = &api.MongoDBTLSConfig{
+		IssuerRef: &api.MongoDBTLSIssuerRef{
+			Kind: kind,
+			Name: "pki",
+		},
+		DNSNames:    []string{"mongo.example.com"},
+		IPAddresses: []string{"10.0.0.10"},
+		PrivateKey: &api.MongoDBTLSPrivateKey{
+			Algorithm: algorithm,
+			Size:      size,
+		},
+	}
+	return old
+}
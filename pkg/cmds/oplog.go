@@ -0,0 +1,170 @@
+package cmds
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/appscode/go/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"kubedb.dev/mongodb/pkg/dbclient"
+	"kubedb.dev/mongodb/pkg/oplog"
+)
+
+const (
+	envDatabaseUser     = "DB_USER"
+	envDatabasePassword = "DB_PASSWORD"
+)
+
+// oplogOptions are the flags shared by oplog-archiver and oplog-replay.
+type oplogOptions struct {
+	hosts      []string
+	replicaSet string
+	caFile     string
+	pemFile    string
+	osmConfig  string
+	bucket     string
+	folder     string
+}
+
+func (o *oplogOptions) addFlags(fs *pflag.FlagSet) {
+	fs.StringSliceVar(&o.hosts, "hosts", o.hosts, "host:port of the members of the replicaset")
+	fs.StringVar(&o.replicaSet, "replica-set", o.replicaSet, "Name of the replicaset")
+	fs.StringVar(&o.caFile, "ca-file", o.caFile, "CA certificate to verify the servers with, if TLS is enabled")
+	fs.StringVar(&o.pemFile, "pem-file", o.pemFile, "Client certificate and key to authenticate with, if TLS is enabled")
+	fs.StringVar(&o.osmConfig, "osmconfig", "/etc/osm/config", "Path of the osm config of the backend")
+	fs.StringVar(&o.bucket, "bucket", o.bucket, "Bucket of the backend")
+	fs.StringVar(&o.folder, "folder", o.folder, "Folder of the oplog archive in the bucket")
+}
+
+func (o *oplogOptions) connect(ctx context.Context) (*dbclient.Client, *oplog.Store, error) {
+	if len(o.hosts) == 0 {
+		return nil, nil, errors.New("--hosts is required")
+	}
+	store, err := oplog.NewStore(o.osmConfig, o.bucket, o.folder)
+	if err != nil {
+		return nil, nil, err
+	}
+	client, err := connectTo(ctx, o.hosts, o.replicaSet, o.caFile, o.pemFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	return client, store, nil
+}
+
+// connectTo connects to the members hosts of replicaset replicaSet, or directly to the first host if replicaSet
+// is empty, with the credentials in DB_USER and DB_PASSWORD. TLS is enabled if caFile is set.
+func connectTo(ctx context.Context, hosts []string, replicaSet, caFile, pemFile string) (*dbclient.Client, error) {
+	cfg := dbclient.Config{
+		Hosts:      hosts,
+		Username:   os.Getenv(envDatabaseUser),
+		Password:   os.Getenv(envDatabasePassword),
+		ReplicaSet: replicaSet,
+		Direct:     replicaSet == "",
+	}
+	if caFile != "" {
+		caCert, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		clientPem, err := ioutil.ReadFile(pemFile)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.TLSConfig, err = dbclient.ClientTLSConfig(caCert, clientPem); err != nil {
+			return nil, err
+		}
+	}
+	return dbclient.New(ctx, cfg)
+}
+
+// contextFor returns a context that is cancelled when stopCh is closed.
+func contextFor(stopCh <-chan struct{}) context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-stopCh
+		cancel()
+	}()
+	return ctx
+}
+
+func NewCmdOplogArchiver(stopCh <-chan struct{}) *cobra.Command {
+	o := &oplogOptions{}
+	interval := oplog.DefaultChunkInterval
+
+	cmd := &cobra.Command{
+		Use:               "oplog-archiver",
+		Short:             "Archive the oplog of a MongoDB replicaset",
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if o.replicaSet == "" {
+				return errors.New("--replica-set is required")
+			}
+			ctx := contextFor(stopCh)
+			client, store, err := o.connect(ctx)
+			if err != nil {
+				return err
+			}
+			defer client.Close()
+
+			log.Infof("Archiving the oplog of replicaset %s to %s/%s", o.replicaSet, o.bucket, o.folder)
+			archiver := &oplog.Archiver{
+				Client:     client,
+				Store:      store,
+				ReplicaSet: o.replicaSet,
+				Interval:   interval,
+			}
+			return archiver.Run(ctx)
+		},
+	}
+	o.addFlags(cmd.Flags())
+	cmd.Flags().DurationVar(&interval, "chunk-interval", interval, "How often a chunk of oplog is written to the backend")
+	return cmd
+}
+
+func NewCmdOplogReplay(stopCh <-chan struct{}) *cobra.Command {
+	o := &oplogOptions{}
+	var from, until, sourceReplicaSet string
+
+	cmd := &cobra.Command{
+		Use:               "oplog-replay",
+		Short:             "Replay the archived oplog of a MongoDB replicaset",
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fromTime, err := time.Parse(time.RFC3339, from)
+			if err != nil {
+				return errors.Wrap(err, "invalid --from")
+			}
+			untilTime, err := time.Parse(time.RFC3339, until)
+			if err != nil {
+				return errors.Wrap(err, "invalid --until")
+			}
+			if untilTime.Before(fromTime) {
+				return errors.Errorf("--until %s is before --from %s", until, from)
+			}
+			ctx := contextFor(stopCh)
+			client, store, err := o.connect(ctx)
+			if err != nil {
+				return err
+			}
+			defer client.Close()
+
+			log.Infof("Replaying the oplog in %s/%s from %s until %s into %s", o.bucket, o.folder, from, until, strings.Join(o.hosts, ","))
+			replayer := &oplog.Replayer{
+				Client:     client,
+				Store:      store,
+				ReplicaSet: sourceReplicaSet,
+			}
+			return replayer.Replay(ctx, fromTime, untilTime)
+		},
+	}
+	o.addFlags(cmd.Flags())
+	cmd.Flags().StringVar(&from, "from", from, "Time to replay the oplog from, in RFC3339. Usually the start of the restored snapshot")
+	cmd.Flags().StringVar(&until, "until", until, "Time to replay the oplog until, in RFC3339")
+	cmd.Flags().StringVar(&sourceReplicaSet, "source-replica-set", sourceReplicaSet, "Replicaset whose oplog is replayed. Required if the archive has more than one")
+	return cmd
+}
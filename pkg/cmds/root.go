@@ -38,6 +38,11 @@ func NewRootCmd(version string) *cobra.Command {
 
 	stopCh := genericapiserver.SetupSignalHandler()
 	rootCmd.AddCommand(NewCmdRun(version, os.Stdout, os.Stderr, stopCh))
+	rootCmd.AddCommand(NewCmdOplogArchiver(stopCh))
+	rootCmd.AddCommand(NewCmdOplogReplay(stopCh))
+	rootCmd.AddCommand(NewCmdShardedBackup(stopCh))
+	rootCmd.AddCommand(NewCmdShardedRestore(stopCh))
+	rootCmd.AddCommand(NewCmdSlowQueryLog(stopCh))
 
 	return rootCmd
 }
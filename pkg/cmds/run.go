@@ -12,6 +12,8 @@ import (
 
 func NewCmdRun(version string, out, errOut io.Writer, stopCh <-chan struct{}) *cobra.Command {
 	o := server.NewMongoDBServerOptions(out, errOut)
+	o.ExtraOptions.OplogArchiverImage = "kubedb/mg-operator:" + version
+	o.ExtraOptions.SlowQueryLogImage = "kubedb/mg-operator:" + version
 
 	cmd := &cobra.Command{
 		Use:               "run",
@@ -33,6 +33,11 @@ type ExtraOptions struct {
 	ResyncPeriod                time.Duration
 	MaxNumRequeues              int
 	NumThreads                  int
+	HealthCheckInterval         time.Duration
+	CertRenewBefore             time.Duration
+	CASecret                    string
+	OplogArchiverImage          string
+	SlowQueryLogImage           string
 
 	EnableMutatingWebhook   bool
 	EnableValidatingWebhook bool
@@ -47,12 +52,14 @@ func (s ExtraOptions) WatchNamespace() string {
 
 func NewExtraOptions() *ExtraOptions {
 	return &ExtraOptions{
-		EnableRBAC:        true,
-		OperatorNamespace: meta.Namespace(),
-		GoverningService:  "kubedb",
-		ResyncPeriod:      10 * time.Minute,
-		MaxNumRequeues:    5,
-		NumThreads:        2,
+		EnableRBAC:          true,
+		OperatorNamespace:   meta.Namespace(),
+		GoverningService:    "kubedb",
+		ResyncPeriod:        10 * time.Minute,
+		MaxNumRequeues:      5,
+		NumThreads:          2,
+		HealthCheckInterval: 30 * time.Second,
+		CertRenewBefore:     30 * 24 * time.Hour,
 		// ref: https://github.com/kubernetes/ingress-nginx/blob/e4d53786e771cc6bdd55f180674b79f5b692e552/pkg/ingress/controller/launch.go#L252-L259
 		// High enough QPS to fit all expected use cases. QPS=0 is not set here, because client code is overriding it.
 		QPS: 1e6,
@@ -67,6 +74,11 @@ func (s *ExtraOptions) AddGoFlags(fs *flag.FlagSet) {
 
 	fs.Float64Var(&s.QPS, "qps", s.QPS, "The maximum QPS to the master from this client")
 	fs.IntVar(&s.Burst, "burst", s.Burst, "The maximum burst for throttle")
+	fs.DurationVar(&s.HealthCheckInterval, "health-check-interval", s.HealthCheckInterval, "How often the members of MongoDB are probed for their health. Zero disables probing.")
+	fs.DurationVar(&s.CertRenewBefore, "cert-renew-before", s.CertRenewBefore, "How long before expiry the certificates of MongoDB, created by the operator, are renewed. Zero disables renewal.")
+	fs.StringVar(&s.CASecret, "ca-secret", s.CASecret, "Name of the secret in the operator namespace with the CA that signs the certificates of MongoDB, whose spec.tls.issuerRef is of kind OperatorCA")
+	fs.StringVar(&s.OplogArchiverImage, "oplog-archiver-image", s.OplogArchiverImage, "Image that archives and replays the oplog of MongoDB, whose spec.oplogArchive or spec.init.mongodbOplog is set")
+	fs.StringVar(&s.SlowQueryLogImage, "slow-query-log-image", s.SlowQueryLogImage, "Image of the sidecar that ships the slow operations of MongoDB, whose spec.profiling.slowQueryLog is set")
 	fs.DurationVar(&s.ResyncPeriod, "resync-period", s.ResyncPeriod, "If non-zero, will re-list this often. Otherwise, re-list will be delayed aslong as possible (until the upstream source closes the watch or times out.")
 
 	fs.BoolVar(&s.RestrictToOperatorNamespace, "restrict-to-operator-namespace", s.RestrictToOperatorNamespace, "If true, KubeDB operator will only handle Kubernetes objects in its own namespace.")
@@ -98,6 +110,11 @@ func (s *ExtraOptions) ApplyTo(cfg *controller.OperatorConfig) error {
 	cfg.ResyncPeriod = s.ResyncPeriod
 	cfg.MaxNumRequeues = s.MaxNumRequeues
 	cfg.NumThreads = s.NumThreads
+	cfg.HealthCheckInterval = s.HealthCheckInterval
+	cfg.CertRenewBefore = s.CertRenewBefore
+	cfg.CASecret = s.CASecret
+	cfg.OplogArchiverImage = s.OplogArchiverImage
+	cfg.SlowQueryLogImage = s.SlowQueryLogImage
 	cfg.WatchNamespace = s.WatchNamespace()
 	cfg.EnableMutatingWebhook = s.EnableMutatingWebhook
 	cfg.EnableValidatingWebhook = s.EnableValidatingWebhook
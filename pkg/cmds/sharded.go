@@ -0,0 +1,165 @@
+package cmds
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"kubedb.dev/mongodb/pkg/dbclient"
+	"kubedb.dev/mongodb/pkg/oplog"
+	"kubedb.dev/mongodb/pkg/shardedbackup"
+)
+
+// shardedOptions are the flags shared by sharded-backup and sharded-restore.
+type shardedOptions struct {
+	mongos       string
+	configServer string
+	shards       []string
+	caFile       string
+	pemFile      string
+	osmConfig    string
+	bucket       string
+	folder       string
+}
+
+func (o *shardedOptions) addFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.mongos, "mongos", o.mongos, "host:port of the mongos")
+	fs.StringVar(&o.configServer, "config-server", o.configServer, "Replicaset of the config server, as <replicaset>/<host:port>,...")
+	fs.StringArrayVar(&o.shards, "shard", o.shards, "Replicaset of a shard, as <replicaset>/<host:port>,... Repeated for every shard, in order")
+	fs.StringVar(&o.caFile, "ca-file", o.caFile, "CA certificate to verify the servers with, if TLS is enabled")
+	fs.StringVar(&o.pemFile, "pem-file", o.pemFile, "Client certificate and key to authenticate with, if TLS is enabled")
+	fs.StringVar(&o.osmConfig, "osmconfig", "/etc/osm/config", "Path of the osm config of the backend")
+	fs.StringVar(&o.bucket, "bucket", o.bucket, "Bucket of the backend")
+	fs.StringVar(&o.folder, "folder", o.folder, "Folder of the snapshot in the bucket")
+}
+
+// components parses the config server and the shards.
+func (o *shardedOptions) components() (shardedbackup.Component, []shardedbackup.Component, error) {
+	if o.mongos == "" {
+		return shardedbackup.Component{}, nil, errors.New("--mongos is required")
+	}
+	configServer, err := shardedbackup.ParseComponent(o.configServer)
+	if err != nil {
+		return shardedbackup.Component{}, nil, errors.Wrap(err, "invalid --config-server")
+	}
+	if len(o.shards) == 0 {
+		return shardedbackup.Component{}, nil, errors.New("--shard is required")
+	}
+	shards := make([]shardedbackup.Component, 0, len(o.shards))
+	for _, dsn := range o.shards {
+		shard, err := shardedbackup.ParseComponent(dsn)
+		if err != nil {
+			return shardedbackup.Component{}, nil, errors.Wrap(err, "invalid --shard")
+		}
+		shards = append(shards, shard)
+	}
+	return configServer, shards, nil
+}
+
+func (o *shardedOptions) connector() shardedbackup.Connector {
+	return func(ctx context.Context, hosts []string, replicaSet string) (*dbclient.Client, error) {
+		return connectTo(ctx, hosts, replicaSet, o.caFile, o.pemFile)
+	}
+}
+
+func (o *shardedOptions) connectToMongos(ctx context.Context) (*dbclient.Client, error) {
+	return connectTo(ctx, []string{o.mongos}, "", o.caFile, o.pemFile)
+}
+
+func NewCmdShardedBackup(stopCh <-chan struct{}) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:               "sharded-backup",
+		Short:             "Coordinate a consistent backup of a MongoDB sharded cluster",
+		DisableAutoGenTag: true,
+	}
+	cmd.AddCommand(newCmdShardedBackupPhase(stopCh, "start", "Stop the balancer and record the oplog of the shards before the dumps start"))
+	cmd.AddCommand(newCmdShardedBackupPhase(stopCh, "finish", "Wait for the dumps, capture the oplog of the shards until a common cluster time and restore the balancer"))
+	return cmd
+}
+
+func newCmdShardedBackupPhase(stopCh <-chan struct{}, phase, short string) *cobra.Command {
+	o := &shardedOptions{}
+	stateDir := "/var/data/.sharded-backup"
+	dumpTimeout := 24 * time.Hour
+
+	cmd := &cobra.Command{
+		Use:               phase,
+		Short:             short,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			configServer, shards, err := o.components()
+			if err != nil {
+				return err
+			}
+			ctx := contextFor(stopCh)
+			store, err := oplog.NewStore(o.osmConfig, o.bucket, o.folder)
+			if err != nil {
+				return err
+			}
+			mongos, err := o.connectToMongos(ctx)
+			if err != nil {
+				return err
+			}
+			defer mongos.Close()
+
+			backup := &shardedbackup.Backup{
+				Mongos:       mongos,
+				ConfigServer: configServer,
+				Shards:       shards,
+				Connect:      o.connector(),
+				Store:        store,
+				StateDir:     stateDir,
+				DumpTimeout:  dumpTimeout,
+			}
+			if phase == "start" {
+				return backup.Start(ctx)
+			}
+			return backup.Finish(ctx)
+		},
+	}
+	o.addFlags(cmd.Flags())
+	cmd.Flags().StringVar(&stateDir, "state-dir", stateDir, "Directory shared with the dumps, to coordinate with them")
+	if phase == "finish" {
+		cmd.Flags().DurationVar(&dumpTimeout, "dump-timeout", dumpTimeout, "How long to wait for the dumps")
+	}
+	return cmd
+}
+
+func NewCmdShardedRestore(stopCh <-chan struct{}) *cobra.Command {
+	o := &shardedOptions{}
+
+	cmd := &cobra.Command{
+		Use:               "sharded-restore",
+		Short:             "Restore the sharding metadata and replay the oplog of a consistent backup of a MongoDB sharded cluster",
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			configServer, shards, err := o.components()
+			if err != nil {
+				return err
+			}
+			ctx := contextFor(stopCh)
+			store, err := oplog.NewStore(o.osmConfig, o.bucket, o.folder)
+			if err != nil {
+				return err
+			}
+			mongos, err := o.connectToMongos(ctx)
+			if err != nil {
+				return err
+			}
+			defer mongos.Close()
+
+			restore := &shardedbackup.Restore{
+				Mongos:       mongos,
+				ConfigServer: configServer,
+				Shards:       shards,
+				Connect:      o.connector(),
+				Store:        store,
+			}
+			return restore.Run(ctx)
+		},
+	}
+	o.addFlags(cmd.Flags())
+	return cmd
+}
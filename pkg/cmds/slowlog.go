@@ -0,0 +1,31 @@
+package cmds
+
+import (
+	"os"
+
+	"github.com/appscode/go/log"
+	"github.com/spf13/cobra"
+	"kubedb.dev/mongodb/pkg/slowlog"
+)
+
+func NewCmdSlowQueryLog(stopCh <-chan struct{}) *cobra.Command {
+	shipper := &slowlog.Shipper{
+		Path:        "/var/log/mongodb/mongod.log",
+		Records:     os.Stdout,
+		Passthrough: os.Stderr,
+		MaxLogSize:  slowlog.DefaultMaxLogSize,
+	}
+
+	cmd := &cobra.Command{
+		Use:               "slow-query-log",
+		Short:             "Follow the log of mongod and write its slow operations to stdout as JSON",
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log.Infof("Following the slow operations in %s", shipper.Path)
+			return shipper.Run(contextFor(stopCh))
+		},
+	}
+	cmd.Flags().StringVar(&shipper.Path, "log-file", shipper.Path, "Path of the log file of mongod")
+	cmd.Flags().Int64Var(&shipper.MaxLogSize, "max-log-size", shipper.MaxLogSize, "Size in bytes above which the log file is truncated, once it has been read")
+	return cmd
+}
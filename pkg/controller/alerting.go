@@ -0,0 +1,229 @@
+package controller
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	promapi "github.com/coreos/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/pkg/errors"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	clientsetscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/reference"
+	core_util "kmodules.xyz/client-go/core/v1"
+	mona "kmodules.xyz/monitoring-agent-api/api/v1"
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+)
+
+// defaults of spec.alerting
+const (
+	defaultAlertFor                = 5 * time.Minute
+	defaultReplicationLagThreshold = 30 * time.Second
+	defaultConnectionUsagePercent  = 80
+	defaultDiskUsagePercent        = 80
+	defaultSnapshotAgeThreshold    = 25 * time.Hour
+)
+
+const (
+	severityWarning  = "warning"
+	severityCritical = "critical"
+)
+
+// alertRuleName returns the name of the PrometheusRule of mongodb.
+func alertRuleName(mongodb *api.MongoDB) string {
+	return fmt.Sprintf("kubedb-%s-%s", mongodb.Namespace, mongodb.Name)
+}
+
+// alertRuleNamespace returns the namespace of the PrometheusRule of mongodb, that of the Prometheus, as
+// for the ServiceMonitors.
+func alertRuleNamespace(mongodb *api.MongoDB) string {
+	if mongodb.Spec.Monitor != nil && mongodb.Spec.Monitor.Prometheus != nil && mongodb.Spec.Monitor.Prometheus.Namespace != "" {
+		return mongodb.Spec.Monitor.Prometheus.Namespace
+	}
+	return mongodb.Namespace
+}
+
+// manageAlerting creates or updates the PrometheusRule of mongodb, as given by spec.alerting, or deletes it
+// once spec.alerting is removed.
+func (c *Controller) manageAlerting(mongodb *api.MongoDB) error {
+	if mongodb.Spec.Alerting == nil {
+		return c.deleteAlertRules(mongodb)
+	}
+	if mongodb.Spec.Monitor == nil || mongodb.Spec.Monitor.Agent != mona.AgentCoreOSPrometheus {
+		return errors.Errorf("spec.alerting requires spec.monitor.agent %v", mona.AgentCoreOSPrometheus)
+	}
+
+	rule := &promapi.PrometheusRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      alertRuleName(mongodb),
+			Namespace: alertRuleNamespace(mongodb),
+			Labels: core_util.UpsertMap(
+				core_util.UpsertMap(mongodb.OffshootSelectors(), mongodb.Spec.Monitor.Prometheus.Labels),
+				mongodb.Spec.Alerting.Labels,
+			),
+		},
+		Spec: promapi.PrometheusRuleSpec{
+			Groups: []promapi.RuleGroup{{
+				Name:  alertRuleName(mongodb),
+				Rules: alertRules(mongodb),
+			}},
+		},
+	}
+	// an owner must be in the same namespace
+	if rule.Namespace == mongodb.Namespace {
+		ref, err := reference.GetReference(clientsetscheme.Scheme, mongodb)
+		if err != nil {
+			return err
+		}
+		core_util.EnsureOwnerReference(&rule.ObjectMeta, ref)
+	}
+
+	cur, err := c.promClient.PrometheusRules(rule.Namespace).Get(rule.Name, metav1.GetOptions{})
+	if kerr.IsNotFound(err) {
+		_, err = c.promClient.PrometheusRules(rule.Namespace).Create(rule)
+		return err
+	} else if err != nil {
+		return err
+	}
+	if reflect.DeepEqual(cur.Labels, rule.Labels) && reflect.DeepEqual(cur.Spec, rule.Spec) {
+		return nil
+	}
+	cur.Labels = rule.Labels
+	cur.OwnerReferences = rule.OwnerReferences
+	cur.Spec = rule.Spec
+	_, err = c.promClient.PrometheusRules(cur.Namespace).Update(cur)
+	return err
+}
+
+// deleteAlertRules deletes the PrometheusRule of mongodb, if any.
+func (c *Controller) deleteAlertRules(mongodb *api.MongoDB) error {
+	err := c.promClient.PrometheusRules(alertRuleNamespace(mongodb)).Delete(alertRuleName(mongodb), nil)
+	if err != nil && !kerr.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// alertRules returns the alerts of mongodb. Every alert is labelled with the name and the namespace of mongodb.
+//
+// The snapshot alerts use the snapshot metrics of the operator, so they require the operator to be scraped
+// with its labels honored.
+func alertRules(mongodb *api.MongoDB) []promapi.Rule {
+	spec := mongodb.Spec.Alerting
+	forDuration := promDuration(durationOrDefault(spec.For, defaultAlertFor))
+	sel := statsSelector(mongodb)
+
+	rule := func(alert, severity, expr, summary string) promapi.Rule {
+		return promapi.Rule{
+			Alert: alert,
+			Expr:  intstr.FromString(expr),
+			For:   forDuration,
+			Labels: map[string]string{
+				"database":  mongodb.Name,
+				"namespace": mongodb.Namespace,
+				"severity":  severity,
+			},
+			Annotations: map[string]string{
+				"summary": fmt.Sprintf("MongoDB %s/%s: %s", mongodb.Namespace, mongodb.Name, summary),
+			},
+		}
+	}
+
+	rules := []promapi.Rule{
+		rule("MongoDBMemberDown", severityCritical,
+			fmt.Sprintf(`up{%s} == 0 or mongodb_up{%s} == 0`, sel, sel),
+			"server {{ $labels.pod }} is down"),
+		rule("MongoDBConnectionSaturation", severityWarning,
+			fmt.Sprintf(`100 * sum by (pod) (mongodb_connections{%s,state="current"}) / sum by (pod) (mongodb_connections{%s,state=~"current|available"}) > %d`,
+				sel, sel, int32OrDefault(spec.ConnectionUsagePercent, defaultConnectionUsagePercent)),
+			"server {{ $labels.pod }} uses {{ $value | humanize }}% of its connections"),
+	}
+
+	if mongodb.Spec.ReplicaSet != nil || mongodb.Spec.ShardTopology != nil {
+		rules = append(rules,
+			rule("MongoDBReplicationLag", severityWarning,
+				fmt.Sprintf(`max by (pod, set) (mongodb_mongod_replset_member_replication_lag{%s}) > %d`,
+					sel, int64(durationOrDefault(spec.ReplicationLagThreshold, defaultReplicationLagThreshold).Seconds())),
+				"replicaset {{ $labels.set }} lags {{ $value }}s behind its primary"),
+			rule("MongoDBNoPrimary", severityCritical,
+				fmt.Sprintf(`max by (set) (mongodb_mongod_replset_my_state{%s} == bool 1) == 0`, sel),
+				"replicaset {{ $labels.set }} has no primary"),
+		)
+	}
+
+	if mongodb.Spec.StorageType != api.StorageTypeEphemeral {
+		pvc := dataVolumeSelector(mongodb)
+		rules = append(rules,
+			rule("MongoDBDiskUsage", severityWarning,
+				fmt.Sprintf(`100 * sum by (persistentvolumeclaim) (kubelet_volume_stats_used_bytes{%s}) / sum by (persistentvolumeclaim) (kubelet_volume_stats_capacity_bytes{%s}) > %d`,
+					pvc, pvc, int32OrDefault(spec.DiskUsagePercent, defaultDiskUsagePercent)),
+				"volume {{ $labels.persistentvolumeclaim }} is {{ $value | humanize }}% full"),
+		)
+	}
+
+	if mongodb.Spec.BackupSchedule != nil {
+		snapshot := func(phase api.SnapshotPhase) string {
+			return fmt.Sprintf(`max(kubedb_mongodb_operator_snapshot_last_completion_timestamp_seconds{namespace=%q,database=%q,scheduled="true",phase=%q})`,
+				mongodb.Namespace, mongodb.Name, phase)
+		}
+		failed, succeeded := snapshot(api.SnapshotPhaseFailed), snapshot(api.SnapshotPhaseSucceeded)
+		rules = append(rules,
+			rule("MongoDBScheduledSnapshotFailed", severityWarning,
+				fmt.Sprintf(`%s > %s or (%s unless %s)`, failed, succeeded, failed, succeeded),
+				"the last scheduled snapshot failed"),
+			rule("MongoDBScheduledSnapshotMissing", severityWarning,
+				fmt.Sprintf(`time() - %s > %d`, succeeded, int64(durationOrDefault(spec.SnapshotAgeThreshold, defaultSnapshotAgeThreshold).Seconds())),
+				"no scheduled snapshot succeeded for {{ $value | humanizeDuration }}"),
+		)
+	}
+	return rules
+}
+
+// statsSelector selects the metrics scraped from the exporters of mongodb.
+func statsSelector(mongodb *api.MongoDB) string {
+	services := statsServices(mongodb)
+	names := make([]string, 0, len(services))
+	for _, stats := range services {
+		names = append(names, stats.ServiceName())
+	}
+	return fmt.Sprintf(`namespace=%q,service=~"%s"`, mongodb.Namespace, strings.Join(names, "|"))
+}
+
+// dataVolumeSelector selects the volume metrics of the kubelets for the data volumes of mongodb.
+func dataVolumeSelector(mongodb *api.MongoDB) string {
+	return fmt.Sprintf(`namespace=%q,persistentvolumeclaim=~"%s-(%s)-[0-9]+"`,
+		mongodb.Namespace, dataDirectoryName, strings.Join(dataStatefulSets(mongodb), "|"))
+}
+
+// dataStatefulSets returns the StatefulSets of mongodb that have a data volume.
+func dataStatefulSets(mongodb *api.MongoDB) []string {
+	var names []string
+	for _, component := range desiredComponents(mongodb) {
+		if component.status.Type != api.MongoDBComponentMongos {
+			names = append(names, component.status.Name)
+		}
+	}
+	return names
+}
+
+// promDuration formats d in seconds, as Prometheus does not parse durations of more than one unit.
+func promDuration(d time.Duration) string {
+	return fmt.Sprintf("%ds", int64(d.Seconds()))
+}
+
+func durationOrDefault(d *metav1.Duration, def time.Duration) time.Duration {
+	if d == nil || d.Duration <= 0 {
+		return def
+	}
+	return d.Duration
+}
+
+func int32OrDefault(v *int32, def int32) int32 {
+	if v == nil {
+		return def
+	}
+	return *v
+}
@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+)
+
+func TestAlertRules(t *testing.T) {
+	mongodb := &api.MongoDB{
+		ObjectMeta: metav1.ObjectMeta{Name: "mg", Namespace: "demo"},
+		Spec: api.MongoDBSpec{
+			StorageType: api.StorageTypeEphemeral,
+			Alerting:    &api.MongoDBAlertingSpec{},
+		},
+	}
+
+	alerts := func() map[string]bool {
+		names := map[string]bool{}
+		for _, rule := range alertRules(mongodb) {
+			if rule.Labels["database"] != "mg" || rule.Labels["namespace"] != "demo" {
+				t.Errorf("alert %v is not labelled with the database, got %v", rule.Alert, rule.Labels)
+			}
+			if rule.For != "300s" {
+				t.Errorf("expected alert %v to fire after 300s, got %v", rule.Alert, rule.For)
+			}
+			names[rule.Alert] = true
+		}
+		return names
+	}
+
+	if names := alerts(); len(names) != 2 || !names["MongoDBMemberDown"] || !names["MongoDBConnectionSaturation"] {
+		t.Errorf("expected only the member and connection alerts of a standalone ephemeral server, got %v", names)
+	}
+
+	mongodb.Spec.StorageType = api.StorageTypeDurable
+	mongodb.Spec.ReplicaSet = &api.MongoDBReplicaSet{Name: "rs0"}
+	mongodb.Spec.BackupSchedule = &api.BackupScheduleSpec{}
+	names := alerts()
+	for _, alert := range []string{"MongoDBReplicationLag", "MongoDBNoPrimary", "MongoDBDiskUsage",
+		"MongoDBScheduledSnapshotFailed", "MongoDBScheduledSnapshotMissing"} {
+		if !names[alert] {
+			t.Errorf("expected alert %v, got %v", alert, names)
+		}
+	}
+}
@@ -79,9 +79,16 @@ func (c *Controller) ensureAppBinding(db *api.MongoDB) (kutil.VerbType, error) {
 		return kutil.VerbUnchanged, fmt.Errorf("failed to get MongoDBVersion %v for %v/%v. Reason: %v", db.Spec.Version, db.Namespace, db.Name, err)
 	}
 
+	credentialRevision, err := c.credentialRevision(db)
+	if err != nil {
+		return kutil.VerbUnchanged, err
+	}
+
 	_, vt, err := appcat_util.CreateOrPatchAppBinding(c.AppCatalogClient, meta, func(in *appcat.AppBinding) *appcat.AppBinding {
 		core_util.EnsureOwnerReference(&in.ObjectMeta, ref)
 		in.Labels = db.OffshootLabels()
+		// consumers reload the secret when the root password is rotated.
+		in.Annotations = upsertAnnotation(in.Annotations, credentialRevisionAnnotation, credentialRevision)
 
 		in.Spec.Type = appmeta.Type()
 		in.Spec.Version = mongodbVersion.Spec.Version
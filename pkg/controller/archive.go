@@ -0,0 +1,169 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	batch "k8s.io/api/batch/v1"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	core_util "kmodules.xyz/client-go/core/v1"
+	catalog "kubedb.dev/apimachinery/apis/catalog/v1alpha1"
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+	"kubedb.dev/apimachinery/client/clientset/versioned/typed/kubedb/v1alpha1/util"
+)
+
+const (
+	// snapshotCipher is the cipher mongo-tools.sh encrypts the archives with.
+	snapshotCipher         = "aes-256-cbc"
+	snapshotKeyVolume      = "encryption-key"
+	snapshotKeyPath        = "/var/run/mongodb/encryption-key"
+	snapshotPassphraseFile = "passphrase"
+)
+
+// addBackupArchive makes the snapshot job store the dump as an archive, compressed and encrypted as the snapshot
+// asks. How the archive is written is recorded in the annotations of the snapshot, that the restore follows.
+func (c *Controller) addBackupArchive(mongodbVersion *catalog.MongoDBVersion, snapshot *api.Snapshot, job *batch.Job) error {
+	archive := snapshot.Spec.Archive
+	if archive == nil {
+		return nil
+	}
+	if err := archiveSupported(mongodbVersion); err != nil {
+		return err
+	}
+	compression := archive.Compression
+	if compression == "" {
+		compression = api.SnapshotCompressionNone
+	}
+	annotations := map[string]string{
+		api.AnnotationSnapshotCompression: string(compression),
+	}
+	var key *core.SecretKeySelector
+	if archive.Encryption != nil {
+		key = &archive.Encryption.KeySecret
+		digest, err := c.passphraseDigest(snapshot.Namespace, *key)
+		if err != nil {
+			return err
+		}
+		annotations[api.AnnotationSnapshotEncryption] = snapshotCipher
+		annotations[api.AnnotationSnapshotEncryptionKey] = key.Name + "/" + key.Key
+		annotations[api.AnnotationSnapshotEncryptionKeySHA256] = digest
+	}
+
+	_, _, err := util.PatchSnapshot(c.ExtClient.KubedbV1alpha1(), snapshot, func(in *api.Snapshot) *api.Snapshot {
+		in.Annotations = core_util.UpsertMap(in.Annotations, annotations)
+		return in
+	})
+	if err != nil {
+		return err
+	}
+	withArchive(&job.Spec.Template.Spec, compression, key)
+	return nil
+}
+
+// addRestoreArchive makes the restore job read the archive of snapshot, as recorded in its annotations. Snapshots
+// without these annotations are stored as a directory of dumps, and are restored as before. The passphrase must
+// be in a secret of the same name in the namespace of mongodb, and match the one the archive is encrypted with.
+func (c *Controller) addRestoreArchive(mongodb *api.MongoDB, mongodbVersion *catalog.MongoDBVersion, snapshot *api.Snapshot, job *batch.Job) error {
+	compression := api.SnapshotCompression(snapshot.Annotations[api.AnnotationSnapshotCompression])
+	ref := snapshot.Annotations[api.AnnotationSnapshotEncryptionKey]
+	if compression == "" && ref == "" {
+		return nil
+	}
+	if err := archiveSupported(mongodbVersion); err != nil {
+		return err
+	}
+	if compression == "" {
+		compression = api.SnapshotCompressionNone
+	}
+
+	var key *core.SecretKeySelector
+	if ref != "" {
+		if cipher := snapshot.Annotations[api.AnnotationSnapshotEncryption]; cipher != snapshotCipher {
+			return errors.Errorf(`snapshot "%v/%v" is encrypted with unsupported cipher %q`, snapshot.Namespace, snapshot.Name, cipher)
+		}
+		parts := strings.SplitN(ref, "/", 2)
+		if len(parts) != 2 {
+			return errors.Errorf(`snapshot "%v/%v" has invalid encryption key %q`, snapshot.Namespace, snapshot.Name, ref)
+		}
+		key = &core.SecretKeySelector{
+			LocalObjectReference: core.LocalObjectReference{Name: parts[0]},
+			Key:                  parts[1],
+		}
+		digest, err := c.passphraseDigest(mongodb.Namespace, *key)
+		if err != nil {
+			return err
+		}
+		if digest != snapshot.Annotations[api.AnnotationSnapshotEncryptionKeySHA256] {
+			return errors.Errorf(`key %v of secret "%v/%v" is not the passphrase snapshot "%v/%v" is encrypted with`,
+				key.Key, mongodb.Namespace, key.Name, snapshot.Namespace, snapshot.Name)
+		}
+	}
+	withArchive(&job.Spec.Template.Spec, compression, key)
+	return nil
+}
+
+// archiveSupported returns an error, unless the tools image of mongodbVersion reads and writes archives. The images
+// published before would reject the arguments of withArchive.
+func archiveSupported(mongodbVersion *catalog.MongoDBVersion) error {
+	if !mongodbVersion.Spec.Tools.Archive {
+		return errors.Errorf("tools image %v of MongoDBVersion %v doesn't support snapshot archives",
+			mongodbVersion.Spec.Tools.Image, mongodbVersion.Name)
+	}
+	return nil
+}
+
+// passphraseDigest returns the hex encoded SHA-256 digest of the passphrase of key in namespace.
+func (c *Controller) passphraseDigest(namespace string, key core.SecretKeySelector) (string, error) {
+	secret, err := c.Client.CoreV1().Secrets(namespace).Get(key.Name, metav1.GetOptions{})
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get the secret of the encryption key")
+	}
+	passphrase := secret.Data[key.Key]
+	if len(passphrase) == 0 {
+		return "", errors.Errorf(`secret "%v/%v" has no passphrase at key %v`, namespace, key.Name, key.Key)
+	}
+	digest := sha256.Sum256(passphrase)
+	return hex.EncodeToString(digest[:]), nil
+}
+
+// withArchive passes the compression and the passphrase of the archive to the mongo-tools.sh container of podSpec,
+// and mounts the passphrase of key, if it is encrypted.
+func withArchive(podSpec *core.PodSpec, compression api.SnapshotCompression, key *core.SecretKeySelector) {
+	container := &podSpec.Containers[0]
+	args := []string{fmt.Sprintf("--compression=%v", strings.ToLower(string(compression)))}
+	if key != nil {
+		args = append(args, fmt.Sprintf("--encryption-key-file=%v", filepath.Join(snapshotKeyPath, snapshotPassphraseFile)))
+		container.VolumeMounts = append(container.VolumeMounts, core.VolumeMount{
+			Name:      snapshotKeyVolume,
+			MountPath: snapshotKeyPath,
+			ReadOnly:  true,
+		})
+		podSpec.Volumes = append(podSpec.Volumes, core.Volume{
+			Name: snapshotKeyVolume,
+			VolumeSource: core.VolumeSource{
+				Secret: &core.SecretVolumeSource{
+					SecretName: key.Name,
+					Items: []core.KeyToPath{
+						{Key: key.Key, Path: snapshotPassphraseFile},
+					},
+				},
+			},
+		})
+	}
+	withToolsArgs(container, args...)
+}
+
+// withToolsArgs adds the options of mongo-tools.sh to container, before the arguments passed through to mongodump
+// and mongorestore.
+func withToolsArgs(container *core.Container, args ...string) {
+	i := 0
+	for i < len(container.Args) && container.Args[i] != "--" {
+		i++
+	}
+	container.Args = append(container.Args[:i], append(args, container.Args[i:]...)...)
+}
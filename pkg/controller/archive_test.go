@@ -0,0 +1,71 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	batch "k8s.io/api/batch/v1"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	catalog "kubedb.dev/apimachinery/apis/catalog/v1alpha1"
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+)
+
+func TestWithArchive(t *testing.T) {
+	podSpec := core.PodSpec{
+		Containers: []core.Container{
+			{Args: []string{api.JobTypeRestore, "--host=mgo", "--skip-config=true", "--", "--drop"}},
+		},
+	}
+	key := &core.SecretKeySelector{LocalObjectReference: core.LocalObjectReference{Name: "backup-key"}, Key: "passphrase"}
+	withArchive(&podSpec, api.SnapshotCompressionZstd, key)
+
+	expected := []string{api.JobTypeRestore, "--host=mgo", "--skip-config=true",
+		"--compression=zstd", "--encryption-key-file=/var/run/mongodb/encryption-key/passphrase", "--", "--drop"}
+	if args := podSpec.Containers[0].Args; !reflect.DeepEqual(args, expected) {
+		t.Errorf("expected args %v, got %v", expected, args)
+	}
+	if len(podSpec.Volumes) != 1 || podSpec.Volumes[0].Secret.SecretName != "backup-key" ||
+		podSpec.Volumes[0].Secret.Items[0].Key != "passphrase" {
+		t.Errorf("expected the passphrase to be mounted, got volumes %+v", podSpec.Volumes)
+	}
+
+	podSpec = core.PodSpec{Containers: []core.Container{{Args: []string{api.JobTypeBackup, "--host=mgo"}}}}
+	withArchive(&podSpec, api.SnapshotCompressionGzip, nil)
+	expected = []string{api.JobTypeBackup, "--host=mgo", "--compression=gzip"}
+	if args := podSpec.Containers[0].Args; !reflect.DeepEqual(args, expected) || len(podSpec.Volumes) != 0 {
+		t.Errorf("expected args %v and no volumes, got %v and %+v", expected, args, podSpec.Volumes)
+	}
+}
+
+func TestAddRestoreArchiveOfOlderTools(t *testing.T) {
+	version := &catalog.MongoDBVersion{
+		ObjectMeta: metav1.ObjectMeta{Name: "4.1.13"},
+		Spec: catalog.MongoDBVersionSpec{
+			Tools: catalog.MongoDBVersionTools{Image: "kubedb/mongo-tools:4.1.13"},
+		},
+	}
+	snapshot := &api.Snapshot{ObjectMeta: metav1.ObjectMeta{
+		Name:      "snap",
+		Namespace: "demo",
+		Annotations: map[string]string{
+			api.AnnotationSnapshotCompression: string(api.SnapshotCompressionGzip),
+		},
+	}}
+	job := &batch.Job{}
+	job.Spec.Template.Spec.Containers = []core.Container{{Args: []string{api.JobTypeRestore, "--host=mgo"}}}
+
+	c := &Controller{}
+	if err := c.addRestoreArchive(shardedMongoDB(), version, snapshot, job); err == nil {
+		t.Errorf("expected an error for a tools image without archive support")
+	}
+
+	version.Spec.Tools.Archive = true
+	if err := c.addRestoreArchive(shardedMongoDB(), version, snapshot, job); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{api.JobTypeRestore, "--host=mgo", "--compression=gzip"}
+	if args := job.Spec.Template.Spec.Containers[0].Args; !reflect.DeepEqual(args, expected) {
+		t.Errorf("expected args %v, got %v", expected, args)
+	}
+}
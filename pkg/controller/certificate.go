@@ -1,20 +1,36 @@
 package controller
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"fmt"
 	"net"
+	"time"
 
 	"github.com/pkg/errors"
 	"gomodules.xyz/cert"
 	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
 )
 
+const (
+	// certDuration is the validity of the certificates issued by the operator, as of the certificates signed by
+	// gomodules.xyz/cert.
+	certDuration = 365 * 24 * time.Hour
+
+	// defaultClusterDomain is the domain the hostnames of the members are resolved in, as reported by peer-finder.
+	defaultClusterDomain = "cluster.local"
+)
+
 // createCaCertificate returns generated caKey, caCert, err in order.
-func createCaCertificate() (*rsa.PrivateKey, *x509.Certificate, error) {
+func createCaCertificate(commonName string) (*rsa.PrivateKey, *x509.Certificate, error) {
 	cfg := cert.Config{
-		CommonName:   "ca",
+		CommonName:   commonName,
 		Organization: []string{"kubedb:ca"},
 	}
 
@@ -34,67 +50,160 @@ func createCaCertificate() (*rsa.PrivateKey, *x509.Certificate, error) {
 	return caKey, caCert, nil
 }
 
-// createPEMCertificate returns generated Key, Cert, err in order.
-func createPEMCertificate(mongodb *api.MongoDB, caKey *rsa.PrivateKey, caCert *x509.Certificate, cfg cert.Config) ([]byte, error) {
-	privateKey, err := cert.NewPrivateKey()
-	if err != nil {
-		return nil, errors.New("failed to generate key for client certificate")
-	}
-
-	certificate, err := cert.NewSignedCert(cfg, privateKey, caCert, caKey)
-	if err != nil {
-		return nil, errors.New("failed to sign client certificate")
-	}
-
-	keyBytes := cert.EncodePrivateKeyPEM(privateKey)
-	certBytes := cert.EncodeCertPEM(certificate)
-	pemBytes := append(certBytes, keyBytes...)
-
-	return pemBytes, nil
+// certificateRequest describes a certificate the operator issues for MongoDB.
+type certificateRequest struct {
+	// name identifies the certificate among the certificates of MongoDB.
+	name     string
+	subject  pkix.Name
+	dnsNames []string
+	ips      []net.IP
+	usages   []x509.ExtKeyUsage
 }
 
-// createServerPEMCertificate returns generated Key, Cert, err in order.
+// serverCertificateRequest returns the request of the server certificate of commonName.
+// hosts are added to the DNS SANs, along with the names of the database Service and spec.tls.dnsNames.
+// The subject, OU=MongoDB,CN=<commonName>, matches the certificates generated by the bootstrap scripts,
+// as members of a cluster authenticate each other by the O, OU and DC of their certificates.
 // xref: https://docs.mongodb.com/manual/core/security-x.509/#member-x-509-certificates
-func createServerPEMCertificate(mongodb *api.MongoDB, caKey *rsa.PrivateKey, caCert *x509.Certificate) ([]byte, error) {
-	cfg := cert.Config{
-		CommonName:   mongodb.OffshootName(),
-		Organization: []string{"kubedb:server"},
-		AltNames: cert.AltNames{
-			DNSNames: []string{
-				"localhost",
-				fmt.Sprintf("%v.%v.svc", mongodb.OffshootName(), mongodb.Namespace),
-				mongodb.OffshootName(),
-				mongodb.ServiceName(),
-			},
-			IPs: []net.IP{net.ParseIP("127.0.0.1")},
+func serverCertificateRequest(mongodb *api.MongoDB, commonName string, hosts ...string) certificateRequest {
+	req := certificateRequest{
+		name: commonName,
+		subject: pkix.Name{
+			CommonName:         commonName,
+			OrganizationalUnit: []string{"MongoDB"},
 		},
-		Usages: []x509.ExtKeyUsage{
+		dnsNames: append(serviceDNSNames(mongodb), hosts...),
+		ips:      []net.IP{net.ParseIP("127.0.0.1")},
+		usages: []x509.ExtKeyUsage{
 			x509.ExtKeyUsageServerAuth,
 			x509.ExtKeyUsageClientAuth,
 		},
 	}
-	return createPEMCertificate(mongodb, caKey, caCert, cfg)
+	if tls := mongodb.Spec.TLS; tls != nil {
+		req.dnsNames = append(req.dnsNames, tls.DNSNames...)
+		for _, ip := range tls.IPAddresses {
+			if parsed := net.ParseIP(ip); parsed != nil {
+				req.ips = append(req.ips, parsed)
+			}
+		}
+	}
+	return req
 }
 
-// createPEMCertificate returns generated Key, Cert, err in order.
+// clientCertificateRequest returns the request of the client certificate of the root user.
 // xref: https://docs.mongodb.com/manual/tutorial/configure-x509-client-authentication/
-func createClientPEMCertificate(mongodb *api.MongoDB, caKey *rsa.PrivateKey, caCert *x509.Certificate) ([]byte, error) {
-	cfg := cert.Config{
-		CommonName:   "root",
-		Organization: []string{"kubedb:client"},
-		AltNames: cert.AltNames{
-			DNSNames: []string{
-				"localhost",
-				fmt.Sprintf("%v.%v.svc", mongodb.OffshootName(), mongodb.Namespace),
-				mongodb.OffshootName(),
-				mongodb.ServiceName(),
-			},
-			IPs: []net.IP{net.ParseIP("127.0.0.1")},
+func clientCertificateRequest(mongodb *api.MongoDB) certificateRequest {
+	return certificateRequest{
+		name: mongodb.Name + "-client",
+		subject: pkix.Name{
+			CommonName:   "root",
+			Organization: []string{"kubedb:client"},
 		},
-		Usages: []x509.ExtKeyUsage{
+		dnsNames: serviceDNSNames(mongodb),
+		ips:      []net.IP{net.ParseIP("127.0.0.1")},
+		usages: []x509.ExtKeyUsage{
 			x509.ExtKeyUsageServerAuth,
 			x509.ExtKeyUsageClientAuth,
 		},
 	}
-	return createPEMCertificate(mongodb, caKey, caCert, cfg)
+}
+
+// serviceDNSNames returns the hostnames of the database Service of mongodb.
+func serviceDNSNames(mongodb *api.MongoDB) []string {
+	return []string{
+		"localhost",
+		fmt.Sprintf("%v.%v.svc", mongodb.OffshootName(), mongodb.Namespace),
+		mongodb.OffshootName(),
+		mongodb.ServiceName(),
+	}
+}
+
+// signCertificate returns the PEM encoded certificate of req for key, signed by signer, along with the PEM
+// encoded CA that verifies it.
+func signCertificate(signer Signer, req certificateRequest, key crypto.Signer) ([]byte, []byte, error) {
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:     req.subject,
+		DNSNames:    req.dnsNames,
+		IPAddresses: req.ips,
+	}, key)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to create certificate request %v", req.name)
+	}
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		return nil, nil, err
+	}
+	return signer.Sign(req.name, csr, req.usages, certDuration)
+}
+
+// newPrivateKey generates a key of the algorithm and size of spec.tls.privateKey, RSA 2048 by default.
+func newPrivateKey(tls *api.MongoDBTLSConfig) (crypto.Signer, error) {
+	var spec api.MongoDBTLSPrivateKey
+	if tls != nil && tls.PrivateKey != nil {
+		spec = *tls.PrivateKey
+	}
+	switch spec.Algorithm {
+	case api.MongoDBKeyAlgorithmECDSA:
+		var curve elliptic.Curve
+		switch spec.Size {
+		case 0, 256:
+			curve = elliptic.P256()
+		case 384:
+			curve = elliptic.P384()
+		case 521:
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported ECDSA key size %v", spec.Size)
+		}
+		return ecdsa.GenerateKey(curve, rand.Reader)
+	case "", api.MongoDBKeyAlgorithmRSA:
+		size := spec.Size
+		if size == 0 {
+			size = 2048
+		}
+		return rsa.GenerateKey(rand.Reader, size)
+	}
+	return nil, fmt.Errorf("unsupported key algorithm %v", spec.Algorithm)
+}
+
+// encodePrivateKeyPEM returns the PEM of an RSA or ECDSA key.
+func encodePrivateKeyPEM(key crypto.Signer) ([]byte, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return cert.EncodePrivateKeyPEM(k), nil
+	case *ecdsa.PrivateKey:
+		der, err := x509.MarshalECPrivateKey(k)
+		if err != nil {
+			return nil, err
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: cert.ECPrivateKeyBlockType, Bytes: der}), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %T", key)
+}
+
+// parsePrivateKeyPEM parses the PEM of an RSA or ECDSA key.
+func parsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	key, err := cert.ParsePrivateKeyPEM(data)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+	return signer, nil
+}
+
+// memberDNSNames returns the hostnames of the pod ordinal of StatefulSet stsName, as its peers and clients dial it.
+func memberDNSNames(mongodb *api.MongoDB, stsName string, ordinal int32) []string {
+	pod := fmt.Sprintf("%v-%v", stsName, ordinal)
+	gvr := mongodb.GvrSvcName(stsName)
+	return []string{
+		stsName,
+		pod,
+		fmt.Sprintf("%v.%v", pod, gvr),
+		fmt.Sprintf("%v.%v.%v", pod, gvr, mongodb.Namespace),
+		fmt.Sprintf("%v.%v.%v.svc", pod, gvr, mongodb.Namespace),
+		fmt.Sprintf("%v.%v.%v.svc.%v", pod, gvr, mongodb.Namespace, defaultClusterDomain),
+	}
 }
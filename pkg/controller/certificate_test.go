@@ -0,0 +1,253 @@
+package controller
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/tls"
+	"crypto/x509"
+	"reflect"
+	"testing"
+
+	"gomodules.xyz/cert"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	catalog "kubedb.dev/apimachinery/apis/catalog/v1alpha1"
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+)
+
+func TestCreateServerPEMCertificate(t *testing.T) {
+	mongodb := &api.MongoDB{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "mg",
+			Namespace: "demo",
+		},
+	}
+	caKey, caCert, err := createCaCertificate("ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := newPrivateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crtPEM, _, err := signCertificate(&caSigner{key: caKey, cert: caCert}, serverCertificateRequest(mongodb, "mg-1", memberDNSNames(mongodb, "mg", 1)...), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM, err := encodePrivateKeyPEM(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tls.X509KeyPair(crtPEM, keyPEM); err != nil {
+		t.Fatalf("mongo.pem has no matching key pair: %v", err)
+	}
+	certs, err := cert.ParseCertsPEM(crtPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crt := certs[0]
+	if crt.Subject.CommonName != "mg-1" {
+		t.Errorf("expected common name mg-1, got %v", crt.Subject.CommonName)
+	}
+	// x509 member authentication requires the same O, OU and DC for every member.
+	if len(crt.Subject.OrganizationalUnit) != 1 || crt.Subject.OrganizationalUnit[0] != "MongoDB" {
+		t.Errorf("expected organizational unit MongoDB, got %v", crt.Subject.OrganizationalUnit)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(caCert)
+	for _, host := range []string{"mg-1.mg-gvr.demo.svc.cluster.local", "mg-1.mg-gvr", "localhost"} {
+		if _, err := crt.Verify(x509.VerifyOptions{
+			DNSName:   host,
+			Roots:     pool,
+			KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		}); err != nil {
+			t.Errorf("failed to verify certificate for %v: %v", host, err)
+		}
+	}
+	if _, err := crt.Verify(x509.VerifyOptions{DNSName: "mg-0.mg-gvr.demo.svc", Roots: pool}); err == nil {
+		t.Errorf("certificate of mg-1 is valid for mg-0")
+	}
+}
+
+func TestSignCertificateWithSpecTLS(t *testing.T) {
+	mongodb := &api.MongoDB{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "mg",
+			Namespace: "demo",
+		},
+		Spec: api.MongoDBSpec{
+			TLS: &api.MongoDBTLSConfig{
+				DNSNames:    []string{"mongo.example.com"},
+				IPAddresses: []string{"10.0.0.10"},
+				PrivateKey: &api.MongoDBTLSPrivateKey{
+					Algorithm: api.MongoDBKeyAlgorithmECDSA,
+					Size:      384,
+				},
+			},
+		},
+	}
+	caKey, caCert, err := createCaCertificate("ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := newPrivateKey(mongodb.Spec.TLS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok || ecKey.Curve != elliptic.P384() {
+		t.Fatalf("expected an ECDSA P-384 key, got %T", key)
+	}
+	crtPEM, caPEM, err := signCertificate(&caSigner{key: caKey, cert: caCert}, serverCertificateRequest(mongodb, "mg"), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM, err := encodePrivateKeyPEM(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tls.X509KeyPair(crtPEM, keyPEM); err != nil {
+		t.Fatalf("mongo.pem has no matching key pair: %v", err)
+	}
+
+	certs, err := cert.ParseCertsPEM(crtPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(caPEM)
+	if _, err := certs[0].Verify(x509.VerifyOptions{DNSName: "mongo.example.com", Roots: pool}); err != nil {
+		t.Errorf("failed to verify certificate for spec.tls.dnsNames: %v", err)
+	}
+	if err := certs[0].VerifyHostname("10.0.0.10"); err != nil {
+		t.Errorf("failed to verify certificate for spec.tls.ipAddresses: %v", err)
+	}
+}
+
+func TestParseCA(t *testing.T) {
+	oldKey, oldCert, err := createCaCertificate("ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKey, newCert, err := createCaCertificate("ca-next")
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := &core.Secret{
+		Data: map[string][]byte{
+			api.MongoTLSKeyFileName:  cert.EncodePrivateKeyPEM(oldKey),
+			api.MongoTLSCertFileName: append(cert.EncodeCertPEM(newCert), cert.EncodeCertPEM(oldCert)...),
+		},
+	}
+	_, crt, err := parseCA(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if crt.Subject.CommonName != "ca" {
+		t.Errorf("expected the CA of ca.key, got %v", crt.Subject.CommonName)
+	}
+
+	secret.Data[api.MongoTLSKeyFileName] = cert.EncodePrivateKeyPEM(newKey)
+	secret.Data[api.MongoTLSCertFileName] = cert.EncodeCertPEM(oldCert)
+	if _, _, err := parseCA(secret); err == nil {
+		t.Errorf("expected an error for a CA key without certificate")
+	}
+}
+
+func TestAddCA(t *testing.T) {
+	_, oldCA, err := createCaCertificate("ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, newCA, err := createCaCertificate("ca-next")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle, err := addCA(cert.EncodeCertPEM(oldCA), cert.EncodeCertPEM(newCA))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, err := addCA(bundle, cert.EncodeCertPEM(newCA)); err != nil || string(again) != string(bundle) {
+		t.Errorf("expected CA already in the bundle to be skipped, err: %v", err)
+	}
+	cas, err := cert.ParseCertsPEM(bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cas) != 2 || cas[0].Subject.CommonName != "ca-next" {
+		t.Errorf("expected the new CA in front of the old CA, got %v certificates", len(cas))
+	}
+}
+
+func TestInstallInitContainerCAKey(t *testing.T) {
+	caKeyMounted := func(mongodb *api.MongoDB, memberCertificates bool) bool {
+		version := &catalog.MongoDBVersion{Spec: catalog.MongoDBVersionSpec{
+			DB: catalog.MongoDBVersionDatabase{Image: "kubedb/mongo:4.1.13", MemberCertificates: memberCertificates},
+		}}
+		container, volumes := installInitContainer(mongodb, version, nil, nil)
+		for _, volume := range volumes {
+			if volume.Name != caKeyDirectoryName {
+				continue
+			}
+			if volume.Secret == nil || volume.Secret.SecretName != caSecretName(mongodb) {
+				t.Errorf("expected the CA key from secret %v, got %+v", caSecretName(mongodb), volume.VolumeSource)
+			}
+			for _, mount := range container.VolumeMounts {
+				if mount.Name == caKeyDirectoryName {
+					return true
+				}
+			}
+			t.Errorf("expected the CA key to be mounted into the init container")
+		}
+		return false
+	}
+
+	mongodb := shardedMongoDB()
+	mongodb.Spec.SSLMode = api.SSLModeRequireSSL
+	mongodb.Spec.CertificateSecret = &core.SecretVolumeSource{SecretName: "mg-cert"}
+	if !caKeyMounted(mongodb, false) {
+		t.Errorf("expected the CA key for an image that signs its own certificates")
+	}
+	if caKeyMounted(mongodb, true) {
+		t.Errorf("expected no CA key for an image that uses the member certificates")
+	}
+
+	mongodb.Spec.SSLMode = api.SSLModeDisabled
+	if caKeyMounted(mongodb, false) {
+		t.Errorf("expected no CA key without TLS")
+	}
+
+	standalone := &api.MongoDB{
+		ObjectMeta: metav1.ObjectMeta{Name: "mg", Namespace: "demo"},
+		Spec: api.MongoDBSpec{
+			SSLMode:           api.SSLModeRequireSSL,
+			CertificateSecret: &core.SecretVolumeSource{SecretName: "mg-cert"},
+		},
+	}
+	if caKeyMounted(standalone, false) {
+		t.Errorf("expected no CA key for a standalone")
+	}
+}
+
+func TestMemberCertsVolume(t *testing.T) {
+	mongodb := shardedMongoDB()
+	if volume := memberCertsVolume(mongodb, "mg-shard0", 3); volume != nil {
+		t.Errorf("expected no volume without TLS, got %+v", volume)
+	}
+
+	mongodb.Spec.SSLMode = api.SSLModeRequireSSL
+	volume := memberCertsVolume(mongodb, "mg-shard0", 3)
+	if volume == nil || volume.Projected == nil {
+		t.Fatalf("expected a projected volume, got %+v", volume)
+	}
+	var paths []string
+	for _, source := range volume.Projected.Sources {
+		paths = append(paths, source.Secret.Items[0].Path)
+	}
+	expected := []string{"mg-shard0-0.pem", "mg-shard0-1.pem", "mg-shard0-2.pem"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("expected the certificates of the replicas %v, got %v", expected, paths)
+	}
+}
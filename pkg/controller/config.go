@@ -1,6 +1,8 @@
 package controller
 
 import (
+	"time"
+
 	pcm "github.com/coreos/prometheus-operator/pkg/client/versioned/typed/monitoring/v1"
 	crd_cs "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/typed/apiextensions/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -36,6 +38,12 @@ type OperatorConfig struct {
 	AppCatalogClient appcat_cs.AppcatalogV1alpha1Interface
 	PromClient       pcm.MonitoringV1Interface
 	CronController   snapc.CronControllerInterface
+
+	HealthCheckInterval time.Duration
+	CertRenewBefore     time.Duration
+	CASecret            string
+	OplogArchiverImage  string
+	SlowQueryLogImage   string
 }
 
 func NewOperatorConfig(clientConfig *rest.Config) *OperatorConfig {
@@ -64,6 +72,11 @@ func (c *OperatorConfig) New() (*Controller, error) {
 		c.Config,
 		recorder,
 	)
+	ctrl.healthCheckInterval = c.HealthCheckInterval
+	ctrl.certRenewBefore = c.CertRenewBefore
+	ctrl.caSecret = c.CASecret
+	ctrl.oplogArchiverImage = c.OplogArchiverImage
+	ctrl.slowQueryLogImage = c.SlowQueryLogImage
 
 	tweakListOptions := func(options *metav1.ListOptions) {
 		options.LabelSelector = ctrl.selector.String()
@@ -0,0 +1,308 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+	core "k8s.io/api/core/v1"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	clientsetscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/reference"
+	kutil "kmodules.xyz/client-go"
+	core_util "kmodules.xyz/client-go/core/v1"
+	meta_util "kmodules.xyz/client-go/meta"
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+	"kubedb.dev/apimachinery/pkg/eventer"
+)
+
+const (
+	mongodConfigFileName = "mongod.conf"
+
+	// configHashAnnotation holds the hash of the options, that take effect on restart only, in the pod template,
+	// so that the pods are restarted when these options change.
+	configHashAnnotation = api.MongoDBKey + "/config-hash"
+)
+
+// configMapName returns the name of the ConfigMap that holds the rendered configuration of workload name.
+// The suffix keeps it apart from the ConfigMaps of users, that are commonly named <name>-config.
+func configMapName(name string) string {
+	return name + "-rendered-mongod-conf"
+}
+
+// ensureConfiguration renders opts.configuration, with the options of opts.profiling, merged into the configuration
+// file of opts.configSource, into a ConfigMap managed for the workload. It returns the config source to mount in
+// place of opts.configSource, and the hash of the options that take effect on restart only. Without
+// opts.configuration and opts.profiling, opts.configSource is used as it is.
+func (c *Controller) ensureConfiguration(mongodb *api.MongoDB, opts workloadOptions) (*core.VolumeSource, string, error) {
+	name := configMapName(opts.stsName)
+	configuration := profiledConfiguration(opts.configuration, opts.profiling)
+	if configuration == nil {
+		// spec.configuration and spec.profiling may have been removed.
+		if err := c.deleteOwnedConfigMap(mongodb, name); err != nil {
+			return nil, "", err
+		}
+		return opts.configSource, "", nil
+	}
+	if opts.configSource != nil && opts.configSource.ConfigMap != nil && opts.configSource.ConfigMap.Name == name {
+		return nil, "", fmt.Errorf(`configSource "%v/%v" is the ConfigMap that the configuration is rendered into`, mongodb.Namespace, name)
+	}
+
+	base, err := c.readConfigSource(mongodb.Namespace, opts.configSource)
+	if err != nil {
+		return nil, "", err
+	}
+	conf, err := renderConfiguration(base, configuration)
+	if err != nil {
+		return nil, "", err
+	}
+	rendered, err := yaml.Marshal(conf)
+	if err != nil {
+		return nil, "", err
+	}
+	// Changes to the other options are applied to the running servers by applyRuntimeConfiguration.
+	restartHash, err := yaml.Marshal(restartOptions(flattenConfig(conf)))
+	if err != nil {
+		return nil, "", err
+	}
+
+	if cm, err := c.Client.CoreV1().ConfigMaps(mongodb.Namespace).Get(name, metav1.GetOptions{}); err == nil {
+		if !ownedBy(cm.ObjectMeta, mongodb.UID) {
+			return nil, "", fmt.Errorf(`intended configMap "%v/%v" already exists`, mongodb.Namespace, name)
+		}
+		if old, err := parseConfig([]byte(cm.Data[mongodConfigFileName])); err == nil {
+			c.recordRestartOptionChanges(mongodb, opts.stsName, flattenConfig(old), flattenConfig(conf))
+		}
+	} else if !kerr.IsNotFound(err) {
+		return nil, "", err
+	}
+
+	ref, err := reference.GetReference(clientsetscheme.Scheme, mongodb)
+	if err != nil {
+		return nil, "", err
+	}
+	_, vt, err := core_util.CreateOrPatchConfigMap(c.Client, metav1.ObjectMeta{
+		Name:      name,
+		Namespace: mongodb.Namespace,
+	}, func(in *core.ConfigMap) *core.ConfigMap {
+		in.Labels = opts.labels
+		core_util.EnsureOwnerReference(&in.ObjectMeta, ref)
+		in.Data = map[string]string{
+			mongodConfigFileName: string(rendered),
+		}
+		return in
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if vt != kutil.VerbUnchanged {
+		c.recorder.Eventf(
+			mongodb,
+			core.EventTypeNormal,
+			eventer.EventReasonSuccessful,
+			"Successfully %v configuration ConfigMap %v/%v",
+			vt, mongodb.Namespace, name,
+		)
+	}
+
+	source := &core.VolumeSource{
+		ConfigMap: &core.ConfigMapVolumeSource{
+			LocalObjectReference: core.LocalObjectReference{
+				Name: name,
+			},
+		},
+	}
+	return source, fmt.Sprintf("%x", sha256.Sum256(restartHash)), nil
+}
+
+// deleteOwnedConfigMap deletes the ConfigMap name, if it is owned by mongodb. ConfigMaps of others are left alone.
+func (c *Controller) deleteOwnedConfigMap(mongodb *api.MongoDB, name string) error {
+	cm, err := c.Client.CoreV1().ConfigMaps(mongodb.Namespace).Get(name, metav1.GetOptions{})
+	if kerr.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !ownedBy(cm.ObjectMeta, mongodb.UID) {
+		return nil
+	}
+	err = c.Client.CoreV1().ConfigMaps(mongodb.Namespace).Delete(name, meta_util.DeleteInBackground())
+	if err != nil && !kerr.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// recordRestartOptionChanges records an event for every option, that takes effect on restart only,
+// changed from old to conf in the configuration of workload name.
+func (c *Controller) recordRestartOptionChanges(mongodb *api.MongoDB, name string, old, conf map[string]interface{}) {
+	oldOptions, options := restartOptions(old), restartOptions(conf)
+	changed := sets.NewString()
+	for option, value := range options {
+		if v, ok := oldOptions[option]; !ok || fmt.Sprint(v) != fmt.Sprint(value) {
+			changed.Insert(option)
+		}
+	}
+	for option := range oldOptions {
+		if _, ok := options[option]; !ok {
+			changed.Insert(option)
+		}
+	}
+	for _, option := range changed.List() {
+		c.recorder.Eventf(
+			mongodb,
+			core.EventTypeNormal,
+			eventer.EventReasonSuccessful,
+			"Option %v of %v changed to %v. It takes effect on restart, restarting the pods of %v",
+			option,
+			name,
+			options[option],
+			name,
+		)
+	}
+}
+
+// readConfigSource reads the configuration file from a ConfigMap or Secret config source.
+func (c *Controller) readConfigSource(namespace string, source *core.VolumeSource) ([]byte, error) {
+	switch {
+	case source == nil:
+		return nil, nil
+	case source.ConfigMap != nil:
+		cm, err := c.Client.CoreV1().ConfigMaps(namespace).Get(source.ConfigMap.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		key := configSourceKey(source.ConfigMap.Items)
+		if data, ok := cm.Data[key]; ok {
+			return []byte(data), nil
+		}
+		if data, ok := cm.BinaryData[key]; ok {
+			return data, nil
+		}
+		return nil, fmt.Errorf("ConfigMap %v/%v has no key %v", namespace, cm.Name, key)
+	case source.Secret != nil:
+		secret, err := c.Client.CoreV1().Secrets(namespace).Get(source.Secret.SecretName, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		key := configSourceKey(source.Secret.Items)
+		if data, ok := secret.Data[key]; ok {
+			return data, nil
+		}
+		return nil, fmt.Errorf("secret %v/%v has no key %v", namespace, secret.Name, key)
+	}
+	return nil, errors.New("configuration can only be merged into a ConfigMap or Secret configSource")
+}
+
+// configSourceKey returns the key projected to mongod.conf.
+func configSourceKey(items []core.KeyToPath) string {
+	for _, item := range items {
+		if item.Path == mongodConfigFileName {
+			return item.Key
+		}
+	}
+	return mongodConfigFileName
+}
+
+// renderConfiguration merges cfg into the configuration file base. Options of cfg take precedence.
+func renderConfiguration(base []byte, cfg *api.MongoDBConfiguration) (map[string]interface{}, error) {
+	conf := map[string]interface{}{}
+	if len(base) > 0 {
+		parsed, err := parseConfig(base)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse configuration file of configSource")
+		}
+		conf = parsed
+	}
+
+	for option, value := range configurationOptions(cfg) {
+		setConfigOption(conf, option, value)
+	}
+	return conf, nil
+}
+
+// configurationOptions flattens cfg to options by their dotted path in the configuration file.
+func configurationOptions(cfg *api.MongoDBConfiguration) map[string]interface{} {
+	options := map[string]interface{}{}
+	for option, value := range cfg.Options {
+		options[option] = parseOptionValue(value)
+	}
+	for name, value := range cfg.SetParameter {
+		options["setParameter."+name] = parseOptionValue(value)
+	}
+	if cfg.Storage != nil && cfg.Storage.WiredTiger != nil && cfg.Storage.WiredTiger.CacheSize != nil {
+		gb := float64(cfg.Storage.WiredTiger.CacheSize.Value()) / (1 << 30)
+		options["storage.wiredTiger.engineConfig.cacheSizeGB"] = math.Round(gb*100) / 100
+	}
+	if cfg.Net != nil && cfg.Net.MaxIncomingConnections != nil {
+		options["net.maxIncomingConnections"] = int(*cfg.Net.MaxIncomingConnections)
+	}
+	if p := cfg.OperationProfiling; p != nil {
+		if p.Mode != "" {
+			options["operationProfiling.mode"] = p.Mode
+		}
+		if p.SlowOpThresholdMs != nil {
+			options["operationProfiling.slowOpThresholdMs"] = int(*p.SlowOpThresholdMs)
+		}
+	}
+	return options
+}
+
+// setConfigOption sets the option at dotted path in conf, creating the intermediate sections.
+func setConfigOption(conf map[string]interface{}, path string, value interface{}) {
+	keys := strings.Split(path, ".")
+	for _, key := range keys[:len(keys)-1] {
+		section, ok := conf[key].(map[string]interface{})
+		if !ok {
+			section = map[string]interface{}{}
+			conf[key] = section
+		}
+		conf = section
+	}
+	conf[keys[len(keys)-1]] = value
+}
+
+// parseOptionValue converts the string value of an option to bool or number, where possible.
+func parseOptionValue(value string) interface{} {
+	if value == "true" || value == "false" {
+		return value == "true"
+	}
+	if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(value, 64); err == nil {
+		return f
+	}
+	return value
+}
+
+// normalizeConfig converts the sections of a parsed configuration file to map[string]interface{}.
+func normalizeConfig(in map[interface{}]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(in))
+	for k, v := range in {
+		if section, ok := v.(map[interface{}]interface{}); ok {
+			v = normalizeConfig(section)
+		}
+		out[fmt.Sprintf("%v", k)] = v
+	}
+	return out
+}
+
+// upsertAnnotation returns a copy of annotations with key set to value, unless value is empty.
+func upsertAnnotation(annotations map[string]string, key, value string) map[string]string {
+	if value == "" {
+		return annotations
+	}
+	out := make(map[string]string, len(annotations)+1)
+	for k, v := range annotations {
+		out[k] = v
+	}
+	out[key] = value
+	return out
+}
@@ -0,0 +1,298 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+	amc "kubedb.dev/apimachinery/pkg/controller"
+)
+
+func TestParseOptionValue(t *testing.T) {
+	cases := []struct {
+		value string
+		want  interface{}
+	}{
+		{"true", true},
+		{"false", false},
+		{"200", int64(200)},
+		{"-1", int64(-1)},
+		{"0.5", 0.5},
+		{"snappy", "snappy"},
+		{"True", "True"},
+		{"", ""},
+	}
+	for _, c := range cases {
+		if got := parseOptionValue(c.value); got != c.want {
+			t.Errorf("expected %q to be parsed to %#v, got %#v", c.value, c.want, got)
+		}
+	}
+}
+
+func TestSetConfigOption(t *testing.T) {
+	conf := map[string]interface{}{
+		"net": map[string]interface{}{
+			"port": 27017,
+		},
+		"security": "invalid",
+	}
+	setConfigOption(conf, "net.maxIncomingConnections", 100)
+	setConfigOption(conf, "storage.wiredTiger.engineConfig.cacheSizeGB", 0.25)
+	setConfigOption(conf, "security.authorization", "enabled")
+	setConfigOption(conf, "quiet", true)
+
+	want := map[string]interface{}{
+		"net": map[string]interface{}{
+			"port":                   27017,
+			"maxIncomingConnections": 100,
+		},
+		"storage": map[string]interface{}{
+			"wiredTiger": map[string]interface{}{
+				"engineConfig": map[string]interface{}{
+					"cacheSizeGB": 0.25,
+				},
+			},
+		},
+		// a value in place of a section is replaced by the section
+		"security": map[string]interface{}{
+			"authorization": "enabled",
+		},
+		"quiet": true,
+	}
+	if !reflect.DeepEqual(conf, want) {
+		t.Errorf("expected %v, got %v", want, conf)
+	}
+}
+
+func TestConfigurationOptions(t *testing.T) {
+	cacheSize := resource.MustParse("1536Mi")
+	connections, slowMs := int32(500), int32(200)
+	cfg := &api.MongoDBConfiguration{
+		Storage: &api.MongoDBStorageConfiguration{
+			WiredTiger: &api.MongoDBWiredTigerConfiguration{CacheSize: &cacheSize},
+		},
+		Net: &api.MongoDBNetConfiguration{MaxIncomingConnections: &connections},
+		OperationProfiling: &api.MongoDBOperationProfilingConfiguration{
+			Mode:              "slowOp",
+			SlowOpThresholdMs: &slowMs,
+		},
+		SetParameter: map[string]string{
+			"cursorTimeoutMillis": "600000",
+		},
+		Options: map[string]string{
+			"net.compression.compressors": "snappy",
+			// typed fields take precedence over options
+			"net.maxIncomingConnections": "100",
+		},
+	}
+	want := map[string]interface{}{
+		"storage.wiredTiger.engineConfig.cacheSizeGB": 1.5,
+		"net.maxIncomingConnections":                  500,
+		"operationProfiling.mode":                     "slowOp",
+		"operationProfiling.slowOpThresholdMs":        200,
+		"setParameter.cursorTimeoutMillis":            int64(600000),
+		"net.compression.compressors":                 "snappy",
+	}
+	if got := configurationOptions(cfg); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestRenderConfiguration(t *testing.T) {
+	base := []byte(`
+net:
+  port: 27017
+  maxIncomingConnections: 100
+storage:
+  dbPath: /data/db
+`)
+	connections := int32(500)
+	cfg := &api.MongoDBConfiguration{
+		Net:          &api.MongoDBNetConfiguration{MaxIncomingConnections: &connections},
+		SetParameter: map[string]string{"cursorTimeoutMillis": "600000"},
+	}
+	conf, err := renderConfiguration(base, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"net.port":                         27017,
+		"net.maxIncomingConnections":       500,
+		"storage.dbPath":                   "/data/db",
+		"setParameter.cursorTimeoutMillis": int64(600000),
+	}
+	if got := flattenConfig(conf); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	if _, err := renderConfiguration([]byte("net: [port"), cfg); err == nil {
+		t.Error("expected an invalid configuration file to be rejected")
+	}
+}
+
+func TestEnsureConfigurationMergesConfigSource(t *testing.T) {
+	mongodb := &api.MongoDB{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       api.ResourceKindMongoDB,
+			APIVersion: api.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "mg",
+			Namespace: "demo",
+			UID:       "mg-uid",
+		},
+	}
+	custom := &core.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "mg-custom-config",
+			Namespace: "demo",
+		},
+		Data: map[string]string{
+			"custom.conf": "net:\n  maxIncomingConnections: 100\nstorage:\n  journal:\n    enabled: true\n",
+		},
+	}
+	c := &Controller{
+		Controller: &amc.Controller{Client: fake.NewSimpleClientset(custom)},
+		recorder:   record.NewFakeRecorder(10),
+	}
+
+	connections := int32(500)
+	opts := workloadOptions{
+		stsName: mongodb.OffshootName(),
+		configSource: &core.VolumeSource{
+			ConfigMap: &core.ConfigMapVolumeSource{
+				LocalObjectReference: core.LocalObjectReference{Name: custom.Name},
+				Items:                []core.KeyToPath{{Key: "custom.conf", Path: mongodConfigFileName}},
+			},
+		},
+		configuration: &api.MongoDBConfiguration{
+			Net: &api.MongoDBNetConfiguration{MaxIncomingConnections: &connections},
+		},
+	}
+	source, hash, err := c.ensureConfiguration(mongodb, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source.ConfigMap == nil || source.ConfigMap.Name != configMapName(opts.stsName) {
+		t.Fatalf("expected the rendered ConfigMap to be mounted, got %+v", source)
+	}
+	if hash == "" {
+		t.Error("expected the hash of the options that take effect on restart")
+	}
+
+	cm, err := c.Client.CoreV1().ConfigMaps("demo").Get(source.ConfigMap.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rendered, err := parseConfig([]byte(cm.Data[mongodConfigFileName]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"net.maxIncomingConnections": 500,
+		"storage.journal.enabled":    true,
+	}
+	if got := flattenConfig(rendered); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	// without configuration, the config source is mounted as it is
+	opts.configuration = nil
+	source, hash, err = c.ensureConfiguration(mongodb, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source != opts.configSource || hash != "" {
+		t.Errorf("expected the config source to be used as it is, got %+v", source)
+	}
+	if _, err := c.Client.CoreV1().ConfigMaps("demo").Get(configMapName(opts.stsName), metav1.GetOptions{}); err == nil {
+		t.Error("expected the rendered ConfigMap to be deleted")
+	}
+}
+
+func TestEnsureConfigurationKeepsForeignConfigMaps(t *testing.T) {
+	mongodb := &api.MongoDB{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       api.ResourceKindMongoDB,
+			APIVersion: api.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "mg",
+			Namespace: "demo",
+			UID:       "mg-uid",
+		},
+	}
+	userConfig := "net:\n  maxIncomingConnections: 100\n"
+	configMap := func(name string) *core.ConfigMap {
+		return &core.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "demo",
+			},
+			Data: map[string]string{mongodConfigFileName: userConfig},
+		}
+	}
+	// the configSource of the user, named as the ConfigMap of the rendered configuration used to be
+	custom := configMap("mg-config")
+	c := &Controller{
+		Controller: &amc.Controller{Client: fake.NewSimpleClientset(custom, configMap(configMapName("mg")))},
+		recorder:   record.NewFakeRecorder(10),
+	}
+	opts := workloadOptions{
+		stsName: mongodb.OffshootName(),
+		configSource: &core.VolumeSource{
+			ConfigMap: &core.ConfigMapVolumeSource{LocalObjectReference: core.LocalObjectReference{Name: custom.Name}},
+		},
+	}
+	unchanged := func(name string) {
+		cm, err := c.Client.CoreV1().ConfigMaps("demo").Get(name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("expected ConfigMap %v to be kept, got %v", name, err)
+		}
+		if cm.Data[mongodConfigFileName] != userConfig {
+			t.Errorf("expected ConfigMap %v to be unchanged, got %v", name, cm.Data)
+		}
+	}
+
+	// without configuration, ConfigMaps that MongoDB doesn't own are not deleted
+	if source, _, err := c.ensureConfiguration(mongodb, opts); err != nil || source != opts.configSource {
+		t.Fatalf("expected the config source to be used as it is, got %+v, %v", source, err)
+	}
+	unchanged(custom.Name)
+	unchanged(configMapName("mg"))
+
+	// nor are they adopted to render the configuration into
+	connections := int32(500)
+	opts.configuration = &api.MongoDBConfiguration{
+		Net: &api.MongoDBNetConfiguration{MaxIncomingConnections: &connections},
+	}
+	if _, _, err := c.ensureConfiguration(mongodb, opts); err == nil {
+		t.Error("expected a ConfigMap that MongoDB doesn't own to be refused")
+	}
+	unchanged(custom.Name)
+	unchanged(configMapName("mg"))
+
+	// a config source can't be the ConfigMap that it is rendered into
+	if err := c.Client.CoreV1().ConfigMaps("demo").Delete(configMapName("mg"), nil); err != nil {
+		t.Fatal(err)
+	}
+	opts.configSource.ConfigMap.Name = configMapName("mg")
+	if _, _, err := c.ensureConfiguration(mongodb, opts); err == nil {
+		t.Error("expected the rendered ConfigMap to be refused as the config source")
+	}
+
+	opts.configSource.ConfigMap.Name = custom.Name
+	source, _, err := c.ensureConfiguration(mongodb, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source.ConfigMap == nil || source.ConfigMap.Name != configMapName("mg") {
+		t.Errorf("expected the rendered ConfigMap to be mounted, got %+v", source)
+	}
+	unchanged(custom.Name)
+}
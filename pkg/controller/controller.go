@@ -1,6 +1,8 @@
 package controller
 
 import (
+	"time"
+
 	"github.com/appscode/go/encoding/json/types"
 	"github.com/appscode/go/log"
 	pcm "github.com/coreos/prometheus-operator/pkg/client/versioned/typed/monitoring/v1"
@@ -14,6 +16,7 @@ import (
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
 	reg_util "kmodules.xyz/client-go/admissionregistration/v1beta1"
 	apiext_util "kmodules.xyz/client-go/apiextensions/v1beta1"
 	meta_util "kmodules.xyz/client-go/meta"
@@ -51,6 +54,34 @@ type Controller struct {
 	mgQueue    *queue.Worker
 	mgInformer cache.SharedIndexInformer
 	mgLister   api_listers.MongoDBLister
+	// backoff for requeueing MongoDB until its workloads are ready
+	mgReadinessBackoff workqueue.RateLimiter
+
+	// MongoDBUser
+	mgUserQueue    *queue.Worker
+	mgUserInformer cache.SharedIndexInformer
+	mgUserLister   api_listers.MongoDBUserLister
+
+	// MongoDBRole
+	mgRoleQueue    *queue.Worker
+	mgRoleInformer cache.SharedIndexInformer
+	mgRoleLister   api_listers.MongoDBRoleLister
+
+	// MongoDBRestore
+	mgRestoreQueue    *queue.Worker
+	mgRestoreInformer cache.SharedIndexInformer
+	mgRestoreLister   api_listers.MongoDBRestoreLister
+
+	// how often the members of MongoDB are probed. Zero disables the prober.
+	healthCheckInterval time.Duration
+	// how long before expiry the certificates of MongoDB are renewed. Zero disables the renewal.
+	certRenewBefore time.Duration
+	// secret in the operator namespace with the CA of the issuers of kind OperatorCA.
+	caSecret string
+	// image of the pods that archive and replay the oplog.
+	oplogArchiverImage string
+	// image of the sidecar that ships the slow operations.
+	slowQueryLogImage string
 }
 
 var _ amc.Snapshotter = &Controller{}
@@ -89,11 +120,14 @@ func New(
 	}
 }
 
-// EnsureCustomResourceDefinitions ensures CRD for MongoDB, DormantDatabase and Snapshot
+// EnsureCustomResourceDefinitions ensures CRD for MongoDB, MongoDBUser, MongoDBRole, MongoDBRestore, DormantDatabase and Snapshot
 func (c *Controller) EnsureCustomResourceDefinitions() error {
 	log.Infoln("Ensuring CustomResourceDefinition...")
 	crds := []*crd_api.CustomResourceDefinition{
 		api.MongoDB{}.CustomResourceDefinition(),
+		api.MongoDBUser{}.CustomResourceDefinition(),
+		api.MongoDBRole{}.CustomResourceDefinition(),
+		api.MongoDBRestore{}.CustomResourceDefinition(),
 		catlog.MongoDBVersion{}.CustomResourceDefinition(),
 		api.DormantDatabase{}.CustomResourceDefinition(),
 		api.Snapshot{}.CustomResourceDefinition(),
@@ -119,9 +153,16 @@ func (c *Controller) RunControllers(stopCh <-chan struct{}) {
 
 	// Watch x  TPR objects
 	c.mgQueue.Run(stopCh)
+	c.mgRoleQueue.Run(stopCh)
+	c.mgUserQueue.Run(stopCh)
+	c.mgRestoreQueue.Run(stopCh)
 	c.DrmnQueue.Run(stopCh)
 	c.SnapQueue.Run(stopCh)
 	c.JobQueue.Run(stopCh)
+
+	go c.runHealthProber(stopCh)
+	go c.runSnapshotPruner(stopCh)
+	go c.runSnapshotVerifier(stopCh)
 }
 
 // Blocks caller. Intended to be called as a Go routine.
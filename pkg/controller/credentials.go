@@ -0,0 +1,475 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+	core "k8s.io/api/core/v1"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	core_util "kmodules.xyz/client-go/core/v1"
+	"kubedb.dev/apimachinery/apis"
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+	"kubedb.dev/apimachinery/client/clientset/versioned/typed/kubedb/v1alpha1/util"
+	"kubedb.dev/apimachinery/pkg/eventer"
+	validator "kubedb.dev/mongodb/pkg/admission"
+	"kubedb.dev/mongodb/pkg/dbclient"
+)
+
+const (
+	// credentialRevisionAnnotation is bumped on spec.databaseSecret every time the operator rotates the root password.
+	// It is copied to the pod templates of the oplog archivers, so that they are restarted with the new password,
+	// and to the AppBinding, so that its consumers reload the secret.
+	credentialRevisionAnnotation = api.MongoDBKey + "/credential-revision"
+
+	// keyNextPassword holds the new root password in spec.databaseSecret, until it is changed in every server.
+	keyNextPassword = "next-password"
+
+	// rootUserDatabase is the database of the root user.
+	rootUserDatabase = "admin"
+)
+
+// rotateCredentials rotates the root password and the keyfile, when requested by MongoDBRotateCredentialsAnnotation
+// or when due by spec.credentialRotation.
+//
+// The root password is changed in the servers first, then in spec.databaseSecret. The keyfile is rotated in two
+// phases, each rolled out to every pod before the next one starts: the new key is added next to the old key,
+// then the old key is retired.
+//
+// A credentialRotationError is returned while the pods are restarted to load the rotated credentials.
+func (c *Controller) rotateCredentials(mongodb *api.MongoDB) error {
+	if isUpgradeRunning(mongodb) {
+		return nil
+	}
+	requested := requestedCredentials(mongodb)
+	status := mongodb.Status.CredentialRotation
+	if status == nil {
+		status = &api.MongoDBCredentialRotationStatus{}
+	}
+
+	if requested.Has(api.MongoDBCredentialRootPassword) || rootPasswordRotationDue(mongodb) {
+		if err := c.rotateRootPassword(mongodb); err != nil {
+			return err
+		}
+	}
+	if mongodb.Spec.CertificateSecret != nil &&
+		(status.KeyFilePhase != "" || requested.Has(api.MongoDBCredentialKeyFile) || keyFileRotationDue(mongodb)) {
+		if err := c.rotateKeyFile(mongodb); err != nil {
+			return err
+		}
+	}
+
+	if unknown := requested.Difference(sets.NewString(api.MongoDBCredentialRootPassword, api.MongoDBCredentialKeyFile)); unknown.Len() > 0 {
+		c.recorder.Eventf(
+			mongodb,
+			core.EventTypeWarning,
+			eventer.EventReasonFailedToUpdate,
+			"Ignored unknown credentials %v in annotation %v",
+			unknown.List(),
+			api.MongoDBRotateCredentialsAnnotation,
+		)
+		return c.dropRotationRequest(mongodb, unknown.List()...)
+	}
+	return nil
+}
+
+// rotateRootPassword changes the root password in every server of mongodb, then stores it in spec.databaseSecret.
+// The new password is kept in spec.databaseSecret until then, so that an interrupted rotation resumes with it.
+func (c *Controller) rotateRootPassword(mongodb *api.MongoDB) error {
+	secret, err := c.Client.CoreV1().Secrets(mongodb.Namespace).Get(mongodb.Spec.DatabaseSecret.SecretName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	next := string(secret.Data[keyNextPassword])
+	if next == "" {
+		next = generatePassword()
+		secret, _, err = core_util.PatchSecret(c.Client, secret, func(in *core.Secret) *core.Secret {
+			if in.Data == nil {
+				in.Data = map[string][]byte{}
+			}
+			in.Data[keyNextPassword] = []byte(next)
+			return in
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), membershipTimeout)
+	defer cancel()
+	for _, target := range rootPasswordTargets(mongodb) {
+		if err := c.changeRootPassword(ctx, mongodb, target, next); err != nil {
+			return c.recordCredentialRotationFailure(mongodb, api.MongoDBCredentialRootPassword, err)
+		}
+	}
+
+	_, _, err = core_util.PatchSecret(c.Client, secret, func(in *core.Secret) *core.Secret {
+		in.Data[KeyMongoDBPassword] = []byte(next)
+		delete(in.Data, keyNextPassword)
+		revision, _ := strconv.Atoi(in.Annotations[credentialRevisionAnnotation])
+		if in.Annotations == nil {
+			in.Annotations = map[string]string{}
+		}
+		in.Annotations[credentialRevisionAnnotation] = strconv.Itoa(revision + 1)
+		return in
+	})
+	if err != nil {
+		return c.recordCredentialRotationFailure(mongodb, api.MongoDBCredentialRootPassword, err)
+	}
+	now := metav1.Now()
+	if err := c.updateCredentialRotation(mongodb, func(in *api.MongoDBCredentialRotationStatus) {
+		in.RootPasswordRotationTime = &now
+	}); err != nil {
+		return err
+	}
+	if err := c.dropRotationRequest(mongodb, api.MongoDBCredentialRootPassword); err != nil {
+		return err
+	}
+	c.recorder.Eventf(
+		mongodb,
+		core.EventTypeNormal,
+		eventer.EventReasonSuccessful,
+		"Rotated root password of secret %v",
+		secret.Name,
+	)
+	return nil
+}
+
+// rootPasswordTarget is a server, or a replicaset, that keeps its own copy of the root user.
+type rootPasswordTarget struct {
+	hosts      []string
+	replicaSet string
+	direct     bool
+	// optional targets may not have the root user, ie, the shards of a sharded cluster.
+	optional bool
+}
+
+// rootPasswordTargets returns the servers to change the root password in. The root user of a sharded cluster is
+// stored in the config servers, and the shards have their own root user, used to connect to the shards directly.
+func rootPasswordTargets(mongodb *api.MongoDB) []rootPasswordTarget {
+	if mongodb.Spec.ShardTopology != nil {
+		targets := []rootPasswordTarget{{
+			hosts: []string{fmt.Sprintf("%v.%v.svc:%v", mongodb.ServiceName(), mongodb.Namespace, api.MongoDBMongosPort)},
+		}}
+		for _, component := range desiredComponents(mongodb) {
+			if component.status.Type == api.MongoDBComponentShard {
+				targets = append(targets, rootPasswordTarget{
+					hosts:      stsMemberHosts(mongodb, component.status.Name, component.status.Replicas),
+					replicaSet: component.status.ReplicaSetName,
+					optional:   true,
+				})
+			}
+		}
+		return targets
+	}
+	component := desiredComponents(mongodb)[0].status
+	if component.Type == api.MongoDBComponentReplicaSet {
+		return []rootPasswordTarget{{
+			hosts:      stsMemberHosts(mongodb, component.Name, component.Replicas),
+			replicaSet: component.ReplicaSetName,
+		}}
+	}
+	return []rootPasswordTarget{{hosts: stsMemberHosts(mongodb, component.Name, 1), direct: true}}
+}
+
+// changeRootPassword changes the password of the root user in target to next. A target that already
+// accepts next is left as is, as it was changed by an interrupted rotation.
+func (c *Controller) changeRootPassword(ctx context.Context, mongodb *api.MongoDB, target rootPasswordTarget, next string) error {
+	cfg, err := c.dbClientConfig(mongodb)
+	if err != nil {
+		return err
+	}
+	cfg.Hosts = target.hosts
+	cfg.ReplicaSet = target.replicaSet
+	cfg.Direct = target.direct
+
+	client, err := dbclient.New(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	err = client.ChangeUserPassword(ctx, rootUserDatabase, cfg.Username, next)
+	client.Close()
+	if err == nil || (target.optional && dbclient.IsUserNotFound(err)) {
+		return nil
+	}
+
+	cfg.Password = next
+	client, cerr := dbclient.New(ctx, cfg)
+	if cerr != nil {
+		return err
+	}
+	defer client.Close()
+	if _, cerr := client.UserExists(ctx, rootUserDatabase, cfg.Username); cerr == nil {
+		return nil
+	}
+	return err
+}
+
+// rotateKeyFile rolls a new key of the keyfile out to every pod. The keyfile holds both the old and the new key
+// until every pod accepts the new key, so that the members keep authenticating to each other during the restarts.
+// ref: https://docs.mongodb.com/manual/tutorial/rotate-key-replica-set/
+func (c *Controller) rotateKeyFile(mongodb *api.MongoDB) error {
+	secret, err := c.Client.CoreV1().Secrets(mongodb.Namespace).Get(mongodb.Spec.CertificateSecret.SecretName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	var phase api.MongoDBKeyFileRotationPhase
+	if mongodb.Status.CredentialRotation != nil {
+		phase = mongodb.Status.CredentialRotation.KeyFilePhase
+	}
+	if phase == "" {
+		return c.startKeyFileRotation(mongodb, secret)
+	}
+
+	rolledOut, err := c.certificatesRolledOut(mongodb, secret.Annotations[certRevisionAnnotation])
+	if err != nil {
+		return err
+	}
+	if !rolledOut {
+		return &credentialRotationError{secret: secret.Name, phase: string(phase)}
+	}
+
+	switch phase {
+	case api.MongoDBKeyFileRotationAddNewKey:
+		keys, err := keyFileKeys(secret.Data[KeyForKeyFile])
+		if err != nil {
+			return err
+		}
+		if _, err := c.patchCertSecret(secret, setSecretData(map[string][]byte{
+			KeyForKeyFile: encodeKeyFile(keys[len(keys)-1:]...),
+		})); err != nil {
+			return c.recordCredentialRotationFailure(mongodb, api.MongoDBCredentialKeyFile, err)
+		}
+		if err := c.updateCredentialRotation(mongodb, func(in *api.MongoDBCredentialRotationStatus) {
+			in.KeyFilePhase = api.MongoDBKeyFileRotationRetireOldKey
+		}); err != nil {
+			return err
+		}
+		return &credentialRotationError{secret: secret.Name, phase: string(api.MongoDBKeyFileRotationRetireOldKey)}
+	default:
+		now := metav1.Now()
+		if err := c.updateCredentialRotation(mongodb, func(in *api.MongoDBCredentialRotationStatus) {
+			in.KeyFilePhase = ""
+			in.KeyFileRotationTime = &now
+		}); err != nil {
+			return err
+		}
+		c.recorder.Eventf(
+			mongodb,
+			core.EventTypeNormal,
+			eventer.EventReasonSuccessful,
+			"Rotated keyfile of secret %v",
+			secret.Name,
+		)
+		return nil
+	}
+}
+
+// startKeyFileRotation adds a new key to the keyfile, after the key in use.
+func (c *Controller) startKeyFileRotation(mongodb *api.MongoDB, secret *core.Secret) error {
+	if mongodb.Status.CertificateRotation != nil {
+		// the keyfile is rotated once the CA rotation is done, as both restart the pods.
+		return nil
+	}
+	if err := c.keyFileRotatable(mongodb, secret); err != nil {
+		c.recorder.Eventf(
+			mongodb,
+			core.EventTypeWarning,
+			eventer.EventReasonFailedToUpdate,
+			"Skipped rotating keyfile. Reason: %v",
+			err,
+		)
+		return c.dropRotationRequest(mongodb, api.MongoDBCredentialKeyFile)
+	}
+
+	keys, err := keyFileKeys(secret.Data[KeyForKeyFile])
+	if err != nil {
+		return err
+	}
+	if _, err := c.patchCertSecret(secret, setSecretData(map[string][]byte{
+		KeyForKeyFile: encodeKeyFile(keys[len(keys)-1], newKeyFileKey()),
+	})); err != nil {
+		return c.recordCredentialRotationFailure(mongodb, api.MongoDBCredentialKeyFile, err)
+	}
+	if err := c.updateCredentialRotation(mongodb, func(in *api.MongoDBCredentialRotationStatus) {
+		in.KeyFilePhase = api.MongoDBKeyFileRotationAddNewKey
+	}); err != nil {
+		return err
+	}
+	if err := c.dropRotationRequest(mongodb, api.MongoDBCredentialKeyFile); err != nil {
+		return err
+	}
+	c.recorder.Eventf(
+		mongodb,
+		core.EventTypeNormal,
+		eventer.EventReasonSuccessful,
+		"Started rotating keyfile of secret %v, restarting the pods to accept the new key",
+		secret.Name,
+	)
+	return &credentialRotationError{secret: secret.Name, phase: string(api.MongoDBKeyFileRotationAddNewKey)}
+}
+
+// keyFileRotatable returns why the keyfile of mongodb can't be rotated, if so.
+func (c *Controller) keyFileRotatable(mongodb *api.MongoDB, secret *core.Secret) error {
+	if mongodb.Spec.ReplicaSet == nil && mongodb.Spec.ShardTopology == nil {
+		return errors.New("standalone MongoDB does not use a keyfile")
+	}
+	if !isManagedCertSecret(mongodb, secret) {
+		return errors.Errorf("secret %v is not managed by the operator", secret.Name)
+	}
+	series, err := c.releaseSeries(string(mongodb.Spec.Version))
+	if err != nil {
+		return err
+	}
+	if series.Less(validator.MultiKeyFileRelease) {
+		return errors.Errorf("MongoDB %v does not accept more than one key in keyfile, %v or later is required", series, validator.MultiKeyFileRelease)
+	}
+	return nil
+}
+
+// keyFileKeys returns the keys of a keyfile, that is either a single key or a YAML sequence of keys.
+func keyFileKeys(data []byte) ([]string, error) {
+	content := strings.TrimSpace(string(data))
+	if !strings.HasPrefix(content, "-") {
+		return []string{content}, nil
+	}
+	var keys []string
+	if err := yaml.Unmarshal(data, &keys); err != nil {
+		return nil, errors.Wrap(err, "failed to parse keyfile")
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("keyfile has no key")
+	}
+	return keys, nil
+}
+
+// encodeKeyFile returns a keyfile with keys. More than one key is written as a YAML sequence.
+func encodeKeyFile(keys ...string) []byte {
+	if len(keys) == 1 {
+		return []byte(keys[0])
+	}
+	var b strings.Builder
+	for _, key := range keys {
+		b.WriteString("- " + key + "\n")
+	}
+	return []byte(b.String())
+}
+
+// requestedCredentials returns the credentials listed in MongoDBRotateCredentialsAnnotation.
+func requestedCredentials(mongodb *api.MongoDB) sets.String {
+	requested := sets.NewString()
+	for _, credential := range strings.Split(mongodb.Annotations[api.MongoDBRotateCredentialsAnnotation], ",") {
+		if credential = strings.TrimSpace(credential); credential != "" {
+			requested.Insert(credential)
+		}
+	}
+	return requested
+}
+
+// dropRotationRequest removes credentials from MongoDBRotateCredentialsAnnotation, and the annotation once empty.
+func (c *Controller) dropRotationRequest(mongodb *api.MongoDB, credentials ...string) error {
+	requested := requestedCredentials(mongodb)
+	if !requested.HasAny(credentials...) {
+		return nil
+	}
+	remaining := requested.Difference(sets.NewString(credentials...))
+	mg, _, err := util.PatchMongoDB(c.ExtClient.KubedbV1alpha1(), mongodb, func(in *api.MongoDB) *api.MongoDB {
+		if remaining.Len() == 0 {
+			delete(in.Annotations, api.MongoDBRotateCredentialsAnnotation)
+		} else {
+			in.Annotations[api.MongoDBRotateCredentialsAnnotation] = strings.Join(remaining.List(), ",")
+		}
+		return in
+	})
+	if err != nil {
+		return err
+	}
+	mongodb.ObjectMeta = mg.ObjectMeta
+	return nil
+}
+
+// rootPasswordRotationDue reports whether the root password is due by spec.credentialRotation.rootPasswordInterval.
+func rootPasswordRotationDue(mongodb *api.MongoDB) bool {
+	if mongodb.Spec.CredentialRotation == nil {
+		return false
+	}
+	var last *metav1.Time
+	if status := mongodb.Status.CredentialRotation; status != nil {
+		last = status.RootPasswordRotationTime
+	}
+	return rotationDue(mongodb, mongodb.Spec.CredentialRotation.RootPasswordInterval, last)
+}
+
+// keyFileRotationDue reports whether the keyfile is due by spec.credentialRotation.keyFileInterval.
+func keyFileRotationDue(mongodb *api.MongoDB) bool {
+	if mongodb.Spec.CredentialRotation == nil {
+		return false
+	}
+	var last *metav1.Time
+	if status := mongodb.Status.CredentialRotation; status != nil {
+		last = status.KeyFileRotationTime
+	}
+	return rotationDue(mongodb, mongodb.Spec.CredentialRotation.KeyFileInterval, last)
+}
+
+// rotationDue reports whether interval has passed since the last rotation, or since mongodb was created.
+func rotationDue(mongodb *api.MongoDB, interval *metav1.Duration, last *metav1.Time) bool {
+	if interval == nil || interval.Duration <= 0 {
+		return false
+	}
+	since := mongodb.CreationTimestamp
+	if last != nil {
+		since = *last
+	}
+	return !time.Now().Before(since.Add(interval.Duration))
+}
+
+// credentialRevision returns the credentialRevisionAnnotation of spec.databaseSecret, if any.
+func (c *Controller) credentialRevision(mongodb *api.MongoDB) (string, error) {
+	if mongodb.Spec.DatabaseSecret == nil {
+		return "", nil
+	}
+	secret, err := c.Client.CoreV1().Secrets(mongodb.Namespace).Get(mongodb.Spec.DatabaseSecret.SecretName, metav1.GetOptions{})
+	if err != nil {
+		if kerr.IsNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	return secret.Annotations[credentialRevisionAnnotation], nil
+}
+
+func (c *Controller) recordCredentialRotationFailure(mongodb *api.MongoDB, credential string, err error) error {
+	c.recorder.Eventf(
+		mongodb,
+		core.EventTypeWarning,
+		eventer.EventReasonFailedToUpdate,
+		"Failed to rotate %v. Reason: %v",
+		credential,
+		err,
+	)
+	return err
+}
+
+// updateCredentialRotation records the state of credential rotation in MongoDB status.
+func (c *Controller) updateCredentialRotation(mongodb *api.MongoDB, transform func(in *api.MongoDBCredentialRotationStatus)) error {
+	mg, err := util.UpdateMongoDBStatus(c.ExtClient.KubedbV1alpha1(), mongodb, func(in *api.MongoDBStatus) *api.MongoDBStatus {
+		if in.CredentialRotation == nil {
+			in.CredentialRotation = &api.MongoDBCredentialRotationStatus{}
+		}
+		transform(in.CredentialRotation)
+		return in
+	}, apis.EnableStatusSubresource)
+	if err != nil {
+		return err
+	}
+	mongodb.Status = mg.Status
+	return nil
+}
@@ -0,0 +1,53 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+)
+
+func TestKeyFileKeys(t *testing.T) {
+	old, next := newKeyFileKey(), newKeyFileKey()
+
+	keys, err := keyFileKeys([]byte(old + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(keys, []string{old}) {
+		t.Errorf("expected the single key, got %v", keys)
+	}
+
+	keys, err = keyFileKeys(encodeKeyFile(old, next))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(keys, []string{old, next}) {
+		t.Errorf("expected the old and the new key, got %v", keys)
+	}
+	if data := encodeKeyFile(keys[len(keys)-1:]...); string(data) != next {
+		t.Errorf("expected the new key only, got %s", data)
+	}
+}
+
+func TestRotationDue(t *testing.T) {
+	mongodb := &api.MongoDB{
+		ObjectMeta: metav1.ObjectMeta{
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-2 * time.Hour)),
+		},
+	}
+	hour := &metav1.Duration{Duration: time.Hour}
+	recent := metav1.NewTime(time.Now().Add(-time.Minute))
+
+	if rotationDue(mongodb, nil, nil) {
+		t.Error("rotation without interval should never be due")
+	}
+	if !rotationDue(mongodb, hour, nil) {
+		t.Error("rotation should be due an interval after creation")
+	}
+	if rotationDue(mongodb, hour, &recent) {
+		t.Error("rotation should not be due within an interval of the last rotation")
+	}
+}
@@ -0,0 +1,315 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	core "k8s.io/api/core/v1"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientsetscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/reference"
+	kutil "kmodules.xyz/client-go"
+	core_util "kmodules.xyz/client-go/core/v1"
+	meta_util "kmodules.xyz/client-go/meta"
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+	"kubedb.dev/apimachinery/pkg/eventer"
+)
+
+const (
+	// grafanaDashboardLabelKey is the label the dashboard sidecar of Grafana selects its ConfigMaps by, by default.
+	grafanaDashboardLabelKey = "grafana_dashboard"
+
+	// dashboardRateInterval is the range of the rates in the dashboards, that spans a few scrapes.
+	dashboardRateInterval = "5m"
+)
+
+// dashboardConfigMapName returns the name of the ConfigMap that holds the dashboards of mongodb.
+func dashboardConfigMapName(mongodb *api.MongoDB) string {
+	return mongodb.OffshootName() + "-dashboard"
+}
+
+// dashboardFileName returns the file name of the dashboard of mongodb. The dashboard sidecar writes the files of
+// every ConfigMap into the same folder, so the name is unique across namespaces.
+func dashboardFileName(mongodb *api.MongoDB) string {
+	return fmt.Sprintf("kubedb-%s-%s.json", mongodb.Namespace, mongodb.Name)
+}
+
+// manageDashboards creates or updates the ConfigMap of the Grafana dashboards of mongodb, as given by
+// spec.dashboards, or deletes it once spec.dashboards or spec.monitor is removed.
+func (c *Controller) manageDashboards(mongodb *api.MongoDB) error {
+	name := dashboardConfigMapName(mongodb)
+	if mongodb.Spec.Dashboards == nil || mongodb.Spec.Monitor == nil {
+		err := c.Client.CoreV1().ConfigMaps(mongodb.Namespace).Delete(name, meta_util.DeleteInBackground())
+		if err != nil && !kerr.IsNotFound(err) {
+			return err
+		}
+		return nil
+	}
+
+	dashboard, err := json.MarshalIndent(mongoDBDashboard(mongodb), "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to render the dashboards")
+	}
+	labels := mongodb.Spec.Dashboards.Labels
+	if len(labels) == 0 {
+		labels = map[string]string{grafanaDashboardLabelKey: "1"}
+	}
+
+	ref, err := reference.GetReference(clientsetscheme.Scheme, mongodb)
+	if err != nil {
+		return err
+	}
+	_, vt, err := core_util.CreateOrPatchConfigMap(c.Client, metav1.ObjectMeta{
+		Name:      name,
+		Namespace: mongodb.Namespace,
+	}, func(in *core.ConfigMap) *core.ConfigMap {
+		in.Labels = core_util.UpsertMap(mongodb.OffshootSelectors(), labels)
+		in.Annotations = core_util.UpsertMap(in.Annotations, mongodb.Spec.Dashboards.Annotations)
+		core_util.EnsureOwnerReference(&in.ObjectMeta, ref)
+		in.Data = map[string]string{
+			dashboardFileName(mongodb): string(dashboard),
+		}
+		return in
+	})
+	if err != nil {
+		return err
+	}
+	if vt != kutil.VerbUnchanged {
+		c.recorder.Eventf(
+			mongodb,
+			core.EventTypeNormal,
+			eventer.EventReasonSuccessful,
+			"Successfully %v dashboard ConfigMap %v/%v",
+			vt, mongodb.Namespace, name,
+		)
+	}
+	return nil
+}
+
+// grafanaDashboard is the JSON model of a Grafana dashboard, with only the fields the dashboards set.
+type grafanaDashboard struct {
+	UID           string            `json:"uid"`
+	Title         string            `json:"title"`
+	Tags          []string          `json:"tags"`
+	Editable      bool              `json:"editable"`
+	SchemaVersion int               `json:"schemaVersion"`
+	Refresh       string            `json:"refresh"`
+	Time          grafanaTimeRange  `json:"time"`
+	Templating    grafanaTemplating `json:"templating"`
+	Panels        []grafanaPanel    `json:"panels"`
+}
+
+type grafanaTimeRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type grafanaTemplating struct {
+	List []grafanaVariable `json:"list"`
+}
+
+type grafanaVariable struct {
+	Name  string `json:"name"`
+	Label string `json:"label"`
+	Type  string `json:"type"`
+	Query string `json:"query"`
+}
+
+type grafanaPanel struct {
+	ID         int             `json:"id"`
+	Type       string          `json:"type"`
+	Title      string          `json:"title"`
+	GridPos    grafanaGridPos  `json:"gridPos"`
+	Datasource string          `json:"datasource,omitempty"`
+	Targets    []grafanaTarget `json:"targets,omitempty"`
+	Format     string          `json:"format,omitempty"`
+	YAxes      []grafanaYAxis  `json:"yaxes,omitempty"`
+	Lines      bool            `json:"lines,omitempty"`
+	LineWidth  int             `json:"linewidth,omitempty"`
+	Fill       int             `json:"fill,omitempty"`
+}
+
+type grafanaGridPos struct {
+	H int `json:"h"`
+	W int `json:"w"`
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type grafanaTarget struct {
+	Expr         string `json:"expr"`
+	LegendFormat string `json:"legendFormat,omitempty"`
+	RefID        string `json:"refId"`
+}
+
+type grafanaYAxis struct {
+	Format string `json:"format"`
+	Show   bool   `json:"show"`
+	Min    *int   `json:"min"`
+}
+
+const (
+	dashboardDatasource = "$datasource"
+	dashboardWidth      = 24
+	panelWidth          = 12
+	panelHeight         = 8
+	statWidth           = 6
+	statHeight          = 4
+)
+
+// dashboardBuilder lays the panels of a dashboard out, a row at a time.
+type dashboardBuilder struct {
+	panels []grafanaPanel
+	// x and y are the position of the next panel, and height the height of the current line of panels.
+	x, y, height int
+}
+
+func (b *dashboardBuilder) add(p grafanaPanel, w, h int) {
+	if b.x+w > dashboardWidth {
+		b.x, b.y, b.height = 0, b.y+b.height, 0
+	}
+	p.ID = len(b.panels) + 1
+	p.GridPos = grafanaGridPos{H: h, W: w, X: b.x, Y: b.y}
+	b.panels = append(b.panels, p)
+	b.x += w
+	if h > b.height {
+		b.height = h
+	}
+}
+
+// row starts a new row of panels.
+func (b *dashboardBuilder) row(title string) {
+	b.x = dashboardWidth
+	b.add(grafanaPanel{Type: "row", Title: title}, dashboardWidth, 1)
+}
+
+// stat adds a single value of expr.
+func (b *dashboardBuilder) stat(title, format, expr string) {
+	b.add(grafanaPanel{
+		Type:       "singlestat",
+		Title:      title,
+		Datasource: dashboardDatasource,
+		Format:     format,
+		Targets:    []grafanaTarget{{Expr: expr, RefID: "A"}},
+	}, statWidth, statHeight)
+}
+
+// graph adds a graph of the series of exprs, each a query and its legend.
+func (b *dashboardBuilder) graph(title, format string, exprs ...string) {
+	var targets []grafanaTarget
+	for i := 0; i+1 < len(exprs); i += 2 {
+		targets = append(targets, grafanaTarget{
+			Expr:         exprs[i],
+			LegendFormat: exprs[i+1],
+			RefID:        string(rune('A' + i/2)),
+		})
+	}
+	zero := 0
+	b.add(grafanaPanel{
+		Type:       "graph",
+		Title:      title,
+		Datasource: dashboardDatasource,
+		Targets:    targets,
+		YAxes:      []grafanaYAxis{{Format: format, Show: true, Min: &zero}, {Format: "short"}},
+		Lines:      true,
+		LineWidth:  1,
+		Fill:       1,
+	}, panelWidth, panelHeight)
+}
+
+// mongoDBDashboard returns the dashboard of mongodb, for its topology: the servers, then the replication of the
+// replicaset or the shards, then the data volumes. The metrics are the ones of the exporters of mongodb, selected
+// by the stats Services they are scraped through.
+func mongoDBDashboard(mongodb *api.MongoDB) grafanaDashboard {
+	sel := statsSelector(mongodb)
+	rate := func(metric string, matchers ...string) string {
+		return fmt.Sprintf(`rate(%s{%s}[%s])`, metric, strings.Join(append([]string{sel}, matchers...), ","), dashboardRateInterval)
+	}
+	topology := "standalone"
+	switch {
+	case mongodb.Spec.ShardTopology != nil:
+		topology = "sharded"
+	case mongodb.Spec.ReplicaSet != nil:
+		topology = "replicaset"
+	}
+
+	var b dashboardBuilder
+	b.row("Overview")
+	b.stat("Servers Up", "none", fmt.Sprintf(`sum(mongodb_up{%s})`, sel))
+	b.stat("Servers Down", "none", fmt.Sprintf(`count(mongodb_up{%s} == 0) or vector(0)`, sel))
+	b.stat("Connections", "none", fmt.Sprintf(`sum(mongodb_connections{%s,state="current"})`, sel))
+	b.stat("Uptime", "s", fmt.Sprintf(`min(mongodb_instance_uptime_seconds{%s})`, sel))
+
+	b.row("Servers")
+	b.graph("Operations", "ops",
+		fmt.Sprintf(`sum by (type) (%s)`, rate("mongodb_op_counters_total")), "{{type}}")
+	b.graph("Operation Latency", "µs",
+		fmt.Sprintf(`sum by (type) (%s) / sum by (type) (%s)`,
+			rate("mongodb_mongod_op_latencies_latency_total"), rate("mongodb_mongod_op_latencies_ops_total")), "{{type}}")
+	b.graph("Connections", "short",
+		fmt.Sprintf(`sum by (pod) (mongodb_connections{%s,state="current"})`, sel), "{{pod}}")
+	b.graph("Documents", "ops",
+		fmt.Sprintf(`sum by (state) (%s)`, rate("mongodb_mongod_metrics_document_total")), "{{state}}")
+	b.graph("Resident Memory", "decmbytes",
+		fmt.Sprintf(`sum by (pod) (mongodb_memory{%s,type="resident"})`, sel), "{{pod}}")
+	b.graph("Network", "Bps",
+		fmt.Sprintf(`sum by (pod) (%s)`, rate("mongodb_network_bytes_total", `state="in_bytes"`)), "{{pod}} in",
+		fmt.Sprintf(`sum by (pod) (%s)`, rate("mongodb_network_bytes_total", `state="out_bytes"`)), "{{pod}} out")
+
+	if mongodb.Spec.ReplicaSet != nil || mongodb.Spec.ShardTopology != nil {
+		b.row("Replication")
+		b.graph("Replication Lag", "s",
+			fmt.Sprintf(`max by (set, pod) (mongodb_mongod_replset_member_replication_lag{%s})`, sel), "{{set}} {{pod}}")
+		b.graph("Oplog Window", "s",
+			fmt.Sprintf(`max by (set) (mongodb_mongod_replset_oplog_head_timestamp{%s} - mongodb_mongod_replset_oplog_tail_timestamp{%s})`, sel, sel), "{{set}}")
+		b.graph("Primaries", "short",
+			fmt.Sprintf(`sum by (set) (mongodb_mongod_replset_my_state{%s} == bool 1)`, sel), "{{set}}")
+		b.graph("Replicated Operations", "ops",
+			fmt.Sprintf(`sum by (set) (%s)`, rate("mongodb_mongod_op_counters_repl_total")), "{{set}}")
+	}
+
+	if mongodb.Spec.ShardTopology != nil {
+		b.row("Shards")
+		b.graph("Operations by Component", "ops",
+			fmt.Sprintf(`sum by (service) (%s)`, rate("mongodb_op_counters_total")), "{{service}}")
+		b.graph("Connections by Component", "short",
+			fmt.Sprintf(`sum by (service) (mongodb_connections{%s,state="current"})`, sel), "{{service}}")
+		b.graph("Chunks", "short",
+			fmt.Sprintf(`max by (shard) (mongodb_mongos_sharding_chunks_total{%s})`, sel), "{{shard}}")
+		b.graph("Balancer Enabled", "short",
+			fmt.Sprintf(`max(mongodb_mongos_sharding_balancer_enabled{%s})`, sel), "balancer")
+	}
+
+	if mongodb.Spec.StorageType != api.StorageTypeEphemeral {
+		pvc := dataVolumeSelector(mongodb)
+		b.row("Storage")
+		b.graph("Disk Usage", "percent",
+			fmt.Sprintf(`100 * sum by (persistentvolumeclaim) (kubelet_volume_stats_used_bytes{%s}) / sum by (persistentvolumeclaim) (kubelet_volume_stats_capacity_bytes{%s})`, pvc, pvc),
+			"{{persistentvolumeclaim}}")
+		b.graph("Disk Used", "bytes",
+			fmt.Sprintf(`sum by (persistentvolumeclaim) (kubelet_volume_stats_used_bytes{%s})`, pvc),
+			"{{persistentvolumeclaim}}")
+	}
+
+	return grafanaDashboard{
+		// a uid is at most 40 characters long.
+		UID:           fmt.Sprintf("kubedb-mongodb-%x", sha256.Sum256([]byte(mongodb.Namespace+"/"+mongodb.Name)))[:40],
+		Title:         fmt.Sprintf("MongoDB / %s / %s", mongodb.Namespace, mongodb.Name),
+		Tags:          []string{"kubedb", "mongodb", topology},
+		Editable:      false,
+		SchemaVersion: 16,
+		Refresh:       "30s",
+		Time:          grafanaTimeRange{From: "now-6h", To: "now"},
+		Templating: grafanaTemplating{List: []grafanaVariable{{
+			Name:  "datasource",
+			Label: "Data Source",
+			Type:  "datasource",
+			Query: "prometheus",
+		}}},
+		Panels: b.panels,
+	}
+}
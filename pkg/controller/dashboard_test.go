@@ -0,0 +1,51 @@
+package controller
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+)
+
+func TestMongoDBDashboard(t *testing.T) {
+	mongodb := &api.MongoDB{
+		ObjectMeta: metav1.ObjectMeta{Name: "mg", Namespace: "demo"},
+		Spec:       api.MongoDBSpec{StorageType: api.StorageTypeEphemeral},
+	}
+
+	rows := func() map[string]bool {
+		dashboard := mongoDBDashboard(mongodb)
+		if len(dashboard.UID) > 40 {
+			t.Errorf("uid %v is longer than 40 characters", dashboard.UID)
+		}
+		titles := map[string]bool{}
+		for i, p := range dashboard.Panels {
+			if p.Type == "row" {
+				titles[p.Title] = true
+			}
+			// panels must not overlap
+			for _, q := range dashboard.Panels[:i] {
+				if p.GridPos.X < q.GridPos.X+q.GridPos.W && q.GridPos.X < p.GridPos.X+p.GridPos.W &&
+					p.GridPos.Y < q.GridPos.Y+q.GridPos.H && q.GridPos.Y < p.GridPos.Y+p.GridPos.H {
+					t.Errorf("panel %v overlaps panel %v", p.Title, q.Title)
+				}
+			}
+		}
+		return titles
+	}
+
+	if titles := rows(); len(titles) != 2 || !titles["Overview"] || !titles["Servers"] {
+		t.Errorf("expected only the overview and the servers of a standalone ephemeral server, got %v", titles)
+	}
+
+	mongodb.Spec.StorageType = api.StorageTypeDurable
+	mongodb.Spec.ShardTopology = &api.MongoDBShardingTopology{
+		Shard: api.MongoDBShardNode{Shards: 2, MongoDBNode: api.MongoDBNode{Replicas: 3}},
+	}
+	titles := rows()
+	for _, row := range []string{"Replication", "Shards", "Storage"} {
+		if !titles[row] {
+			t.Errorf("expected row %v in the dashboard of a sharded cluster, got %v", row, titles)
+		}
+	}
+}
@@ -64,7 +64,8 @@ func (c *Controller) WipeOutDatabase(drmn *api.DormantDatabase) error {
 	if rerr != nil {
 		return rerr
 	}
-	if err := c.wipeOutDatabase(drmn.ObjectMeta, drmn.GetDatabaseSecrets(), ref); err != nil {
+	secrets := append(drmn.GetDatabaseSecrets(), drmn.Name+CASecretSuffix, drmn.Name+MonitorSecretSuffix)
+	if err := c.wipeOutDatabase(drmn.ObjectMeta, secrets, ref); err != nil {
 		return errors.Wrap(err, "error in wiping out database.")
 	}
 	return nil
@@ -81,6 +82,11 @@ func (c *Controller) wipeOutDatabase(meta metav1.ObjectMeta, secrets []string, r
 	//Dont delete unused secrets that are not owned by kubeDB
 	for _, unusedSecret := range unusedSecrets.List() {
 		secret, err := c.Client.CoreV1().Secrets(meta.Namespace).Get(unusedSecret, metav1.GetOptions{})
+		if kerr.IsNotFound(err) {
+			// the CA secret exists only for the databases with certificates issued by the operator.
+			unusedSecrets.Delete(unusedSecret)
+			continue
+		}
 		if err != nil {
 			return errors.Wrap(err, "error in getting db secret")
 		}
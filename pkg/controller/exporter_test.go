@@ -0,0 +1,45 @@
+package controller
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+)
+
+func TestStatsServices(t *testing.T) {
+	mongodb := &api.MongoDB{
+		ObjectMeta: metav1.ObjectMeta{Name: "mg", Namespace: "demo"},
+		Spec: api.MongoDBSpec{
+			ShardTopology: &api.MongoDBShardingTopology{
+				Shard: api.MongoDBShardNode{Shards: 2, MongoDBNode: api.MongoDBNode{Replicas: 3}},
+			},
+		},
+	}
+
+	services := statsServices(mongodb)
+	if len(services) != 4 {
+		t.Fatalf("expected a stats Service for the config servers, 2 shards and mongos, got %d", len(services))
+	}
+	for _, stats := range services {
+		// a ServiceMonitor selects its stats Service by every label of the Service
+		for _, other := range services {
+			if other.ServiceName() != stats.ServiceName() && labels.SelectorFromSet(stats.labels).Matches(labels.Set(other.labels)) {
+				t.Errorf("labels of %v select %v too", stats.ServiceName(), other.ServiceName())
+			}
+		}
+	}
+
+	shard := services[2]
+	if shard.ServiceName() != "mg-shard1-stats" || shard.ServiceMonitorName() != "kubedb-demo-mg-shard1" {
+		t.Errorf("unexpected names %v and %v of the stats Service of shard1", shard.ServiceName(), shard.ServiceMonitorName())
+	}
+	relabeled := map[string]string{}
+	for _, r := range shard.relabelings {
+		relabeled[r.TargetLabel] = r.Replacement
+	}
+	if relabeled[componentMetricLabel] != "shard" || relabeled[shardMetricLabel] != "shard1" {
+		t.Errorf("expected the metrics of shard1 to be labelled component=shard and shard=shard1, got %v", relabeled)
+	}
+}
@@ -0,0 +1,251 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/appscode/go/log"
+	"github.com/pkg/errors"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"kmodules.xyz/client-go/tools/queue"
+	"kubedb.dev/apimachinery/apis"
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+	"kubedb.dev/apimachinery/client/clientset/versioned/typed/kubedb/v1alpha1/util"
+	"kubedb.dev/mongodb/pkg/dbclient"
+)
+
+const (
+	healthCheckTimeout = 10 * time.Second
+
+	memberStateStandalone = "STANDALONE"
+)
+
+// runHealthProber probes the members of every MongoDB periodically, until stopCh is closed.
+func (c *Controller) runHealthProber(stopCh <-chan struct{}) {
+	if c.healthCheckInterval <= 0 {
+		log.Infoln("Health prober is disabled")
+		return
+	}
+	wait.Until(c.probeMongoDBs, c.healthCheckInterval, stopCh)
+}
+
+func (c *Controller) probeMongoDBs() {
+	mongodbs, err := c.mgLister.List(labels.Everything())
+	if err != nil {
+		log.Errorf("failed to list MongoDB. Reason: %v", err)
+		return
+	}
+	for _, mongodb := range mongodbs {
+		if mongodb.DeletionTimestamp != nil ||
+			mongodb.Spec.DatabaseSecret == nil ||
+			len(mongodb.Status.Components) == 0 {
+			continue
+		}
+		if rootPasswordRotationDue(mongodb) || keyFileRotationDue(mongodb) {
+			queue.Enqueue(c.mgQueue.GetQueue(), mongodb)
+		}
+		if err := c.probeMongoDB(mongodb.DeepCopy()); err != nil {
+			log.Errorf("failed to probe MongoDB %v/%v. Reason: %v", mongodb.Namespace, mongodb.Name, err)
+		}
+	}
+}
+
+// probeMongoDB queries the members of every component of mongodb and publishes their state,
+// replication lag and the current primary in MongoDB status.
+func (c *Controller) probeMongoDB(mongodb *api.MongoDB) error {
+	probed := make([]api.MongoDBComponentStatus, 0, len(mongodb.Status.Components))
+	for _, component := range mongodb.Status.Components {
+		if component.Type == api.MongoDBComponentMongos {
+			continue
+		}
+		result, err := c.probeComponent(mongodb, component)
+		if err != nil {
+			// keep the last known state, the prober will try again in next round.
+			log.Warningf("failed to probe %v of MongoDB %v/%v. Reason: %v", component.Name, mongodb.Namespace, mongodb.Name, err)
+			continue
+		}
+		probed = append(probed, *result)
+	}
+	return c.publishProbedComponents(mongodb, probed)
+}
+
+// publishProbedComponents records the primary and members of probed components in MongoDB status.
+func (c *Controller) publishProbedComponents(mongodb *api.MongoDB, probed []api.MongoDBComponentStatus) error {
+	changed := false
+	for _, p := range probed {
+		cur := getComponentStatus(mongodb.Status.Components, p.Name)
+		if cur.PrimaryPod != p.PrimaryPod || !apiequality.Semantic.DeepEqual(cur.Members, p.Members) {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	mg, err := util.UpdateMongoDBStatus(c.ExtClient.KubedbV1alpha1(), mongodb, func(in *api.MongoDBStatus) *api.MongoDBStatus {
+		for i := range in.Components {
+			if p := getComponentStatus(probed, in.Components[i].Name); p != nil {
+				in.Components[i].PrimaryPod = p.PrimaryPod
+				in.Components[i].Members = p.Members
+			}
+		}
+		return in
+	}, apis.EnableStatusSubresource)
+	if err != nil {
+		return err
+	}
+	mongodb.Status = mg.Status
+	return nil
+}
+
+// probeComponent runs replSetGetStatus (or isMaster, for standalone) against the members of component,
+// one at a time, until one of them answers.
+func (c *Controller) probeComponent(mongodb *api.MongoDB, component api.MongoDBComponentStatus) (*api.MongoDBComponentStatus, error) {
+	var lastErr error
+	for _, host := range memberHosts(mongodb, component) {
+		ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+		result, err := c.probeMember(ctx, mongodb, component, host)
+		cancel()
+		if err == nil {
+			return result, nil
+		}
+		lastErr = err
+	}
+	if lastErr == nil {
+		lastErr = errors.New("no member to probe")
+	}
+	return nil, lastErr
+}
+
+func (c *Controller) probeMember(ctx context.Context, mongodb *api.MongoDB, component api.MongoDBComponentStatus, host string) (*api.MongoDBComponentStatus, error) {
+	client, err := c.connectToMember(ctx, mongodb, host)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	result := component.DeepCopy()
+	if component.Type == api.MongoDBComponentStandalone {
+		if _, err := client.IsMaster(ctx); err != nil {
+			return nil, err
+		}
+		result.PrimaryPod = ""
+		result.Members = []api.MongoDBMemberStatus{{
+			Name:    host,
+			Pod:     dbclient.PodName(host),
+			State:   memberStateStandalone,
+			Healthy: true,
+		}}
+		return result, nil
+	}
+
+	status, err := client.ReplSetGetStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result.PrimaryPod = ""
+	if primary := status.Primary(); primary != nil {
+		result.PrimaryPod = primary.PodName()
+	}
+	result.Members = make([]api.MongoDBMemberStatus, 0, len(status.Members))
+	for _, m := range status.Members {
+		member := api.MongoDBMemberStatus{
+			Name:    m.Name,
+			Pod:     m.PodName(),
+			State:   m.StateStr,
+			Healthy: m.Healthy(),
+		}
+		if lag, ok := status.ReplicationLag(m); ok {
+			seconds := int64(lag / time.Second)
+			member.ReplicationLagSeconds = &seconds
+		}
+		result.Members = append(result.Members, member)
+	}
+	return result, nil
+}
+
+// memberHosts returns the host:port of every pod of the StatefulSet of component.
+func memberHosts(mongodb *api.MongoDB, component api.MongoDBComponentStatus) []string {
+	return stsMemberHosts(mongodb, component.Name, component.Replicas)
+}
+
+// stsMemberHosts returns the host:port of the first replicas pods of StatefulSet stsName.
+func stsMemberHosts(mongodb *api.MongoDB, stsName string, replicas int32) []string {
+	hosts := make([]string, 0, replicas)
+	for i := int32(0); i < replicas; i++ {
+		hosts = append(hosts, fmt.Sprintf("%v-%v.%v.%v.svc:%v",
+			stsName, i, mongodb.GvrSvcName(stsName), mongodb.Namespace, api.MongoDBShardPort))
+	}
+	return hosts
+}
+
+// connectToMember connects directly to the server at host, using the root credentials
+// in spec.databaseSecret and, if ssl is enabled, the client PEM in spec.certificateSecret.
+func (c *Controller) connectToMember(ctx context.Context, mongodb *api.MongoDB, host string) (*dbclient.Client, error) {
+	cfg, err := c.dbClientConfig(mongodb)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Hosts = []string{host}
+	cfg.Direct = true
+	return dbclient.New(ctx, cfg)
+}
+
+// connectToAddress connects to the server at address, that is named host in its certificate.
+func (c *Controller) connectToAddress(ctx context.Context, mongodb *api.MongoDB, host, address string) (*dbclient.Client, error) {
+	cfg, err := c.dbClientConfig(mongodb)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Hosts = []string{host}
+	cfg.Direct = true
+	cfg.Address = address
+	return dbclient.New(ctx, cfg)
+}
+
+// connectToDatabase connects to the server that accepts writes for the whole of mongodb:
+// a mongos of a sharded cluster, the primary of a replicaset or the standalone server.
+func (c *Controller) connectToDatabase(ctx context.Context, mongodb *api.MongoDB) (*dbclient.Client, error) {
+	switch component := desiredComponents(mongodb)[0].status; {
+	case mongodb.Spec.ShardTopology != nil:
+		return c.connectToMongos(ctx, mongodb)
+	case component.Type == api.MongoDBComponentReplicaSet:
+		client, _, err := c.connectToPrimary(ctx, mongodb, stsMemberHosts(mongodb, component.Name, component.Replicas))
+		return client, err
+	default:
+		return c.connectToMember(ctx, mongodb, stsMemberHosts(mongodb, component.Name, 1)[0])
+	}
+}
+
+// dbClientConfig returns the credentials and TLS config to connect to the servers of mongodb.
+func (c *Controller) dbClientConfig(mongodb *api.MongoDB) (dbclient.Config, error) {
+	cfg := dbclient.Config{Timeout: healthCheckTimeout}
+	if mongodb.Spec.DatabaseSecret == nil {
+		return cfg, errors.New("spec.databaseSecret is not set")
+	}
+	secret, err := c.Client.CoreV1().Secrets(mongodb.Namespace).Get(mongodb.Spec.DatabaseSecret.SecretName, metav1.GetOptions{})
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Username = string(secret.Data[KeyMongoDBUser])
+	cfg.Password = string(secret.Data[KeyMongoDBPassword])
+
+	sslMode := mongodb.Spec.SSLMode
+	if sslMode == "" || sslMode == api.SSLModeDisabled {
+		return cfg, nil
+	}
+	if mongodb.Spec.CertificateSecret == nil {
+		return cfg, errors.New("spec.certificateSecret is not set")
+	}
+	certSecret, err := c.Client.CoreV1().Secrets(mongodb.Namespace).Get(mongodb.Spec.CertificateSecret.SecretName, metav1.GetOptions{})
+	if err != nil {
+		return cfg, err
+	}
+	cfg.TLSConfig, err = dbclient.ClientTLSConfig(certSecret.Data[string(api.MongoTLSCertFileName)], certSecret.Data[string(api.MongoClientPemFileName)])
+	return cfg, err
+}
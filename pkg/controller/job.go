@@ -19,7 +19,10 @@ const (
 	restoreConfigArg = "--skip-config"
 )
 
-func (c *Controller) createRestoreJob(mongodb *api.MongoDB, snapshot *api.Snapshot) (*batch.Job, error) {
+// createRestoreJob creates the job that restores snapshot into mongodb. The job initializes mongodb from
+// spec.init.snapshotSource, unless restore is given. Then it restores the snapshot of the MongoDBRestore
+// into the running mongodb, and is owned by the MongoDBRestore instead.
+func (c *Controller) createRestoreJob(mongodb *api.MongoDB, snapshot *api.Snapshot, restore *api.MongoDBRestore) (*batch.Job, error) {
 	mongodbVersion, err := c.ExtClient.CatalogV1alpha1().MongoDBVersions().Get(string(mongodb.Spec.Version), metav1.GetOptions{})
 	if err != nil {
 		return nil, err
@@ -31,6 +34,25 @@ func (c *Controller) createRestoreJob(mongodb *api.MongoDB, snapshot *api.Snapsh
 	}
 	jobLabel[api.LabelDatabaseKind] = api.ResourceKindMongoDB
 	jobLabel[api.AnnotationJobType] = api.JobTypeRestore
+	owner := metav1.OwnerReference{
+		APIVersion: api.SchemeGroupVersion.String(),
+		Kind:       api.ResourceKindMongoDB,
+		Name:       mongodb.Name,
+		UID:        mongodb.UID,
+	}
+	source := mongodb.Spec.Init.SnapshotSource
+	if restore != nil {
+		// the job is left out of the selector of the job controller, that would complete it as an initialization
+		jobName = restoreJobName(restore)
+		jobLabel[api.LabelDatabaseKind] = api.ResourceKindMongoDBRestore
+		owner = metav1.OwnerReference{
+			APIVersion: api.SchemeGroupVersion.String(),
+			Kind:       api.ResourceKindMongoDBRestore,
+			Name:       restore.Name,
+			UID:        restore.UID,
+		}
+		source = &restore.Spec.Snapshot
+	}
 
 	backupSpec := snapshot.Spec.Backend
 	bucket, err := backupSpec.Container()
@@ -68,17 +90,10 @@ func (c *Controller) createRestoreJob(mongodb *api.MongoDB, snapshot *api.Snapsh
 
 	job := &batch.Job{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:        jobName,
-			Labels:      jobLabel,
-			Annotations: snapshot.Spec.PodTemplate.Controller.Annotations,
-			OwnerReferences: []metav1.OwnerReference{
-				{
-					APIVersion: api.SchemeGroupVersion.String(),
-					Kind:       api.ResourceKindMongoDB,
-					Name:       mongodb.Name,
-					UID:        mongodb.UID,
-				},
-			},
+			Name:            jobName,
+			Labels:          jobLabel,
+			Annotations:     snapshot.Spec.PodTemplate.Controller.Annotations,
+			OwnerReferences: []metav1.OwnerReference{owner},
 		},
 		Spec: batch.JobSpec{
 			Template: core.PodTemplateSpec{
@@ -98,7 +113,7 @@ func (c *Controller) createRestoreJob(mongodb *api.MongoDB, snapshot *api.Snapsh
 								fmt.Sprintf(`--folder=%s`, folderName),
 								fmt.Sprintf(`--snapshot=%s`, snapshot.Name),
 								fmt.Sprintf(`--enable-analytics=%v`, c.EnableAnalytics),
-							}, getRestoreConfigArg(mongodb)...),
+							}, getRestoreConfigArg(source)...),
 							Env: []core.EnvVar{
 								{
 									Name:  analytics.Key,
@@ -187,6 +202,24 @@ func (c *Controller) createRestoreJob(mongodb *api.MongoDB, snapshot *api.Snapsh
 		job.Spec.Template.Spec.Volumes = append(job.Spec.Template.Spec.Volumes, volume)
 	}
 
+	addRestoreSelection(source, job)
+	if restore != nil {
+		addRestoreRequest(restore, job)
+	}
+	if err := c.addRestoreArchive(mongodb, mongodbVersion, snapshot, job); err != nil {
+		return nil, err
+	}
+	if restore == nil && mongodb.Spec.Init.MongoDBOplog != nil {
+		if err := c.addOplogReplay(mongodb, snapshot, job); err != nil {
+			return nil, err
+		}
+	}
+	if snapshot.Annotations[api.MongoDBBackupModeAnnotation] == string(api.MongoDBShardedBackupModeConsistent) {
+		if err := c.addShardedRestore(mongodb, snapshot, job, bucket, folderName); err != nil {
+			return nil, err
+		}
+	}
+
 	if c.EnableRBAC {
 		if snapshot.Spec.PodTemplate.Spec.ServiceAccountName == "" {
 			if err := c.ensureSnapshotRBAC(mongodb); err != nil {
@@ -374,6 +407,18 @@ func (c *Controller) getSnapshotterJob(snapshot *api.Snapshot) (*batch.Job, erro
 		})
 	}
 
+	if args := dumpSelectionArgs(snapshot.Spec.Selection); len(args) > 0 {
+		job.Spec.Template.Spec.Containers[0].Args = append(job.Spec.Template.Spec.Containers[0].Args, args...)
+	}
+	if err := c.addBackupArchive(mongodbVersion, snapshot, job); err != nil {
+		return nil, err
+	}
+	if isConsistentShardedBackup(mongodb) {
+		if err := c.addShardedBackup(mongodb, snapshot, job, bucket, folderName); err != nil {
+			return nil, err
+		}
+	}
+
 	if c.EnableRBAC {
 		if snapshot.Spec.PodTemplate.Spec.ServiceAccountName == "" {
 			job.Spec.Template.Spec.ServiceAccountName = mongodb.SnapshotSAName()
@@ -402,8 +447,8 @@ func snapshotStorageSize(db *api.MongoDB) *core.PersistentVolumeClaimSpec {
 	return db.Spec.Storage
 }
 
-func getRestoreConfigArg(db *api.MongoDB) []string {
-	args := append([]string{"--"}, db.Spec.Init.SnapshotSource.Args...)
+func getRestoreConfigArg(source *api.SnapshotSourceSpec) []string {
+	args := append([]string{"--"}, source.Args...)
 	for in, val := range args {
 		if strings.HasPrefix(val, string(restoreConfigArg)) {
 			// Move '--skip-config=*' to the beginning (before of '--') of argument list
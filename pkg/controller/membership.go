@@ -0,0 +1,210 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/appscode/go/log"
+	"github.com/pkg/errors"
+	core "k8s.io/api/core/v1"
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+	"kubedb.dev/apimachinery/pkg/eventer"
+	"kubedb.dev/mongodb/pkg/dbclient"
+)
+
+const (
+	// how long a stepped down primary stays ineligible for election
+	stepDownSeconds = 60
+
+	membershipTimeout = 3 * healthCheckTimeout
+)
+
+// removeReplicaSetMembers removes the members served by the pods of StatefulSet stsName with
+// ordinal >= replicas from the replicaset config, so that scaling the StatefulSet down to replicas
+// does not leave unreachable members behind. It must be called before the StatefulSet is scaled down.
+//
+// If the primary is one of the departing members, it is stepped down first and a replicaSetNotReadyError
+// is returned, so that MongoDB is requeued until one of the remaining members is elected.
+func (c *Controller) removeReplicaSetMembers(mongodb *api.MongoDB, stsName, replSetName string, current, replicas int32) error {
+	ctx, cancel := context.WithTimeout(context.Background(), membershipTimeout)
+	defer cancel()
+
+	client, primary, err := c.connectToPrimary(ctx, mongodb, stsMemberHosts(mongodb, stsName, current))
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	departing := func(m dbclient.ReplSetConfigMember) bool {
+		return !isRetainedMember(stsName, replicas, dbclient.PodName(m.Host))
+	}
+
+	if !isRetainedMember(stsName, replicas, dbclient.PodName(primary)) {
+		if err := client.StepDown(ctx, stepDownSeconds); err != nil {
+			c.recorder.Eventf(
+				mongodb,
+				core.EventTypeWarning,
+				eventer.EventReasonFailedToUpdate,
+				"Failed to step down primary %v of replicaset %v. Reason: %v",
+				primary,
+				replSetName,
+				err,
+			)
+			return err
+		}
+		c.recorder.Eventf(
+			mongodb,
+			core.EventTypeNormal,
+			eventer.EventReasonSuccessful,
+			"Stepped down primary %v of replicaset %v to remove it",
+			primary,
+			replSetName,
+		)
+		return &replicaSetNotReadyError{
+			replicaSet: replSetName,
+			reason:     fmt.Sprintf("primary %v is stepped down, waiting for a new primary", primary),
+		}
+	}
+
+	// Remove one member per reconfig, as newer versions of mongodb reject reconfigs that change
+	// more than one voting member at a time.
+	for {
+		config, err := client.ReplSetGetConfig(ctx)
+		if err != nil {
+			return err
+		}
+		var removed *dbclient.ReplSetConfigMember
+		config.RemoveMembers(func(m dbclient.ReplSetConfigMember) bool {
+			if removed == nil && departing(m) {
+				removed = &m
+				return true
+			}
+			return false
+		})
+		if removed == nil {
+			return nil
+		}
+
+		if err := client.ReplSetReconfig(ctx, config); err != nil {
+			c.recorder.Eventf(
+				mongodb,
+				core.EventTypeWarning,
+				eventer.EventReasonFailedToUpdate,
+				"Failed to remove member %v from replicaset %v. Reason: %v",
+				removed.Host,
+				replSetName,
+				err,
+			)
+			return err
+		}
+		c.recorder.Eventf(
+			mongodb,
+			core.EventTypeNormal,
+			eventer.EventReasonSuccessful,
+			"Removed member %v from replicaset %v",
+			removed.Host,
+			replSetName,
+		)
+		log.Infof("removed member %v from replicaset %v of MongoDB %v/%v", removed.Host, replSetName, mongodb.Namespace, mongodb.Name)
+	}
+}
+
+// connectToPrimary finds the primary of the replicaset served by hosts and connects directly to it.
+// It returns the host of the primary, as known by the replicaset.
+func (c *Controller) connectToPrimary(ctx context.Context, mongodb *api.MongoDB, hosts []string) (*dbclient.Client, string, error) {
+	var lastErr error
+	for _, host := range hosts {
+		client, err := c.connectToMember(ctx, mongodb, host)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		result, err := client.IsMaster(ctx)
+		if err != nil {
+			client.Close()
+			lastErr = err
+			continue
+		}
+		if result.IsMaster {
+			return client, primaryHost(result, host), nil
+		}
+		client.Close()
+
+		if result.Primary != "" {
+			client, err := c.connectToMember(ctx, mongodb, result.Primary)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			if result, err := client.IsMaster(ctx); err == nil && result.IsMaster {
+				return client, primaryHost(result, host), nil
+			}
+			client.Close()
+		}
+	}
+	if lastErr == nil {
+		lastErr = errors.New("no primary found")
+	}
+	return nil, "", errors.Wrapf(lastErr, "failed to connect to primary of %v", hosts)
+}
+
+// primaryHost returns the host of the primary that replied isMaster to a connection to host.
+func primaryHost(result *dbclient.IsMasterResult, host string) string {
+	if result.Me != "" {
+		return result.Me
+	}
+	if result.Primary != "" {
+		return result.Primary
+	}
+	return host
+}
+
+// checkReplicaSetMembers verifies that every pod of every replicaset of mongodb has joined its replicaset
+// and reached PRIMARY or SECONDARY state. Ready pods are not enough, as a new member goes through
+// STARTUP2 and RECOVERING states while it syncs data. The probed members are published in status.
+func (c *Controller) checkReplicaSetMembers(mongodb *api.MongoDB) error {
+	var probed []api.MongoDBComponentStatus
+	var notReady error
+	for _, component := range mongodb.Status.Components {
+		if component.Type == api.MongoDBComponentStandalone || component.Type == api.MongoDBComponentMongos {
+			continue
+		}
+
+		result, err := c.probeComponent(mongodb, component)
+		if err != nil {
+			if notReady == nil {
+				notReady = &replicaSetNotReadyError{replicaSet: component.ReplicaSetName, reason: err.Error()}
+			}
+			continue
+		}
+		probed = append(probed, *result)
+
+		var joined int32
+		for _, m := range result.Members {
+			if (m.State == "PRIMARY" || m.State == "SECONDARY") && isRetainedMember(component.Name, component.Replicas, m.Pod) {
+				joined++
+			}
+		}
+		if joined < component.Replicas && notReady == nil {
+			notReady = &replicaSetNotReadyError{
+				replicaSet: component.ReplicaSetName,
+				reason:     fmt.Sprintf("%v/%v members are PRIMARY or SECONDARY", joined, component.Replicas),
+			}
+		}
+	}
+
+	if err := c.publishProbedComponents(mongodb, probed); err != nil {
+		return err
+	}
+	return notReady
+}
+
+// isRetainedMember reports whether pod is one of the first replicas pods of StatefulSet stsName.
+func isRetainedMember(stsName string, replicas int32, pod string) bool {
+	for i := int32(0); i < replicas; i++ {
+		if pod == fmt.Sprintf("%v-%v", stsName, i) {
+			return true
+		}
+	}
+	return false
+}
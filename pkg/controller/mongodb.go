@@ -11,6 +11,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	clientsetscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/reference"
 	kutil "kmodules.xyz/client-go"
 	dynamic_util "kmodules.xyz/client-go/dynamic"
@@ -74,19 +75,41 @@ func (c *Controller) create(mongodb *api.MongoDB) error {
 	if err := c.ensureDatabaseSecret(mongodb); err != nil {
 		return err
 	}
+	if err := c.ensureMonitorSecret(mongodb); err != nil {
+		return err
+	}
 
 	// ensure certificate or keyfile for cluster
 	sslMode := mongodb.Spec.SSLMode
 	if (sslMode != api.SSLModeDisabled && sslMode != "") ||
 		mongodb.Spec.ReplicaSet != nil || mongodb.Spec.ShardTopology != nil {
 		if err := c.ensureCertSecret(mongodb); err != nil {
+			if isWorkloadNotReady(err) {
+				return c.requeueMongoDB(mongodb, err)
+			}
 			return err
 		}
 	}
+	if sslMode != api.SSLModeDisabled && sslMode != "" {
+		if err := c.ensureCertificateStatus(mongodb); err != nil {
+			return err
+		}
+	} else if err := c.removeMongoDBCondition(mongodb, api.MongoDBConditionTLSReady); err != nil {
+		return err
+	}
+
+	// Start upgrading, if spec.version is changed, before the workloads are patched with the new image.
+	if err := c.ensureVersionUpgrade(mongodb); err != nil {
+		return err
+	}
 
 	// ensure database StatefulSet
 	vt2, err := c.ensureMongoDBNode(mongodb)
-	if err != nil {
+	if err != nil && !isWorkloadNotReady(err) {
+		return err
+	}
+
+	if err := c.updateComponentStatus(mongodb); err != nil {
 		return err
 	}
 
@@ -106,6 +129,70 @@ func (c *Controller) create(mongodb *api.MongoDB) error {
 		)
 	}
 
+	if err != nil {
+		// Database pods are not ready yet. Record the progress and requeue,
+		// instead of holding the queue worker until the pods come up.
+		return c.requeueMongoDB(mongodb, err)
+	}
+
+	// Pods are ready, but new members may still be syncing data from the replicaset.
+	if err := c.checkReplicaSetMembers(mongodb); err != nil {
+		if isWorkloadNotReady(err) {
+			return c.requeueMongoDB(mongodb, err)
+		}
+		return err
+	}
+
+	// Register new shards, and drain the removed ones, through mongos.
+	if mongodb.Spec.ShardTopology != nil {
+		if err := c.ensureShards(mongodb); err != nil {
+			if isWorkloadNotReady(err) {
+				return c.requeueMongoDB(mongodb, err)
+			}
+			return err
+		}
+	}
+
+	// Restart the members, to apply options that take effect on restart only, one at a time.
+	if err := c.restartOutdatedMembers(mongodb); err != nil {
+		if isWorkloadNotReady(err) {
+			return c.requeueMongoDB(mongodb, err)
+		}
+		return err
+	}
+
+	// Apply the other options to the running servers.
+	if err := c.applyRuntimeConfiguration(mongodb); err != nil {
+		return err
+	}
+
+	// Renew the certificates ahead of expiry, once every pod runs the current template.
+	if sslMode != api.SSLModeDisabled && sslMode != "" {
+		if err := c.rotateCertificates(mongodb); err != nil {
+			if isWorkloadNotReady(err) {
+				return c.requeueMongoDB(mongodb, err)
+			}
+			return err
+		}
+		c.enqueueCertificateRenewal(mongodb)
+	}
+
+	// Upgrade one pod at a time, once every member is healthy again.
+	if err := c.progressVersionUpgrade(mongodb); err != nil {
+		if isWorkloadNotReady(err) {
+			return c.requeueMongoDB(mongodb, err)
+		}
+		return err
+	}
+
+	// Rotate the credentials on request or schedule, once every member is healthy again.
+	if err := c.rotateCredentials(mongodb); err != nil {
+		if isWorkloadNotReady(err) {
+			return c.requeueMongoDB(mongodb, err)
+		}
+		return err
+	}
+
 	// ensure appbinding before ensuring Restic scheduler and restore
 	_, err = c.ensureAppBinding(mongodb)
 	if err != nil {
@@ -146,6 +233,9 @@ func (c *Controller) create(mongodb *api.MongoDB) error {
 
 	mg, err := util.UpdateMongoDBStatus(c.ExtClient.KubedbV1alpha1(), mongodb, func(in *api.MongoDBStatus) *api.MongoDBStatus {
 		in.Phase = api.DatabasePhaseRunning
+		in.Reason = ""
+		in.Conditions = setCondition(in.Conditions, newCondition(api.MongoDBConditionProvisioned, core.ConditionTrue,
+			ConditionReasonReady, "database is provisioned and ready"))
 		in.ObservedGeneration = types.NewIntHash(mongodb.Generation, meta_util.GenerationHash(mongodb))
 		return in
 	}, apis.EnableStatusSubresource)
@@ -154,6 +244,10 @@ func (c *Controller) create(mongodb *api.MongoDB) error {
 	}
 	mongodb.Status = mg.Status
 
+	if key, err := cache.MetaNamespaceKeyFunc(mongodb); err == nil {
+		c.mgReadinessBackoff.Forget(key)
+	}
+
 	// Ensure Schedule backup
 	if err := c.ensureBackupScheduler(mongodb); err != nil {
 		c.recorder.Eventf(
@@ -163,7 +257,56 @@ func (c *Controller) create(mongodb *api.MongoDB) error {
 			err.Error(),
 		)
 		log.Errorln(err)
+		if err := c.setMongoDBConditions(mongodb, newCondition(api.MongoDBConditionBackupScheduled, core.ConditionFalse,
+			ConditionReasonFailed, err.Error())); err != nil {
+			log.Errorln(err)
+		}
+		// Don't return error. Continue processing rest.
+	} else if mongodb.Spec.BackupSchedule != nil {
+		if err := c.setMongoDBConditions(mongodb, newCondition(api.MongoDBConditionBackupScheduled, core.ConditionTrue,
+			ConditionReasonScheduled, "backup is scheduled")); err != nil {
+			log.Errorln(err)
+		}
+	} else if err := c.removeMongoDBCondition(mongodb, api.MongoDBConditionBackupScheduled); err != nil {
+		log.Errorln(err)
+	}
+
+	// Ensure the oplog archivers for point-in-time recovery
+	if err := c.ensureOplogArchive(mongodb); err != nil {
+		c.recorder.Eventf(
+			mongodb,
+			core.EventTypeWarning,
+			eventer.EventReasonFailedToCreate,
+			"Failed to archive oplog. Reason: %v",
+			err,
+		)
+		log.Errorln(err)
+		if err := c.setMongoDBConditions(mongodb, newCondition(api.MongoDBConditionOplogArchiving, core.ConditionFalse,
+			ConditionReasonFailed, err.Error())); err != nil {
+			log.Errorln(err)
+		}
 		// Don't return error. Continue processing rest.
+	} else if mongodb.Spec.OplogArchive != nil {
+		if err := c.setMongoDBConditions(mongodb, newCondition(api.MongoDBConditionOplogArchiving, core.ConditionTrue,
+			ConditionReasonConfigured, "oplog archivers are deployed")); err != nil {
+			log.Errorln(err)
+		}
+	} else if err := c.removeMongoDBCondition(mongodb, api.MongoDBConditionOplogArchiving); err != nil {
+		log.Errorln(err)
+	}
+
+	// ensure the user the exporters connect with
+	if err := c.ensureMonitorUser(mongodb); err != nil {
+		c.recorder.Eventf(
+			mongodb,
+			core.EventTypeWarning,
+			eventer.EventReasonFailedToCreate,
+			"Failed to manage monitoring system. Reason: %v",
+			err,
+		)
+		log.Errorf("failed to manage monitoring system. Reason: %v", err)
+		c.setMonitoringCondition(mongodb, err)
+		return nil
 	}
 
 	// ensure StatsService for desired monitoring
@@ -176,6 +319,7 @@ func (c *Controller) create(mongodb *api.MongoDB) error {
 			err,
 		)
 		log.Errorf("failed to manage monitoring system. Reason: %v", err)
+		c.setMonitoringCondition(mongodb, err)
 		return nil
 	}
 
@@ -188,12 +332,59 @@ func (c *Controller) create(mongodb *api.MongoDB) error {
 			err,
 		)
 		log.Errorf("failed to manage monitoring system. Reason: %v", err)
+		c.setMonitoringCondition(mongodb, err)
 		return nil
 	}
 
+	if err := c.manageAlerting(mongodb); err != nil {
+		c.recorder.Eventf(
+			mongodb,
+			core.EventTypeWarning,
+			eventer.EventReasonFailedToCreate,
+			"Failed to manage alerting rules. Reason: %v",
+			err,
+		)
+		log.Errorf("failed to manage alerting rules. Reason: %v", err)
+		c.setMonitoringCondition(mongodb, err)
+		return nil
+	}
+
+	if err := c.manageDashboards(mongodb); err != nil {
+		c.recorder.Eventf(
+			mongodb,
+			core.EventTypeWarning,
+			eventer.EventReasonFailedToCreate,
+			"Failed to manage dashboards. Reason: %v",
+			err,
+		)
+		log.Errorf("failed to manage dashboards. Reason: %v", err)
+		c.setMonitoringCondition(mongodb, err)
+		return nil
+	}
+	c.setMonitoringCondition(mongodb, nil)
+
 	return nil
 }
 
+// setMonitoringCondition records the outcome of configuring monitoring in MongoDB status.
+// Failing to do so is only logged, as monitoring does not affect the database itself.
+func (c *Controller) setMonitoringCondition(mongodb *api.MongoDB, reason error) {
+	var err error
+	switch {
+	case mongodb.Spec.Monitor == nil:
+		err = c.removeMongoDBCondition(mongodb, api.MongoDBConditionMonitoringReady)
+	case reason != nil:
+		err = c.setMongoDBConditions(mongodb, newCondition(api.MongoDBConditionMonitoringReady, core.ConditionFalse,
+			ConditionReasonFailed, reason.Error()))
+	default:
+		err = c.setMongoDBConditions(mongodb, newCondition(api.MongoDBConditionMonitoringReady, core.ConditionTrue,
+			ConditionReasonConfigured, "monitoring is configured"))
+	}
+	if err != nil {
+		log.Errorln(err)
+	}
+}
+
 func (c *Controller) ensureBackupScheduler(mongodb *api.MongoDB) error {
 	mongodbVersion, err := c.ExtClient.CatalogV1alpha1().MongoDBVersions().Get(string(mongodb.Spec.Version), metav1.GetOptions{})
 	if err != nil {
@@ -249,7 +440,7 @@ func (c *Controller) initializeFromSnapshot(mongodb *api.MongoDB) error {
 		return err
 	}
 
-	job, err := c.createRestoreJob(mongodb, snapshot)
+	job, err := c.createRestoreJob(mongodb, snapshot, nil)
 	if err != nil {
 		return err
 	}
@@ -302,6 +493,11 @@ func (c *Controller) terminate(mongodb *api.MongoDB) error {
 
 	c.cronController.StopBackupScheduling(mongodb.ObjectMeta)
 
+	if mongodb.Spec.Alerting != nil {
+		if err := c.deleteAlertRules(mongodb); err != nil {
+			log.Errorln(err)
+		}
+	}
 	if mongodb.Spec.Monitor != nil {
 		if _, err := c.deleteMonitor(mongodb); err != nil {
 			log.Errorln(err)
@@ -325,7 +521,7 @@ func (c *Controller) setOwnerReferenceToOffshoots(mongodb *api.MongoDB, ref *cor
 			ref); err != nil {
 			return err
 		}
-		if err := c.wipeOutDatabase(mongodb.ObjectMeta, mongodb.Spec.GetSecrets(), ref); err != nil {
+		if err := c.wipeOutDatabase(mongodb.ObjectMeta, append(mongodb.Spec.GetSecrets(), caSecretName(mongodb), monitorSecretName(mongodb)), ref); err != nil {
 			return errors.Wrap(err, "error in wiping out database.")
 		}
 	} else {
@@ -28,12 +28,16 @@ func (c *Controller) newMonitorController(mongodb *api.MongoDB) (mona.Agent, err
 	return nil, fmt.Errorf("monitoring controller not found for %v", monitorSpec)
 }
 
-func (c *Controller) addOrUpdateMonitor(mongodb *api.MongoDB) (kutil.VerbType, error) {
+func (c *Controller) addOrUpdateMonitor(mongodb *api.MongoDB, stats statsService) (kutil.VerbType, error) {
 	agent, err := c.newMonitorController(mongodb)
 	if err != nil {
 		return kutil.VerbUnchanged, err
 	}
-	return agent.CreateOrUpdate(mongodb.StatsService(), mongodb.Spec.Monitor)
+	vt, err := agent.CreateOrUpdate(stats, mongodb.Spec.Monitor)
+	if err != nil {
+		return kutil.VerbUnchanged, err
+	}
+	return vt, c.ensureStatsRelabelings(mongodb, stats)
 }
 
 func (c *Controller) deleteMonitor(mongodb *api.MongoDB) (kutil.VerbType, error) {
@@ -41,11 +45,20 @@ func (c *Controller) deleteMonitor(mongodb *api.MongoDB) (kutil.VerbType, error)
 	if err != nil {
 		return kutil.VerbUnchanged, err
 	}
-	return agent.Delete(mongodb.StatsService())
+	vt := kutil.VerbUnchanged
+	for _, stats := range statsServices(mongodb) {
+		svt, err := agent.Delete(stats)
+		if err != nil {
+			return kutil.VerbUnchanged, err
+		} else if svt != kutil.VerbUnchanged {
+			vt = svt
+		}
+	}
+	return vt, nil
 }
 
-func (c *Controller) getOldAgent(mongodb *api.MongoDB) mona.Agent {
-	service, err := c.Client.CoreV1().Services(mongodb.Namespace).Get(mongodb.StatsService().ServiceName(), metav1.GetOptions{})
+func (c *Controller) getOldAgent(mongodb *api.MongoDB, stats statsService) mona.Agent {
+	service, err := c.Client.CoreV1().Services(mongodb.Namespace).Get(stats.ServiceName(), metav1.GetOptions{})
 	if err != nil {
 		return nil
 	}
@@ -53,8 +66,8 @@ func (c *Controller) getOldAgent(mongodb *api.MongoDB) mona.Agent {
 	return agents.New(mona.AgentType(oldAgentType), c.Client, c.ApiExtKubeClient, c.promClient)
 }
 
-func (c *Controller) setNewAgent(mongodb *api.MongoDB) error {
-	service, err := c.Client.CoreV1().Services(mongodb.Namespace).Get(mongodb.StatsService().ServiceName(), metav1.GetOptions{})
+func (c *Controller) setNewAgent(mongodb *api.MongoDB, stats statsService) error {
+	service, err := c.Client.CoreV1().Services(mongodb.Namespace).Get(stats.ServiceName(), metav1.GetOptions{})
 	if err != nil {
 		return err
 	}
@@ -68,20 +81,30 @@ func (c *Controller) setNewAgent(mongodb *api.MongoDB) error {
 	return err
 }
 
+// manageMonitor sets the monitoring agent up for every stats Service of mongodb.
 func (c *Controller) manageMonitor(mongodb *api.MongoDB) error {
-	oldAgent := c.getOldAgent(mongodb)
+	for _, stats := range statsServices(mongodb) {
+		if err := c.manageStatsMonitor(mongodb, stats); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Controller) manageStatsMonitor(mongodb *api.MongoDB, stats statsService) error {
+	oldAgent := c.getOldAgent(mongodb, stats)
 	if mongodb.Spec.Monitor != nil {
 		if oldAgent != nil && oldAgent.GetType() != mongodb.Spec.Monitor.Agent {
-			if _, err := oldAgent.Delete(mongodb.StatsService()); err != nil {
+			if _, err := oldAgent.Delete(stats); err != nil {
 				log.Errorf("error in deleting Prometheus agent. Reason: %v", err.Error())
 			}
 		}
-		if _, err := c.addOrUpdateMonitor(mongodb); err != nil {
+		if _, err := c.addOrUpdateMonitor(mongodb, stats); err != nil {
 			return err
 		}
-		return c.setNewAgent(mongodb)
+		return c.setNewAgent(mongodb, stats)
 	} else if oldAgent != nil {
-		if _, err := oldAgent.Delete(mongodb.StatsService()); err != nil {
+		if _, err := oldAgent.Delete(stats); err != nil {
 			log.Errorf("error in deleting Prometheus agent. Reason: %v", err.Error())
 		}
 	}
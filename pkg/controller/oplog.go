@@ -0,0 +1,386 @@
+package controller
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/appscode/go/types"
+	"github.com/pkg/errors"
+	apps "k8s.io/api/apps/v1"
+	batch "k8s.io/api/batch/v1"
+	core "k8s.io/api/core/v1"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	clientsetscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/reference"
+	app_util "kmodules.xyz/client-go/apps/v1"
+	core_util "kmodules.xyz/client-go/core/v1"
+	meta_util "kmodules.xyz/client-go/meta"
+	"kmodules.xyz/client-go/tools/analytics"
+	store "kmodules.xyz/objectstore-api/api/v1"
+	storage "kmodules.xyz/objectstore-api/osm"
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+	"kubedb.dev/mongodb/pkg/oplog"
+)
+
+const (
+	// oplogArchiverLabel is set on the archiver Deployments to the name of the archived statefulset.
+	oplogArchiverLabel = api.MongoDBKey + "/oplog-archiver"
+	oplogArchiverRole  = "oplog-archiver"
+	oplogFolder        = "oplog"
+	oplogTLSPath       = "/var/run/mongodb/tls"
+	oplogReplayRole    = "oplog-replay"
+	// the volumes are named apart from those of the restore container, that runs in the same pod as the replay.
+	osmConfigVolume    = "oplog-osmconfig"
+	localBackendVolume = "oplog-local"
+	oplogTLSVolume     = "oplog-certdir"
+)
+
+// oplogArchiveLocation returns the bucket and the folder of the oplog archive of the MongoDB dbName in namespace,
+// next to the folder of its snapshots in backend.
+func oplogArchiveLocation(backend store.Backend, namespace, dbName string) (string, string, error) {
+	bucket, err := backend.Container()
+	if err != nil {
+		return "", "", err
+	}
+	snapshot := api.Snapshot{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace},
+		Spec:       api.SnapshotSpec{DatabaseName: dbName, Backend: backend},
+	}
+	folder, err := snapshot.Location()
+	if err != nil {
+		return "", "", err
+	}
+	return bucket, filepath.Join(folder, oplogFolder), nil
+}
+
+func oplogOSMSecretName(mongodb *api.MongoDB) string {
+	return fmt.Sprintf("%v-oplog-osm", mongodb.OffshootName())
+}
+
+func oplogArchiverName(stsName string) string {
+	return fmt.Sprintf("%v-%v", stsName, oplogArchiverRole)
+}
+
+// oplogArchiveTargets returns the replicasets whose oplog is archived: the replicaset, or every shard.
+// The config servers are not archived, as the data is restored into a replicaset.
+func oplogArchiveTargets(mongodb *api.MongoDB) []api.MongoDBComponentStatus {
+	var targets []api.MongoDBComponentStatus
+	for _, component := range desiredComponents(mongodb) {
+		if component.status.Type == api.MongoDBComponentReplicaSet || component.status.Type == api.MongoDBComponentShard {
+			targets = append(targets, component.status)
+		}
+	}
+	return targets
+}
+
+// ensureOplogArchive runs an archiver Deployment per target of spec.oplogArchive, and removes the archivers
+// that are no longer needed.
+func (c *Controller) ensureOplogArchive(mongodb *api.MongoDB) error {
+	desired := sets.NewString()
+	if spec := mongodb.Spec.OplogArchive; spec != nil {
+		if err := c.ensureOplogOSMSecret(mongodb, spec.Backend); err != nil {
+			return err
+		}
+		for _, target := range oplogArchiveTargets(mongodb) {
+			if err := c.ensureOplogArchiver(mongodb, target); err != nil {
+				return err
+			}
+			desired.Insert(oplogArchiverName(target.Name))
+		}
+	}
+
+	selector := labels.SelectorFromSet(mongodb.OffshootSelectors()).String() + "," + oplogArchiverLabel
+	deployments, err := c.Client.AppsV1().Deployments(mongodb.Namespace).List(metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return err
+	}
+	for _, deployment := range deployments.Items {
+		if desired.Has(deployment.Name) {
+			continue
+		}
+		err := c.Client.AppsV1().Deployments(mongodb.Namespace).Delete(deployment.Name, meta_util.DeleteInBackground())
+		if err != nil && !kerr.IsNotFound(err) {
+			return err
+		}
+	}
+	if mongodb.Spec.OplogArchive == nil {
+		err := c.Client.CoreV1().Secrets(mongodb.Namespace).Delete(oplogOSMSecretName(mongodb), &metav1.DeleteOptions{})
+		if err != nil && !kerr.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Controller) ensureOplogOSMSecret(mongodb *api.MongoDB, backend store.Backend) error {
+	ref, err := reference.GetReference(clientsetscheme.Scheme, mongodb)
+	if err != nil {
+		return err
+	}
+	secret, err := storage.NewOSMSecret(c.Client, oplogOSMSecretName(mongodb), mongodb.Namespace, backend)
+	if err != nil {
+		return err
+	}
+	_, _, err = core_util.CreateOrPatchSecret(c.Client, secret.ObjectMeta, func(in *core.Secret) *core.Secret {
+		in.Labels = mongodb.OffshootLabels()
+		core_util.EnsureOwnerReference(&in.ObjectMeta, ref)
+		in.Data = secret.Data
+		return in
+	})
+	return err
+}
+
+func (c *Controller) ensureOplogArchiver(mongodb *api.MongoDB, target api.MongoDBComponentStatus) error {
+	spec := mongodb.Spec.OplogArchive
+	pt := spec.PodTemplate
+
+	ref, err := reference.GetReference(clientsetscheme.Scheme, mongodb)
+	if err != nil {
+		return err
+	}
+	bucket, folder, err := oplogArchiveLocation(spec.Backend, mongodb.Namespace, mongodb.Name)
+	if err != nil {
+		return err
+	}
+	interval := oplog.DefaultChunkInterval
+	if spec.ChunkInterval != nil {
+		interval = spec.ChunkInterval.Duration
+	}
+
+	certRevision, err := c.certificateRevision(mongodb)
+	if err != nil {
+		return err
+	}
+	credentialRevision, err := c.credentialRevision(mongodb)
+	if err != nil {
+		return err
+	}
+	// the archiver reads the credentials and the client certificate once, so it is restarted when they change.
+	podAnnotations := upsertAnnotation(pt.Annotations, certRevisionAnnotation, certRevision)
+	podAnnotations = upsertAnnotation(podAnnotations, credentialRevisionAnnotation, credentialRevision)
+
+	name := oplogArchiverName(target.Name)
+	selectors := map[string]string{
+		api.LabelDatabaseKind: api.ResourceKindMongoDB,
+		oplogArchiverLabel:    target.Name,
+	}
+	deploymentLabels := core_util.UpsertMap(mongodb.OffshootLabels(), map[string]string{
+		meta_util.ComponentLabelKey: oplogArchiverRole,
+		oplogArchiverLabel:          target.Name,
+	})
+
+	args := []string{
+		oplogArchiverRole,
+		fmt.Sprintf("--hosts=%v", strings.Join(stsMemberHosts(mongodb, target.Name, target.Replicas), ",")),
+		fmt.Sprintf("--replica-set=%v", target.ReplicaSetName),
+		fmt.Sprintf("--bucket=%v", bucket),
+		fmt.Sprintf("--folder=%v", folder),
+		fmt.Sprintf("--chunk-interval=%v", interval),
+		fmt.Sprintf("--enable-analytics=%v", c.EnableAnalytics),
+	}
+	volumeMounts, volumes := oplogBackendVolumes(oplogOSMSecretName(mongodb), spec.Backend)
+	args, volumeMounts, volumes = withOplogTLS(mongodb, args, volumeMounts, volumes)
+
+	deploymentMeta := metav1.ObjectMeta{
+		Name:      name,
+		Namespace: mongodb.Namespace,
+	}
+	_, _, err = app_util.CreateOrPatchDeployment(c.Client, deploymentMeta, func(in *apps.Deployment) *apps.Deployment {
+		in.Labels = deploymentLabels
+		in.Annotations = pt.Controller.Annotations
+		core_util.EnsureOwnerReference(&in.ObjectMeta, ref)
+
+		// a single archiver per replicaset, as two would write overlapping chunks.
+		in.Spec.Replicas = types.Int32P(1)
+		in.Spec.Strategy = apps.DeploymentStrategy{Type: apps.RecreateDeploymentStrategyType}
+		in.Spec.Selector = &metav1.LabelSelector{
+			MatchLabels: selectors,
+		}
+		in.Spec.Template.Labels = selectors
+		in.Spec.Template.Annotations = podAnnotations
+		in.Spec.Template.Spec.Containers = core_util.UpsertContainer(
+			in.Spec.Template.Spec.Containers,
+			core.Container{
+				Name:            oplogArchiverRole,
+				Image:           c.oplogArchiverImage,
+				ImagePullPolicy: core.PullIfNotPresent,
+				Args:            meta_util.UpsertArgumentList(args, pt.Spec.Args),
+				Env:             core_util.UpsertEnvVars(c.oplogEnvs(mongodb), pt.Spec.Env...),
+				Resources:       pt.Spec.Resources,
+				VolumeMounts:    volumeMounts,
+			})
+		in.Spec.Template.Spec.Volumes = core_util.UpsertVolume(in.Spec.Template.Spec.Volumes, volumes...)
+
+		in.Spec.Template.Spec.NodeSelector = pt.Spec.NodeSelector
+		in.Spec.Template.Spec.Affinity = pt.Spec.Affinity
+		if pt.Spec.SchedulerName != "" {
+			in.Spec.Template.Spec.SchedulerName = pt.Spec.SchedulerName
+		}
+		in.Spec.Template.Spec.Tolerations = pt.Spec.Tolerations
+		in.Spec.Template.Spec.ImagePullSecrets = pt.Spec.ImagePullSecrets
+		in.Spec.Template.Spec.PriorityClassName = pt.Spec.PriorityClassName
+		in.Spec.Template.Spec.Priority = pt.Spec.Priority
+		in.Spec.Template.Spec.SecurityContext = pt.Spec.SecurityContext
+		in.Spec.Template.Spec.ServiceAccountName = pt.Spec.ServiceAccountName
+		return in
+	})
+	return err
+}
+
+// withOplogTLS adds the client certificate in spec.certificateSecret to the arguments and the volumes of
+// an archiver or a replay, if TLS is enabled.
+func withOplogTLS(mongodb *api.MongoDB, args []string, mounts []core.VolumeMount, volumes []core.Volume) ([]string, []core.VolumeMount, []core.Volume) {
+	if !isTLSEnabled(mongodb) {
+		return args, mounts, volumes
+	}
+	args = append(args,
+		fmt.Sprintf("--ca-file=%v", filepath.Join(oplogTLSPath, api.MongoTLSCertFileName)),
+		fmt.Sprintf("--pem-file=%v", filepath.Join(oplogTLSPath, api.MongoClientPemFileName)),
+	)
+	mounts = append(mounts, core.VolumeMount{
+		Name:      oplogTLSVolume,
+		MountPath: oplogTLSPath,
+		ReadOnly:  true,
+	})
+	volumes = append(volumes, core.Volume{
+		Name: oplogTLSVolume,
+		VolumeSource: core.VolumeSource{
+			Secret: &core.SecretVolumeSource{
+				SecretName: mongodb.Spec.CertificateSecret.SecretName,
+			},
+		},
+	})
+	return args, mounts, volumes
+}
+
+// addOplogReplay makes the restore job replay the oplog in spec.init.mongodbOplog from the start of snapshot
+// until the target time, after restoring snapshot. The restore runs as an init container, so that the oplog is
+// replayed only once the snapshot is restored.
+func (c *Controller) addOplogReplay(mongodb *api.MongoDB, snapshot *api.Snapshot, job *batch.Job) error {
+	source := mongodb.Spec.Init.MongoDBOplog
+	if snapshot.Status.StartTime == nil {
+		return errors.Errorf(`snapshot "%v/%v" has not started`, snapshot.Namespace, snapshot.Name)
+	}
+	bucket, folder, err := oplogArchiveLocation(source.Backend, snapshot.Namespace, source.DatabaseName)
+	if err != nil {
+		return err
+	}
+
+	ref, err := reference.GetReference(clientsetscheme.Scheme, mongodb)
+	if err != nil {
+		return err
+	}
+	secretName := fmt.Sprintf("%v-%v-osm", mongodb.OffshootName(), oplogReplayRole)
+	secret, err := storage.NewOSMSecret(c.Client, secretName, mongodb.Namespace, source.Backend)
+	if err != nil {
+		return err
+	}
+	_, _, err = core_util.CreateOrPatchSecret(c.Client, secret.ObjectMeta, func(in *core.Secret) *core.Secret {
+		in.Labels = mongodb.OffshootLabels()
+		core_util.EnsureOwnerReference(&in.ObjectMeta, ref)
+		in.Data = secret.Data
+		return in
+	})
+	if err != nil {
+		return err
+	}
+
+	target := desiredComponents(mongodb)[0].status
+	args := []string{
+		oplogReplayRole,
+		fmt.Sprintf("--hosts=%v", strings.Join(stsMemberHosts(mongodb, target.Name, target.Replicas), ",")),
+		fmt.Sprintf("--replica-set=%v", target.ReplicaSetName),
+		fmt.Sprintf("--bucket=%v", bucket),
+		fmt.Sprintf("--folder=%v", folder),
+		fmt.Sprintf("--from=%v", snapshot.Status.StartTime.UTC().Format(time.RFC3339)),
+		fmt.Sprintf("--until=%v", source.TargetTime.UTC().Format(time.RFC3339)),
+		fmt.Sprintf("--enable-analytics=%v", c.EnableAnalytics),
+	}
+	volumeMounts, volumes := oplogBackendVolumes(secretName, source.Backend)
+	args, volumeMounts, volumes = withOplogTLS(mongodb, args, volumeMounts, volumes)
+
+	podSpec := &job.Spec.Template.Spec
+	podSpec.InitContainers = append(podSpec.InitContainers, podSpec.Containers...)
+	podSpec.Containers = []core.Container{
+		{
+			Name:         oplogReplayRole,
+			Image:        c.oplogArchiverImage,
+			Args:         args,
+			Env:          c.oplogEnvs(mongodb),
+			Resources:    snapshot.Spec.PodTemplate.Spec.Resources,
+			VolumeMounts: volumeMounts,
+		},
+	}
+	podSpec.Volumes = append(podSpec.Volumes, volumes...)
+	return nil
+}
+
+// oplogBackendVolumes mounts the osm config in secret osmSecret and, for a local backend, its volume.
+func oplogBackendVolumes(osmSecret string, backend store.Backend) ([]core.VolumeMount, []core.Volume) {
+	mounts := []core.VolumeMount{
+		{
+			Name:      osmConfigVolume,
+			MountPath: storage.SecretMountPath,
+			ReadOnly:  true,
+		},
+	}
+	volumes := []core.Volume{
+		{
+			Name: osmConfigVolume,
+			VolumeSource: core.VolumeSource{
+				Secret: &core.SecretVolumeSource{
+					SecretName: osmSecret,
+				},
+			},
+		},
+	}
+	if backend.Local != nil {
+		mounts = append(mounts, core.VolumeMount{
+			Name:      localBackendVolume,
+			MountPath: backend.Local.MountPath,
+			SubPath:   backend.Local.SubPath,
+		})
+		volumes = append(volumes, core.Volume{
+			Name:         localBackendVolume,
+			VolumeSource: backend.Local.VolumeSource,
+		})
+	}
+	return mounts, volumes
+}
+
+// oplogEnvs passes the root credentials in spec.databaseSecret as DB_USER and DB_PASSWORD.
+func (c *Controller) oplogEnvs(mongodb *api.MongoDB) []core.EnvVar {
+	return []core.EnvVar{
+		{
+			Name:  analytics.Key,
+			Value: c.AnalyticsClientID,
+		},
+		{
+			Name: "DB_USER",
+			ValueFrom: &core.EnvVarSource{
+				SecretKeyRef: &core.SecretKeySelector{
+					LocalObjectReference: core.LocalObjectReference{
+						Name: mongodb.Spec.DatabaseSecret.SecretName,
+					},
+					Key: KeyMongoDBUser,
+				},
+			},
+		},
+		{
+			Name: "DB_PASSWORD",
+			ValueFrom: &core.EnvVarSource{
+				SecretKeyRef: &core.SecretKeySelector{
+					LocalObjectReference: core.LocalObjectReference{
+						Name: mongodb.Spec.DatabaseSecret.SecretName,
+					},
+					Key: KeyMongoDBPassword,
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"fmt"
+	"strconv"
+
+	core "k8s.io/api/core/v1"
+	core_util "kmodules.xyz/client-go/core/v1"
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+)
+
+const (
+	slowQueryLogContainerName = "slow-query-log"
+
+	logDirectoryName = "logdir"
+	logDirectoryPath = "/var/log/mongodb"
+	mongodLogFile    = logDirectoryPath + "/mongod.log"
+)
+
+// profilingModes are the operationProfiling.mode of the configuration file, by profiling level.
+var profilingModes = []string{"off", "slowOp", "all"}
+
+// profiledConfiguration returns cfg with the options of profiling p, that take precedence. While the slow
+// operations are shipped by the sidecar, the server logs to a file the sidecar follows.
+func profiledConfiguration(cfg *api.MongoDBConfiguration, p *api.MongoDBProfilingSpec) *api.MongoDBConfiguration {
+	if p == nil {
+		return cfg
+	}
+	out := cfg.DeepCopy()
+	if out == nil {
+		out = &api.MongoDBConfiguration{}
+	}
+	if out.OperationProfiling == nil {
+		out.OperationProfiling = &api.MongoDBOperationProfilingConfiguration{}
+	}
+	if p.Level != nil && int(*p.Level) < len(profilingModes) {
+		out.OperationProfiling.Mode = profilingModes[*p.Level]
+	}
+	if p.SlowMs != nil {
+		out.OperationProfiling.SlowOpThresholdMs = p.SlowMs
+	}
+
+	options := map[string]string{}
+	if p.SampleRate != nil {
+		options["operationProfiling.slowOpSampleRate"] = strconv.FormatFloat(*p.SampleRate, 'f', -1, 64)
+	}
+	if p.SlowQueryLog != nil {
+		options["systemLog.destination"] = "file"
+		options["systemLog.path"] = mongodLogFile
+		options["systemLog.logAppend"] = "true"
+	}
+	out.Options = core_util.UpsertMap(out.Options, options)
+	return out
+}
+
+// upsertSlowQueryLog adds the sidecar that ships the slow operations of the server to template, if profiling
+// p asks for it, or removes it.
+func upsertSlowQueryLog(template core.PodTemplateSpec, p *api.MongoDBProfilingSpec, image string) core.PodTemplateSpec {
+	if p == nil || p.SlowQueryLog == nil {
+		template.Spec.Containers = core_util.EnsureContainerDeleted(template.Spec.Containers, slowQueryLogContainerName)
+		for i, container := range template.Spec.Containers {
+			if container.Name == api.ResourceSingularMongoDB {
+				template.Spec.Containers[i].VolumeMounts = core_util.EnsureVolumeMountDeleted(container.VolumeMounts, logDirectoryName)
+			}
+		}
+		template.Spec.Volumes = core_util.EnsureVolumeDeleted(template.Spec.Volumes, logDirectoryName)
+		return template
+	}
+
+	mount := core.VolumeMount{
+		Name:      logDirectoryName,
+		MountPath: logDirectoryPath,
+	}
+	for i, container := range template.Spec.Containers {
+		if container.Name == api.ResourceSingularMongoDB {
+			template.Spec.Containers[i].VolumeMounts = core_util.UpsertVolumeMount(container.VolumeMounts, mount)
+		}
+	}
+	template.Spec.Containers = core_util.UpsertContainer(template.Spec.Containers, core.Container{
+		Name:            slowQueryLogContainerName,
+		Image:           image,
+		ImagePullPolicy: core.PullIfNotPresent,
+		Args: []string{
+			slowQueryLogContainerName,
+			fmt.Sprintf("--log-file=%v", mongodLogFile),
+		},
+		Resources:    p.SlowQueryLog.Resources,
+		VolumeMounts: []core.VolumeMount{mount},
+	})
+	template.Spec.Volumes = core_util.UpsertVolume(template.Spec.Volumes, core.Volume{
+		Name: logDirectoryName,
+		VolumeSource: core.VolumeSource{
+			EmptyDir: &core.EmptyDirVolumeSource{},
+		},
+	})
+	return template
+}
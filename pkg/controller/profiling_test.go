@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"testing"
+
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+)
+
+func TestProfiledConfiguration(t *testing.T) {
+	level, slowMs, sampleRate := int32(1), int32(200), 0.5
+	cfg := &api.MongoDBConfiguration{
+		OperationProfiling: &api.MongoDBOperationProfilingConfiguration{Mode: "all"},
+		Options:            map[string]string{"systemLog.verbosity": "1"},
+	}
+	p := &api.MongoDBProfilingSpec{Level: &level, SlowMs: &slowMs, SampleRate: &sampleRate, SlowQueryLog: &api.MongoDBSlowQueryLogSpec{}}
+
+	options := configurationOptions(profiledConfiguration(cfg, p))
+	for option, want := range map[string]interface{}{
+		"operationProfiling.mode":              "slowOp",
+		"operationProfiling.slowOpThresholdMs": 200,
+		"operationProfiling.slowOpSampleRate":  0.5,
+		"systemLog.destination":                "file",
+		"systemLog.path":                       mongodLogFile,
+		"systemLog.verbosity":                  int64(1),
+	} {
+		if options[option] != want {
+			t.Errorf("expected %v to be %v, got %v", option, want, options[option])
+		}
+	}
+	if cfg.OperationProfiling.Mode != "all" || len(cfg.Options) != 1 {
+		t.Errorf("expected spec.configuration to be left as it is, got %+v", cfg)
+	}
+}
+
+func TestUpsertSlowQueryLog(t *testing.T) {
+	template := core.PodTemplateSpec{
+		Spec: core.PodSpec{
+			Containers: []core.Container{{Name: api.ResourceSingularMongoDB}},
+		},
+	}
+	p := &api.MongoDBProfilingSpec{SlowQueryLog: &api.MongoDBSlowQueryLogSpec{}}
+
+	containers := func() sets.String {
+		names := sets.NewString()
+		for _, c := range template.Spec.Containers {
+			names.Insert(c.Name)
+		}
+		return names
+	}
+
+	template = upsertSlowQueryLog(template, p, "kubedb/mg-operator")
+	if !containers().Has(slowQueryLogContainerName) || len(template.Spec.Volumes) != 1 ||
+		len(template.Spec.Containers[0].VolumeMounts) != 1 {
+		t.Fatalf("expected the sidecar to share the log directory of mongod, got %+v", template.Spec)
+	}
+
+	template = upsertSlowQueryLog(template, nil, "kubedb/mg-operator")
+	if containers().Has(slowQueryLogContainerName) || len(template.Spec.Volumes) != 0 ||
+		len(template.Spec.Containers[0].VolumeMounts) != 0 {
+		t.Errorf("expected the sidecar and the log directory to be removed, got %+v", template.Spec)
+	}
+}
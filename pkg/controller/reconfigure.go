@@ -0,0 +1,378 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+	apps "k8s.io/api/apps/v1"
+	core "k8s.io/api/core/v1"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+	"kubedb.dev/apimachinery/pkg/eventer"
+	"kubedb.dev/mongodb/pkg/dbclient"
+)
+
+// runtimeOptions maps the options of the configuration file, that can be changed on a running server,
+// to the server parameter that changes them.
+var runtimeOptions = map[string]string{
+	"systemLog.verbosity":                         "logLevel",
+	"systemLog.quiet":                             "quiet",
+	"systemLog.traceAllExceptions":                "traceExceptions",
+	"storage.syncPeriodSecs":                      "syncdelay",
+	"storage.journal.commitIntervalMs":            "journalCommitInterval",
+	"storage.wiredTiger.engineConfig.cacheSizeGB": "wiredTigerEngineRuntimeConfig",
+}
+
+// runtimeSetParameters are the server parameters that can be changed on a running server.
+// Any other setParameter option is set at startup only.
+// ref: https://docs.mongodb.com/manual/reference/parameters/
+var runtimeSetParameters = sets.NewString(
+	"cursorTimeoutMillis",
+	"diagnosticDataCollectionEnabled",
+	"diagnosticDataCollectionPeriodMillis",
+	"failIndexKeyTooLong",
+	"internalQueryExecMaxBlockingSortBytes",
+	"journalCommitInterval",
+	"logLevel",
+	"maxIndexBuildMemoryUsageMegabytes",
+	"maxLogSizeKB",
+	"maxTransactionLockRequestTimeoutMillis",
+	"notablescan",
+	"quiet",
+	"syncdelay",
+	"traceExceptions",
+	"transactionLifetimeLimitSeconds",
+	"ttlMonitorEnabled",
+	"wiredTigerConcurrentReadTransactions",
+	"wiredTigerConcurrentWriteTransactions",
+)
+
+const setParameterPrefix = "setParameter."
+
+// restartAnnotations are the annotations of the pod template that are changed to restart the pods.
+var restartAnnotations = []string{configHashAnnotation, certRevisionAnnotation, credentialRevisionAnnotation}
+
+// runtimeParameter returns the server parameter that changes option on a running server, if any.
+func runtimeParameter(option string) (string, bool) {
+	if strings.HasPrefix(option, setParameterPrefix) {
+		name := strings.TrimPrefix(option, setParameterPrefix)
+		return name, runtimeSetParameters.Has(name)
+	}
+	name, ok := runtimeOptions[option]
+	return name, ok
+}
+
+// runtimeParameterValue converts the value of option in the configuration file to the value of its server parameter.
+func runtimeParameterValue(option string, value interface{}) interface{} {
+	if option == "storage.wiredTiger.engineConfig.cacheSizeGB" {
+		if gb, ok := value.(float64); ok {
+			return fmt.Sprintf("cache_size=%dM", int64(gb*1024))
+		}
+		return fmt.Sprintf("cache_size=%vG", value)
+	}
+	return value
+}
+
+// runtimeParameters returns the server parameters, by name, that carry the runtime options of the flattened conf.
+func runtimeParameters(conf map[string]interface{}) map[string]interface{} {
+	params := map[string]interface{}{}
+	for option, value := range conf {
+		if name, ok := runtimeParameter(option); ok {
+			params[name] = runtimeParameterValue(option, value)
+		}
+	}
+	return params
+}
+
+// restartOptions returns the options of the flattened conf that take effect on restart only.
+func restartOptions(conf map[string]interface{}) map[string]interface{} {
+	options := map[string]interface{}{}
+	for option, value := range conf {
+		if _, ok := runtimeParameter(option); !ok {
+			options[option] = value
+		}
+	}
+	return options
+}
+
+// flattenConfig returns the options of a configuration file by their dotted path.
+func flattenConfig(conf map[string]interface{}) map[string]interface{} {
+	out := map[string]interface{}{}
+	var flatten func(prefix string, section map[string]interface{})
+	flatten = func(prefix string, section map[string]interface{}) {
+		for k, v := range section {
+			if sub, ok := v.(map[string]interface{}); ok {
+				flatten(prefix+k+".", sub)
+				continue
+			}
+			out[prefix+k] = v
+		}
+	}
+	flatten("", conf)
+	return out
+}
+
+// parseConfig parses a configuration file rendered by renderConfiguration.
+func parseConfig(data []byte) (map[string]interface{}, error) {
+	var parsed map[interface{}]interface{}
+	if err := yaml.Unmarshal(data, &parsed); err != nil {
+		return nil, errors.Wrap(err, "failed to parse configuration file")
+	}
+	return normalizeConfig(parsed), nil
+}
+
+// applyRuntimeConfiguration sets the runtime options of the configuration of every component on each of its
+// members, where the member runs with a different value. Options that take effect on restart only are applied
+// by restarting the pods, as the hash of these options is part of the pod template.
+func (c *Controller) applyRuntimeConfiguration(mongodb *api.MongoDB) error {
+	for _, component := range desiredComponents(mongodb) {
+		if componentConfiguration(mongodb, component.status.Type) == nil {
+			continue
+		}
+		cm, err := c.Client.CoreV1().ConfigMaps(mongodb.Namespace).Get(configMapName(component.status.Name), metav1.GetOptions{})
+		if err != nil {
+			if kerr.IsNotFound(err) {
+				continue
+			}
+			return err
+		}
+		conf, err := parseConfig([]byte(cm.Data[mongodConfigFileName]))
+		if err != nil {
+			return err
+		}
+		params := runtimeParameters(flattenConfig(conf))
+		if len(params) == 0 {
+			continue
+		}
+
+		hosts := stsMemberHosts(mongodb, component.status.Name, component.status.Replicas)
+		connect := func(ctx context.Context, host string) (*dbclient.Client, error) {
+			return c.connectToMember(ctx, mongodb, host)
+		}
+		if component.status.Type == api.MongoDBComponentMongos {
+			if hosts, err = c.mongosAddresses(mongodb); err != nil {
+				return err
+			}
+			// the pods of the Deployment have no DNS record of their own, so they are dialed by IP and
+			// verified against the mongos Service that their certificate names.
+			connect = func(ctx context.Context, address string) (*dbclient.Client, error) {
+				return c.connectToAddress(ctx, mongodb, mongosServiceHost(mongodb), address)
+			}
+		}
+		if err := c.setRuntimeParameters(mongodb, component.status.Name, hosts, connect, params); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setRuntimeParameters sets params on the server at each of hosts, that connect dials. Every change is recorded
+// as an event on mongodb.
+func (c *Controller) setRuntimeParameters(
+	mongodb *api.MongoDB,
+	component string,
+	hosts []string,
+	connect func(ctx context.Context, host string) (*dbclient.Client, error),
+	params map[string]interface{},
+) error {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	changed := sets.NewString()
+	var failed error
+	for _, host := range hosts {
+		err := func() error {
+			ctx, cancel := context.WithTimeout(context.Background(), membershipTimeout)
+			defer cancel()
+
+			client, err := connect(ctx, host)
+			if err != nil {
+				return err
+			}
+			defer client.Close()
+
+			current, err := client.GetParameters(ctx, names...)
+			if err != nil {
+				return err
+			}
+			for _, name := range names {
+				if fmt.Sprint(current[name]) == fmt.Sprint(params[name]) {
+					continue
+				}
+				if err := client.SetParameter(ctx, name, params[name]); err != nil {
+					c.recorder.Eventf(
+						mongodb,
+						core.EventTypeWarning,
+						eventer.EventReasonFailedToUpdate,
+						"Failed to set parameter %v to %v on %v. Reason: %v",
+						name,
+						params[name],
+						host,
+						err,
+					)
+					return err
+				}
+				changed.Insert(name)
+			}
+			return nil
+		}()
+		if err != nil && failed == nil {
+			failed = err
+		}
+	}
+
+	for _, name := range changed.List() {
+		c.recorder.Eventf(
+			mongodb,
+			core.EventTypeNormal,
+			eventer.EventReasonSuccessful,
+			"Set parameter %v to %v on %v without restart",
+			name,
+			params[name],
+			component,
+		)
+	}
+	return failed
+}
+
+// restartOutdatedMembers restarts, one at a time, the pods of the replicasets that are not recreated
+// from the current template of their StatefulSet yet, when the operator has switched the StatefulSet to
+// OnDelete strategy to restart them in order. A restartInProgressError is returned until all are restarted.
+func (c *Controller) restartOutdatedMembers(mongodb *api.MongoDB) error {
+	// Pods are restarted by the upgrade, or by the user.
+	if isUpgradeRunning(mongodb) || mongodb.Spec.UpdateStrategy.Type == apps.OnDeleteStatefulSetStrategyType {
+		return nil
+	}
+
+	for _, component := range desiredComponents(mongodb) {
+		if component.status.Type == api.MongoDBComponentStandalone || component.status.Type == api.MongoDBComponentMongos {
+			continue
+		}
+		statefulSet, err := c.Client.AppsV1().StatefulSets(mongodb.Namespace).Get(component.status.Name, metav1.GetOptions{})
+		if err != nil {
+			if kerr.IsNotFound(err) {
+				continue
+			}
+			return err
+		}
+		if statefulSet.Spec.UpdateStrategy.Type != apps.OnDeleteStatefulSetStrategyType {
+			continue
+		}
+		if statefulSet.Status.ObservedGeneration < statefulSet.Generation {
+			return &restartInProgressError{statefulSet: statefulSet.Name}
+		}
+
+		outdated, err := c.outdatedPods(statefulSet)
+		if err != nil {
+			return err
+		}
+		if len(outdated) == 0 {
+			continue
+		}
+		if err := c.restartNextMember(mongodb, statefulSet, component.status.Type, outdated, "apply configuration or certificate changes"); err != nil {
+			return err
+		}
+		return &restartInProgressError{statefulSet: statefulSet.Name}
+	}
+	return nil
+}
+
+// statefulSetUpdateStrategy returns the update strategy of the StatefulSet of opts. The pods of a replicaset
+// are restarted by the operator, one at a time and primary last, during an upgrade and when options that
+// take effect on restart only, or the certificates, are changed, ie, any of restartAnnotations in podAnnotations.
+// spec.updateStrategy is used otherwise.
+func (c *Controller) statefulSetUpdateStrategy(mongodb *api.MongoDB, opts workloadOptions, podAnnotations map[string]string) (apps.StatefulSetUpdateStrategy, error) {
+	onDelete := apps.StatefulSetUpdateStrategy{Type: apps.OnDeleteStatefulSetStrategyType}
+	if isUpgradeRunning(mongodb) {
+		return onDelete, nil
+	}
+	if opts.replSetName == "" || mongodb.Spec.UpdateStrategy.Type == apps.OnDeleteStatefulSetStrategyType {
+		return mongodb.Spec.UpdateStrategy, nil
+	}
+
+	statefulSet, err := c.Client.AppsV1().StatefulSets(mongodb.Namespace).Get(opts.stsName, metav1.GetOptions{})
+	if err != nil {
+		if kerr.IsNotFound(err) {
+			return mongodb.Spec.UpdateStrategy, nil
+		}
+		return onDelete, err
+	}
+	for _, key := range restartAnnotations {
+		if statefulSet.Spec.Template.Annotations[key] != podAnnotations[key] {
+			return onDelete, nil
+		}
+	}
+	// keep restarting in order, until every pod is recreated
+	if statefulSet.Spec.UpdateStrategy.Type == apps.OnDeleteStatefulSetStrategyType {
+		if statefulSet.Status.ObservedGeneration < statefulSet.Generation {
+			return onDelete, nil
+		}
+		outdated, err := c.outdatedPods(statefulSet)
+		if err != nil {
+			return onDelete, err
+		}
+		if len(outdated) > 0 {
+			return onDelete, nil
+		}
+	}
+	return mongodb.Spec.UpdateStrategy, nil
+}
+
+// outdatedPods returns the pods of statefulSet that are not created from its current template.
+func (c *Controller) outdatedPods(statefulSet *apps.StatefulSet) ([]string, error) {
+	pods, err := c.Client.CoreV1().Pods(statefulSet.Namespace).List(metav1.ListOptions{
+		LabelSelector: metav1.FormatLabelSelector(statefulSet.Spec.Selector),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var outdated []string
+	for _, pod := range pods.Items {
+		if pod.Labels[apps.StatefulSetRevisionLabel] != statefulSet.Status.UpdateRevision {
+			outdated = append(outdated, pod.Name)
+		}
+	}
+	return outdated, nil
+}
+
+// mongosAddresses returns the IP:port of the running mongos pods.
+func (c *Controller) mongosAddresses(mongodb *api.MongoDB) ([]string, error) {
+	pods, err := c.Client.CoreV1().Pods(mongodb.Namespace).List(metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(mongodb.MongosSelectors()).String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var addresses []string
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == core.PodRunning && pod.Status.PodIP != "" {
+			addresses = append(addresses, net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(api.MongoDBMongosPort)))
+		}
+	}
+	return addresses, nil
+}
+
+// componentConfiguration returns the configuration of the component of type t.
+func componentConfiguration(mongodb *api.MongoDB, t api.MongoDBComponentType) *api.MongoDBConfiguration {
+	switch t {
+	case api.MongoDBComponentShard:
+		return mongodb.Spec.ShardTopology.Shard.Configuration
+	case api.MongoDBComponentConfigServer:
+		return mongodb.Spec.ShardTopology.ConfigServer.Configuration
+	case api.MongoDBComponentMongos:
+		return mongodb.Spec.ShardTopology.Mongos.Configuration
+	}
+	return mongodb.Spec.Configuration
+}
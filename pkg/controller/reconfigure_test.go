@@ -0,0 +1,177 @@
+package controller
+
+import (
+	"crypto/x509"
+	"net"
+	"reflect"
+	"testing"
+
+	"gomodules.xyz/cert"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	amc "kubedb.dev/apimachinery/pkg/controller"
+)
+
+func TestRuntimeParameter(t *testing.T) {
+	cases := []struct {
+		option  string
+		name    string
+		runtime bool
+	}{
+		{"systemLog.verbosity", "logLevel", true},
+		{"storage.wiredTiger.engineConfig.cacheSizeGB", "wiredTigerEngineRuntimeConfig", true},
+		{"setParameter.cursorTimeoutMillis", "cursorTimeoutMillis", true},
+		{"setParameter.enableLocalhostAuthBypass", "enableLocalhostAuthBypass", false},
+		{"net.maxIncomingConnections", "", false},
+		{"storage.dbPath", "", false},
+	}
+	for _, c := range cases {
+		t.Run(c.option, func(t *testing.T) {
+			name, ok := runtimeParameter(c.option)
+			if name != c.name || ok != c.runtime {
+				t.Errorf("expected (%q, %v), got (%q, %v)", c.name, c.runtime, name, ok)
+			}
+		})
+	}
+}
+
+func TestRuntimeParameters(t *testing.T) {
+	conf := map[string]interface{}{
+		"systemLog.verbosity":                         int64(1),
+		"systemLog.quiet":                             true,
+		"storage.wiredTiger.engineConfig.cacheSizeGB": 1.5,
+		"setParameter.cursorTimeoutMillis":            int64(600000),
+		"setParameter.enableLocalhostAuthBypass":      false,
+		"net.maxIncomingConnections":                  500,
+	}
+	want := map[string]interface{}{
+		"logLevel":                      int64(1),
+		"quiet":                         true,
+		"wiredTigerEngineRuntimeConfig": "cache_size=1536M",
+		"cursorTimeoutMillis":           int64(600000),
+	}
+	if got := runtimeParameters(conf); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	// a cache size in whole GB is parsed as an integer
+	conf = map[string]interface{}{"storage.wiredTiger.engineConfig.cacheSizeGB": int64(2)}
+	want = map[string]interface{}{"wiredTigerEngineRuntimeConfig": "cache_size=2G"}
+	if got := runtimeParameters(conf); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestRestartOptions(t *testing.T) {
+	conf := map[string]interface{}{
+		"systemLog.verbosity":                    int64(1),
+		"setParameter.cursorTimeoutMillis":       int64(600000),
+		"setParameter.enableLocalhostAuthBypass": false,
+		"net.maxIncomingConnections":             500,
+		"storage.dbPath":                         "/data/db",
+	}
+	want := map[string]interface{}{
+		"setParameter.enableLocalhostAuthBypass": false,
+		"net.maxIncomingConnections":             500,
+		"storage.dbPath":                         "/data/db",
+	}
+	if got := restartOptions(conf); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestFlattenConfig(t *testing.T) {
+	conf, err := parseConfig([]byte(`
+net:
+  port: 27017
+  compression:
+    compressors: snappy,zstd
+storage:
+  wiredTiger:
+    engineConfig:
+      cacheSizeGB: 0.25
+setParameter:
+  cursorTimeoutMillis: 600000
+replication:
+  replSetName: rs0
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"net.port":                    27017,
+		"net.compression.compressors": "snappy,zstd",
+		"storage.wiredTiger.engineConfig.cacheSizeGB": 0.25,
+		"setParameter.cursorTimeoutMillis":            600000,
+		"replication.replSetName":                     "rs0",
+	}
+	if got := flattenConfig(conf); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if got := flattenConfig(map[string]interface{}{}); len(got) != 0 {
+		t.Errorf("expected no options, got %v", got)
+	}
+}
+
+func TestMongosAddresses(t *testing.T) {
+	mongodb := shardedMongoDB()
+	mongosPod := func(name, ip string, phase core.PodPhase) *core.Pod {
+		return &core.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: mongodb.Namespace,
+				Labels:    mongodb.MongosSelectors(),
+			},
+			Status: core.PodStatus{
+				Phase: phase,
+				PodIP: ip,
+			},
+		}
+	}
+	c := &Controller{Controller: &amc.Controller{Client: fake.NewSimpleClientset(
+		mongosPod("mg-mongos-7d9f8b-x2kq9", "10.0.0.10", core.PodRunning),
+		mongosPod("mg-mongos-7d9f8b-p4lzt", "", core.PodPending),
+	)}}
+	addresses, err := c.mongosAddresses(mongodb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"10.0.0.10:27017"}; !reflect.DeepEqual(addresses, want) {
+		t.Errorf("expected %v, got %v", want, addresses)
+	}
+}
+
+func TestMongosServiceHost(t *testing.T) {
+	mongodb := shardedMongoDB()
+	host, _, err := net.SplitHostPort(mongosServiceHost(mongodb))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if host != "mg.demo.svc" {
+		t.Errorf("expected the mongos Service to be named mg.demo.svc, got %v", host)
+	}
+
+	// the mongos pods, dialed by IP, are verified against the Service, so the shared certificate must name it
+	caKey, caCert, err := createCaCertificate("ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := newPrivateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crtPEM, _, err := signCertificate(&caSigner{key: caKey, cert: caCert}, serverCertificateRequest(mongodb, mongodb.MongosNodeName()), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certs, err := cert.ParseCertsPEM(crtPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(caCert)
+	if _, err := certs[0].Verify(x509.VerifyOptions{DNSName: host, Roots: pool, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}}); err != nil {
+		t.Errorf("failed to verify mongos certificate for %v: %v", host, err)
+	}
+}
@@ -0,0 +1,108 @@
+package controller
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/appscode/go/log"
+	"github.com/appscode/go/types"
+	apps "k8s.io/api/apps/v1"
+	core "k8s.io/api/core/v1"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+	"kubedb.dev/apimachinery/pkg/eventer"
+	"kubedb.dev/mongodb/pkg/dbclient"
+)
+
+// restartNextMember restarts at most one of the pending pods of statefulSet, that serves a component of type t.
+// Secondaries are restarted first, highest ordinal first. The primary is restarted last, after it is stepped down,
+// so that the replicaset elects a new primary only once. The StatefulSet must use OnDelete strategy, so that
+// the deleted pod is recreated from the updated template. reason completes the events, eg, "upgrade it to 3.6".
+func (c *Controller) restartNextMember(mongodb *api.MongoDB, statefulSet *apps.StatefulSet, t api.MongoDBComponentType, pending []string, reason string) error {
+	if len(pending) == 0 {
+		return nil
+	}
+	pending = append([]string(nil), pending...)
+	sort.Slice(pending, func(i, j int) bool { return podOrdinal(pending[i]) > podOrdinal(pending[j]) })
+
+	pod := pending[0]
+	if t != api.MongoDBComponentStandalone {
+		ctx, cancel := context.WithTimeout(context.Background(), membershipTimeout)
+		defer cancel()
+
+		hosts := stsMemberHosts(mongodb, statefulSet.Name, types.Int32(statefulSet.Spec.Replicas))
+		client, primary, err := c.connectToPrimary(ctx, mongodb, hosts)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+
+		primaryPod := dbclient.PodName(primary)
+		for _, p := range pending {
+			if p != primaryPod {
+				pod = p
+				break
+			}
+		}
+		if pod == primaryPod {
+			if err := client.StepDown(ctx, stepDownSeconds); err != nil {
+				c.recorder.Eventf(
+					mongodb,
+					core.EventTypeWarning,
+					eventer.EventReasonFailedToUpdate,
+					"Failed to step down primary %v of %v to %v. Reason: %v",
+					primary,
+					statefulSet.Name,
+					reason,
+					err,
+				)
+				return err
+			}
+			c.recorder.Eventf(
+				mongodb,
+				core.EventTypeNormal,
+				eventer.EventReasonSuccessful,
+				"Stepped down primary %v of %v to %v",
+				primary,
+				statefulSet.Name,
+				reason,
+			)
+			return nil
+		}
+	}
+
+	if err := c.Client.CoreV1().Pods(mongodb.Namespace).Delete(pod, &metav1.DeleteOptions{}); err != nil && !kerr.IsNotFound(err) {
+		c.recorder.Eventf(
+			mongodb,
+			core.EventTypeWarning,
+			eventer.EventReasonFailedToDelete,
+			"Failed to restart pod %v to %v. Reason: %v",
+			pod,
+			reason,
+			err,
+		)
+		return err
+	}
+	c.recorder.Eventf(
+		mongodb,
+		core.EventTypeNormal,
+		eventer.EventReasonSuccessful,
+		"Restarted pod %v to %v",
+		pod,
+		reason,
+	)
+	log.Infof("restarted pod %v/%v of MongoDB %v to %v", mongodb.Namespace, pod, mongodb.Name, reason)
+	return nil
+}
+
+// podOrdinal returns the ordinal of a StatefulSet pod, or -1.
+func podOrdinal(pod string) int {
+	n, err := strconv.Atoi(pod[strings.LastIndex(pod, "-")+1:])
+	if err != nil {
+		return -1
+	}
+	return n
+}
@@ -0,0 +1,271 @@
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/appscode/go/log"
+	"github.com/appscode/go/types"
+	"github.com/pkg/errors"
+	batch "k8s.io/api/batch/v1"
+	core "k8s.io/api/core/v1"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	meta_util "kmodules.xyz/client-go/meta"
+	storage "kmodules.xyz/objectstore-api/osm"
+	"kubedb.dev/apimachinery/apis"
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+	"kubedb.dev/apimachinery/client/clientset/versioned/typed/kubedb/v1alpha1/util"
+	"kubedb.dev/apimachinery/pkg/eventer"
+	validator "kubedb.dev/mongodb/pkg/admission"
+)
+
+// restorePollInterval is how often a MongoDBRestore is processed again, while it waits for its MongoDB
+// or its restore job is running.
+const restorePollInterval = 30 * time.Second
+
+// restoreJobName returns the name of the job that restores the snapshot of restore.
+func restoreJobName(restore *api.MongoDBRestore) string {
+	return fmt.Sprintf("%s-restore-%s", api.DatabaseNamePrefix, restore.Name)
+}
+
+func (c *Controller) runMongoDBRestore(key string) error {
+	log.Debugln("started processing, key:", key)
+	obj, exists, err := c.mgRestoreInformer.GetIndexer().GetByKey(key)
+	if err != nil {
+		log.Errorf("Fetching object with key %s from store failed with %v", key, err)
+		return err
+	}
+
+	if !exists {
+		log.Debugf("MongoDBRestore %s does not exist anymore", key)
+		return nil
+	}
+
+	// a MongoDBRestore is processed once. Its job is deleted with it, by the garbage collector.
+	restore := obj.(*api.MongoDBRestore).DeepCopy()
+	if restore.DeletionTimestamp != nil {
+		return nil
+	}
+	switch restore.Status.Phase {
+	case api.MongoDBRestorePhaseSucceeded, api.MongoDBRestorePhaseFailed:
+		return nil
+	case api.MongoDBRestorePhaseRunning:
+		return c.checkMongoDBRestore(key, restore)
+	}
+	return c.startMongoDBRestore(key, restore)
+}
+
+// startMongoDBRestore creates the job that restores the snapshot of restore into its MongoDB. The restore
+// waits for the MongoDB to be running, and for any other restore into it to complete.
+func (c *Controller) startMongoDBRestore(key string, restore *api.MongoDBRestore) error {
+	if err := validator.ValidateRestoreSelection("spec.snapshot", &restore.Spec.Snapshot); err != nil {
+		return c.failMongoDBRestore(restore, err)
+	}
+
+	mongodb, err := c.accountDatabase(restore.Namespace, restore.Spec.DatabaseName)
+	if err != nil {
+		if _, ok := err.(*accountPendingError); ok {
+			return c.requeueMongoDBRestore(key, restore, err.Error())
+		}
+		return err
+	}
+	running, err := c.runningMongoDBRestore(mongodb)
+	if err != nil {
+		return err
+	}
+	if running != "" {
+		return c.requeueMongoDBRestore(key, restore, fmt.Sprintf("waiting for MongoDBRestore %v to complete", running))
+	}
+
+	snapshot, err := c.ExtClient.KubedbV1alpha1().Snapshots(restore.SnapshotNamespace()).Get(restore.Spec.Snapshot.Name, metav1.GetOptions{})
+	if kerr.IsNotFound(err) {
+		return c.failMongoDBRestore(restore, err)
+	} else if err != nil {
+		return err
+	}
+	if snapshot.Status.Phase != api.SnapshotPhaseSucceeded {
+		return c.failMongoDBRestore(restore, errors.Errorf(`snapshot "%v/%v" has not succeeded, phase is %q`,
+			snapshot.Namespace, snapshot.Name, snapshot.Status.Phase))
+	}
+	if snapshot.Annotations[api.MongoDBBackupModeAnnotation] == string(api.MongoDBShardedBackupModeConsistent) {
+		return c.failMongoDBRestore(restore, errors.Errorf(`snapshot "%v/%v" is a consistent snapshot of a sharded cluster, that can only initialize a MongoDB`,
+			snapshot.Namespace, snapshot.Name))
+	}
+
+	secret, err := storage.NewOSMSecret(c.Client, snapshot.OSMSecretName(), snapshot.Namespace, snapshot.Spec.Backend)
+	if err != nil {
+		return err
+	}
+	secret, err = c.Client.CoreV1().Secrets(secret.Namespace).Create(secret)
+	if err != nil && !kerr.IsAlreadyExists(err) {
+		return err
+	}
+
+	job, err := c.createRestoreJob(mongodb, snapshot, restore)
+	if kerr.IsAlreadyExists(err) {
+		// created before the status of the restore was updated
+		job, err = c.Client.BatchV1().Jobs(mongodb.Namespace).Get(restoreJobName(restore), metav1.GetOptions{})
+	}
+	if err != nil {
+		return c.failMongoDBRestore(restore, err)
+	}
+	if err := c.SetJobOwnerReference(snapshot, job); err != nil {
+		return err
+	}
+
+	c.recorder.Eventf(
+		restore,
+		core.EventTypeNormal,
+		eventer.EventReasonStarting,
+		"Restoring Snapshot %v/%v into MongoDB %v",
+		snapshot.Namespace, snapshot.Name, mongodb.Name,
+	)
+	c.recorder.Eventf(
+		mongodb,
+		core.EventTypeNormal,
+		eventer.EventReasonStarting,
+		"Restoring Snapshot %v/%v, as requested by MongoDBRestore %v",
+		snapshot.Namespace, snapshot.Name, restore.Name,
+	)
+	if _, err := util.UpdateMongoDBRestoreStatus(c.ExtClient.KubedbV1alpha1(), restore, func(in *api.MongoDBRestoreStatus) *api.MongoDBRestoreStatus {
+		t := metav1.Now()
+		in.Phase = api.MongoDBRestorePhaseRunning
+		in.Reason = ""
+		in.Job = job.Name
+		in.StartTime = &t
+		return in
+	}, apis.EnableStatusSubresource); err != nil {
+		return err
+	}
+	c.mgRestoreQueue.GetQueue().AddAfter(key, restorePollInterval)
+	return nil
+}
+
+// checkMongoDBRestore completes restore once its job has succeeded or failed.
+func (c *Controller) checkMongoDBRestore(key string, restore *api.MongoDBRestore) error {
+	job, err := c.Client.BatchV1().Jobs(restore.Namespace).Get(restore.Status.Job, metav1.GetOptions{})
+	if kerr.IsNotFound(err) {
+		return c.failMongoDBRestore(restore, errors.Errorf("restore job %v not found", restore.Status.Job))
+	} else if err != nil {
+		return err
+	}
+
+	if job.Status.Succeeded > 0 {
+		return c.completeMongoDBRestore(restore, job, nil)
+	}
+	if job.Status.Failed > types.Int32(job.Spec.BackoffLimit) {
+		reason := errors.Errorf("restore job %v failed", job.Name)
+		for _, cond := range job.Status.Conditions {
+			if cond.Type == batch.JobFailed && cond.Status == core.ConditionTrue {
+				reason = errors.Errorf("restore job %v failed. Reason: %v", job.Name, cond.Message)
+			}
+		}
+		return c.completeMongoDBRestore(restore, job, reason)
+	}
+	c.mgRestoreQueue.GetQueue().AddAfter(key, restorePollInterval)
+	return nil
+}
+
+// completeMongoDBRestore deletes the job of restore, that failed with reason if not nil, and reports the
+// outcome on restore and its MongoDB.
+func (c *Controller) completeMongoDBRestore(restore *api.MongoDBRestore, job *batch.Job, reason error) error {
+	err := c.Client.BatchV1().Jobs(job.Namespace).Delete(job.Name, meta_util.DeleteInBackground())
+	if err != nil && !kerr.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to delete job %v", job.Name)
+	}
+	if reason != nil {
+		return c.failMongoDBRestore(restore, reason)
+	}
+
+	if _, err := util.UpdateMongoDBRestoreStatus(c.ExtClient.KubedbV1alpha1(), restore, func(in *api.MongoDBRestoreStatus) *api.MongoDBRestoreStatus {
+		t := metav1.Now()
+		in.Phase = api.MongoDBRestorePhaseSucceeded
+		in.Reason = ""
+		in.CompletionTime = &t
+		return in
+	}, apis.EnableStatusSubresource); err != nil {
+		return err
+	}
+	c.recorder.Eventf(
+		restore,
+		core.EventTypeNormal,
+		eventer.EventReasonSuccessful,
+		"Successfully restored Snapshot %v/%v into MongoDB %v",
+		restore.SnapshotNamespace(), restore.Spec.Snapshot.Name, restore.Spec.DatabaseName,
+	)
+	if mongodb, err := c.mgLister.MongoDBs(restore.Namespace).Get(restore.Spec.DatabaseName); err == nil {
+		c.recorder.Eventf(
+			mongodb,
+			core.EventTypeNormal,
+			eventer.EventReasonSuccessful,
+			"Successfully restored Snapshot %v/%v, as requested by MongoDBRestore %v",
+			restore.SnapshotNamespace(), restore.Spec.Snapshot.Name, restore.Name,
+		)
+	}
+	return nil
+}
+
+// failMongoDBRestore marks restore failed with reason. A failed restore is not retried.
+func (c *Controller) failMongoDBRestore(restore *api.MongoDBRestore, reason error) error {
+	if _, err := util.UpdateMongoDBRestoreStatus(c.ExtClient.KubedbV1alpha1(), restore, func(in *api.MongoDBRestoreStatus) *api.MongoDBRestoreStatus {
+		t := metav1.Now()
+		in.Phase = api.MongoDBRestorePhaseFailed
+		in.Reason = reason.Error()
+		in.CompletionTime = &t
+		return in
+	}, apis.EnableStatusSubresource); err != nil {
+		return err
+	}
+	c.recorder.Eventf(
+		restore,
+		core.EventTypeWarning,
+		eventer.EventReasonFailedToInitialize,
+		"Failed to restore Snapshot %v/%v into MongoDB %v. Reason: %v",
+		restore.SnapshotNamespace(), restore.Spec.Snapshot.Name, restore.Spec.DatabaseName, reason,
+	)
+	return nil
+}
+
+// requeueMongoDBRestore records why restore is Pending, and processes it again after restorePollInterval.
+func (c *Controller) requeueMongoDBRestore(key string, restore *api.MongoDBRestore, reason string) error {
+	if restore.Status.Phase != api.MongoDBRestorePhasePending || restore.Status.Reason != reason {
+		if _, err := util.UpdateMongoDBRestoreStatus(c.ExtClient.KubedbV1alpha1(), restore, func(in *api.MongoDBRestoreStatus) *api.MongoDBRestoreStatus {
+			in.Phase = api.MongoDBRestorePhasePending
+			in.Reason = reason
+			return in
+		}, apis.EnableStatusSubresource); err != nil {
+			return err
+		}
+	}
+	c.mgRestoreQueue.GetQueue().AddAfter(key, restorePollInterval)
+	return nil
+}
+
+// runningMongoDBRestore returns the name of the MongoDBRestore running into mongodb, if any.
+func (c *Controller) runningMongoDBRestore(mongodb *api.MongoDB) (string, error) {
+	restores, err := c.mgRestoreLister.MongoDBRestores(mongodb.Namespace).List(labels.Everything())
+	if err != nil {
+		return "", err
+	}
+	for _, restore := range restores {
+		if restore.Spec.DatabaseName == mongodb.Name && restore.Status.Phase == api.MongoDBRestorePhaseRunning {
+			return restore.Name, nil
+		}
+	}
+	return "", nil
+}
+
+// addRestoreRequest makes the restore job of restore leave the config database of the snapshot out, as the
+// metadata of the running cluster must not be overwritten, and drop the collections restored if asked.
+func addRestoreRequest(restore *api.MongoDBRestore, job *batch.Job) {
+	container := &job.Spec.Template.Spec.Containers[0]
+	// a selection skips the config database already
+	if len(restoreSelectionArgs(&restore.Spec.Snapshot)) == 0 {
+		withToolsArgs(container, restoreConfigArg+"=true")
+	}
+	if restore.Spec.Drop {
+		container.Args = append(container.Args, "--drop")
+	}
+}
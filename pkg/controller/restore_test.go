@@ -0,0 +1,40 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	batch "k8s.io/api/batch/v1"
+	core "k8s.io/api/core/v1"
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+)
+
+func TestAddRestoreRequest(t *testing.T) {
+	cases := []struct {
+		name    string
+		restore api.MongoDBRestoreSpec
+		args    []string
+	}{
+		{
+			name:    "skips config",
+			restore: api.MongoDBRestoreSpec{},
+			args:    []string{"restore", "--skip-config=true", "--"},
+		},
+		{
+			name: "drops with selection",
+			restore: api.MongoDBRestoreSpec{
+				Snapshot: api.SnapshotSourceSpec{Selection: &api.SnapshotSelection{Include: []string{"shop"}}},
+				Drop:     true,
+			},
+			args: []string{"restore", "--", "--drop"},
+		},
+	}
+	for _, c := range cases {
+		job := &batch.Job{}
+		job.Spec.Template.Spec.Containers = []core.Container{{Args: []string{"restore", "--"}}}
+		addRestoreRequest(&api.MongoDBRestore{Spec: c.restore}, job)
+		if args := job.Spec.Template.Spec.Containers[0].Args; !reflect.DeepEqual(args, c.args) {
+			t.Errorf("%s: expected %v, got %v", c.name, c.args, args)
+		}
+	}
+}
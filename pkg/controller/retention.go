@@ -0,0 +1,161 @@
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/appscode/go/log"
+	core "k8s.io/api/core/v1"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	meta_util "kmodules.xyz/client-go/meta"
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+	"kubedb.dev/apimachinery/pkg/eventer"
+)
+
+// snapshotPruneInterval is how often the retention policies of the backup schedules are enforced.
+const snapshotPruneInterval = 10 * time.Minute
+
+// runSnapshotPruner prunes the scheduled snapshots of every MongoDB periodically, until stopCh is closed.
+func (c *Controller) runSnapshotPruner(stopCh <-chan struct{}) {
+	wait.Until(c.pruneSnapshots, snapshotPruneInterval, stopCh)
+}
+
+func (c *Controller) pruneSnapshots() {
+	mongodbs, err := c.mgLister.List(labels.Everything())
+	if err != nil {
+		log.Errorf("failed to list MongoDB. Reason: %v", err)
+		return
+	}
+	for _, mongodb := range mongodbs {
+		if mongodb.DeletionTimestamp != nil ||
+			mongodb.Spec.BackupSchedule == nil ||
+			mongodb.Spec.BackupSchedule.RetentionPolicy == nil {
+			continue
+		}
+		if err := c.pruneScheduledSnapshots(mongodb); err != nil {
+			log.Errorf("failed to prune snapshots of MongoDB %v/%v. Reason: %v", mongodb.Namespace, mongodb.Name, err)
+		}
+	}
+}
+
+// pruneScheduledSnapshots deletes the scheduled snapshots of mongodb that its retention policy expires.
+// The data of a deleted snapshot is wiped out from the backend by the snapshot controller.
+func (c *Controller) pruneScheduledSnapshots(mongodb *api.MongoDB) error {
+	snapshots, err := c.ExtClient.KubedbV1alpha1().Snapshots(mongodb.Namespace).List(metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(map[string]string{
+			api.LabelDatabaseKind:      api.ResourceKindMongoDB,
+			api.LabelDatabaseName:      mongodb.Name,
+			api.LabelSnapshotScheduled: "true",
+		}).String(),
+	})
+	if err != nil {
+		return err
+	}
+
+	var pruned []string
+	for _, snapshot := range expiredSnapshots(snapshots.Items, mongodb.Spec.BackupSchedule.RetentionPolicy, time.Now()) {
+		if snapshot.DeletionTimestamp != nil {
+			continue
+		}
+		err := c.ExtClient.KubedbV1alpha1().Snapshots(snapshot.Namespace).Delete(snapshot.Name, meta_util.DeleteInBackground())
+		if err != nil && !kerr.IsNotFound(err) {
+			c.recorder.Eventf(
+				mongodb,
+				core.EventTypeWarning,
+				eventer.EventReasonFailedToDelete,
+				"Failed to prune Snapshot %v. Reason: %v",
+				snapshot.Name,
+				err,
+			)
+			return err
+		}
+		pruned = append(pruned, snapshot.Name)
+	}
+	if len(pruned) > 0 {
+		c.recorder.Eventf(
+			mongodb,
+			core.EventTypeNormal,
+			eventer.EventReasonWipingOut,
+			"Pruned %v Snapshots expired by the retention policy: %v",
+			len(pruned),
+			strings.Join(pruned, ", "),
+		)
+	}
+	return nil
+}
+
+// expiredSnapshots returns the snapshots that policy does not keep at now. Running snapshots are never expired,
+// and failed snapshots expire once a later snapshot succeeds.
+func expiredSnapshots(snapshots []api.Snapshot, policy *api.SnapshotRetentionPolicy, now time.Time) []api.Snapshot {
+	var succeeded, failed []api.Snapshot
+	for _, snapshot := range snapshots {
+		switch snapshot.Status.Phase {
+		case api.SnapshotPhaseSucceeded:
+			succeeded = append(succeeded, snapshot)
+		case api.SnapshotPhaseFailed:
+			failed = append(failed, snapshot)
+		}
+	}
+	// latest first
+	sort.Slice(succeeded, func(i, j int) bool { return snapshotTime(succeeded[j]).Before(snapshotTime(succeeded[i])) })
+
+	keep := make([]bool, len(succeeded))
+	keepRules := false
+	// keepLatest keeps the latest snapshot of each of the latest n periods that have one
+	keepLatest := func(n *int32, period func(api.Snapshot) string) {
+		if n == nil {
+			return
+		}
+		keepRules = true
+		seen := map[string]bool{}
+		for i, snapshot := range succeeded {
+			if len(seen) >= int(*n) {
+				break
+			}
+			p := period(snapshot)
+			if !seen[p] {
+				seen[p] = true
+				keep[i] = true
+			}
+		}
+	}
+	keepLatest(policy.KeepLast, func(s api.Snapshot) string { return s.Name })
+	keepLatest(policy.KeepDaily, func(s api.Snapshot) string { return snapshotTime(s).Format("2006-01-02") })
+	keepLatest(policy.KeepWeekly, func(s api.Snapshot) string {
+		year, week := snapshotTime(s).ISOWeek()
+		return fmt.Sprintf("%v-%v", year, week)
+	})
+	keepLatest(policy.KeepMonthly, func(s api.Snapshot) string { return snapshotTime(s).Format("2006-01") })
+
+	tooOld := func(snapshot api.Snapshot) bool {
+		return policy.MaxAge != nil && now.Sub(snapshotTime(snapshot)) > policy.MaxAge.Duration
+	}
+	var expired []api.Snapshot
+	for i, snapshot := range succeeded {
+		if i == 0 {
+			continue
+		}
+		if (keepRules && !keep[i]) || tooOld(snapshot) {
+			expired = append(expired, snapshot)
+		}
+	}
+	for _, snapshot := range failed {
+		if (len(succeeded) > 0 && snapshotTime(snapshot).Before(snapshotTime(succeeded[0]))) || tooOld(snapshot) {
+			expired = append(expired, snapshot)
+		}
+	}
+	return expired
+}
+
+// snapshotTime is when the snapshot started, or was created if it has not started, in UTC.
+func snapshotTime(snapshot api.Snapshot) time.Time {
+	if snapshot.Status.StartTime != nil {
+		return snapshot.Status.StartTime.UTC()
+	}
+	return snapshot.CreationTimestamp.UTC()
+}
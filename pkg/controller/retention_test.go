@@ -0,0 +1,92 @@
+package controller
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/appscode/go/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+)
+
+func TestExpiredSnapshots(t *testing.T) {
+	now := time.Date(2019, time.August, 31, 12, 0, 0, 0, time.UTC)
+	snapshot := func(name string, age time.Duration, phase api.SnapshotPhase) api.Snapshot {
+		start := metav1.NewTime(now.Add(-age))
+		return api.Snapshot{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status:     api.SnapshotStatus{StartTime: &start, Phase: phase},
+		}
+	}
+	const day = 24 * time.Hour
+	// taken at 00:00 and 12:00 of every day of August, and a failed and a running one today
+	var snapshots []api.Snapshot
+	for d := 0; d < 30; d++ {
+		snapshots = append(snapshots,
+			snapshot(now.Add(-time.Duration(d)*day).Format("0102")+"-1200", time.Duration(d)*day, api.SnapshotPhaseSucceeded),
+			snapshot(now.Add(-time.Duration(d)*day).Format("0102")+"-0000", time.Duration(d)*day+12*time.Hour, api.SnapshotPhaseSucceeded))
+	}
+	snapshots = append(snapshots,
+		snapshot("0830-1800-failed", 18*time.Hour, api.SnapshotPhaseFailed),
+		snapshot("0831-1300-running", -time.Hour, api.SnapshotPhaseRunning))
+
+	kept := func(policy *api.SnapshotRetentionPolicy) []string {
+		expired := map[string]bool{}
+		for _, s := range expiredSnapshots(snapshots, policy, now) {
+			expired[s.Name] = true
+		}
+		var names []string
+		for _, s := range snapshots {
+			if !expired[s.Name] {
+				names = append(names, s.Name)
+			}
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	cases := []struct {
+		name   string
+		policy *api.SnapshotRetentionPolicy
+		kept   []string
+	}{
+		{
+			name:   "keep last",
+			policy: &api.SnapshotRetentionPolicy{KeepLast: types.Int32P(3)},
+			kept:   []string{"0830-1200", "0831-0000", "0831-1200", "0831-1300-running"},
+		},
+		{
+			name:   "keep daily",
+			policy: &api.SnapshotRetentionPolicy{KeepDaily: types.Int32P(2)},
+			kept:   []string{"0830-1200", "0831-1200", "0831-1300-running"},
+		},
+		{
+			name:   "keep last and weekly",
+			policy: &api.SnapshotRetentionPolicy{KeepLast: types.Int32P(1), KeepWeekly: types.Int32P(2)},
+			// 31 August 2019 is a Saturday, so the week before ends on 25 August
+			kept: []string{"0825-1200", "0831-1200", "0831-1300-running"},
+		},
+		{
+			name:   "keep monthly",
+			policy: &api.SnapshotRetentionPolicy{KeepMonthly: types.Int32P(12)},
+			kept:   []string{"0831-1200", "0831-1300-running"},
+		},
+		{
+			name:   "max age",
+			policy: &api.SnapshotRetentionPolicy{MaxAge: &metav1.Duration{Duration: day}},
+			kept:   []string{"0830-1200", "0831-0000", "0831-1200", "0831-1300-running"},
+		},
+		{
+			name:   "max age keeps the latest",
+			policy: &api.SnapshotRetentionPolicy{KeepLast: types.Int32P(5), MaxAge: &metav1.Duration{Duration: time.Hour}},
+			kept:   []string{"0831-1200", "0831-1300-running"},
+		},
+	}
+	for _, c := range cases {
+		if got := kept(c.policy); !reflect.DeepEqual(got, c.kept) {
+			t.Errorf("%s: expected %v to be kept, got %v", c.name, c.kept, got)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/appscode/go/encoding/json/types"
+	"github.com/appscode/go/log"
+	core "k8s.io/api/core/v1"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	core_util "kmodules.xyz/client-go/core/v1"
+	meta_util "kmodules.xyz/client-go/meta"
+	"kmodules.xyz/client-go/tools/queue"
+	"kubedb.dev/apimachinery/apis"
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+	"kubedb.dev/apimachinery/client/clientset/versioned/typed/kubedb/v1alpha1/util"
+	"kubedb.dev/apimachinery/pkg/eventer"
+	"kubedb.dev/mongodb/pkg/dbclient"
+)
+
+// accountPendingError is returned while the MongoDB of a MongoDBUser or MongoDBRole does not exist or is not running.
+type accountPendingError struct {
+	mongodb string
+	reason  string
+}
+
+func (e *accountPendingError) Error() string {
+	return fmt.Sprintf("waiting for MongoDB %v: %v", e.mongodb, e.reason)
+}
+
+// accountDatabase returns the running MongoDB name in namespace, that users and roles are managed in.
+func (c *Controller) accountDatabase(namespace, name string) (*api.MongoDB, error) {
+	mongodb, err := c.mgLister.MongoDBs(namespace).Get(name)
+	if kerr.IsNotFound(err) {
+		return nil, &accountPendingError{mongodb: name, reason: "not found"}
+	} else if err != nil {
+		return nil, err
+	}
+	if mongodb.DeletionTimestamp != nil {
+		return nil, &accountPendingError{mongodb: name, reason: "being deleted"}
+	}
+	if mongodb.Status.Phase != api.DatabasePhaseRunning {
+		return nil, &accountPendingError{mongodb: name, reason: fmt.Sprintf("phase is %q", mongodb.Status.Phase)}
+	}
+	return mongodb, nil
+}
+
+// enqueueAccounts puts the MongoDBUsers and MongoDBRoles of mongodb in their queues.
+func (c *Controller) enqueueAccounts(mongodb *api.MongoDB) {
+	roles, err := c.mgRoleLister.MongoDBRoles(mongodb.Namespace).List(labels.Everything())
+	if err != nil {
+		log.Errorln(err)
+	}
+	for _, role := range roles {
+		if role.Spec.DatabaseName == mongodb.Name {
+			queue.Enqueue(c.mgRoleQueue.GetQueue(), role)
+		}
+	}
+	users, err := c.mgUserLister.MongoDBUsers(mongodb.Namespace).List(labels.Everything())
+	if err != nil {
+		log.Errorln(err)
+	}
+	for _, user := range users {
+		if user.Spec.DatabaseName == mongodb.Name {
+			queue.Enqueue(c.mgUserQueue.GetQueue(), user)
+		}
+	}
+}
+
+func (c *Controller) runMongoDBRole(key string) error {
+	log.Debugln("started processing, key:", key)
+	obj, exists, err := c.mgRoleInformer.GetIndexer().GetByKey(key)
+	if err != nil {
+		log.Errorf("Fetching object with key %s from store failed with %v", key, err)
+		return err
+	}
+
+	if !exists {
+		log.Debugf("MongoDBRole %s does not exist anymore", key)
+		return nil
+	}
+
+	role := obj.(*api.MongoDBRole).DeepCopy()
+	if role.DeletionTimestamp != nil {
+		if core_util.HasFinalizer(role.ObjectMeta, api.GenericKey) {
+			if err := c.dropMongoDBRole(role); err != nil {
+				log.Errorln(err)
+				return err
+			}
+			_, _, err = util.PatchMongoDBRole(c.ExtClient.KubedbV1alpha1(), role, func(in *api.MongoDBRole) *api.MongoDBRole {
+				in.ObjectMeta = core_util.RemoveFinalizer(in.ObjectMeta, api.GenericKey)
+				return in
+			})
+			return err
+		}
+		return nil
+	}
+
+	role, _, err = util.PatchMongoDBRole(c.ExtClient.KubedbV1alpha1(), role, func(in *api.MongoDBRole) *api.MongoDBRole {
+		in.ObjectMeta = core_util.AddFinalizer(in.ObjectMeta, api.GenericKey)
+		return in
+	})
+	if err != nil {
+		return err
+	}
+	if err := c.syncMongoDBRole(role); err != nil {
+		log.Errorln(err)
+		return err
+	}
+	return nil
+}
+
+// syncMongoDBRole creates the role in its MongoDB, or replaces the privileges and roles of the existing role.
+func (c *Controller) syncMongoDBRole(role *api.MongoDBRole) error {
+	mongodb, err := c.accountDatabase(role.Namespace, role.Spec.DatabaseName)
+	if err != nil {
+		if _, ok := err.(*accountPendingError); ok {
+			return c.updateMongoDBRoleStatus(role, api.MongoDBRolePhasePending, err.Error())
+		}
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), membershipTimeout)
+	defer cancel()
+	client, err := c.connectToDatabase(ctx, mongodb)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	db, name := role.DatabaseOrDefault(), role.RoleNameOrDefault()
+	exists, err := client.RoleExists(ctx, db, name)
+	if err != nil {
+		return err
+	}
+	privileges := dbPrivileges(role.Spec.Privileges)
+	roles := dbRoleRefs(role.Spec.Roles, db)
+	if exists {
+		err = client.UpdateRole(ctx, db, name, privileges, roles)
+	} else {
+		err = client.CreateRole(ctx, db, name, privileges, roles)
+	}
+	if err != nil {
+		c.recorder.Eventf(
+			role,
+			core.EventTypeWarning,
+			eventer.EventReasonFailedToUpdate,
+			"Failed to sync role %v@%v. Reason: %v",
+			name, db, err,
+		)
+		if err2 := c.updateMongoDBRoleStatus(role, api.MongoDBRolePhaseFailed, err.Error()); err2 != nil {
+			return err2
+		}
+		return err
+	}
+
+	if !exists || !generationObserved(role, role.Status.ObservedGeneration) {
+		c.recorder.Eventf(
+			role,
+			core.EventTypeNormal,
+			eventer.EventReasonSuccessful,
+			"Successfully synced role %v@%v in MongoDB %v",
+			name, db, mongodb.Name,
+		)
+	}
+	return c.updateMongoDBRoleStatus(role, api.MongoDBRolePhaseSynced, "")
+}
+
+// dropMongoDBRole drops the role from its MongoDB. The role is revoked from the users it is granted to.
+func (c *Controller) dropMongoDBRole(role *api.MongoDBRole) error {
+	mongodb, err := c.mgLister.MongoDBs(role.Namespace).Get(role.Spec.DatabaseName)
+	if kerr.IsNotFound(err) || (err == nil && mongodb.DeletionTimestamp != nil) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), membershipTimeout)
+	defer cancel()
+	client, err := c.connectToDatabase(ctx, mongodb)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	db, name := role.DatabaseOrDefault(), role.RoleNameOrDefault()
+	if err := client.DropRole(ctx, db, name); err != nil {
+		c.recorder.Eventf(
+			role,
+			core.EventTypeWarning,
+			eventer.EventReasonFailedToDelete,
+			"Failed to drop role %v@%v. Reason: %v",
+			name, db, err,
+		)
+		return err
+	}
+	c.recorder.Eventf(
+		role,
+		core.EventTypeNormal,
+		eventer.EventReasonSuccessful,
+		"Successfully dropped role %v@%v from MongoDB %v",
+		name, db, mongodb.Name,
+	)
+	return nil
+}
+
+func (c *Controller) updateMongoDBRoleStatus(role *api.MongoDBRole, phase api.MongoDBRolePhase, reason string) error {
+	mr, err := util.UpdateMongoDBRoleStatus(c.ExtClient.KubedbV1alpha1(), role, func(in *api.MongoDBRoleStatus) *api.MongoDBRoleStatus {
+		in.Phase = phase
+		in.Reason = reason
+		if phase != api.MongoDBRolePhasePending {
+			in.ObservedGeneration = types.NewIntHash(role.Generation, meta_util.GenerationHash(role))
+		}
+		return in
+	}, apis.EnableStatusSubresource)
+	if err != nil {
+		return err
+	}
+	role.Status = mr.Status
+	return nil
+}
+
+// dbRoleRefs converts refs to the roles of dbclient. Roles without database are looked up in db.
+func dbRoleRefs(refs []api.MongoDBRoleRef, db string) []dbclient.RoleRef {
+	out := make([]dbclient.RoleRef, 0, len(refs))
+	for _, ref := range refs {
+		r := dbclient.RoleRef{Role: ref.Name, DB: ref.Database}
+		if r.DB == "" {
+			r.DB = db
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+func dbPrivileges(privileges []api.MongoDBPrivilege) []dbclient.Privilege {
+	out := make([]dbclient.Privilege, 0, len(privileges))
+	for _, p := range privileges {
+		out = append(out, dbclient.Privilege{
+			Resource: dbclient.Resource{
+				DB:         p.Resource.Database,
+				Collection: p.Resource.Collection,
+				Cluster:    p.Resource.Cluster,
+			},
+			Actions: p.Actions,
+		})
+	}
+	return out
+}
@@ -0,0 +1,542 @@
+package controller
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/appscode/go/log"
+	"github.com/pkg/errors"
+	"gomodules.xyz/cert"
+	core "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	core_util "kmodules.xyz/client-go/core/v1"
+	"kubedb.dev/apimachinery/apis"
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+	"kubedb.dev/apimachinery/client/clientset/versioned/typed/kubedb/v1alpha1/util"
+	"kubedb.dev/apimachinery/pkg/eventer"
+)
+
+const (
+	// certRevisionAnnotation is bumped on spec.certificateSecret every time the operator rotates its certificates.
+	// It is copied to the pod templates, so that the pods are restarted to load the rotated certificates.
+	certRevisionAnnotation = api.MongoDBKey + "/cert-revision"
+
+	// nextCAKeyFileName holds the key of the new CA, while the pods are rolled out to trust the new CA.
+	nextCAKeyFileName = "next-ca.key"
+)
+
+// certificateFiles are the keys of spec.certificateSecret whose expiry is tracked.
+var certificateFiles = []string{
+	api.MongoTLSCertFileName,
+	api.MongoServerPemFileName,
+	api.MongoClientPemFileName,
+}
+
+// certificateStatus returns the expiry of the certificates in secret. renewBefore is the renewal window before expiry.
+func certificateStatus(secret *core.Secret, renewBefore time.Duration) ([]api.MongoDBCertificateStatus, error) {
+	var status []api.MongoDBCertificateStatus
+	for _, name := range certificateFiles {
+		data, ok := secret.Data[name]
+		if !ok {
+			continue
+		}
+		certs, err := cert.ParseCertsPEM(data)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse %v of secret %v/%v", name, secret.Namespace, secret.Name)
+		}
+		for _, crt := range certs {
+			status = append(status, api.MongoDBCertificateStatus{
+				Name:        name,
+				Subject:     crt.Subject.String(),
+				NotAfter:    metav1.NewTime(crt.NotAfter),
+				RenewalTime: metav1.NewTime(crt.NotAfter.Add(-renewBefore)),
+			})
+		}
+	}
+	return status, nil
+}
+
+// ensureCertificateStatus publishes the expiry of the certificates in spec.certificateSecret and
+// the TLSReady condition, that reports whether the certificates are valid, expiring or being rotated.
+func (c *Controller) ensureCertificateStatus(mongodb *api.MongoDB) error {
+	secret, err := c.Client.CoreV1().Secrets(mongodb.Namespace).Get(mongodb.Spec.CertificateSecret.SecretName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	certificates, err := certificateStatus(secret, c.certRenewBefore)
+	if err != nil {
+		return err
+	}
+	members, err := c.serverCertSecrets(mongodb)
+	if err != nil {
+		return err
+	}
+	for _, member := range members {
+		crt, err := certificateStatus(&member, c.certRenewBefore)
+		if err != nil {
+			return err
+		}
+		for i := range crt {
+			crt[i].Name = member.Name
+		}
+		certificates = append(certificates, crt...)
+	}
+
+	now := time.Now()
+	cond := newCondition(api.MongoDBConditionTLSReady, core.ConditionTrue, ConditionReasonCertAvailable, "certificates are available")
+	var expiring []api.MongoDBCertificateStatus
+	for _, crt := range certificates {
+		if crt.NotAfter.Time.Before(now) {
+			cond = newCondition(api.MongoDBConditionTLSReady, core.ConditionFalse, ConditionReasonCertExpired,
+				fmt.Sprintf("certificate %v (%v) expired at %v", crt.Name, crt.Subject, crt.NotAfter.UTC()))
+			break
+		}
+		if c.certRenewBefore > 0 && crt.RenewalTime.Time.Before(now) {
+			expiring = append(expiring, crt)
+		}
+	}
+	if cond.Status == core.ConditionTrue {
+		if rotation := mongodb.Status.CertificateRotation; rotation != nil {
+			cond = newCondition(api.MongoDBConditionTLSReady, core.ConditionTrue, ConditionReasonCertRotating,
+				fmt.Sprintf("rotating CA, phase %v", rotation.Phase))
+		} else if len(expiring) > 0 {
+			cond = newCondition(api.MongoDBConditionTLSReady, core.ConditionTrue, ConditionReasonCertExpiring,
+				fmt.Sprintf("certificate %v (%v) expires at %v", expiring[0].Name, expiring[0].Subject, expiring[0].NotAfter.UTC()))
+		}
+	}
+
+	if apiequality.Semantic.DeepEqual(mongodb.Status.Certificates, certificates) &&
+		!conditionsChanged(mongodb.Status.Conditions, cond) {
+		return nil
+	}
+	if cur := getCondition(mongodb.Status.Conditions, api.MongoDBConditionTLSReady); cur == nil || cur.Reason != cond.Reason {
+		switch cond.Reason {
+		case ConditionReasonCertExpired:
+			c.recorder.Event(mongodb, core.EventTypeWarning, eventer.EventReasonFailedToUpdate, cond.Message)
+		case ConditionReasonCertExpiring:
+			msg := cond.Message
+			if !isManagedCertSecret(mongodb, secret) {
+				msg += ". Secret " + secret.Name + " is not managed by the operator, renew it before it expires"
+			}
+			c.recorder.Event(mongodb, core.EventTypeWarning, eventer.EventReasonSuccessful, msg)
+		}
+	}
+
+	mg, err := util.UpdateMongoDBStatus(c.ExtClient.KubedbV1alpha1(), mongodb, func(in *api.MongoDBStatus) *api.MongoDBStatus {
+		in.Certificates = certificates
+		in.Conditions = setCondition(in.Conditions, cond)
+		return in
+	}, apis.EnableStatusSubresource)
+	if err != nil {
+		return err
+	}
+	mongodb.Status = mg.Status
+	return nil
+}
+
+// rotateCertificates renews the certificates of spec.certificateSecret, when it is managed by the operator,
+// once they enter the renewal window. The server and client certificates are reissued by the issuer as they are.
+// The CA generated by the operator is rotated in phases, each rolled out to every pod before the next one starts:
+//   - TrustNewCA: ca.cert holds the new and the old CA, so that the pods trust both.
+//   - IssueCertificates: the certificates, including the server certificates of the members, are reissued
+//     by the new CA.
+//   - RetireOldCA: ca.cert holds the new CA only.
+//
+// The CA of spec.tls.issuerRef is rotated by its owner. Certificates issued by a new CA of the issuer are
+// verified by the CAs of ca.cert, that the new CA is added to.
+//
+// The server certificates of the members are reissued on every change of spec.certificateSecret,
+// as they carry its certRevisionAnnotation.
+//
+// A certificateRotationError is returned while the pods are restarted to load the rotated certificates.
+func (c *Controller) rotateCertificates(mongodb *api.MongoDB) error {
+	if c.certRenewBefore == 0 || mongodb.Spec.CertificateSecret == nil || isUpgradeRunning(mongodb) {
+		return nil
+	}
+	secret, err := c.Client.CoreV1().Secrets(mongodb.Namespace).Get(mongodb.Spec.CertificateSecret.SecretName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if !isManagedCertSecret(mongodb, secret) {
+		return nil
+	}
+
+	if rotation := mongodb.Status.CertificateRotation; rotation != nil {
+		rolledOut, err := c.certificatesRolledOut(mongodb, secret.Annotations[certRevisionAnnotation])
+		if err != nil {
+			return err
+		}
+		if !rolledOut {
+			return &certificateRotationError{secret: secret.Name, phase: string(rotation.Phase)}
+		}
+		return c.advanceCARotation(mongodb, secret, rotation.Phase)
+	}
+
+	cas, err := cert.ParseCertsPEM(secret.Data[api.MongoTLSCertFileName])
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse CA of secret %v/%v", secret.Namespace, secret.Name)
+	}
+	renewAt := time.Now().Add(c.certRenewBefore)
+	if cas[0].NotAfter.Before(renewAt) && issuerRef(mongodb) == nil {
+		return c.startCARotation(mongodb, secret, cas[0])
+	}
+
+	due, err := c.dueCertificates(mongodb, secret, renewAt)
+	if err != nil {
+		return err
+	}
+	if len(due) == 0 {
+		return nil
+	}
+
+	signer, err := c.signer(mongodb)
+	if err != nil {
+		return err
+	}
+	data, issued, err := c.reissueCertificates(mongodb, secret, signer)
+	if err != nil {
+		return err
+	}
+	if _, err := c.patchCertSecret(secret, setSecretData(data)); err != nil {
+		return c.recordRotationFailure(mongodb, err)
+	}
+	if err := c.releasePendingKeys(mongodb, issued...); err != nil {
+		return err
+	}
+	c.recorder.Eventf(
+		mongodb,
+		core.EventTypeNormal,
+		eventer.EventReasonSuccessful,
+		"Renewed certificates %v of secret %v, restarting the pods to load them",
+		due,
+		secret.Name,
+	)
+	return &certificateRotationError{secret: secret.Name, phase: "Renewal"}
+}
+
+// nextCertificateRenewal returns how long after now the earliest renewal time of certificates, that is yet to come, is.
+func nextCertificateRenewal(certificates []api.MongoDBCertificateStatus, now time.Time) (time.Duration, bool) {
+	var next *time.Time
+	for i := range certificates {
+		renewal := certificates[i].RenewalTime.Time
+		if renewal.After(now) && (next == nil || renewal.Before(*next)) {
+			next = &renewal
+		}
+	}
+	if next == nil {
+		return 0, false
+	}
+	return next.Sub(now), true
+}
+
+// enqueueCertificateRenewal puts mongodb back in the queue when the next of its certificates enters the renewal
+// window. Otherwise, a MongoDB that is not updated is never reconciled to renew its certificates.
+func (c *Controller) enqueueCertificateRenewal(mongodb *api.MongoDB) {
+	if c.certRenewBefore == 0 {
+		return
+	}
+	delay, ok := nextCertificateRenewal(mongodb.Status.Certificates, time.Now())
+	if !ok {
+		return
+	}
+	key, err := cache.MetaNamespaceKeyFunc(mongodb)
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+	log.Debugf("certificates of MongoDB %v are renewed in %v", key, delay)
+	c.mgQueue.GetQueue().AddAfter(key, delay)
+}
+
+// startCARotation adds a new CA to ca.cert, next to the expiring CA, and stores its key in the CA secret
+// for the next phase.
+func (c *Controller) startCARotation(mongodb *api.MongoDB, secret *core.Secret, expiring *x509.Certificate) error {
+	caSecret, err := c.Client.CoreV1().Secrets(mongodb.Namespace).Get(caSecretName(mongodb), metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	caKey, caCert, err := createCaCertificate(fmt.Sprintf("ca-%v", time.Now().Unix()))
+	if err != nil {
+		return err
+	}
+	if _, _, err := core_util.PatchSecret(c.Client, caSecret, func(in *core.Secret) *core.Secret {
+		in.Data[nextCAKeyFileName] = cert.EncodePrivateKeyPEM(caKey)
+		return in
+	}); err != nil {
+		return c.recordRotationFailure(mongodb, err)
+	}
+	if _, err := c.patchCertSecret(secret, func(in *core.Secret) error {
+		in.Data[api.MongoTLSCertFileName] = append(cert.EncodeCertPEM(caCert), cert.EncodeCertPEM(expiring)...)
+		return nil
+	}); err != nil {
+		return c.recordRotationFailure(mongodb, err)
+	}
+	if err := c.updateCertificateRotation(mongodb, &api.MongoDBCertificateRotationStatus{
+		Phase:     api.MongoDBCertificateRotationTrustNewCA,
+		StartTime: metav1.Now(),
+	}); err != nil {
+		return err
+	}
+	c.recorder.Eventf(
+		mongodb,
+		core.EventTypeNormal,
+		eventer.EventReasonSuccessful,
+		"Started rotating CA %v of secret %v, that expires at %v. Restarting the pods to trust the new CA",
+		expiring.Subject,
+		secret.Name,
+		expiring.NotAfter.UTC(),
+	)
+	return &certificateRotationError{secret: secret.Name, phase: string(api.MongoDBCertificateRotationTrustNewCA)}
+}
+
+// advanceCARotation moves the CA rotation to the phase after phase, once phase is rolled out to every pod.
+func (c *Controller) advanceCARotation(mongodb *api.MongoDB, secret *core.Secret, phase api.MongoDBCertificateRotationPhase) error {
+	cas, err := cert.ParseCertsPEM(secret.Data[api.MongoTLSCertFileName])
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse CA of secret %v/%v", secret.Namespace, secret.Name)
+	}
+	// the new CA is always listed first.
+	newCA := cas[0]
+
+	var next api.MongoDBCertificateRotationPhase
+	var transform func(in *core.Secret) error
+	var msg string
+	switch phase {
+	case api.MongoDBCertificateRotationTrustNewCA:
+		caSecret, err := c.Client.CoreV1().Secrets(mongodb.Namespace).Get(caSecretName(mongodb), metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		caKey, err := parseCAKey(caSecret.Data[nextCAKeyFileName])
+		if err != nil {
+			return err
+		}
+		data, _, err := c.reissueCertificates(mongodb, secret, &caSigner{key: caKey, cert: newCA})
+		if err != nil {
+			return err
+		}
+		// the server certificates of the members are signed by the CA of the CA secret.
+		if err := c.writeCASecret(mongodb, caKey, newCA, nil); err != nil {
+			return c.recordRotationFailure(mongodb, err)
+		}
+		next = api.MongoDBCertificateRotationIssueCertificates
+		transform = setSecretData(data)
+		msg = "Issued certificates signed by the new CA %v, restarting the pods to load them"
+	case api.MongoDBCertificateRotationIssueCertificates:
+		next = api.MongoDBCertificateRotationRetireOldCA
+		transform = func(in *core.Secret) error {
+			in.Data[api.MongoTLSCertFileName] = cert.EncodeCertPEM(newCA)
+			return nil
+		}
+		msg = "Retired the old CA, restarting the pods to trust the new CA %v only"
+	default:
+		if err := c.updateCertificateRotation(mongodb, nil); err != nil {
+			return err
+		}
+		c.recorder.Eventf(
+			mongodb,
+			core.EventTypeNormal,
+			eventer.EventReasonSuccessful,
+			"Rotated CA of secret %v to %v, valid until %v",
+			secret.Name,
+			newCA.Subject,
+			newCA.NotAfter.UTC(),
+		)
+		return nil
+	}
+
+	if _, err := c.patchCertSecret(secret, transform); err != nil {
+		return c.recordRotationFailure(mongodb, err)
+	}
+	if err := c.updateCertificateRotation(mongodb, &api.MongoDBCertificateRotationStatus{
+		Phase:     next,
+		StartTime: mongodb.Status.CertificateRotation.StartTime,
+	}); err != nil {
+		return err
+	}
+	c.recorder.Eventf(mongodb, core.EventTypeNormal, eventer.EventReasonSuccessful, msg, newCA.Subject)
+	return &certificateRotationError{secret: secret.Name, phase: string(next)}
+}
+
+// dueCertificates returns the certificates, of secret and of the members, that expire before renewAt.
+func (c *Controller) dueCertificates(mongodb *api.MongoDB, secret *core.Secret, renewAt time.Time) ([]string, error) {
+	var due []string
+	for _, name := range []string{api.MongoServerPemFileName, api.MongoClientPemFileName} {
+		data, ok := secret.Data[name]
+		if !ok {
+			continue
+		}
+		certs, err := cert.ParseCertsPEM(data)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse %v of secret %v/%v", name, secret.Namespace, secret.Name)
+		}
+		if certs[0].NotAfter.Before(renewAt) {
+			due = append(due, name)
+		}
+	}
+
+	members, err := c.serverCertSecrets(mongodb)
+	if err != nil {
+		return nil, err
+	}
+	for _, member := range members {
+		certs, err := cert.ParseCertsPEM(member.Data[api.MongoServerPemFileName])
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse %v of secret %v/%v", api.MongoServerPemFileName, member.Namespace, member.Name)
+		}
+		if certs[0].NotAfter.Before(renewAt) {
+			due = append(due, member.Name)
+		}
+	}
+	return due, nil
+}
+
+// reissueCertificates returns the certificates of secret reissued by signer, along with their names
+// to release their pending keys.
+func (c *Controller) reissueCertificates(mongodb *api.MongoDB, secret *core.Secret, signer Signer) (map[string][]byte, []string, error) {
+	data := map[string][]byte{
+		api.MongoTLSCertFileName: secret.Data[api.MongoTLSCertFileName],
+	}
+	_, server := secret.Data[api.MongoServerPemFileName]
+	issued, err := c.issueCertificates(mongodb, signer, data, server)
+	if err != nil {
+		if isWorkloadNotReady(err) {
+			return nil, nil, err
+		}
+		return nil, nil, c.recordRotationFailure(mongodb, err)
+	}
+	return data, issued, nil
+}
+
+// setSecretData returns the transform that sets data into a secret.
+func setSecretData(data map[string][]byte) func(in *core.Secret) error {
+	return func(in *core.Secret) error {
+		for k, v := range data {
+			in.Data[k] = v
+		}
+		return nil
+	}
+}
+
+// patchCertSecret applies transform to secret and bumps its certRevisionAnnotation.
+func (c *Controller) patchCertSecret(secret *core.Secret, transform func(in *core.Secret) error) (*core.Secret, error) {
+	var terr error
+	out, _, err := core_util.PatchSecret(c.Client, secret, func(in *core.Secret) *core.Secret {
+		if terr = transform(in); terr != nil {
+			return in
+		}
+		revision, _ := strconv.Atoi(in.Annotations[certRevisionAnnotation])
+		if in.Annotations == nil {
+			in.Annotations = map[string]string{}
+		}
+		in.Annotations[certRevisionAnnotation] = strconv.Itoa(revision + 1)
+		return in
+	})
+	if terr != nil {
+		return nil, terr
+	}
+	return out, err
+}
+
+func (c *Controller) recordRotationFailure(mongodb *api.MongoDB, err error) error {
+	c.recorder.Eventf(
+		mongodb,
+		core.EventTypeWarning,
+		eventer.EventReasonFailedToUpdate,
+		"Failed to rotate certificates. Reason: %v",
+		err,
+	)
+	return err
+}
+
+// updateCertificateRotation records the progress of CA rotation in MongoDB status.
+func (c *Controller) updateCertificateRotation(mongodb *api.MongoDB, rotation *api.MongoDBCertificateRotationStatus) error {
+	mg, err := util.UpdateMongoDBStatus(c.ExtClient.KubedbV1alpha1(), mongodb, func(in *api.MongoDBStatus) *api.MongoDBStatus {
+		in.CertificateRotation = rotation
+		return in
+	}, apis.EnableStatusSubresource)
+	if err != nil {
+		return err
+	}
+	mongodb.Status = mg.Status
+	return nil
+}
+
+// certificatesRolledOut reports whether every pod of mongodb is restarted from a template at certificate revision.
+func (c *Controller) certificatesRolledOut(mongodb *api.MongoDB, revision string) (bool, error) {
+	for _, component := range desiredComponents(mongodb) {
+		if component.status.Type == api.MongoDBComponentMongos {
+			deployment, err := c.Client.AppsV1().Deployments(mongodb.Namespace).Get(component.status.Name, metav1.GetOptions{})
+			if err != nil {
+				return false, err
+			}
+			if deployment.Spec.Template.Annotations[certRevisionAnnotation] != revision ||
+				deployment.Status.ObservedGeneration < deployment.Generation ||
+				deployment.Status.UpdatedReplicas != component.status.Replicas ||
+				deployment.Status.Replicas != component.status.Replicas ||
+				deployment.Status.AvailableReplicas != component.status.Replicas {
+				return false, nil
+			}
+			continue
+		}
+
+		statefulSet, err := c.Client.AppsV1().StatefulSets(mongodb.Namespace).Get(component.status.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		if statefulSet.Spec.Template.Annotations[certRevisionAnnotation] != revision ||
+			statefulSet.Status.ObservedGeneration < statefulSet.Generation ||
+			statefulSet.Status.ReadyReplicas != component.status.Replicas {
+			return false, nil
+		}
+		outdated, err := c.outdatedPods(statefulSet)
+		if err != nil {
+			return false, err
+		}
+		if len(outdated) > 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// certificateRevision returns the certRevisionAnnotation of spec.certificateSecret, if any.
+func (c *Controller) certificateRevision(mongodb *api.MongoDB) (string, error) {
+	if mongodb.Spec.CertificateSecret == nil {
+		return "", nil
+	}
+	secret, err := c.Client.CoreV1().Secrets(mongodb.Namespace).Get(mongodb.Spec.CertificateSecret.SecretName, metav1.GetOptions{})
+	if err != nil {
+		if kerr.IsNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	return secret.Annotations[certRevisionAnnotation], nil
+}
+
+// isManagedCertSecret reports whether secret is the certificate secret created by the operator for mongodb.
+func isManagedCertSecret(mongodb *api.MongoDB, secret *core.Secret) bool {
+	return secret.Name == mongodb.Name+CertificateSecretSuffix &&
+		secret.Labels[api.LabelDatabaseKind] == api.ResourceKindMongoDB &&
+		secret.Labels[api.LabelDatabaseName] == mongodb.Name
+}
+
+// parseCAKey parses the PEM encoded RSA key of a CA.
+func parseCAKey(data []byte) (*rsa.PrivateKey, error) {
+	key, err := cert.ParsePrivateKeyPEM(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse CA key")
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("CA key is not an RSA key")
+	}
+	return rsaKey, nil
+}
@@ -0,0 +1,195 @@
+package controller
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"gomodules.xyz/cert"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/util/workqueue"
+	core_util "kmodules.xyz/client-go/core/v1"
+	"kmodules.xyz/client-go/tools/queue"
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+	amc "kubedb.dev/apimachinery/pkg/controller"
+)
+
+// testCertificatePEM returns a certificate for cn signed by the CA, that expires at notAfter.
+func testCertificatePEM(t *testing.T, caKey *rsa.PrivateKey, caCert *x509.Certificate, cn string, notAfter time.Time) []byte {
+	key, err := cert.NewPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}, caCert, key.Public(), caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crt, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert.EncodeCertPEM(crt)
+}
+
+func TestCertificateStatus(t *testing.T) {
+	caKey, caCert, err := createCaCertificate("ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiry := time.Now().Add(90 * 24 * time.Hour).Truncate(time.Second)
+	secret := &core.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "mg-cert", Namespace: "demo"},
+		Data: map[string][]byte{
+			api.MongoTLSCertFileName:   cert.EncodeCertPEM(caCert),
+			api.MongoClientPemFileName: testCertificatePEM(t, caKey, caCert, "root", expiry),
+			"ca.key":                   cert.EncodePrivateKeyPEM(caKey),
+		},
+	}
+
+	renewBefore := 30 * 24 * time.Hour
+	status, err := certificateStatus(secret, renewBefore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status) != 2 {
+		t.Fatalf("expected the status of the CA and the client certificate, got %+v", status)
+	}
+	if status[0].Name != api.MongoTLSCertFileName || status[0].Subject != caCert.Subject.String() {
+		t.Errorf("unexpected CA status %+v", status[0])
+	}
+	client := status[1]
+	if client.Name != api.MongoClientPemFileName || client.Subject != "CN=root" {
+		t.Errorf("unexpected client certificate status %+v", client)
+	}
+	if !client.NotAfter.Time.Equal(expiry) || !client.RenewalTime.Time.Equal(expiry.Add(-renewBefore)) {
+		t.Errorf("expected expiry %v and renewal %v, got %+v", expiry, expiry.Add(-renewBefore), client)
+	}
+
+	secret.Data[api.MongoServerPemFileName] = []byte("invalid")
+	if _, err := certificateStatus(secret, renewBefore); err == nil {
+		t.Error("expected an invalid certificate to be reported")
+	}
+}
+
+func TestDueCertificates(t *testing.T) {
+	mongodb := &api.MongoDB{ObjectMeta: metav1.ObjectMeta{Name: "mg", Namespace: "demo"}}
+	caKey, caCert, err := createCaCertificate("ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	soon, later := now.Add(10*24*time.Hour), now.Add(60*24*time.Hour)
+
+	secret := &core.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "mg-cert", Namespace: "demo"},
+		Data: map[string][]byte{
+			api.MongoTLSCertFileName:   cert.EncodeCertPEM(caCert),
+			api.MongoServerPemFileName: testCertificatePEM(t, caKey, caCert, "mg", later),
+			api.MongoClientPemFileName: testCertificatePEM(t, caKey, caCert, "root", soon),
+		},
+	}
+	member := func(pod string, notAfter time.Time) *core.Secret {
+		return &core.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      serverCertSecretName(pod),
+				Namespace: "demo",
+				Labels:    core_util.UpsertMap(mongodb.OffshootSelectors(), map[string]string{serverCertLabelKey: "true"}),
+			},
+			Data: map[string][]byte{
+				api.MongoServerPemFileName: testCertificatePEM(t, caKey, caCert, pod, notAfter),
+			},
+		}
+	}
+	c := &Controller{Controller: &amc.Controller{Client: fake.NewSimpleClientset(
+		member("mg-0", later),
+		member("mg-1", soon),
+	)}}
+
+	cases := []struct {
+		name    string
+		renewAt time.Time
+		want    []string
+	}{
+		{"none", now, nil},
+		{"expiring soon", now.Add(30 * 24 * time.Hour), []string{api.MongoClientPemFileName, serverCertSecretName("mg-1")}},
+		{"all", now.Add(90 * 24 * time.Hour), []string{api.MongoServerPemFileName, api.MongoClientPemFileName, serverCertSecretName("mg-0"), serverCertSecretName("mg-1")}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			due, err := c.dueCertificates(mongodb, secret, tc.renewAt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(due, tc.want) {
+				t.Errorf("expected %v, got %v", tc.want, due)
+			}
+		})
+	}
+}
+
+func TestNextCertificateRenewal(t *testing.T) {
+	now := time.Now()
+	at := func(d time.Duration) api.MongoDBCertificateStatus {
+		return api.MongoDBCertificateStatus{RenewalTime: metav1.NewTime(now.Add(d))}
+	}
+
+	if _, ok := nextCertificateRenewal(nil, now); ok {
+		t.Error("expected no renewal without certificates")
+	}
+	// renewal times that have passed are left to the reconcile that renews them
+	if _, ok := nextCertificateRenewal([]api.MongoDBCertificateStatus{at(-time.Hour)}, now); ok {
+		t.Error("expected no renewal once every renewal time has passed")
+	}
+	delay, ok := nextCertificateRenewal([]api.MongoDBCertificateStatus{at(-time.Hour), at(48 * time.Hour), at(24 * time.Hour)}, now)
+	if !ok || delay != 24*time.Hour {
+		t.Errorf("expected the earliest renewal in 24h, got %v", delay)
+	}
+}
+
+func TestEnqueueCertificateRenewal(t *testing.T) {
+	mongodb := &api.MongoDB{
+		ObjectMeta: metav1.ObjectMeta{Name: "mg", Namespace: "demo"},
+		Status: api.MongoDBStatus{
+			Certificates: []api.MongoDBCertificateStatus{
+				{Name: api.MongoClientPemFileName, RenewalTime: metav1.NewTime(time.Now().Add(50 * time.Millisecond))},
+			},
+		},
+	}
+	c := &Controller{
+		mgQueue:            queue.New("MongoDB", 5, 1, nil),
+		mgReadinessBackoff: workqueue.NewItemExponentialFailureRateLimiter(readinessBaseDelay, readinessMaxDelay),
+	}
+	defer c.mgQueue.GetQueue().ShutDown()
+
+	c.enqueueCertificateRenewal(mongodb)
+	time.Sleep(200 * time.Millisecond)
+	if n := c.mgQueue.GetQueue().Len(); n != 0 {
+		t.Fatalf("expected nothing to be queued while renewal is disabled, got %v", n)
+	}
+
+	c.certRenewBefore = 30 * 24 * time.Hour
+	mongodb.Status.Certificates[0].RenewalTime = metav1.NewTime(time.Now().Add(50 * time.Millisecond))
+	c.enqueueCertificateRenewal(mongodb)
+	if n := c.mgQueue.GetQueue().Len(); n != 0 {
+		t.Fatalf("expected MongoDB to be queued at the renewal time only, got %v", n)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if n := c.mgQueue.GetQueue().Len(); n != 1 {
+		t.Fatalf("expected MongoDB to be queued at the renewal time, got %v", n)
+	}
+	if key, _ := c.mgQueue.GetQueue().Get(); key != "demo/mg" {
+		t.Errorf("expected demo/mg to be queued, got %v", key)
+	}
+}
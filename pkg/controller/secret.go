@@ -5,7 +5,6 @@ import (
 	"fmt"
 
 	"github.com/appscode/go/crypto/rand"
-	"gomodules.xyz/cert"
 	core "k8s.io/api/core/v1"
 	kerr "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -22,6 +21,8 @@ const (
 
 	DatabaseSecretSuffix    = "-auth"
 	CertificateSecretSuffix = "-cert"
+	CASecretSuffix          = "-ca"
+	MonitorSecretSuffix     = "-monitor"
 )
 
 func (c *Controller) ensureDatabaseSecret(mongodb *api.MongoDB) error {
@@ -61,7 +62,22 @@ func (c *Controller) ensureCertSecret(mongodb *api.MongoDB) error {
 		}
 		mongodb.Spec.CertificateSecret = ms.Spec.CertificateSecret
 	}
-	return nil
+	return c.ensureCASecret(mongodb)
+}
+
+// generatePassword returns a random password for a database user.
+func generatePassword() string {
+	randPassword := ""
+	// if the password starts with "-" it will cause error in bash scripts (in mongodb-tools)
+	for randPassword = rand.GeneratePassword(); randPassword[0] == '-'; randPassword = rand.GeneratePassword() {
+	}
+	return randPassword
+}
+
+// newKeyFileKey returns a random key for the keyfile of internal authentication.
+func newKeyFileKey() string {
+	randToken := rand.GenerateTokenWithLength(756)
+	return base64.StdEncoding.EncodeToString([]byte(randToken))
 }
 
 func (c *Controller) createDatabaseSecret(mongodb *api.MongoDB) (*core.SecretVolumeSource, error) {
@@ -72,12 +88,6 @@ func (c *Controller) createDatabaseSecret(mongodb *api.MongoDB) (*core.SecretVol
 		return nil, err
 	}
 	if sc == nil {
-		randPassword := ""
-
-		// if the password starts with "-" it will cause error in bash scripts (in mongodb-tools)
-		for randPassword = rand.GeneratePassword(); randPassword[0] == '-'; randPassword = rand.GeneratePassword() {
-		}
-
 		secret := &core.Secret{
 			ObjectMeta: metav1.ObjectMeta{
 				Name:   authSecretName,
@@ -86,7 +96,7 @@ func (c *Controller) createDatabaseSecret(mongodb *api.MongoDB) (*core.SecretVol
 			Type: core.SecretTypeOpaque,
 			StringData: map[string]string{
 				KeyMongoDBUser:     mongodbUser,
-				KeyMongoDBPassword: randPassword,
+				KeyMongoDBPassword: generatePassword(),
 			},
 		}
 		if _, err := c.Client.CoreV1().Secrets(mongodb.Namespace).Create(secret); err != nil {
@@ -106,18 +116,25 @@ func (c *Controller) createCertificateSecret(mongodb *api.MongoDB) (*core.Secret
 		return nil, err
 	}
 	if sc == nil {
-		randToken := rand.GenerateTokenWithLength(756)
-		base64Token := base64.StdEncoding.EncodeToString([]byte(randToken))
-
-		caKey, caCert, err := createCaCertificate()
-		if err != nil {
+		var signer Signer
+		if issuerRef(mongodb) == nil {
+			caKey, caCert, err := createCaCertificate("ca")
+			if err != nil {
+				return nil, err
+			}
+			// The CA key is kept in a secret of its own, that is never mounted into the database pods.
+			if err := c.writeCASecret(mongodb, caKey, caCert, nil); err != nil {
+				return nil, err
+			}
+			signer = &caSigner{key: caKey, cert: caCert}
+		} else if signer, err = c.signer(mongodb); err != nil {
 			return nil, err
 		}
-		svrPem, err := createServerPEMCertificate(mongodb, caKey, caCert)
-		if err != nil {
-			return nil, err
-		}
-		clientPem, err := createClientPEMCertificate(mongodb, caKey, caCert)
+
+		// add mongo.pem (for standalone) in secret, only if the db id standalone
+		data := map[string][]byte{}
+		standalone := mongodb.Spec.ReplicaSet == nil && mongodb.Spec.ShardTopology == nil
+		issued, err := c.issueCertificates(mongodb, signer, data, standalone)
 		if err != nil {
 			return nil, err
 		}
@@ -129,24 +146,16 @@ func (c *Controller) createCertificateSecret(mongodb *api.MongoDB) (*core.Secret
 			},
 			Type: core.SecretTypeOpaque,
 			StringData: map[string]string{
-				KeyForKeyFile: base64Token,
-			},
-			Data: map[string][]byte{
-				string(api.MongoTLSKeyFileName):    cert.EncodePrivateKeyPEM(caKey),
-				string(api.MongoTLSCertFileName):   cert.EncodeCertPEM(caCert),
-				string(api.MongoClientPemFileName): clientPem,
+				KeyForKeyFile: newKeyFileKey(),
 			},
+			Data: data,
 		}
-
-		// add mongo.pem (for standalone) in secret, only if the db id standalone
-		if mongodb.Spec.ReplicaSet == nil &&
-			mongodb.Spec.ShardTopology == nil {
-			secret.Data[string(api.MongoServerPemFileName)] = svrPem
-		}
-
 		if _, err := c.Client.CoreV1().Secrets(mongodb.Namespace).Create(secret); err != nil {
 			return nil, err
 		}
+		if err := c.releasePendingKeys(mongodb, issued...); err != nil {
+			return nil, err
+		}
 	}
 	return &core.SecretVolumeSource{
 		SecretName: tokenSecretName,
@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	batch "k8s.io/api/batch/v1"
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+	validator "kubedb.dev/mongodb/pkg/admission"
+)
+
+// dumpSelectionArgs returns the mongodump arguments of selection, that is validated by
+// validator.ValidateSnapshotSelection.
+func dumpSelectionArgs(selection *api.SnapshotSelection) []string {
+	if selection == nil || len(selection.Include) == 0 {
+		return nil
+	}
+	database, collection := validator.SplitNamespace(selection.Include[0])
+	args := []string{fmt.Sprintf("--db=%v", database)}
+	if collection != "*" {
+		args = append(args, fmt.Sprintf("--collection=%v", collection))
+	}
+	for _, ns := range selection.Exclude {
+		_, excluded := validator.SplitNamespace(ns)
+		if strings.HasSuffix(excluded, "*") {
+			args = append(args, fmt.Sprintf("--excludeCollectionsWithPrefix=%v", strings.TrimSuffix(excluded, "*")))
+		} else {
+			args = append(args, fmt.Sprintf("--excludeCollection=%v", excluded))
+		}
+	}
+	if selection.Query != "" {
+		args = append(args, fmt.Sprintf("--query=%v", selection.Query))
+	}
+	return args
+}
+
+// restoreSelectionArgs returns the mongorestore arguments of the selection and the renames of source.
+func restoreSelectionArgs(source *api.SnapshotSourceSpec) []string {
+	var args []string
+	if selection := source.Selection; selection != nil {
+		for _, ns := range selection.Include {
+			args = append(args, fmt.Sprintf("--nsInclude=%v", validator.QualifiedNamespace(ns)))
+		}
+		for _, ns := range selection.Exclude {
+			args = append(args, fmt.Sprintf("--nsExclude=%v", validator.QualifiedNamespace(ns)))
+		}
+	}
+	for _, rename := range source.Rename {
+		args = append(args,
+			fmt.Sprintf("--nsFrom=%v", validator.QualifiedNamespace(rename.From)),
+			fmt.Sprintf("--nsTo=%v", validator.QualifiedNamespace(rename.To)))
+	}
+	return args
+}
+
+// addRestoreSelection makes the restore job restore the selected namespaces of the snapshot only, renamed if asked.
+// The config database of a sharded cluster in the snapshot is skipped, as its metadata is of every namespace.
+func addRestoreSelection(source *api.SnapshotSourceSpec, job *batch.Job) {
+	args := restoreSelectionArgs(source)
+	if len(args) == 0 {
+		return
+	}
+	container := &job.Spec.Template.Spec.Containers[0]
+	withToolsArgs(container, restoreConfigArg+"=true")
+	container.Args = append(container.Args, args...)
+}
+
+// selectedNamespace returns whether the namespace ns, as <database>.<collection>, is selected by selection.
+func selectedNamespace(selection *api.SnapshotSelection, ns string) bool {
+	if selection == nil {
+		return true
+	}
+	matches := func(patterns []string) bool {
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(validator.QualifiedNamespace(pattern), ns); ok {
+				return true
+			}
+		}
+		return false
+	}
+	return (len(selection.Include) == 0 || matches(selection.Include)) && !matches(selection.Exclude)
+}
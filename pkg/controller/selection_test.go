@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+)
+
+func TestDumpSelectionArgs(t *testing.T) {
+	cases := []struct {
+		selection *api.SnapshotSelection
+		args      []string
+	}{
+		{selection: nil},
+		{selection: &api.SnapshotSelection{}},
+		{
+			selection: &api.SnapshotSelection{Include: []string{"shop"}, Exclude: []string{"shop.sessions", "shop.tmp_*"}},
+			args:      []string{"--db=shop", "--excludeCollection=sessions", "--excludeCollectionsWithPrefix=tmp_"},
+		},
+		{
+			selection: &api.SnapshotSelection{Include: []string{"shop.orders"}, Query: `{"status": "open"}`},
+			args:      []string{"--db=shop", "--collection=orders", `--query={"status": "open"}`},
+		},
+	}
+	for _, c := range cases {
+		if args := dumpSelectionArgs(c.selection); !reflect.DeepEqual(args, c.args) {
+			t.Errorf("%+v: expected %v, got %v", c.selection, c.args, args)
+		}
+	}
+}
+
+func TestRestoreSelectionArgs(t *testing.T) {
+	source := &api.SnapshotSourceSpec{
+		Selection: &api.SnapshotSelection{Include: []string{"shop", "crm.contacts"}, Exclude: []string{"shop.tmp_*"}},
+		Rename:    []api.SnapshotNamespaceRename{{From: "shop", To: "shop_restored"}},
+	}
+	expected := []string{"--nsInclude=shop.*", "--nsInclude=crm.contacts", "--nsExclude=shop.tmp_*",
+		"--nsFrom=shop.*", "--nsTo=shop_restored.*"}
+	if args := restoreSelectionArgs(source); !reflect.DeepEqual(args, expected) {
+		t.Errorf("expected %v, got %v", expected, args)
+	}
+}
+
+func TestSelectedNamespace(t *testing.T) {
+	selection := &api.SnapshotSelection{Include: []string{"shop"}, Exclude: []string{"shop.tmp_*"}}
+	for ns, selected := range map[string]bool{
+		"shop.orders":     true,
+		"shop.tmp_orders": false,
+		"crm.contacts":    false,
+	} {
+		if got := selectedNamespace(selection, ns); got != selected {
+			t.Errorf("%v: expected selected %v, got %v", ns, selected, got)
+		}
+	}
+	if !selectedNamespace(nil, "crm.contacts") {
+		t.Errorf("expected every namespace to be selected without a selection")
+	}
+}
@@ -0,0 +1,345 @@
+package controller
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"fmt"
+
+	"github.com/appscode/go/types"
+	"github.com/pkg/errors"
+	"gomodules.xyz/cert"
+	core "k8s.io/api/core/v1"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	clientsetscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/reference"
+	core_util "kmodules.xyz/client-go/core/v1"
+	"kubedb.dev/apimachinery/apis/catalog/v1alpha1"
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+)
+
+const (
+	// serverCertLabelKey is set on the secrets that hold the server certificate of a member.
+	serverCertLabelKey = api.MongoDBKey + "/server-cert"
+
+	serverCertsDirectoryName = "server-certs"
+	serverCertsDirectoryPath = "/server-certs-readonly"
+
+	caKeyDirectoryName = "ca-key"
+	caKeyDirectoryPath = "/ca-key-readonly"
+)
+
+// serverCertSecretName returns the name of the secret that holds the server certificate of pod, or of mongos.
+func serverCertSecretName(pod string) string {
+	return pod + "-server-cert"
+}
+
+// caSecretName returns the name of the secret that holds the CA key of the certificates of mongodb,
+// and the keys of the certificate requests pending at an external issuer. It is never mounted into the database pods.
+func caSecretName(mongodb *api.MongoDB) string {
+	return mongodb.Name + CASecretSuffix
+}
+
+// memberCertsVolume returns the volume that projects the server certificate of each of the replicas pods of
+// StatefulSet stsName to <pod>.pem. The pods share the template, so every member is projected and the init container
+// copies its own. The template changes when the StatefulSet is scaled. Replicas are capped at
+// validator.MaxReplicaSetMembers.
+func memberCertsVolume(mongodb *api.MongoDB, stsName string, replicas int32) *core.VolumeSource {
+	if !isTLSEnabled(mongodb) {
+		return nil
+	}
+	projected := &core.ProjectedVolumeSource{
+		DefaultMode: types.Int32P(256),
+	}
+	for i := int32(0); i < replicas; i++ {
+		pod := fmt.Sprintf("%v-%v", stsName, i)
+		projected.Sources = append(projected.Sources, core.VolumeProjection{
+			Secret: &core.SecretProjection{
+				LocalObjectReference: core.LocalObjectReference{
+					Name: serverCertSecretName(pod),
+				},
+				Items: []core.KeyToPath{
+					{
+						Key:  api.MongoServerPemFileName,
+						Path: pod + ".pem",
+					},
+				},
+				Optional: types.BoolP(true),
+			},
+		})
+	}
+	return &core.VolumeSource{Projected: projected}
+}
+
+// mongosCertsVolume returns the volume with the server certificate shared by the mongos pods.
+func mongosCertsVolume(mongodb *api.MongoDB) *core.VolumeSource {
+	if !isTLSEnabled(mongodb) {
+		return nil
+	}
+	return &core.VolumeSource{
+		Secret: &core.SecretVolumeSource{
+			DefaultMode: types.Int32P(256),
+			SecretName:  serverCertSecretName(mongodb.MongosNodeName()),
+		},
+	}
+}
+
+// caKeyVolume returns the volume with the CA key of mongodb, for the database images whose bootstrap scripts sign
+// the server certificates of the members themselves. Images with spec.db.memberCertificates in their catalog use the
+// certificates issued by the operator, and never get the CA key. A standalone uses mongo.pem of
+// spec.certificateSecret either way.
+func caKeyVolume(mongodb *api.MongoDB, mongodbVersion *v1alpha1.MongoDBVersion) *core.VolumeSource {
+	if !isTLSEnabled(mongodb) || mongodbVersion.Spec.DB.MemberCertificates ||
+		(mongodb.Spec.ReplicaSet == nil && mongodb.Spec.ShardTopology == nil) {
+		return nil
+	}
+	return &core.VolumeSource{
+		Secret: &core.SecretVolumeSource{
+			DefaultMode: types.Int32P(256),
+			SecretName:  caSecretName(mongodb),
+			Items: []core.KeyToPath{
+				{
+					Key:  api.MongoTLSKeyFileName,
+					Path: api.MongoTLSKeyFileName,
+				},
+			},
+			// spec.certificateSecret provided by the user keeps its CA key, and there is no CA secret.
+			Optional: types.BoolP(true),
+		},
+	}
+}
+
+// ensureMemberCertificates issues the server certificate of every pod of the StatefulSet of opts.
+func (c *Controller) ensureMemberCertificates(mongodb *api.MongoDB, opts workloadOptions, revision string) error {
+	if ok, err := c.issuesServerCertificates(mongodb); !ok || err != nil {
+		return err
+	}
+	signer := &signerLoader{c: c, mongodb: mongodb}
+	// every certificate is requested, before waiting for any of them to be signed.
+	var pending error
+	for i := int32(0); i < types.Int32(opts.replicas); i++ {
+		pod := fmt.Sprintf("%v-%v", opts.stsName, i)
+		err := c.ensureServerCertificate(mongodb, signer, pod, memberDNSNames(mongodb, opts.stsName, i), revision)
+		if _, ok := err.(*certificatePendingError); ok {
+			pending = err
+		} else if err != nil {
+			return err
+		}
+	}
+	return pending
+}
+
+// ensureMongosCertificate issues the server certificate shared by the mongos pods.
+func (c *Controller) ensureMongosCertificate(mongodb *api.MongoDB, revision string) error {
+	if ok, err := c.issuesServerCertificates(mongodb); !ok || err != nil {
+		return err
+	}
+	return c.ensureServerCertificate(mongodb, &signerLoader{c: c, mongodb: mongodb}, mongodb.MongosNodeName(), nil, revision)
+}
+
+// issuesServerCertificates reports whether the operator issues the server certificates of mongodb. It doesn't,
+// if spec.certificateSecret is provided by the user without its CA key and spec.tls.issuerRef. Then, mongo.pem
+// of it is used.
+func (c *Controller) issuesServerCertificates(mongodb *api.MongoDB) (bool, error) {
+	if !isTLSEnabled(mongodb) {
+		return false, nil
+	}
+	if issuerRef(mongodb) != nil {
+		return true, nil
+	}
+	_, err := c.Client.CoreV1().Secrets(mongodb.Namespace).Get(caSecretName(mongodb), metav1.GetOptions{})
+	if err == nil {
+		return true, nil
+	} else if !kerr.IsNotFound(err) {
+		return false, err
+	}
+	secret, err := c.Client.CoreV1().Secrets(mongodb.Namespace).Get(mongodb.Spec.CertificateSecret.SecretName, metav1.GetOptions{})
+	if err != nil {
+		return false, err
+	}
+	_, ok := secret.Data[api.MongoTLSKeyFileName]
+	return ok, nil
+}
+
+// ensureServerCertificate issues the server certificate of commonName into its secret, unless it is already
+// issued at revision of spec.certificateSecret. Certificates are reissued on every rotation of spec.certificateSecret.
+func (c *Controller) ensureServerCertificate(mongodb *api.MongoDB, signer *signerLoader, commonName string, hosts []string, revision string) error {
+	name := serverCertSecretName(commonName)
+	secret, err := c.Client.CoreV1().Secrets(mongodb.Namespace).Get(name, metav1.GetOptions{})
+	if err == nil {
+		if _, ok := secret.Data[api.MongoServerPemFileName]; ok && secret.Annotations[certRevisionAnnotation] == revision {
+			return nil
+		}
+	} else if !kerr.IsNotFound(err) {
+		return err
+	}
+
+	s, err := signer.load()
+	if err != nil {
+		return err
+	}
+	pem, _, err := c.issueCertificate(mongodb, s, serverCertificateRequest(mongodb, commonName, hosts...))
+	if err != nil {
+		return err
+	}
+	ref, err := reference.GetReference(clientsetscheme.Scheme, mongodb)
+	if err != nil {
+		return err
+	}
+	_, _, err = core_util.CreateOrPatchSecret(c.Client, metav1.ObjectMeta{
+		Name:      name,
+		Namespace: mongodb.Namespace,
+	}, func(in *core.Secret) *core.Secret {
+		in.Labels = core_util.UpsertMap(mongodb.OffshootLabels(), map[string]string{serverCertLabelKey: "true"})
+		in.Annotations = upsertAnnotation(in.Annotations, certRevisionAnnotation, revision)
+		core_util.EnsureOwnerReference(&in.ObjectMeta, ref)
+		in.Type = core.SecretTypeOpaque
+		in.Data = map[string][]byte{
+			api.MongoServerPemFileName: pem,
+		}
+		return in
+	})
+	if err != nil {
+		return err
+	}
+	return c.releasePendingKeys(mongodb, commonName)
+}
+
+// serverCertSecrets returns the secrets of the server certificates issued for mongodb.
+func (c *Controller) serverCertSecrets(mongodb *api.MongoDB) ([]core.Secret, error) {
+	secrets, err := c.Client.CoreV1().Secrets(mongodb.Namespace).List(metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(core_util.UpsertMap(mongodb.OffshootSelectors(), map[string]string{
+			serverCertLabelKey: "true",
+		})).String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return secrets.Items, nil
+}
+
+// signerLoader loads the Signer of mongodb once, when a certificate is to be issued.
+type signerLoader struct {
+	c       *Controller
+	mongodb *api.MongoDB
+
+	signer Signer
+}
+
+func (l *signerLoader) load() (Signer, error) {
+	if l.signer == nil {
+		signer, err := l.c.signer(l.mongodb)
+		if err != nil {
+			return nil, err
+		}
+		l.signer = signer
+	}
+	return l.signer, nil
+}
+
+// issuingCA returns the key and certificate of the CA the operator generated for mongodb. It is read from
+// the CA secret of the operator, or from spec.certificateSecret, when it is provided by the user with its CA key.
+func (c *Controller) issuingCA(mongodb *api.MongoDB) (crypto.Signer, *x509.Certificate, error) {
+	secret, err := c.Client.CoreV1().Secrets(mongodb.Namespace).Get(caSecretName(mongodb), metav1.GetOptions{})
+	if kerr.IsNotFound(err) {
+		secret, err = c.Client.CoreV1().Secrets(mongodb.Namespace).Get(mongodb.Spec.CertificateSecret.SecretName, metav1.GetOptions{})
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return parseCA(secret)
+}
+
+// parseCA returns ca.key of secret, and the certificate of ca.cert that belongs to it. While the CA is rotated,
+// ca.cert of spec.certificateSecret holds both the new and the old CA. A secret of type kubernetes.io/tls,
+// with tls.key and tls.crt, is accepted as well.
+func parseCA(secret *core.Secret) (crypto.Signer, *x509.Certificate, error) {
+	keyFile, certFile := api.MongoTLSKeyFileName, api.MongoTLSCertFileName
+	if _, ok := secret.Data[keyFile]; !ok {
+		keyFile, certFile = core.TLSPrivateKeyKey, core.TLSCertKey
+	}
+	key, err := parsePrivateKeyPEM(secret.Data[keyFile])
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to parse CA key of secret %v/%v", secret.Namespace, secret.Name)
+	}
+	pub, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return nil, nil, err
+	}
+	certs, err := cert.ParseCertsPEM(secret.Data[certFile])
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to parse CA of secret %v/%v", secret.Namespace, secret.Name)
+	}
+	for _, crt := range certs {
+		if bytes.Equal(crt.RawSubjectPublicKeyInfo, pub) {
+			return key, crt, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("secret %v/%v has no CA certificate for its CA key", secret.Namespace, secret.Name)
+}
+
+// ensureCASecret moves the CA key out of spec.certificateSecret, created by an older operator, into the CA secret,
+// so that it is no longer mounted into the database pods.
+func (c *Controller) ensureCASecret(mongodb *api.MongoDB) error {
+	certSecret, err := c.Client.CoreV1().Secrets(mongodb.Namespace).Get(mongodb.Spec.CertificateSecret.SecretName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if _, ok := certSecret.Data[api.MongoTLSKeyFileName]; !ok || !isManagedCertSecret(mongodb, certSecret) {
+		return nil
+	}
+
+	caKey, caCert, err := parseCA(certSecret)
+	if err != nil {
+		return err
+	}
+	if _, err := c.Client.CoreV1().Secrets(mongodb.Namespace).Get(caSecretName(mongodb), metav1.GetOptions{}); kerr.IsNotFound(err) {
+		if err := c.writeCASecret(mongodb, caKey, caCert, certSecret.Data[nextCAKeyFileName]); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	_, _, err = core_util.PatchSecret(c.Client, certSecret, func(in *core.Secret) *core.Secret {
+		delete(in.Data, api.MongoTLSKeyFileName)
+		delete(in.Data, nextCAKeyFileName)
+		return in
+	})
+	return err
+}
+
+// writeCASecret writes the CA key and certificate of mongodb into its CA secret, along with the key of the
+// next CA, if the CA is being rotated.
+func (c *Controller) writeCASecret(mongodb *api.MongoDB, caKey crypto.Signer, caCert *x509.Certificate, nextCAKey []byte) error {
+	keyPEM, err := encodePrivateKeyPEM(caKey)
+	if err != nil {
+		return err
+	}
+	_, _, err = core_util.CreateOrPatchSecret(c.Client, metav1.ObjectMeta{
+		Name:      caSecretName(mongodb),
+		Namespace: mongodb.Namespace,
+	}, func(in *core.Secret) *core.Secret {
+		in.Labels = mongodb.OffshootLabels()
+		in.Type = core.SecretTypeOpaque
+		if in.Data == nil {
+			in.Data = map[string][]byte{}
+		}
+		in.Data[api.MongoTLSKeyFileName] = keyPEM
+		in.Data[api.MongoTLSCertFileName] = cert.EncodeCertPEM(caCert)
+		if len(nextCAKey) > 0 {
+			in.Data[nextCAKeyFileName] = nextCAKey
+		} else {
+			delete(in.Data, nextCAKeyFileName)
+		}
+		return in
+	})
+	return err
+}
+
+// isTLSEnabled reports whether the servers of mongodb use TLS.
+func isTLSEnabled(mongodb *api.MongoDB) bool {
+	return mongodb.Spec.SSLMode != api.SSLModeDisabled && mongodb.Spec.SSLMode != ""
+}
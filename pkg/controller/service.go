@@ -12,6 +12,7 @@ import (
 	"k8s.io/client-go/tools/reference"
 	kutil "kmodules.xyz/client-go"
 	core_util "kmodules.xyz/client-go/core/v1"
+	meta_util "kmodules.xyz/client-go/meta"
 	mona "kmodules.xyz/monitoring-agent-api/api/v1"
 	ofst "kmodules.xyz/offshoot-api/api/v1"
 	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
@@ -122,25 +123,53 @@ func (c *Controller) ensureStatsService(mongodb *api.MongoDB) (kutil.VerbType, e
 		return kutil.VerbUnchanged, nil
 	}
 
-	// Check if stats Service name exists
-	if err := c.checkService(mongodb, mongodb.StatsService().ServiceName()); err != nil {
-		return kutil.VerbUnchanged, err
-	}
-
 	ref, rerr := reference.GetReference(clientsetscheme.Scheme, mongodb)
 	if rerr != nil {
 		return kutil.VerbUnchanged, rerr
 	}
 
+	vt := kutil.VerbUnchanged
+	for _, stats := range statsServices(mongodb) {
+		svt, err := c.createStatsService(mongodb, stats, ref)
+		if err != nil {
+			return kutil.VerbUnchanged, err
+		} else if svt != kutil.VerbUnchanged {
+			vt = svt
+			c.recorder.Eventf(
+				ref,
+				core.EventTypeNormal,
+				eventer.EventReasonSuccessful,
+				"Successfully %s stats service %v",
+				svt,
+				stats.ServiceName(),
+			)
+		}
+	}
+
+	// a sharded cluster is scraped through the stats Services of its components.
+	if mongodb.Spec.ShardTopology != nil {
+		if err := c.deleteStatsService(mongodb, mongodb.StatsService().ServiceName()); err != nil {
+			return kutil.VerbUnchanged, err
+		}
+	}
+	return vt, nil
+}
+
+func (c *Controller) createStatsService(mongodb *api.MongoDB, stats statsService, ref *core.ObjectReference) (kutil.VerbType, error) {
+	// Check if stats Service name exists
+	if err := c.checkService(mongodb, stats.ServiceName()); err != nil {
+		return kutil.VerbUnchanged, err
+	}
+
 	// create/patch stats Service
 	meta := metav1.ObjectMeta{
-		Name:      mongodb.StatsService().ServiceName(),
+		Name:      stats.ServiceName(),
 		Namespace: mongodb.Namespace,
 	}
 	_, vt, err := core_util.CreateOrPatchService(c.Client, meta, func(in *core.Service) *core.Service {
 		core_util.EnsureOwnerReference(&in.ObjectMeta, ref)
-		in.Labels = mongodb.StatsServiceLabels()
-		in.Spec.Selector = mongodb.OffshootSelectors()
+		in.Labels = stats.labels
+		in.Spec.Selector = stats.selector
 		in.Spec.Ports = core_util.MergeServicePorts(in.Spec.Ports, []core.ServicePort{
 			{
 				Name:       api.PrometheusExporterPortName,
@@ -151,18 +180,26 @@ func (c *Controller) ensureStatsService(mongodb *api.MongoDB) (kutil.VerbType, e
 		})
 		return in
 	})
-	if err != nil {
-		return kutil.VerbUnchanged, err
-	} else if vt != kutil.VerbUnchanged {
-		c.recorder.Eventf(
-			ref,
-			core.EventTypeNormal,
-			eventer.EventReasonSuccessful,
-			"Successfully %s stats service",
-			vt,
-		)
+	return vt, err
+}
+
+// deleteStatsService deletes stats Service serviceName of mongodb, if any. Its ServiceMonitor is owned by it.
+func (c *Controller) deleteStatsService(mongodb *api.MongoDB, serviceName string) error {
+	service, err := c.Client.CoreV1().Services(mongodb.Namespace).Get(serviceName, metav1.GetOptions{})
+	if kerr.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
 	}
-	return vt, nil
+	if service.Labels[api.LabelDatabaseKind] != api.ResourceKindMongoDB ||
+		service.Labels[api.LabelDatabaseName] != mongodb.Name {
+		return nil
+	}
+	err = c.Client.CoreV1().Services(mongodb.Namespace).Delete(serviceName, meta_util.DeleteInBackground())
+	if err != nil && !kerr.IsNotFound(err) {
+		return err
+	}
+	return nil
 }
 
 func (c *Controller) ensureMongoGvrSvc(mongodb *api.MongoDB) error {
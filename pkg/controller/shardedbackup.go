@@ -0,0 +1,233 @@
+package controller
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	batch "k8s.io/api/batch/v1"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	core_util "kmodules.xyz/client-go/core/v1"
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+	"kubedb.dev/apimachinery/client/clientset/versioned/typed/kubedb/v1alpha1/util"
+	"kubedb.dev/mongodb/pkg/shardedbackup"
+)
+
+const (
+	shardedBackupRole     = "sharded-backup"
+	shardedRestoreRole    = "sharded-restore"
+	shardedBackupStateDir = snapshotDumpDir + "/.sharded-backup"
+)
+
+func isConsistentShardedBackup(mongodb *api.MongoDB) bool {
+	return mongodb.Spec.ShardTopology != nil &&
+		mongodb.Spec.ShardTopology.BackupMode == api.MongoDBShardedBackupModeConsistent
+}
+
+// runningSnapshot returns the name of a running snapshot of mongodb, if any.
+func (c *Controller) runningSnapshot(mongodb *api.MongoDB) (string, error) {
+	snapshots, err := c.ExtClient.KubedbV1alpha1().Snapshots(mongodb.Namespace).List(metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(map[string]string{
+			api.LabelDatabaseKind:   api.ResourceKindMongoDB,
+			api.LabelDatabaseName:   mongodb.Name,
+			api.LabelSnapshotStatus: string(api.SnapshotPhaseRunning),
+		}).String(),
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(snapshots.Items) == 0 {
+		return "", nil
+	}
+	return snapshots.Items[0].Name, nil
+}
+
+// shardedComponents returns the config server and the shards of mongodb, in order.
+func shardedComponents(mongodb *api.MongoDB) (api.MongoDBComponentStatus, []api.MongoDBComponentStatus) {
+	var configServer api.MongoDBComponentStatus
+	var shards []api.MongoDBComponentStatus
+	for _, component := range desiredComponents(mongodb) {
+		switch component.status.Type {
+		case api.MongoDBComponentConfigServer:
+			configServer = component.status
+		case api.MongoDBComponentShard:
+			shards = append(shards, component.status)
+		}
+	}
+	return configServer, shards
+}
+
+func componentDSN(mongodb *api.MongoDB, component api.MongoDBComponentStatus) string {
+	return component.ReplicaSetName + "/" + strings.Join(stsMemberHosts(mongodb, component.Name, component.Replicas), ",")
+}
+
+// toolsHost returns the host of a replicaset for mongo-tools.sh, that passes the port apart.
+func toolsHost(mongodb *api.MongoDB, component api.MongoDBComponentStatus) string {
+	hosts := stsMemberHosts(mongodb, component.Name, component.Replicas)
+	for i, host := range hosts {
+		hosts[i] = strings.TrimSuffix(host, fmt.Sprintf(":%v", api.MongoDBShardPort))
+	}
+	return component.ReplicaSetName + "/" + strings.Join(hosts, ",")
+}
+
+// shardedArgs returns the arguments of the operator commands that coordinate the backup and the restore.
+func shardedArgs(mongodb *api.MongoDB, bucket, folder string) []string {
+	configServer, shards := shardedComponents(mongodb)
+	args := []string{
+		fmt.Sprintf("--mongos=%v.%v.svc:%v", mongodb.ServiceName(), mongodb.Namespace, api.MongoDBMongosPort),
+		fmt.Sprintf("--config-server=%v", componentDSN(mongodb, configServer)),
+	}
+	for _, shard := range shards {
+		args = append(args, fmt.Sprintf("--shard=%v", componentDSN(mongodb, shard)))
+	}
+	return append(args, fmt.Sprintf("--bucket=%v", bucket), fmt.Sprintf("--folder=%v", folder))
+}
+
+// coordinator returns a container of the operator image that runs args, with the volumes of the tools container
+// base and the client certificate, if TLS is enabled.
+func (c *Controller) coordinator(mongodb *api.MongoDB, base core.Container, name string, args []string) (core.Container, []core.Volume) {
+	args = append(args, fmt.Sprintf("--enable-analytics=%v", c.EnableAnalytics))
+	args, mounts, volumes := withOplogTLS(mongodb, args, append([]core.VolumeMount(nil), base.VolumeMounts...), nil)
+	return core.Container{
+		Name:         name,
+		Image:        c.oplogArchiverImage,
+		Args:         args,
+		Env:          c.oplogEnvs(mongodb),
+		Resources:    base.Resources,
+		VolumeMounts: mounts,
+	}, volumes
+}
+
+// addShardedBackup makes the snapshot job of a sharded cluster take a consistent snapshot. The config server and
+// every shard are dumped from their secondaries in parallel, into a folder of the snapshot named after their
+// replicaset. An init container stops the balancer before the dumps start, and a container alongside the dumps
+// captures the oplog of the shards until a common cluster time once they complete, and starts the balancer.
+// The dumps write their exit codes to the state directory, so that it knows when they complete.
+func (c *Controller) addShardedBackup(mongodb *api.MongoDB, snapshot *api.Snapshot, job *batch.Job, bucket, folder string) error {
+	configServer, shards := shardedComponents(mongodb)
+	shardNames := make([]string, 0, len(shards))
+	for _, shard := range shards {
+		shardNames = append(shardNames, shard.ReplicaSetName)
+	}
+	// restoring the snapshot depends on its layout
+	_, _, err := util.PatchSnapshot(c.ExtClient.KubedbV1alpha1(), snapshot, func(in *api.Snapshot) *api.Snapshot {
+		in.Annotations = core_util.UpsertMap(in.Annotations, map[string]string{
+			api.MongoDBBackupModeAnnotation:   string(api.MongoDBShardedBackupModeConsistent),
+			api.MongoDBBackupShardsAnnotation: strings.Join(shardNames, ","),
+		})
+		return in
+	})
+	if err != nil {
+		return err
+	}
+
+	podSpec := &job.Spec.Template.Spec
+	base := podSpec.Containers[0]
+	var dumps []core.Container
+	for _, component := range append([]api.MongoDBComponentStatus{configServer}, shards...) {
+		args := []string{
+			"mongo-tools.sh",
+			api.JobTypeBackup,
+			fmt.Sprintf(`--host=%s`, toolsHost(mongodb, component)),
+			fmt.Sprintf(`--data-dir=%s`, filepath.Join(snapshotDumpDir, component.ReplicaSetName)),
+			fmt.Sprintf(`--bucket=%s`, bucket),
+			fmt.Sprintf(`--folder=%s`, folder),
+			fmt.Sprintf(`--snapshot=%s/%s`, snapshot.Name, component.ReplicaSetName),
+			fmt.Sprintf(`--enable-analytics=%v`, c.EnableAnalytics),
+			"--",
+			"--readPreference=secondaryPreferred",
+		}
+		if component.Type == api.MongoDBComponentConfigServer {
+			// only the sharding metadata of the config server is restored
+			args = append(args, "--db=config")
+		}
+		args = append(args, snapshot.Spec.PodTemplate.Spec.Args...)
+
+		dump := *base.DeepCopy()
+		dump.Name = fmt.Sprintf("%v-%v", api.JobTypeBackup, component.Name)
+		dump.Command = []string{"/bin/sh", "-c", fmt.Sprintf("%v; rc=$?; echo $rc > %v; exit $rc",
+			shellJoin(args), shellQuote(shardedbackup.ExitFile(shardedBackupStateDir, component.ReplicaSetName)))}
+		dump.Args = nil
+		dumps = append(dumps, dump)
+	}
+
+	args := append(shardedArgs(mongodb, bucket, filepath.Join(folder, snapshot.Name)), fmt.Sprintf("--state-dir=%v", shardedBackupStateDir))
+	start, volumes := c.coordinator(mongodb, base, shardedBackupRole+"-start", append([]string{shardedBackupRole, "start"}, args...))
+	finish, _ := c.coordinator(mongodb, base, shardedBackupRole+"-finish", append([]string{shardedBackupRole, "finish"}, args...))
+	podSpec.InitContainers = append(podSpec.InitContainers, start)
+	podSpec.Containers = append(dumps, finish)
+	podSpec.Volumes = append(podSpec.Volumes, volumes...)
+	return nil
+}
+
+// addShardedRestore makes the restore job restore a consistent snapshot of a sharded cluster. The dump of every
+// shard of the snapshot is restored into the shard of the same index by an init container, then the sharding
+// metadata is restored and the captured oplog of the shards is replayed until the cluster time of the snapshot.
+func (c *Controller) addShardedRestore(mongodb *api.MongoDB, snapshot *api.Snapshot, job *batch.Job, bucket, folder string) error {
+	if mongodb.Spec.ShardTopology == nil {
+		return errors.Errorf(`snapshot "%v/%v" is of a sharded cluster. It can't be restored into a non-sharded MongoDB`,
+			snapshot.Namespace, snapshot.Name)
+	}
+	if source := mongodb.Spec.Init.SnapshotSource; source.Selection != nil || len(source.Rename) > 0 {
+		return errors.Errorf(`snapshot "%v/%v" is of a sharded cluster. It can't be restored selectively`,
+			snapshot.Namespace, snapshot.Name)
+	}
+	sources := strings.Split(snapshot.Annotations[api.MongoDBBackupShardsAnnotation], ",")
+	_, shards := shardedComponents(mongodb)
+	if len(sources) != len(shards) {
+		return errors.Errorf(`snapshot "%v/%v" has %v shards, but MongoDB %v/%v has %v. It can be restored into a cluster of the same number of shards only`,
+			snapshot.Namespace, snapshot.Name, len(sources), mongodb.Namespace, mongodb.Name, len(shards))
+	}
+
+	var extraArgs []string
+	for _, arg := range mongodb.Spec.Init.SnapshotSource.Args {
+		if !strings.HasPrefix(arg, restoreConfigArg) {
+			extraArgs = append(extraArgs, arg)
+		}
+	}
+
+	podSpec := &job.Spec.Template.Spec
+	base := podSpec.Containers[0]
+	for i, shard := range shards {
+		restore := *base.DeepCopy()
+		restore.Name = fmt.Sprintf("%v-%v", api.JobTypeRestore, shard.Name)
+		restore.Args = append([]string{
+			api.JobTypeRestore,
+			fmt.Sprintf(`--host=%s`, toolsHost(mongodb, shard)),
+			fmt.Sprintf(`--data-dir=%s`, filepath.Join(snapshotDumpDir, shard.ReplicaSetName)),
+			fmt.Sprintf(`--bucket=%s`, bucket),
+			fmt.Sprintf(`--folder=%s`, folder),
+			fmt.Sprintf(`--snapshot=%s/%s`, snapshot.Name, sources[i]),
+			fmt.Sprintf(`--enable-analytics=%v`, c.EnableAnalytics),
+			// the config database of a shard is its own, and the users of the cluster are on the config server
+			restoreConfigArg + "=true",
+			"--",
+			"--nsExclude=admin.*",
+			// the sharding metadata names the collections by their UUIDs
+			"--drop",
+			"--preserveUUID",
+		}, extraArgs...)
+		podSpec.InitContainers = append(podSpec.InitContainers, restore)
+	}
+
+	restore, volumes := c.coordinator(mongodb, base, shardedRestoreRole,
+		append([]string{shardedRestoreRole}, shardedArgs(mongodb, bucket, filepath.Join(folder, snapshot.Name))...))
+	podSpec.Containers = []core.Container{restore}
+	podSpec.Volumes = append(podSpec.Volumes, volumes...)
+	return nil
+}
+
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
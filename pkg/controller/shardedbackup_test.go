@@ -0,0 +1,42 @@
+package controller
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+	extFake "kubedb.dev/apimachinery/client/clientset/versioned/fake"
+	amc "kubedb.dev/apimachinery/pkg/controller"
+)
+
+func TestRunningSnapshot(t *testing.T) {
+	mongodb := shardedMongoDB()
+	snapshot := func(name, database string, phase api.SnapshotPhase) *api.Snapshot {
+		return &api.Snapshot{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: mongodb.Namespace,
+				Labels: map[string]string{
+					api.LabelDatabaseKind:   api.ResourceKindMongoDB,
+					api.LabelDatabaseName:   database,
+					api.LabelSnapshotStatus: string(phase),
+				},
+			},
+		}
+	}
+
+	c := &Controller{Controller: &amc.Controller{ExtClient: extFake.NewSimpleClientset(
+		snapshot("mg-succeeded", mongodb.Name, api.SnapshotPhaseSucceeded),
+		snapshot("other-running", "other", api.SnapshotPhaseRunning),
+	)}}
+	if name, err := c.runningSnapshot(mongodb); err != nil || name != "" {
+		t.Fatalf("expected no running snapshot, got %q, %v", name, err)
+	}
+
+	if _, err := c.ExtClient.KubedbV1alpha1().Snapshots(mongodb.Namespace).Create(snapshot("mg-running", mongodb.Name, api.SnapshotPhaseRunning)); err != nil {
+		t.Fatal(err)
+	}
+	if name, err := c.runningSnapshot(mongodb); err != nil || name != "mg-running" {
+		t.Errorf("expected snapshot mg-running, got %q, %v", name, err)
+	}
+}
@@ -0,0 +1,330 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/appscode/go/log"
+	core "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	meta_util "kmodules.xyz/client-go/meta"
+	"kubedb.dev/apimachinery/apis"
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+	"kubedb.dev/apimachinery/client/clientset/versioned/typed/kubedb/v1alpha1/util"
+	"kubedb.dev/apimachinery/pkg/eventer"
+	"kubedb.dev/mongodb/pkg/dbclient"
+)
+
+// ensureShards registers every shard of spec.shardTopology through mongos, and drains and tears down
+// the shards beyond spec.shardTopology.shard.shards. While any shard is draining, a shardDrainingError
+// is returned, so that MongoDB is requeued until draining completes.
+func (c *Controller) ensureShards(mongodb *api.MongoDB) error {
+	removing, err := c.removedShardNodes(mongodb)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), membershipTimeout)
+	defer cancel()
+
+	client, err := c.connectToMongos(ctx, mongodb)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	shards, err := client.ListShards(ctx)
+	if err != nil {
+		return err
+	}
+	registered := make(map[string]bool, len(shards))
+	for _, shard := range shards {
+		registered[shard.ID] = true
+	}
+
+	for i := int32(0); i < mongodb.Spec.ShardTopology.Shard.Shards; i++ {
+		name := mongodb.ShardRepSetName(i)
+		if registered[name] {
+			continue
+		}
+		if err := client.AddShard(ctx, mongodb.ShardDSN(i)); err != nil {
+			c.recorder.Eventf(
+				mongodb,
+				core.EventTypeWarning,
+				eventer.EventReasonFailedToUpdate,
+				"Failed to add shard %v. Reason: %v",
+				name,
+				err,
+			)
+			return err
+		}
+		c.recorder.Eventf(
+			mongodb,
+			core.EventTypeNormal,
+			eventer.EventReasonSuccessful,
+			"Added shard %v to the cluster",
+			name,
+		)
+	}
+
+	if len(removing) == 0 && len(mongodb.Status.RemovingShards) == 0 {
+		return nil
+	}
+	return c.drainShards(ctx, mongodb, client, registered, removing)
+}
+
+// drainShards drives removeShard for the shards served by nodes, moves the primary of the databases
+// homed on them once their chunks are migrated, and deletes their StatefulSet and governing Service
+// once draining completes. PVCs are kept, as with scaling a StatefulSet down. The balancer runs while shards
+// drain, and is restored to its mode before once all of them are removed.
+func (c *Controller) drainShards(ctx context.Context, mongodb *api.MongoDB, client *dbclient.Client, registered map[string]bool, nodes []int32) error {
+	if len(nodes) > 0 {
+		// a consistent snapshot stops the balancer until the dumps end, so draining waits for it.
+		if isConsistentShardedBackup(mongodb) {
+			snapshot, err := c.runningSnapshot(mongodb)
+			if err != nil {
+				return err
+			}
+			if snapshot != "" {
+				return &snapshotRunningError{snapshot: snapshot}
+			}
+		}
+		if err := c.startDrainingBalancer(ctx, mongodb, client); err != nil {
+			return err
+		}
+	}
+
+	var progress []api.MongoDBShardRemovalStatus
+	var draining error
+	for _, nodeNum := range nodes {
+		name := mongodb.ShardRepSetName(nodeNum)
+		stsName := mongodb.ShardNodeName(nodeNum)
+
+		status := api.MongoDBShardRemovalStatus{
+			Name:        name,
+			StatefulSet: stsName,
+			StartTime:   metav1.Now(),
+		}
+		for _, s := range mongodb.Status.RemovingShards {
+			if s.Name == name {
+				status = s
+			}
+		}
+
+		if registered[name] {
+			result, err := client.RemoveShard(ctx, name)
+			if err != nil {
+				c.recorder.Eventf(
+					mongodb,
+					core.EventTypeWarning,
+					eventer.EventReasonFailedToDelete,
+					"Failed to remove shard %v. Reason: %v",
+					name,
+					err,
+				)
+				return err
+			}
+			if result.State == dbclient.RemoveShardStarted {
+				c.recorder.Eventf(
+					mongodb,
+					core.EventTypeNormal,
+					eventer.EventReasonSuccessful,
+					"Started draining shard %v",
+					name,
+				)
+			}
+			status.State = result.State
+			status.RemainingChunks = result.Remaining.Chunks
+			status.RemainingDatabases = result.Remaining.DBs
+
+			if result.State != dbclient.RemoveShardCompleted {
+				if result.Remaining.Chunks == 0 {
+					if err := c.movePrimaries(ctx, mongodb, client, name, result.DBsToMove); err != nil {
+						return err
+					}
+				}
+				progress = append(progress, status)
+				if draining == nil {
+					draining = &shardDrainingError{
+						shard:     name,
+						chunks:    result.Remaining.Chunks,
+						databases: result.Remaining.DBs,
+					}
+				}
+				continue
+			}
+		}
+
+		// draining is completed, or the shard never joined the cluster.
+		if err := c.deleteShardNode(mongodb, stsName); err != nil {
+			return err
+		}
+		c.recorder.Eventf(
+			mongodb,
+			core.EventTypeNormal,
+			eventer.EventReasonSuccessful,
+			"Removed shard %v from the cluster",
+			name,
+		)
+	}
+
+	// the balancer is restored to its mode before draining, once every shard is removed.
+	balancerMode := mongodb.Status.RemovingShardsBalancerMode
+	if draining == nil && balancerMode != "" {
+		if balancerMode == dbclient.BalancerModeOff {
+			if err := client.BalancerStop(ctx); err != nil {
+				c.recorder.Eventf(
+					mongodb,
+					core.EventTypeWarning,
+					eventer.EventReasonFailedToUpdate,
+					"Failed to stop the balancer, that was stopped before draining shards. Reason: %v",
+					err,
+				)
+				return err
+			}
+			c.recorder.Event(
+				mongodb,
+				core.EventTypeNormal,
+				eventer.EventReasonSuccessful,
+				"Stopped the balancer, as it was before draining shards",
+			)
+		}
+		balancerMode = ""
+	}
+
+	if !apiequality.Semantic.DeepEqual(mongodb.Status.RemovingShards, progress) ||
+		mongodb.Status.RemovingShardsBalancerMode != balancerMode {
+		mg, err := util.UpdateMongoDBStatus(c.ExtClient.KubedbV1alpha1(), mongodb, func(in *api.MongoDBStatus) *api.MongoDBStatus {
+			in.RemovingShards = progress
+			in.RemovingShardsBalancerMode = balancerMode
+			return in
+		}, apis.EnableStatusSubresource)
+		if err != nil {
+			return err
+		}
+		mongodb.Status = mg.Status
+	}
+	return draining
+}
+
+// startDrainingBalancer starts the balancer, that migrates the chunks off draining shards. Its mode is recorded in
+// the status of mongodb before, when the first shard starts draining, so that a balancer stopped on purpose is
+// stopped again once draining completes.
+func (c *Controller) startDrainingBalancer(ctx context.Context, mongodb *api.MongoDB, client *dbclient.Client) error {
+	if mongodb.Status.RemovingShardsBalancerMode == "" {
+		mode, err := client.BalancerMode(ctx)
+		if err != nil {
+			return err
+		}
+		mg, err := util.UpdateMongoDBStatus(c.ExtClient.KubedbV1alpha1(), mongodb, func(in *api.MongoDBStatus) *api.MongoDBStatus {
+			in.RemovingShardsBalancerMode = mode
+			return in
+		}, apis.EnableStatusSubresource)
+		if err != nil {
+			return err
+		}
+		mongodb.Status = mg.Status
+		if mode == dbclient.BalancerModeOff {
+			c.recorder.Event(
+				mongodb,
+				core.EventTypeNormal,
+				eventer.EventReasonSuccessful,
+				"Starting the stopped balancer to drain shards. It is stopped again once draining completes",
+			)
+		}
+	}
+	return client.BalancerStart(ctx)
+}
+
+// movePrimaries moves the databases homed on a draining shard to the first shard of the cluster.
+func (c *Controller) movePrimaries(ctx context.Context, mongodb *api.MongoDB, client *dbclient.Client, shard string, databases []string) error {
+	target := mongodb.ShardRepSetName(0)
+	for _, db := range databases {
+		if err := client.MovePrimary(ctx, db, target); err != nil {
+			c.recorder.Eventf(
+				mongodb,
+				core.EventTypeWarning,
+				eventer.EventReasonFailedToUpdate,
+				"Failed to move primary of database %v from shard %v to %v. Reason: %v",
+				db,
+				shard,
+				target,
+				err,
+			)
+			return err
+		}
+		c.recorder.Eventf(
+			mongodb,
+			core.EventTypeNormal,
+			eventer.EventReasonSuccessful,
+			"Moved primary of database %v from shard %v to %v",
+			db,
+			shard,
+			target,
+		)
+	}
+	return nil
+}
+
+// removedShardNodes returns the node numbers of the shard StatefulSets beyond spec.shardTopology.shard.shards.
+func (c *Controller) removedShardNodes(mongodb *api.MongoDB) ([]int32, error) {
+	statefulSets, err := c.Client.AppsV1().StatefulSets(mongodb.Namespace).List(metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(mongodb.OffshootSelectors()).String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := mongodb.Spec.ShardTopology.Shard.Prefix + mongodb.OffshootName() + "-shard"
+	var nodes []int32
+	for _, sts := range statefulSets.Items {
+		if _, ok := sts.Labels[api.MongoDBShardLabelKey]; !ok || !strings.HasPrefix(sts.Name, prefix) {
+			continue
+		}
+		n, err := strconv.ParseInt(strings.TrimPrefix(sts.Name, prefix), 10, 32)
+		if err != nil {
+			continue
+		}
+		if int32(n) >= mongodb.Spec.ShardTopology.Shard.Shards {
+			nodes = append(nodes, int32(n))
+		}
+	}
+	return nodes, nil
+}
+
+// deleteShardNode deletes the StatefulSet of a removed shard along with its governing Service.
+func (c *Controller) deleteShardNode(mongodb *api.MongoDB, stsName string) error {
+	err := c.Client.AppsV1().StatefulSets(mongodb.Namespace).Delete(stsName, meta_util.DeleteInBackground())
+	if err != nil && !kerr.IsNotFound(err) {
+		return err
+	}
+	err = c.Client.CoreV1().Services(mongodb.Namespace).Delete(mongodb.GvrSvcName(stsName), meta_util.DeleteInBackground())
+	if err != nil && !kerr.IsNotFound(err) {
+		return err
+	}
+	if err := c.deleteStatsService(mongodb, componentStatsService{mongodb: mongodb, component: stsName}.ServiceName()); err != nil {
+		return err
+	}
+	log.Infof("deleted StatefulSet %v/%v of removed shard", mongodb.Namespace, stsName)
+	return nil
+}
+
+// connectToMongos connects to the mongos routers of mongodb through the database Service.
+func (c *Controller) connectToMongos(ctx context.Context, mongodb *api.MongoDB) (*dbclient.Client, error) {
+	cfg, err := c.dbClientConfig(mongodb)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Hosts = []string{mongosServiceHost(mongodb)}
+	return dbclient.New(ctx, cfg)
+}
+
+// mongosServiceHost returns the host:port of the Service of mongos, that the mongos certificate names.
+func mongosServiceHost(mongodb *api.MongoDB) string {
+	return fmt.Sprintf("%v.%v.svc:%v", mongodb.ServiceName(), mongodb.Namespace, api.MongoDBMongosPort)
+}
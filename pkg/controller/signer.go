@@ -0,0 +1,405 @@
+package controller
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math"
+	"math/big"
+	"time"
+
+	"github.com/pkg/errors"
+	"gomodules.xyz/cert"
+	core "k8s.io/api/core/v1"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	core_util "kmodules.xyz/client-go/core/v1"
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+)
+
+const (
+	// certManagerGroup is the API group of CertificateRequests, and the default group of their issuers.
+	certManagerGroup = "cert-manager.io"
+)
+
+// certificateRequestResource is the resource of cert-manager CertificateRequests.
+// ref: https://cert-manager.io/docs/concepts/certificaterequest/
+var certificateRequestResource = schema.GroupVersionResource{
+	Group:    certManagerGroup,
+	Version:  "v1",
+	Resource: "certificaterequests",
+}
+
+// Signer signs the certificates the operator issues for MongoDB.
+type Signer interface {
+	// Sign returns the PEM encoded certificate signed for csr, valid for duration with usages, along with the PEM
+	// encoded CA that verifies it. name identifies the certificate among the certificates of MongoDB.
+	// A *certificatePendingError is returned, while the certificate is waiting to be signed.
+	Sign(name string, csr *x509.CertificateRequest, usages []x509.ExtKeyUsage, duration time.Duration) ([]byte, []byte, error)
+}
+
+// caSigner signs certificates by a CA whose key is held by the operator.
+type caSigner struct {
+	key  crypto.Signer
+	cert *x509.Certificate
+}
+
+var _ Signer = &caSigner{}
+
+func (s *caSigner) Sign(name string, csr *x509.CertificateRequest, usages []x509.ExtKeyUsage, duration time.Duration) ([]byte, []byte, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).SetInt64(math.MaxInt64))
+	if err != nil {
+		return nil, nil, err
+	}
+	notAfter := time.Now().Add(duration).UTC()
+	if notAfter.After(s.cert.NotAfter) {
+		notAfter = s.cert.NotAfter
+	}
+	tmpl := x509.Certificate{
+		SerialNumber: serial,
+		Subject:      csr.Subject,
+		DNSNames:     csr.DNSNames,
+		IPAddresses:  csr.IPAddresses,
+		NotBefore:    s.cert.NotBefore,
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  usages,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, s.cert, csr.PublicKey, s.key)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to sign certificate %v", name)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: cert.CertificateBlockType, Bytes: der}), cert.EncodeCertPEM(s.cert), nil
+}
+
+// certificateRequestSigner signs certificates through cert-manager CertificateRequests to the issuer of issuerRef.
+// The issuer must publish its CA in status.ca of the requests, as the members verify each other by it.
+type certificateRequestSigner struct {
+	client    dynamic.Interface
+	mongodb   *api.MongoDB
+	issuerRef api.MongoDBTLSIssuerRef
+}
+
+var _ Signer = &certificateRequestSigner{}
+
+// keyUsages maps the extended key usages to the usages of CertificateRequests.
+var keyUsages = map[x509.ExtKeyUsage]string{
+	x509.ExtKeyUsageServerAuth: "server auth",
+	x509.ExtKeyUsageClientAuth: "client auth",
+}
+
+func (s *certificateRequestSigner) Sign(name string, csr *x509.CertificateRequest, usages []x509.ExtKeyUsage, duration time.Duration) ([]byte, []byte, error) {
+	ri := s.client.Resource(certificateRequestResource).Namespace(s.mongodb.Namespace)
+	issuer := fmt.Sprintf("%v %v", s.issuerRef.Kind, s.issuerRef.Name)
+
+	reqName := certificateRequestName(name, csr.RawSubjectPublicKeyInfo)
+	req, err := ri.Get(reqName, metav1.GetOptions{})
+	if kerr.IsNotFound(err) {
+		req, err = ri.Create(s.certificateRequest(reqName, csr, usages, duration), metav1.CreateOptions{})
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	conditions, _, _ := unstructured.NestedSlice(req.Object, "status", "conditions")
+	ready := false
+	for _, c := range conditions {
+		cond, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		typ, _, _ := unstructured.NestedString(cond, "type")
+		status, _, _ := unstructured.NestedString(cond, "status")
+		reason, _, _ := unstructured.NestedString(cond, "reason")
+		message, _, _ := unstructured.NestedString(cond, "message")
+		if (typ == "Ready" && status == "False" && reason == "Failed") ||
+			((typ == "Denied" || typ == "InvalidRequest") && status == "True") {
+			// the failed request is deleted, so that it is requested again on the next sync.
+			if err := ri.Delete(reqName, &metav1.DeleteOptions{}); err != nil && !kerr.IsNotFound(err) {
+				return nil, nil, err
+			}
+			return nil, nil, errors.Errorf("certificate request %v/%v is not signed by %v. Reason: %v", req.GetNamespace(), reqName, issuer, message)
+		}
+		if typ == "Ready" && status == "True" {
+			ready = true
+		}
+	}
+	if !ready {
+		return nil, nil, &certificatePendingError{request: reqName, issuer: issuer}
+	}
+
+	crt, err := decodeCertificateRequestField(req, "certificate")
+	if err != nil {
+		return nil, nil, err
+	}
+	ca, err := decodeCertificateRequestField(req, "ca")
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(ca) == 0 {
+		return nil, nil, errors.Errorf("%v doesn't publish its CA in certificate request %v/%v", issuer, req.GetNamespace(), reqName)
+	}
+	return crt, ca, nil
+}
+
+// certificateRequestName returns the name of the CertificateRequest of certificate name for the public key spki.
+// The request is named after its key, so that it is created once for every key.
+func certificateRequestName(name string, spki []byte) string {
+	sum := sha256.Sum256(spki)
+	return fmt.Sprintf("%v-%x", name, sum[:4])
+}
+
+func (s *certificateRequestSigner) certificateRequest(name string, csr *x509.CertificateRequest, usages []x509.ExtKeyUsage, duration time.Duration) *unstructured.Unstructured {
+	group := s.issuerRef.Group
+	if group == "" {
+		group = certManagerGroup
+	}
+	requestUsages := []interface{}{"digital signature", "key encipherment"}
+	for _, usage := range usages {
+		requestUsages = append(requestUsages, keyUsages[usage])
+	}
+
+	req := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"spec": map[string]interface{}{
+				"request": base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{
+					Type:  "CERTIFICATE REQUEST",
+					Bytes: csr.Raw,
+				})),
+				"issuerRef": map[string]interface{}{
+					"name":  s.issuerRef.Name,
+					"kind":  string(s.issuerRef.Kind),
+					"group": group,
+				},
+				"usages":   requestUsages,
+				"duration": duration.String(),
+			},
+		},
+	}
+	req.SetAPIVersion(certificateRequestResource.GroupVersion().String())
+	req.SetKind("CertificateRequest")
+	req.SetName(name)
+	req.SetNamespace(s.mongodb.Namespace)
+	req.SetLabels(s.mongodb.OffshootLabels())
+	req.SetOwnerReferences([]metav1.OwnerReference{
+		{
+			APIVersion: api.SchemeGroupVersion.String(),
+			Kind:       api.ResourceKindMongoDB,
+			Name:       s.mongodb.Name,
+			UID:        s.mongodb.UID,
+		},
+	})
+	return req
+}
+
+// decodeCertificateRequestField returns the PEM of the base64 encoded status field of a CertificateRequest.
+func decodeCertificateRequestField(req *unstructured.Unstructured, field string) ([]byte, error) {
+	value, _, _ := unstructured.NestedString(req.Object, "status", field)
+	data, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decode status.%v of certificate request %v/%v", field, req.GetNamespace(), req.GetName())
+	}
+	return data, nil
+}
+
+// signer returns the Signer of the issuer of spec.tls.issuerRef, or of the CA the operator generated for mongodb.
+func (c *Controller) signer(mongodb *api.MongoDB) (Signer, error) {
+	ref := issuerRef(mongodb)
+	if ref == nil {
+		key, crt, err := c.issuingCA(mongodb)
+		if err != nil {
+			return nil, err
+		}
+		return &caSigner{key: key, cert: crt}, nil
+	}
+
+	switch ref.Kind {
+	case api.MongoDBTLSIssuerKindCASecret:
+		secret, err := c.Client.CoreV1().Secrets(mongodb.Namespace).Get(ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		key, crt, err := parseCA(secret)
+		if err != nil {
+			return nil, err
+		}
+		return &caSigner{key: key, cert: crt}, nil
+	case api.MongoDBTLSIssuerKindOperatorCA:
+		if c.caSecret == "" {
+			return nil, errors.Errorf("issuer %v of MongoDB %v/%v is not configured in the operator", ref.Kind, mongodb.Namespace, mongodb.Name)
+		}
+		secret, err := c.Client.CoreV1().Secrets(c.OperatorNamespace).Get(c.caSecret, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		key, crt, err := parseCA(secret)
+		if err != nil {
+			return nil, err
+		}
+		return &caSigner{key: key, cert: crt}, nil
+	case api.MongoDBTLSIssuerKindIssuer, api.MongoDBTLSIssuerKindClusterIssuer:
+		return &certificateRequestSigner{
+			client:    c.DynamicClient,
+			mongodb:   mongodb,
+			issuerRef: *ref,
+		}, nil
+	}
+	return nil, errors.Errorf("unknown issuer kind %v of MongoDB %v/%v", ref.Kind, mongodb.Namespace, mongodb.Name)
+}
+
+// issuerRef returns spec.tls.issuerRef of mongodb, if any.
+func issuerRef(mongodb *api.MongoDB) *api.MongoDBTLSIssuerRef {
+	if mongodb.Spec.TLS == nil {
+		return nil
+	}
+	return mongodb.Spec.TLS.IssuerRef
+}
+
+// issueCertificate returns the PEM, ie, certificate followed by key, of req signed by signer, along with the PEM
+// of the CA that verifies it. While the request is pending at an external issuer, its key is kept in the CA secret,
+// that is never mounted into the database pods, until it is released by releasePendingKeys once the certificate
+// is stored.
+func (c *Controller) issueCertificate(mongodb *api.MongoDB, signer Signer, req certificateRequest) ([]byte, []byte, error) {
+	pendingKey := req.name + ".key"
+	caSecret, err := c.Client.CoreV1().Secrets(mongodb.Namespace).Get(caSecretName(mongodb), metav1.GetOptions{})
+	if err != nil && !kerr.IsNotFound(err) {
+		return nil, nil, err
+	}
+
+	var key crypto.Signer
+	pending := err == nil && len(caSecret.Data[pendingKey]) > 0
+	if pending {
+		key, err = parsePrivateKeyPEM(caSecret.Data[pendingKey])
+	} else {
+		key, err = newPrivateKey(mongodb.Spec.TLS)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	keyPEM, err := encodePrivateKeyPEM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	crt, ca, err := signCertificate(signer, req, key)
+	if _, ok := err.(*certificatePendingError); ok && !pending {
+		if _, _, err := core_util.CreateOrPatchSecret(c.Client, metav1.ObjectMeta{
+			Name:      caSecretName(mongodb),
+			Namespace: mongodb.Namespace,
+		}, func(in *core.Secret) *core.Secret {
+			in.Labels = mongodb.OffshootLabels()
+			if in.Data == nil {
+				in.Data = map[string][]byte{}
+			}
+			in.Data[pendingKey] = keyPEM
+			return in
+		}); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return append(crt, keyPEM...), ca, nil
+}
+
+// releasePendingKeys removes the keys of the certificates names, that were pending at an external issuer,
+// from the CA secret, along with their CertificateRequests. It is called once the certificates are stored.
+func (c *Controller) releasePendingKeys(mongodb *api.MongoDB, names ...string) error {
+	caSecret, err := c.Client.CoreV1().Secrets(mongodb.Namespace).Get(caSecretName(mongodb), metav1.GetOptions{})
+	if kerr.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var released []string
+	for _, name := range names {
+		data, ok := caSecret.Data[name+".key"]
+		if !ok {
+			continue
+		}
+		if key, err := parsePrivateKeyPEM(data); err == nil {
+			if spki, err := x509.MarshalPKIXPublicKey(key.Public()); err == nil {
+				err := c.DynamicClient.Resource(certificateRequestResource).Namespace(mongodb.Namespace).
+					Delete(certificateRequestName(name, spki), &metav1.DeleteOptions{})
+				if err != nil && !kerr.IsNotFound(err) {
+					return err
+				}
+			}
+		}
+		released = append(released, name+".key")
+	}
+	if len(released) == 0 {
+		return nil
+	}
+	_, _, err = core_util.PatchSecret(c.Client, caSecret, func(in *core.Secret) *core.Secret {
+		for _, key := range released {
+			delete(in.Data, key)
+		}
+		return in
+	})
+	return err
+}
+
+// issueCertificates issues the client certificate, and the server certificate of a standalone if server is true,
+// into data, the data of spec.certificateSecret. The CA that verifies them is added to ca.cert.
+// It returns the names of the certificates, to release their pending keys once data is stored.
+func (c *Controller) issueCertificates(mongodb *api.MongoDB, signer Signer, data map[string][]byte, server bool) ([]string, error) {
+	reqs := map[string]certificateRequest{
+		api.MongoClientPemFileName: clientCertificateRequest(mongodb),
+	}
+	if server {
+		reqs[api.MongoServerPemFileName] = serverCertificateRequest(mongodb, mongodb.OffshootName(), memberDNSNames(mongodb, mongodb.OffshootName(), 0)...)
+	}
+
+	// every certificate is requested, before waiting for any of them to be signed.
+	var names []string
+	var pending error
+	for file, req := range reqs {
+		pem, ca, err := c.issueCertificate(mongodb, signer, req)
+		if _, ok := err.(*certificatePendingError); ok {
+			pending = err
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		data[file] = pem
+		if data[api.MongoTLSCertFileName], err = addCA(data[api.MongoTLSCertFileName], ca); err != nil {
+			return nil, err
+		}
+		names = append(names, req.name)
+	}
+	if pending != nil {
+		return nil, pending
+	}
+	return names, nil
+}
+
+// addCA returns bundle with the first certificate of ca in front, unless bundle already has it.
+func addCA(bundle, ca []byte) ([]byte, error) {
+	certs, err := cert.ParseCertsPEM(ca)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse CA")
+	}
+	if len(bundle) > 0 {
+		cas, err := cert.ParseCertsPEM(bundle)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse CA")
+		}
+		for _, crt := range cas {
+			if bytes.Equal(crt.Raw, certs[0].Raw) {
+				return bundle, nil
+			}
+		}
+	}
+	return append(cert.EncodeCertPEM(certs[0]), bundle...), nil
+}
@@ -11,6 +11,7 @@ import (
 	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
 	"kubedb.dev/apimachinery/client/clientset/versioned/typed/kubedb/v1alpha1/util"
 	amv "kubedb.dev/apimachinery/pkg/validator"
+	validator "kubedb.dev/mongodb/pkg/admission"
 )
 
 func (c *Controller) GetDatabase(meta metav1.ObjectMeta) (runtime.Object, error) {
@@ -55,9 +56,33 @@ func (c *Controller) ValidateSnapshot(snapshot *api.Snapshot) error {
 		return fmt.Errorf(`object 'DatabaseName' is missing in '%v'`, snapshot.Spec)
 	}
 
-	if _, err := c.mgLister.MongoDBs(snapshot.Namespace).Get(databaseName); err != nil {
+	mongodb, err := c.mgLister.MongoDBs(snapshot.Namespace).Get(databaseName)
+	if err != nil {
+		return err
+	}
+
+	if err := validator.ValidateSnapshotArchive(snapshot.Spec.Archive); err != nil {
 		return err
 	}
+	if err := validator.ValidateSnapshotSelection(snapshot.Spec.Selection); err != nil {
+		return err
+	}
+	if isConsistentShardedBackup(mongodb) {
+		if snapshot.Spec.Archive != nil {
+			return fmt.Errorf("archive is not supported for the consistent snapshots of sharded clusters")
+		}
+		if snapshot.Spec.Selection != nil {
+			return fmt.Errorf("selection is not supported for the consistent snapshots of sharded clusters")
+		}
+		// the backup stops the balancer, that migrates the chunks of draining shards.
+		removing, err := c.removedShardNodes(mongodb)
+		if err != nil {
+			return err
+		}
+		if len(removing) > 0 || len(mongodb.Status.RemovingShards) > 0 {
+			return fmt.Errorf("consistent snapshot can't be taken while shards are being removed from MongoDB %v/%v", mongodb.Namespace, mongodb.Name)
+		}
+	}
 
 	return amv.ValidateSnapshotSpec(snapshot.Spec.Backend)
 }
@@ -0,0 +1,271 @@
+package controller
+
+import (
+	"fmt"
+
+	core "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"kubedb.dev/apimachinery/apis"
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+	"kubedb.dev/apimachinery/client/clientset/versioned/typed/kubedb/v1alpha1/util"
+)
+
+// Reasons used in MongoDB conditions.
+const (
+	ConditionReasonReady         = "Ready"
+	ConditionReasonNotReady      = "NotReady"
+	ConditionReasonProvisioning  = "Provisioning"
+	ConditionReasonScheduled     = "Scheduled"
+	ConditionReasonFailed        = "Failed"
+	ConditionReasonConfigured    = "Configured"
+	ConditionReasonCertAvailable = "CertificateAvailable"
+	ConditionReasonCertExpiring  = "CertificateExpiring"
+	ConditionReasonCertRotating  = "CertificateRotating"
+	ConditionReasonCertExpired   = "CertificateExpired"
+)
+
+// componentSelector couples the status of a component with the selector of its pods.
+type componentSelector struct {
+	status   api.MongoDBComponentStatus
+	selector map[string]string
+}
+
+// desiredComponents returns every component of mongodb with its desired replicas.
+func desiredComponents(mongodb *api.MongoDB) []componentSelector {
+	if topology := mongodb.Spec.ShardTopology; topology != nil {
+		components := make([]componentSelector, 0, topology.Shard.Shards+2)
+		components = append(components, componentSelector{
+			status: api.MongoDBComponentStatus{
+				Name:           mongodb.ConfigSvrNodeName(),
+				Type:           api.MongoDBComponentConfigServer,
+				ReplicaSetName: mongodb.ConfigSvrRepSetName(),
+				Replicas:       topology.ConfigServer.Replicas,
+			},
+			selector: mongodb.ConfigSvrSelectors(),
+		})
+		for i := int32(0); i < topology.Shard.Shards; i++ {
+			components = append(components, componentSelector{
+				status: api.MongoDBComponentStatus{
+					Name:           mongodb.ShardNodeName(i),
+					Type:           api.MongoDBComponentShard,
+					ReplicaSetName: mongodb.ShardRepSetName(i),
+					Replicas:       topology.Shard.Replicas,
+				},
+				selector: mongodb.ShardSelectors(i),
+			})
+		}
+		components = append(components, componentSelector{
+			status: api.MongoDBComponentStatus{
+				Name:     mongodb.MongosNodeName(),
+				Type:     api.MongoDBComponentMongos,
+				Replicas: topology.Mongos.Replicas,
+			},
+			selector: mongodb.MongosSelectors(),
+		})
+		return components
+	}
+
+	replicas := int32(1)
+	if mongodb.Spec.Replicas != nil {
+		replicas = *mongodb.Spec.Replicas
+	}
+	component := componentSelector{
+		status: api.MongoDBComponentStatus{
+			Name:     mongodb.OffshootName(),
+			Type:     api.MongoDBComponentStandalone,
+			Replicas: replicas,
+		},
+		selector: mongodb.OffshootSelectors(),
+	}
+	if mongodb.Spec.ReplicaSet != nil {
+		component.status.Type = api.MongoDBComponentReplicaSet
+		component.status.ReplicaSetName = mongodb.RepSetName()
+	}
+	return []componentSelector{component}
+}
+
+// observeComponents counts the ready pods of every component of mongodb.
+// PrimaryPod and Members are carried over from the current status, as they are filled by the health prober.
+func (c *Controller) observeComponents(mongodb *api.MongoDB) ([]api.MongoDBComponentStatus, error) {
+	components := desiredComponents(mongodb)
+	result := make([]api.MongoDBComponentStatus, 0, len(components))
+	for _, component := range components {
+		ready, err := c.countReadyPods(mongodb.Namespace, &metav1.LabelSelector{MatchLabels: component.selector})
+		if err != nil {
+			return nil, err
+		}
+		component.status.ReadyReplicas = ready
+		if cur := getComponentStatus(mongodb.Status.Components, component.status.Name); cur != nil {
+			component.status.PrimaryPod = cur.PrimaryPod
+			component.status.Members = cur.Members
+		}
+		result = append(result, component.status)
+	}
+	return result, nil
+}
+
+// componentConditions derives the readiness conditions of the component types from components.
+func componentConditions(mongodb *api.MongoDB, components []api.MongoDBComponentStatus) []api.MongoDBCondition {
+	var types []api.MongoDBConditionType
+	if mongodb.Spec.ShardTopology != nil {
+		types = []api.MongoDBConditionType{
+			api.MongoDBConditionConfigServerReady,
+			api.MongoDBConditionShardsReady,
+			api.MongoDBConditionMongosReady,
+		}
+	} else if mongodb.Spec.ReplicaSet != nil {
+		types = []api.MongoDBConditionType{api.MongoDBConditionReplicaSetReady}
+	} else {
+		types = []api.MongoDBConditionType{api.MongoDBConditionStandaloneReady}
+	}
+
+	conditionOf := map[api.MongoDBComponentType]api.MongoDBConditionType{
+		api.MongoDBComponentStandalone:   api.MongoDBConditionStandaloneReady,
+		api.MongoDBComponentReplicaSet:   api.MongoDBConditionReplicaSetReady,
+		api.MongoDBComponentShard:        api.MongoDBConditionShardsReady,
+		api.MongoDBComponentConfigServer: api.MongoDBConditionConfigServerReady,
+		api.MongoDBComponentMongos:       api.MongoDBConditionMongosReady,
+	}
+	notReady := make(map[api.MongoDBConditionType][]string)
+	for _, component := range components {
+		if component.ReadyReplicas < component.Replicas {
+			t := conditionOf[component.Type]
+			notReady[t] = append(notReady[t], fmt.Sprintf("%v (%v/%v)", component.Name, component.ReadyReplicas, component.Replicas))
+		}
+	}
+
+	conditions := make([]api.MongoDBCondition, 0, len(types))
+	for _, t := range types {
+		if pending, ok := notReady[t]; ok {
+			conditions = append(conditions, newCondition(t, core.ConditionFalse, ConditionReasonNotReady,
+				fmt.Sprintf("members are not ready: %v", pending)))
+		} else {
+			conditions = append(conditions, newCondition(t, core.ConditionTrue, ConditionReasonReady,
+				"all members are ready"))
+		}
+	}
+	return conditions
+}
+
+// updateComponentStatus observes the components of mongodb and records them,
+// along with their readiness conditions, in MongoDB status.
+func (c *Controller) updateComponentStatus(mongodb *api.MongoDB) error {
+	components, err := c.observeComponents(mongodb)
+	if err != nil {
+		return err
+	}
+	conditions := componentConditions(mongodb, components)
+
+	if apiequality.Semantic.DeepEqual(mongodb.Status.Components, components) && !conditionsChanged(mongodb.Status.Conditions, conditions...) {
+		return nil
+	}
+	mg, err := util.UpdateMongoDBStatus(c.ExtClient.KubedbV1alpha1(), mongodb, func(in *api.MongoDBStatus) *api.MongoDBStatus {
+		in.Components = components
+		for _, cond := range conditions {
+			in.Conditions = setCondition(in.Conditions, cond)
+		}
+		return in
+	}, apis.EnableStatusSubresource)
+	if err != nil {
+		return err
+	}
+	mongodb.Status = mg.Status
+	return nil
+}
+
+// setMongoDBConditions records conditions in MongoDB status. Status is written only if any of them changed.
+func (c *Controller) setMongoDBConditions(mongodb *api.MongoDB, conditions ...api.MongoDBCondition) error {
+	if !conditionsChanged(mongodb.Status.Conditions, conditions...) {
+		return nil
+	}
+	mg, err := util.UpdateMongoDBStatus(c.ExtClient.KubedbV1alpha1(), mongodb, func(in *api.MongoDBStatus) *api.MongoDBStatus {
+		for _, cond := range conditions {
+			in.Conditions = setCondition(in.Conditions, cond)
+		}
+		return in
+	}, apis.EnableStatusSubresource)
+	if err != nil {
+		return err
+	}
+	mongodb.Status = mg.Status
+	return nil
+}
+
+// removeMongoDBCondition drops the condition of type t from MongoDB status, if present.
+func (c *Controller) removeMongoDBCondition(mongodb *api.MongoDB, t api.MongoDBConditionType) error {
+	if getCondition(mongodb.Status.Conditions, t) == nil {
+		return nil
+	}
+	mg, err := util.UpdateMongoDBStatus(c.ExtClient.KubedbV1alpha1(), mongodb, func(in *api.MongoDBStatus) *api.MongoDBStatus {
+		in.Conditions = removeCondition(in.Conditions, t)
+		return in
+	}, apis.EnableStatusSubresource)
+	if err != nil {
+		return err
+	}
+	mongodb.Status = mg.Status
+	return nil
+}
+
+func newCondition(t api.MongoDBConditionType, status core.ConditionStatus, reason, message string) api.MongoDBCondition {
+	return api.MongoDBCondition{
+		Type:               t,
+		Status:             status,
+		LastTransitionTime: metav1.Now(),
+		Reason:             reason,
+		Message:            message,
+	}
+}
+
+func getCondition(conditions []api.MongoDBCondition, t api.MongoDBConditionType) *api.MongoDBCondition {
+	for i := range conditions {
+		if conditions[i].Type == t {
+			return &conditions[i]
+		}
+	}
+	return nil
+}
+
+// setCondition upserts cond in conditions. LastTransitionTime is kept as is, unless the status changes.
+func setCondition(conditions []api.MongoDBCondition, cond api.MongoDBCondition) []api.MongoDBCondition {
+	cur := getCondition(conditions, cond.Type)
+	if cur == nil {
+		return append(conditions, cond)
+	}
+	if cur.Status == cond.Status {
+		cond.LastTransitionTime = cur.LastTransitionTime
+	}
+	*cur = cond
+	return conditions
+}
+
+func removeCondition(conditions []api.MongoDBCondition, t api.MongoDBConditionType) []api.MongoDBCondition {
+	out := conditions[:0]
+	for _, cond := range conditions {
+		if cond.Type != t {
+			out = append(out, cond)
+		}
+	}
+	return out
+}
+
+// conditionsChanged reports whether setting conditions on current would change anything other than timestamps.
+func conditionsChanged(current []api.MongoDBCondition, conditions ...api.MongoDBCondition) bool {
+	for _, cond := range conditions {
+		cur := getCondition(current, cond.Type)
+		if cur == nil || cur.Status != cond.Status || cur.Reason != cond.Reason || cur.Message != cond.Message {
+			return true
+		}
+	}
+	return false
+}
+
+func getComponentStatus(components []api.MongoDBComponentStatus, name string) *api.MongoDBComponentStatus {
+	for i := range components {
+		if components[i].Name == name {
+			return &components[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+package controller
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+	amc "kubedb.dev/apimachinery/pkg/controller"
+)
+
+func TestSetCondition(t *testing.T) {
+	then := metav1.NewTime(time.Now().Add(-time.Hour))
+	conditions := []api.MongoDBCondition{
+		{Type: api.MongoDBConditionProvisioned, Status: core.ConditionFalse, LastTransitionTime: then, Reason: ConditionReasonProvisioning},
+		{Type: api.MongoDBConditionReplicaSetReady, Status: core.ConditionTrue, LastTransitionTime: then, Reason: ConditionReasonReady},
+	}
+
+	// same status, new message: the transition time is kept
+	conditions = setCondition(conditions, newCondition(api.MongoDBConditionProvisioned, core.ConditionFalse, ConditionReasonProvisioning, "waiting"))
+	if cond := getCondition(conditions, api.MongoDBConditionProvisioned); !cond.LastTransitionTime.Equal(&then) || cond.Message != "waiting" {
+		t.Errorf("expected message to be updated at the old transition time, got %+v", cond)
+	}
+
+	// new status: the transition time moves
+	conditions = setCondition(conditions, newCondition(api.MongoDBConditionReplicaSetReady, core.ConditionFalse, ConditionReasonNotReady, ""))
+	if cond := getCondition(conditions, api.MongoDBConditionReplicaSetReady); cond.LastTransitionTime.Equal(&then) || cond.Status != core.ConditionFalse {
+		t.Errorf("expected status to transition, got %+v", cond)
+	}
+
+	// new type: appended
+	conditions = setCondition(conditions, newCondition(api.MongoDBConditionMongosReady, core.ConditionTrue, ConditionReasonReady, ""))
+	if len(conditions) != 3 || conditions[2].Type != api.MongoDBConditionMongosReady {
+		t.Errorf("expected the new condition to be appended, got %+v", conditions)
+	}
+
+	conditions = removeCondition(conditions, api.MongoDBConditionReplicaSetReady)
+	if len(conditions) != 2 || getCondition(conditions, api.MongoDBConditionReplicaSetReady) != nil {
+		t.Errorf("expected the condition to be removed, got %+v", conditions)
+	}
+}
+
+func TestConditionsChanged(t *testing.T) {
+	then := metav1.NewTime(time.Now().Add(-time.Hour))
+	current := []api.MongoDBCondition{
+		{Type: api.MongoDBConditionProvisioned, Status: core.ConditionTrue, LastTransitionTime: then, Reason: ConditionReasonReady, Message: "ready"},
+	}
+
+	cases := []struct {
+		name string
+		cond api.MongoDBCondition
+		want bool
+	}{
+		{"timestamp only", newCondition(api.MongoDBConditionProvisioned, core.ConditionTrue, ConditionReasonReady, "ready"), false},
+		{"status", newCondition(api.MongoDBConditionProvisioned, core.ConditionFalse, ConditionReasonReady, "ready"), true},
+		{"reason", newCondition(api.MongoDBConditionProvisioned, core.ConditionTrue, ConditionReasonScheduled, "ready"), true},
+		{"message", newCondition(api.MongoDBConditionProvisioned, core.ConditionTrue, ConditionReasonReady, "done"), true},
+		{"missing", newCondition(api.MongoDBConditionMongosReady, core.ConditionTrue, ConditionReasonReady, ""), true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := conditionsChanged(current, c.cond); got != c.want {
+				t.Errorf("expected %v, got %v", c.want, got)
+			}
+		})
+	}
+}
+
+func shardedMongoDB() *api.MongoDB {
+	return &api.MongoDB{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "mg",
+			Namespace: "demo",
+		},
+		Spec: api.MongoDBSpec{
+			ShardTopology: &api.MongoDBShardingTopology{
+				Shard: api.MongoDBShardNode{
+					Shards:      2,
+					MongoDBNode: api.MongoDBNode{Replicas: 3},
+				},
+				ConfigServer: api.MongoDBConfigNode{
+					MongoDBNode: api.MongoDBNode{Replicas: 3},
+				},
+				Mongos: api.MongoDBMongosNode{
+					MongoDBNode: api.MongoDBNode{Replicas: 2},
+				},
+			},
+		},
+	}
+}
+
+func TestDesiredComponents(t *testing.T) {
+	replicas := int32(3)
+	replicaSet := &api.MongoDB{
+		ObjectMeta: metav1.ObjectMeta{Name: "mg", Namespace: "demo"},
+		Spec: api.MongoDBSpec{
+			Replicas:   &replicas,
+			ReplicaSet: &api.MongoDBReplicaSet{Name: "rs0"},
+		},
+	}
+	components := desiredComponents(replicaSet)
+	if len(components) != 1 {
+		t.Fatalf("expected a single component, got %v", len(components))
+	}
+	if s := components[0].status; s.Type != api.MongoDBComponentReplicaSet || s.ReplicaSetName != "rs0" || s.Replicas != 3 {
+		t.Errorf("unexpected replicaset component %+v", s)
+	}
+
+	standalone := &api.MongoDB{ObjectMeta: metav1.ObjectMeta{Name: "mg", Namespace: "demo"}}
+	if s := desiredComponents(standalone)[0].status; s.Type != api.MongoDBComponentStandalone || s.Replicas != 1 {
+		t.Errorf("unexpected standalone component %+v", s)
+	}
+
+	sharded := shardedMongoDB()
+	components = desiredComponents(sharded)
+	want := []struct {
+		name     string
+		typ      api.MongoDBComponentType
+		replicas int32
+	}{
+		{sharded.ConfigSvrNodeName(), api.MongoDBComponentConfigServer, 3},
+		{sharded.ShardNodeName(0), api.MongoDBComponentShard, 3},
+		{sharded.ShardNodeName(1), api.MongoDBComponentShard, 3},
+		{sharded.MongosNodeName(), api.MongoDBComponentMongos, 2},
+	}
+	if len(components) != len(want) {
+		t.Fatalf("expected %v components, got %v", len(want), len(components))
+	}
+	for i, w := range want {
+		if s := components[i].status; s.Name != w.name || s.Type != w.typ || s.Replicas != w.replicas {
+			t.Errorf("expected component %v to be %+v, got %+v", i, w, s)
+		}
+	}
+}
+
+func readyPod(name string, labels map[string]string, ready bool) runtime.Object {
+	status := core.ConditionTrue
+	if !ready {
+		status = core.ConditionFalse
+	}
+	return &core.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "demo",
+			Labels:    labels,
+		},
+		Status: core.PodStatus{
+			Phase:      core.PodRunning,
+			Conditions: []core.PodCondition{{Type: core.PodReady, Status: status}},
+		},
+	}
+}
+
+func TestComponentStatus(t *testing.T) {
+	mongodb := shardedMongoDB()
+	mongodb.Status.Components = []api.MongoDBComponentStatus{
+		{Name: mongodb.ShardNodeName(0), PrimaryPod: mongodb.ShardNodeName(0) + "-0"},
+	}
+
+	var pods []runtime.Object
+	for i := 0; i < 3; i++ {
+		pods = append(pods,
+			readyPod(fmt.Sprintf("%v-%v", mongodb.ConfigSvrNodeName(), i), mongodb.ConfigSvrSelectors(), true),
+			readyPod(fmt.Sprintf("%v-%v", mongodb.ShardNodeName(0), i), mongodb.ShardSelectors(0), true),
+			readyPod(fmt.Sprintf("%v-%v", mongodb.ShardNodeName(1), i), mongodb.ShardSelectors(1), i != 2),
+		)
+	}
+	pods = append(pods, readyPod(mongodb.MongosNodeName()+"-0", mongodb.MongosSelectors(), true))
+
+	c := &Controller{Controller: &amc.Controller{Client: fake.NewSimpleClientset(pods...)}}
+	components, err := c.observeComponents(mongodb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ready := map[string]int32{
+		mongodb.ConfigSvrNodeName(): 3,
+		mongodb.ShardNodeName(0):    3,
+		mongodb.ShardNodeName(1):    2,
+		mongodb.MongosNodeName():    1,
+	}
+	for _, component := range components {
+		if component.ReadyReplicas != ready[component.Name] {
+			t.Errorf("expected %v ready replicas of %v, got %v", ready[component.Name], component.Name, component.ReadyReplicas)
+		}
+	}
+	if s := getComponentStatus(components, mongodb.ShardNodeName(0)); s.PrimaryPod != mongodb.ShardNodeName(0)+"-0" {
+		t.Errorf("expected the primary pod to be carried over, got %q", s.PrimaryPod)
+	}
+
+	want := map[api.MongoDBConditionType]core.ConditionStatus{
+		api.MongoDBConditionConfigServerReady: core.ConditionTrue,
+		api.MongoDBConditionShardsReady:       core.ConditionFalse,
+		api.MongoDBConditionMongosReady:       core.ConditionFalse,
+	}
+	conditions := componentConditions(mongodb, components)
+	if len(conditions) != len(want) {
+		t.Fatalf("expected %v conditions, got %+v", len(want), conditions)
+	}
+	for _, cond := range conditions {
+		if cond.Status != want[cond.Type] {
+			t.Errorf("expected %v to be %v, got %+v", cond.Type, want[cond.Type], cond)
+		}
+	}
+	if cond := getCondition(conditions, api.MongoDBConditionShardsReady); cond.Reason != ConditionReasonNotReady || cond.Message != "members are not ready: [mg-shard1 (2/3)]" {
+		t.Errorf("expected the pending shard in the message, got %+v", cond)
+	}
+}
+
+func TestComponentConditionsOfStandalone(t *testing.T) {
+	standalone := &api.MongoDB{ObjectMeta: metav1.ObjectMeta{Name: "mg", Namespace: "demo"}}
+	components := []api.MongoDBComponentStatus{desiredComponents(standalone)[0].status}
+
+	conditions := componentConditions(standalone, components)
+	if len(conditions) != 1 || conditions[0].Type != api.MongoDBConditionStandaloneReady || conditions[0].Status != core.ConditionFalse {
+		t.Errorf("expected only %v to be false, got %+v", api.MongoDBConditionStandaloneReady, conditions)
+	}
+
+	components[0].ReadyReplicas = 1
+	conditions = componentConditions(standalone, components)
+	if len(conditions) != 1 || conditions[0].Type != api.MongoDBConditionStandaloneReady || conditions[0].Status != core.ConditionTrue {
+		t.Errorf("expected only %v to be true, got %+v", api.MongoDBConditionStandaloneReady, conditions)
+	}
+}
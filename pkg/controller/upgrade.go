@@ -0,0 +1,359 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/appscode/go/types"
+	core "k8s.io/api/core/v1"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"kubedb.dev/apimachinery/apis"
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+	"kubedb.dev/apimachinery/client/clientset/versioned/typed/kubedb/v1alpha1/util"
+	"kubedb.dev/apimachinery/pkg/eventer"
+	validator "kubedb.dev/mongodb/pkg/admission"
+)
+
+// featureCompatibilityVersion was introduced in 3.4.
+var minFeatureCompatibilityVersion = validator.ReleaseSeries{Major: 3, Minor: 4}
+
+// ensureVersionUpgrade starts an upgrade, when the image of the running database differs from the image
+// of spec.version. It must be called before the workloads are patched, as the workloads keep their
+// current image until the upgrade reaches them.
+func (c *Controller) ensureVersionUpgrade(mongodb *api.MongoDB) error {
+	if up := mongodb.Status.Upgrade; up != nil && up.Phase == api.MongoDBUpgradePhaseRunning {
+		if up.ToVersion != string(mongodb.Spec.Version) {
+			return fmt.Errorf("upgrade from %v to %v is running. Can't upgrade to %v until it succeeds",
+				up.FromVersion, up.ToVersion, mongodb.Spec.Version)
+		}
+		return nil
+	}
+
+	mongodbVersion, err := c.ExtClient.CatalogV1alpha1().MongoDBVersions().Get(string(mongodb.Spec.Version), metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	// The first component is upgraded first, so it runs the oldest image.
+	components := desiredComponents(mongodb)
+	statefulSet, err := c.Client.AppsV1().StatefulSets(mongodb.Namespace).Get(components[0].status.Name, metav1.GetOptions{})
+	if err != nil {
+		if kerr.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	image := containerImage(statefulSet.Spec.Template.Spec.Containers)
+	if image == "" || image == mongodbVersion.Spec.DB.Image {
+		return nil
+	}
+
+	fromVersion, err := c.versionOfImage(image)
+	if err != nil {
+		return err
+	}
+	upgrade := &api.MongoDBUpgradeStatus{
+		FromVersion: fromVersion,
+		ToVersion:   string(mongodb.Spec.Version),
+		Phase:       api.MongoDBUpgradePhaseRunning,
+		StartTime:   metav1.Now(),
+	}
+	for _, component := range components {
+		upgrade.Components = append(upgrade.Components, api.MongoDBComponentUpgradeStatus{
+			Name:  component.status.Name,
+			Type:  component.status.Type,
+			State: api.MongoDBUpgradeStatePending,
+		})
+	}
+
+	mg, err := util.UpdateMongoDBStatus(c.ExtClient.KubedbV1alpha1(), mongodb, func(in *api.MongoDBStatus) *api.MongoDBStatus {
+		in.Upgrade = upgrade
+		return in
+	}, apis.EnableStatusSubresource)
+	if err != nil {
+		return err
+	}
+	mongodb.Status = mg.Status
+
+	c.recorder.Eventf(
+		mongodb,
+		core.EventTypeNormal,
+		eventer.EventReasonSuccessful,
+		"Upgrading MongoDB from %v to %v",
+		upgrade.FromVersion,
+		upgrade.ToVersion,
+	)
+	return nil
+}
+
+// progressVersionUpgrade moves a running upgrade one step forward. Components are upgraded one at a time,
+// in the order of status.upgrade.components, ie, config servers, shards and then mongos. Once every component
+// is upgraded, featureCompatibilityVersion is bumped to the new release series.
+// An upgradeInProgressError is returned until the upgrade succeeds, so that MongoDB is requeued.
+func (c *Controller) progressVersionUpgrade(mongodb *api.MongoDB) error {
+	up := mongodb.Status.Upgrade
+	if up == nil || up.Phase != api.MongoDBUpgradePhaseRunning {
+		return nil
+	}
+
+	mongodbVersion, err := c.ExtClient.CatalogV1alpha1().MongoDBVersions().Get(up.ToVersion, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	for i, component := range up.Components {
+		switch component.State {
+		case api.MongoDBUpgradeStateUpgraded:
+			continue
+		case api.MongoDBUpgradeStatePending:
+			// The workload is patched with the new image in the next pass.
+			if err := c.updateUpgradeStatus(mongodb, func(in *api.MongoDBUpgradeStatus) {
+				in.Components[i].State = api.MongoDBUpgradeStateUpgrading
+			}); err != nil {
+				return err
+			}
+			c.recorder.Eventf(
+				mongodb,
+				core.EventTypeNormal,
+				eventer.EventReasonSuccessful,
+				"Upgrading %v to %v",
+				component.Name,
+				up.ToVersion,
+			)
+			return &upgradeInProgressError{component: component.Name}
+		}
+
+		var done bool
+		if component.Type == api.MongoDBComponentMongos {
+			done, err = c.upgradeDeployment(mongodb, component)
+		} else {
+			done, err = c.upgradeStatefulSet(mongodb, i, component, mongodbVersion.Spec.DB.Image)
+		}
+		if err != nil {
+			return err
+		}
+		if !done {
+			return &upgradeInProgressError{component: component.Name}
+		}
+
+		if err := c.updateUpgradeStatus(mongodb, func(in *api.MongoDBUpgradeStatus) {
+			in.Components[i].State = api.MongoDBUpgradeStateUpgraded
+		}); err != nil {
+			return err
+		}
+		c.recorder.Eventf(
+			mongodb,
+			core.EventTypeNormal,
+			eventer.EventReasonSuccessful,
+			"Upgraded %v to %v",
+			component.Name,
+			up.ToVersion,
+		)
+	}
+
+	if up.FeatureCompatibilityVersion == "" {
+		if err := c.ensureFeatureCompatibilityVersion(mongodb); err != nil {
+			return err
+		}
+	}
+
+	if err := c.updateUpgradeStatus(mongodb, func(in *api.MongoDBUpgradeStatus) {
+		in.Phase = api.MongoDBUpgradePhaseSucceeded
+		now := metav1.Now()
+		in.CompletionTime = &now
+	}); err != nil {
+		return err
+	}
+	c.recorder.Eventf(
+		mongodb,
+		core.EventTypeNormal,
+		eventer.EventReasonSuccessful,
+		"Successfully upgraded MongoDB from %v to %v",
+		up.FromVersion,
+		up.ToVersion,
+	)
+	return nil
+}
+
+// upgradeStatefulSet restarts at most one pod of the StatefulSet of component, that still runs an image
+// other than image. Secondaries are restarted first, highest ordinal first. The primary is restarted last,
+// after it is stepped down. It reports whether every pod runs image.
+func (c *Controller) upgradeStatefulSet(mongodb *api.MongoDB, index int, component api.MongoDBComponentUpgradeStatus, image string) (bool, error) {
+	statefulSet, err := c.Client.AppsV1().StatefulSets(mongodb.Namespace).Get(component.Name, metav1.GetOptions{})
+	if err != nil {
+		return false, err
+	}
+	pods, err := c.Client.CoreV1().Pods(mongodb.Namespace).List(metav1.ListOptions{
+		LabelSelector: metav1.FormatLabelSelector(statefulSet.Spec.Selector),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	var upgraded, pending []string
+	for _, pod := range pods.Items {
+		if containerImage(pod.Spec.Containers) == image {
+			upgraded = append(upgraded, pod.Name)
+		} else {
+			pending = append(pending, pod.Name)
+		}
+	}
+	sort.Strings(upgraded)
+	if strings.Join(upgraded, ",") != strings.Join(component.UpgradedPods, ",") {
+		if err := c.updateUpgradeStatus(mongodb, func(in *api.MongoDBUpgradeStatus) {
+			in.Components[index].UpgradedPods = upgraded
+		}); err != nil {
+			return false, err
+		}
+	}
+	if len(pending) == 0 {
+		return true, nil
+	}
+	reason := fmt.Sprintf("upgrade it to %v", mongodb.Status.Upgrade.ToVersion)
+	return false, c.restartNextMember(mongodb, statefulSet, component.Type, pending, reason)
+}
+
+// upgradeDeployment reports whether the rollout of the mongos Deployment, patched with the new image, is complete.
+func (c *Controller) upgradeDeployment(mongodb *api.MongoDB, component api.MongoDBComponentUpgradeStatus) (bool, error) {
+	deployment, err := c.Client.AppsV1().Deployments(mongodb.Namespace).Get(component.Name, metav1.GetOptions{})
+	if err != nil {
+		return false, err
+	}
+	replicas := types.Int32(deployment.Spec.Replicas)
+	return deployment.Status.ObservedGeneration >= deployment.Generation &&
+		deployment.Status.UpdatedReplicas == replicas &&
+		deployment.Status.AvailableReplicas == replicas &&
+		deployment.Status.Replicas == replicas, nil
+}
+
+// ensureFeatureCompatibilityVersion bumps featureCompatibilityVersion to the release series of the new
+// version, if the upgrade crossed a release series. It runs through mongos in a sharded cluster, and on
+// the primary otherwise.
+func (c *Controller) ensureFeatureCompatibilityVersion(mongodb *api.MongoDB) error {
+	up := mongodb.Status.Upgrade
+	to, err := c.releaseSeries(up.ToVersion)
+	if err != nil {
+		return err
+	}
+	// FromVersion is the image of the database, if it does not match any MongoDBVersion.
+	if from, err := c.releaseSeries(up.FromVersion); (err == nil && !from.Less(to)) || to.Less(minFeatureCompatibilityVersion) {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), membershipTimeout)
+	defer cancel()
+
+	client, err := c.connectToDatabase(ctx, mongodb)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if err := client.SetFeatureCompatibilityVersion(ctx, to.String()); err != nil {
+		c.recorder.Eventf(
+			mongodb,
+			core.EventTypeWarning,
+			eventer.EventReasonFailedToUpdate,
+			"Failed to set featureCompatibilityVersion to %v. Reason: %v",
+			to,
+			err,
+		)
+		return err
+	}
+	c.recorder.Eventf(
+		mongodb,
+		core.EventTypeNormal,
+		eventer.EventReasonSuccessful,
+		"Set featureCompatibilityVersion to %v",
+		to,
+	)
+	return c.updateUpgradeStatus(mongodb, func(in *api.MongoDBUpgradeStatus) {
+		in.FeatureCompatibilityVersion = to.String()
+	})
+}
+
+// updateUpgradeStatus applies fn to status.upgrade.
+func (c *Controller) updateUpgradeStatus(mongodb *api.MongoDB, fn func(in *api.MongoDBUpgradeStatus)) error {
+	mg, err := util.UpdateMongoDBStatus(c.ExtClient.KubedbV1alpha1(), mongodb, func(in *api.MongoDBStatus) *api.MongoDBStatus {
+		if in.Upgrade != nil {
+			fn(in.Upgrade)
+		}
+		return in
+	}, apis.EnableStatusSubresource)
+	if err != nil {
+		return err
+	}
+	mongodb.Status = mg.Status
+	return nil
+}
+
+// versionOfImage returns the name of the MongoDBVersion with database image, or image itself if there is none.
+func (c *Controller) versionOfImage(image string) (string, error) {
+	versions, err := c.ExtClient.CatalogV1alpha1().MongoDBVersions().List(metav1.ListOptions{})
+	if err != nil {
+		return "", err
+	}
+	for _, v := range versions.Items {
+		if v.Spec.DB.Image == image {
+			return v.Name, nil
+		}
+	}
+	return image, nil
+}
+
+// releaseSeries returns the release series of MongoDBVersion name.
+func (c *Controller) releaseSeries(name string) (validator.ReleaseSeries, error) {
+	mongodbVersion, err := c.ExtClient.CatalogV1alpha1().MongoDBVersions().Get(name, metav1.GetOptions{})
+	if err != nil {
+		return validator.ReleaseSeries{}, err
+	}
+	return validator.ParseReleaseSeries(mongodbVersion.Spec.Version)
+}
+
+// isUpgradeRunning reports whether a version upgrade of mongodb is running.
+func isUpgradeRunning(mongodb *api.MongoDB) bool {
+	return mongodb.Status.Upgrade != nil && mongodb.Status.Upgrade.Phase == api.MongoDBUpgradePhaseRunning
+}
+
+// isUpgradePending reports whether the running upgrade has not reached workload name yet.
+// Such a workload keeps running its current image.
+func isUpgradePending(mongodb *api.MongoDB, name string) bool {
+	if !isUpgradeRunning(mongodb) {
+		return false
+	}
+	for _, component := range mongodb.Status.Upgrade.Components {
+		if component.Name == name {
+			return component.State == api.MongoDBUpgradeStatePending
+		}
+	}
+	return false
+}
+
+// keepContainerImages sets the image of every container of spec to its image in old, so that patching
+// a workload that is not upgraded yet does not roll its pods out with a new image.
+func keepContainerImages(spec *core.PodSpec, old *core.PodSpec) {
+	keep := func(containers, oldContainers []core.Container) {
+		for i := range containers {
+			for _, o := range oldContainers {
+				if o.Name == containers[i].Name {
+					containers[i].Image = o.Image
+				}
+			}
+		}
+	}
+	keep(spec.InitContainers, old.InitContainers)
+	keep(spec.Containers, old.Containers)
+}
+
+// containerImage returns the image of mongodb container.
+func containerImage(containers []core.Container) string {
+	for _, c := range containers {
+		if c.Name == api.ResourceSingularMongoDB {
+			return c.Image
+		}
+	}
+	return ""
+}
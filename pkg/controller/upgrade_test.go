@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"testing"
+
+	core "k8s.io/api/core/v1"
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+)
+
+func TestIsUpgradePending(t *testing.T) {
+	mongodb := shardedMongoDB()
+	if isUpgradePending(mongodb, mongodb.ShardNodeName(0)) {
+		t.Error("no component should be pending without an upgrade")
+	}
+
+	mongodb.Status.Upgrade = &api.MongoDBUpgradeStatus{
+		FromVersion: "3.6",
+		ToVersion:   "4.0",
+		Phase:       api.MongoDBUpgradePhaseRunning,
+		Components: []api.MongoDBComponentUpgradeStatus{
+			{Name: mongodb.ConfigSvrNodeName(), Type: api.MongoDBComponentConfigServer, State: api.MongoDBUpgradeStateUpgraded},
+			{Name: mongodb.ShardNodeName(0), Type: api.MongoDBComponentShard, State: api.MongoDBUpgradeStateUpgrading},
+			{Name: mongodb.ShardNodeName(1), Type: api.MongoDBComponentShard, State: api.MongoDBUpgradeStatePending},
+			{Name: mongodb.MongosNodeName(), Type: api.MongoDBComponentMongos, State: api.MongoDBUpgradeStatePending},
+		},
+	}
+	pending := map[string]bool{
+		mongodb.ConfigSvrNodeName(): false,
+		mongodb.ShardNodeName(0):    false,
+		mongodb.ShardNodeName(1):    true,
+		mongodb.MongosNodeName():    true,
+	}
+	for name, want := range pending {
+		if got := isUpgradePending(mongodb, name); got != want {
+			t.Errorf("expected %v to be pending %v, got %v", name, want, got)
+		}
+	}
+
+	mongodb.Status.Upgrade.Phase = api.MongoDBUpgradePhaseSucceeded
+	if isUpgradePending(mongodb, mongodb.MongosNodeName()) {
+		t.Error("no component should be pending once the upgrade succeeded")
+	}
+}
+
+func TestKeepContainerImages(t *testing.T) {
+	old := &core.PodSpec{
+		InitContainers: []core.Container{{Name: "copy-config", Image: "kubedb/mongo-tools:3.6"}},
+		Containers:     []core.Container{{Name: api.ResourceSingularMongoDB, Image: "kubedb/mongo:3.6"}},
+	}
+	spec := &core.PodSpec{
+		InitContainers: []core.Container{{Name: "copy-config", Image: "kubedb/mongo-tools:4.0"}},
+		Containers: []core.Container{
+			{Name: api.ResourceSingularMongoDB, Image: "kubedb/mongo:4.0"},
+			{Name: "exporter", Image: "kubedb/mongodb_exporter:v0.20.4"},
+		},
+	}
+	keepContainerImages(spec, old)
+
+	if image := containerImage(spec.Containers); image != "kubedb/mongo:3.6" {
+		t.Errorf("expected the old database image, got %v", image)
+	}
+	if image := spec.InitContainers[0].Image; image != "kubedb/mongo-tools:3.6" {
+		t.Errorf("expected the old init container image, got %v", image)
+	}
+	if image := spec.Containers[1].Image; image != "kubedb/mongodb_exporter:v0.20.4" {
+		t.Errorf("expected a new container to keep its image, got %v", image)
+	}
+}
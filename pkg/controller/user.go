@@ -0,0 +1,273 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/appscode/go/encoding/json/types"
+	"github.com/appscode/go/log"
+	"github.com/pkg/errors"
+	core "k8s.io/api/core/v1"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ktypes "k8s.io/apimachinery/pkg/types"
+	clientsetscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/reference"
+	core_util "kmodules.xyz/client-go/core/v1"
+	meta_util "kmodules.xyz/client-go/meta"
+	"kubedb.dev/apimachinery/apis"
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+	"kubedb.dev/apimachinery/client/clientset/versioned/typed/kubedb/v1alpha1/util"
+	"kubedb.dev/apimachinery/pkg/eventer"
+)
+
+func (c *Controller) runMongoDBUser(key string) error {
+	log.Debugln("started processing, key:", key)
+	obj, exists, err := c.mgUserInformer.GetIndexer().GetByKey(key)
+	if err != nil {
+		log.Errorf("Fetching object with key %s from store failed with %v", key, err)
+		return err
+	}
+
+	if !exists {
+		log.Debugf("MongoDBUser %s does not exist anymore", key)
+		return nil
+	}
+
+	user := obj.(*api.MongoDBUser).DeepCopy()
+	if user.DeletionTimestamp != nil {
+		if core_util.HasFinalizer(user.ObjectMeta, api.GenericKey) {
+			if err := c.dropMongoDBUser(user); err != nil {
+				log.Errorln(err)
+				return err
+			}
+			_, _, err = util.PatchMongoDBUser(c.ExtClient.KubedbV1alpha1(), user, func(in *api.MongoDBUser) *api.MongoDBUser {
+				in.ObjectMeta = core_util.RemoveFinalizer(in.ObjectMeta, api.GenericKey)
+				return in
+			})
+			return err
+		}
+		return nil
+	}
+
+	user, _, err = util.PatchMongoDBUser(c.ExtClient.KubedbV1alpha1(), user, func(in *api.MongoDBUser) *api.MongoDBUser {
+		in.ObjectMeta = core_util.AddFinalizer(in.ObjectMeta, api.GenericKey)
+		return in
+	})
+	if err != nil {
+		return err
+	}
+	if err := c.syncMongoDBUser(user); err != nil {
+		log.Errorln(err)
+		return err
+	}
+	return nil
+}
+
+// syncMongoDBUser creates the user in its MongoDB, or updates the password and roles of the existing user.
+// The user is left Pending until the MongoDB is running. Its MongoDB requeues it then.
+func (c *Controller) syncMongoDBUser(user *api.MongoDBUser) error {
+	mongodb, err := c.accountDatabase(user.Namespace, user.Spec.DatabaseName)
+	if err != nil {
+		if _, ok := err.(*accountPendingError); ok {
+			return c.updateMongoDBUserStatus(user, api.MongoDBUserPhasePending, err.Error())
+		}
+		return err
+	}
+
+	if err := c.ensureUserPasswordSecret(user); err != nil {
+		return err
+	}
+	password, err := c.userPassword(user)
+	if err != nil {
+		return c.failMongoDBUser(user, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), membershipTimeout)
+	defer cancel()
+	client, err := c.connectToDatabase(ctx, mongodb)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	db, name := user.DatabaseOrDefault(), user.UsernameOrDefault()
+	exists, err := client.UserExists(ctx, db, name)
+	if err != nil {
+		return err
+	}
+	roles := dbRoleRefs(user.Spec.Roles, db)
+	if exists {
+		err = client.UpdateUser(ctx, db, name, password, roles)
+	} else {
+		err = client.CreateUser(ctx, db, name, password, roles)
+	}
+	if err != nil {
+		return c.failMongoDBUser(user, err)
+	}
+
+	if !exists || !generationObserved(user, user.Status.ObservedGeneration) {
+		c.recorder.Eventf(
+			user,
+			core.EventTypeNormal,
+			eventer.EventReasonSuccessful,
+			"Successfully synced user %v@%v in MongoDB %v",
+			name, db, mongodb.Name,
+		)
+	}
+	return c.updateMongoDBUserStatus(user, api.MongoDBUserPhaseSynced, "")
+}
+
+// dropMongoDBUser revokes the user by dropping it from its MongoDB.
+// Nothing is left to revoke if the MongoDB is gone.
+func (c *Controller) dropMongoDBUser(user *api.MongoDBUser) error {
+	mongodb, err := c.mgLister.MongoDBs(user.Namespace).Get(user.Spec.DatabaseName)
+	if kerr.IsNotFound(err) || (err == nil && mongodb.DeletionTimestamp != nil) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), membershipTimeout)
+	defer cancel()
+	client, err := c.connectToDatabase(ctx, mongodb)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	db, name := user.DatabaseOrDefault(), user.UsernameOrDefault()
+	if err := client.DropUser(ctx, db, name); err != nil {
+		c.recorder.Eventf(
+			user,
+			core.EventTypeWarning,
+			eventer.EventReasonFailedToDelete,
+			"Failed to drop user %v@%v. Reason: %v",
+			name, db, err,
+		)
+		return err
+	}
+	c.recorder.Eventf(
+		user,
+		core.EventTypeNormal,
+		eventer.EventReasonSuccessful,
+		"Successfully dropped user %v@%v from MongoDB %v",
+		name, db, mongodb.Name,
+	)
+	return nil
+}
+
+// ensureUserPasswordSecret creates a secret with a generated password for the user,
+// if spec.passwordSecret is not set, and sets spec.passwordSecret to it.
+// The secret is owned by the MongoDBUser, so it is deleted with the user.
+func (c *Controller) ensureUserPasswordSecret(user *api.MongoDBUser) error {
+	if user.Spec.PasswordSecret != nil {
+		return nil
+	}
+
+	name := user.PasswordSecretName()
+	secret, err := c.Client.CoreV1().Secrets(user.Namespace).Get(name, metav1.GetOptions{})
+	if err == nil {
+		if !ownedBy(secret.ObjectMeta, user.UID) {
+			return fmt.Errorf(`intended secret "%v/%v" already exists`, user.Namespace, name)
+		}
+	} else if kerr.IsNotFound(err) {
+		ref, err := reference.GetReference(clientsetscheme.Scheme, user)
+		if err != nil {
+			return err
+		}
+		secret = &core.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: user.Namespace,
+				Labels: map[string]string{
+					api.LabelDatabaseKind: api.ResourceKindMongoDB,
+					api.LabelDatabaseName: user.Spec.DatabaseName,
+				},
+			},
+			Type: core.SecretTypeOpaque,
+			StringData: map[string]string{
+				KeyMongoDBUser:     user.UsernameOrDefault(),
+				KeyMongoDBPassword: generatePassword(),
+			},
+		}
+		core_util.EnsureOwnerReference(&secret.ObjectMeta, ref)
+		if _, err := c.Client.CoreV1().Secrets(user.Namespace).Create(secret); err != nil {
+			return err
+		}
+	} else {
+		return err
+	}
+
+	mu, _, err := util.PatchMongoDBUser(c.ExtClient.KubedbV1alpha1(), user, func(in *api.MongoDBUser) *api.MongoDBUser {
+		in.Spec.PasswordSecret = &core.SecretVolumeSource{
+			SecretName: name,
+		}
+		return in
+	})
+	if err != nil {
+		return err
+	}
+	user.Spec.PasswordSecret = mu.Spec.PasswordSecret
+	return nil
+}
+
+// userPassword returns the password of the user in spec.passwordSecret.
+func (c *Controller) userPassword(user *api.MongoDBUser) (string, error) {
+	name := user.Spec.PasswordSecret.SecretName
+	secret, err := c.Client.CoreV1().Secrets(user.Namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get password secret %v/%v", user.Namespace, name)
+	}
+	password := string(secret.Data[KeyMongoDBPassword])
+	if password == "" {
+		return "", errors.Errorf("password secret %v/%v has no key %v", user.Namespace, name, KeyMongoDBPassword)
+	}
+	return password, nil
+}
+
+func (c *Controller) failMongoDBUser(user *api.MongoDBUser, reason error) error {
+	c.recorder.Eventf(
+		user,
+		core.EventTypeWarning,
+		eventer.EventReasonFailedToUpdate,
+		"Failed to sync user %v@%v. Reason: %v",
+		user.UsernameOrDefault(), user.DatabaseOrDefault(), reason,
+	)
+	if err := c.updateMongoDBUserStatus(user, api.MongoDBUserPhaseFailed, reason.Error()); err != nil {
+		return err
+	}
+	return reason
+}
+
+// updateMongoDBUserStatus sets the phase of the user. The generation is observed only once the user
+// is synced or has failed, so that a Pending user is processed again on any change.
+func (c *Controller) updateMongoDBUserStatus(user *api.MongoDBUser, phase api.MongoDBUserPhase, reason string) error {
+	mu, err := util.UpdateMongoDBUserStatus(c.ExtClient.KubedbV1alpha1(), user, func(in *api.MongoDBUserStatus) *api.MongoDBUserStatus {
+		in.Phase = phase
+		in.Reason = reason
+		if phase != api.MongoDBUserPhasePending {
+			in.ObservedGeneration = types.NewIntHash(user.Generation, meta_util.GenerationHash(user))
+		}
+		return in
+	}, apis.EnableStatusSubresource)
+	if err != nil {
+		return err
+	}
+	user.Status = mu.Status
+	return nil
+}
+
+// generationObserved reports whether observed matches the current generation of obj.
+func generationObserved(obj metav1.Object, observed *types.IntHash) bool {
+	return observed != nil && observed.Equal(types.NewIntHash(obj.GetGeneration(), meta_util.GenerationHash(obj)))
+}
+
+func ownedBy(meta metav1.ObjectMeta, uid ktypes.UID) bool {
+	for _, ref := range meta.OwnerReferences {
+		if ref.UID == uid {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,343 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/appscode/go/log"
+	"github.com/pkg/errors"
+	core "k8s.io/api/core/v1"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	meta_util "kmodules.xyz/client-go/meta"
+	"kubedb.dev/apimachinery/apis"
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+	"kubedb.dev/apimachinery/client/clientset/versioned/typed/kubedb/v1alpha1/util"
+	"kubedb.dev/apimachinery/pkg/eventer"
+)
+
+const (
+	// snapshotVerifyInterval is how often the verifications of the snapshots are started and checked.
+	snapshotVerifyInterval = time.Minute
+
+	defaultVerificationTimeout    = time.Hour
+	defaultDocumentCountTolerance = 10
+	verificationQueryTimeout      = 5 * time.Minute
+)
+
+// runSnapshotVerifier verifies the snapshots of every MongoDB that opted in periodically, until stopCh is closed.
+func (c *Controller) runSnapshotVerifier(stopCh <-chan struct{}) {
+	wait.Until(c.verifySnapshots, snapshotVerifyInterval, stopCh)
+}
+
+func (c *Controller) verifySnapshots() {
+	mongodbs, err := c.mgLister.List(labels.Everything())
+	if err != nil {
+		log.Errorf("failed to list MongoDB. Reason: %v", err)
+		return
+	}
+	for _, mongodb := range mongodbs {
+		if mongodb.DeletionTimestamp != nil || mongodb.Spec.SnapshotVerification == nil {
+			continue
+		}
+		if err := c.verifyLatestSnapshot(mongodb.DeepCopy()); err != nil {
+			log.Errorf("failed to verify snapshots of MongoDB %v/%v. Reason: %v", mongodb.Namespace, mongodb.Name, err)
+		}
+	}
+}
+
+// verifyLatestSnapshot verifies one snapshot of mongodb at a time. It checks the verification in progress, if any,
+// or starts verifying the latest succeeded snapshot, unless it is verified already. Older snapshots that were never
+// verified are skipped.
+func (c *Controller) verifyLatestSnapshot(mongodb *api.MongoDB) error {
+	snapshots, err := c.ExtClient.KubedbV1alpha1().Snapshots(mongodb.Namespace).List(metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(map[string]string{
+			api.LabelDatabaseKind: api.ResourceKindMongoDB,
+			api.LabelDatabaseName: mongodb.Name,
+		}).String(),
+	})
+	if err != nil {
+		return err
+	}
+
+	var latest *api.Snapshot
+	for i := range snapshots.Items {
+		snapshot := &snapshots.Items[i]
+		if snapshot.DeletionTimestamp != nil {
+			continue
+		}
+		if v := snapshot.Status.Verification; v != nil && v.Phase == api.SnapshotVerificationPhaseRunning {
+			return c.checkVerification(mongodb, snapshot)
+		}
+		if snapshot.Status.Phase == api.SnapshotPhaseSucceeded &&
+			(latest == nil || snapshotTime(*latest).Before(snapshotTime(*snapshot))) {
+			latest = snapshot
+		}
+	}
+	if latest == nil || latest.Status.Verification != nil {
+		return nil
+	}
+	return c.startVerification(mongodb, latest)
+}
+
+// startVerification restores snapshot into an ephemeral copy of mongodb.
+func (c *Controller) startVerification(mongodb *api.MongoDB, snapshot *api.Snapshot) error {
+	verifier := verificationMongoDB(mongodb, snapshot)
+	if _, err := c.ExtClient.KubedbV1alpha1().MongoDBs(verifier.Namespace).Create(verifier); err != nil && !kerr.IsAlreadyExists(err) {
+		c.recorder.Eventf(
+			snapshot,
+			core.EventTypeWarning,
+			eventer.EventReasonFailedToCreate,
+			"Failed to create MongoDB %v to verify Snapshot. Reason: %v",
+			verifier.Name,
+			err,
+		)
+		return err
+	}
+
+	_, err := util.UpdateSnapshotStatus(c.ExtClient.KubedbV1alpha1(), snapshot, func(in *api.SnapshotStatus) *api.SnapshotStatus {
+		now := metav1.Now()
+		in.Verification = &api.SnapshotVerification{
+			Phase:     api.SnapshotVerificationPhaseRunning,
+			StartTime: &now,
+			Database:  verifier.Name,
+		}
+		return in
+	}, apis.EnableStatusSubresource)
+	if err != nil {
+		return err
+	}
+	c.recorder.Eventf(
+		snapshot,
+		core.EventTypeNormal,
+		eventer.EventReasonStarting,
+		"Verifying Snapshot by restoring it into ephemeral MongoDB %v",
+		verifier.Name,
+	)
+	return nil
+}
+
+// checkVerification completes the verification of snapshot once its ephemeral MongoDB is restored, failed to
+// restore or timed out. A restored snapshot is verified by comparing the collections and the document counts of
+// the ephemeral MongoDB with mongodb.
+func (c *Controller) checkVerification(mongodb *api.MongoDB, snapshot *api.Snapshot) error {
+	verification := snapshot.Status.Verification
+	timeout := defaultVerificationTimeout
+	if mongodb.Spec.SnapshotVerification.Timeout != nil {
+		timeout = mongodb.Spec.SnapshotVerification.Timeout.Duration
+	}
+	timedOut := verification.StartTime == nil || time.Since(verification.StartTime.Time) > timeout
+
+	verifier, err := c.mgLister.MongoDBs(snapshot.Namespace).Get(verification.Database)
+	if kerr.IsNotFound(err) {
+		return c.completeVerification(mongodb, snapshot, errors.Errorf("MongoDB %v is deleted", verification.Database), 0, 0)
+	} else if err != nil {
+		return err
+	}
+
+	_, initialized := verifier.Annotations[api.AnnotationInitialized]
+	switch {
+	case verifier.Status.Phase == api.DatabasePhaseFailed:
+		reason := verifier.Status.Reason
+		if reason == "" {
+			reason = "failed to restore the snapshot"
+		}
+		return c.completeVerification(mongodb, snapshot, errors.New(reason), 0, 0)
+	case verifier.Status.Phase != api.DatabasePhaseRunning || !initialized:
+		if timedOut {
+			return c.completeVerification(mongodb, snapshot, errors.Errorf("snapshot is not restored within %v", timeout), 0, 0)
+		}
+		return nil
+	}
+
+	sourceCounts, restoredCounts, err := c.verificationCounts(mongodb, verifier)
+	if err != nil {
+		if timedOut {
+			return c.completeVerification(mongodb, snapshot, err, 0, 0)
+		}
+		// the ephemeral MongoDB may not accept connections yet, try again in next round.
+		return err
+	}
+	tolerance := int32(defaultDocumentCountTolerance)
+	if mongodb.Spec.SnapshotVerification.DocumentCountTolerance != nil {
+		tolerance = *mongodb.Spec.SnapshotVerification.DocumentCountTolerance
+	}
+	// a selective snapshot has the selected collections only
+	for ns := range sourceCounts {
+		if !selectedNamespace(snapshot.Spec.Selection, ns) {
+			delete(sourceCounts, ns)
+		}
+	}
+	collections, documents, err := compareDocumentCounts(sourceCounts, restoredCounts, tolerance)
+	return c.completeVerification(mongodb, snapshot, err, collections, documents)
+}
+
+// verificationCounts returns the document counts of the collections of mongodb and of its ephemeral copy verifier.
+func (c *Controller) verificationCounts(mongodb, verifier *api.MongoDB) (map[string]int64, map[string]int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), verificationQueryTimeout)
+	defer cancel()
+
+	count := func(db *api.MongoDB) (map[string]int64, error) {
+		client, err := c.connectToDatabase(ctx, db)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to connect to MongoDB %v", db.Name)
+		}
+		defer client.Close()
+		return client.DocumentCounts(ctx)
+	}
+	sourceCounts, err := count(mongodb)
+	if err != nil {
+		return nil, nil, err
+	}
+	restoredCounts, err := count(verifier)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sourceCounts, restoredCounts, nil
+}
+
+// completeVerification records the outcome of the verification of snapshot, failed if verifyErr is not nil,
+// and deletes its ephemeral MongoDB.
+func (c *Controller) completeVerification(mongodb *api.MongoDB, snapshot *api.Snapshot, verifyErr error, collections int32, documents int64) error {
+	_, err := util.UpdateSnapshotStatus(c.ExtClient.KubedbV1alpha1(), snapshot, func(in *api.SnapshotStatus) *api.SnapshotStatus {
+		now := metav1.Now()
+		in.Verification.CompletionTime = &now
+		in.Verification.Collections = collections
+		in.Verification.Documents = documents
+		if verifyErr != nil {
+			in.Verification.Phase = api.SnapshotVerificationPhaseFailed
+			in.Verification.Reason = verifyErr.Error()
+		} else {
+			in.Verification.Phase = api.SnapshotVerificationPhaseSucceeded
+			in.Verification.Reason = ""
+		}
+		return in
+	}, apis.EnableStatusSubresource)
+	if err != nil {
+		return err
+	}
+
+	if verifyErr != nil {
+		c.recorder.Eventf(
+			snapshot,
+			core.EventTypeWarning,
+			eventer.EventReasonSnapshotFailed,
+			"Failed to verify Snapshot. Reason: %v",
+			verifyErr,
+		)
+		c.recorder.Eventf(
+			mongodb,
+			core.EventTypeWarning,
+			eventer.EventReasonSnapshotFailed,
+			"Failed to verify Snapshot %v. Reason: %v",
+			snapshot.Name,
+			verifyErr,
+		)
+	} else {
+		c.recorder.Eventf(
+			snapshot,
+			core.EventTypeNormal,
+			eventer.EventReasonSuccessful,
+			"Successfully verified Snapshot: restored %v collections and %v documents",
+			collections,
+			documents,
+		)
+	}
+
+	name := snapshot.Status.Verification.Database
+	err = c.ExtClient.KubedbV1alpha1().MongoDBs(snapshot.Namespace).Delete(name, meta_util.DeleteInBackground())
+	if err != nil && !kerr.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to delete MongoDB %v", name)
+	}
+	return nil
+}
+
+// verificationMongoDB returns the ephemeral MongoDB that snapshot of mongodb is restored into. It has the topology,
+// the version and the configuration of mongodb, and shares its database secret, that the snapshot may restore the
+// root user of. It is owned by snapshot and wiped out when deleted.
+func verificationMongoDB(mongodb *api.MongoDB, snapshot *api.Snapshot) *api.MongoDB {
+	verifier := &api.MongoDB{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      snapshot.Name + "-verify",
+			Namespace: snapshot.Namespace,
+			Labels: map[string]string{
+				api.MongoDBVerifiedSnapshotLabel: snapshot.Name,
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: api.SchemeGroupVersion.String(),
+					Kind:       api.ResourceKindSnapshot,
+					Name:       snapshot.Name,
+					UID:        snapshot.UID,
+				},
+			},
+		},
+		Spec: api.MongoDBSpec{
+			Version:        mongodb.Spec.Version,
+			Replicas:       mongodb.Spec.Replicas,
+			ReplicaSet:     mongodb.Spec.ReplicaSet.DeepCopy(),
+			ShardTopology:  mongodb.Spec.ShardTopology.DeepCopy(),
+			StorageType:    api.StorageTypeEphemeral,
+			DatabaseSecret: mongodb.Spec.DatabaseSecret.DeepCopy(),
+			ConfigSource:   mongodb.Spec.ConfigSource.DeepCopy(),
+			Configuration:  mongodb.Spec.Configuration.DeepCopy(),
+			PodTemplate:    mongodb.Spec.PodTemplate.DeepCopy(),
+			Init: &api.InitSpec{
+				SnapshotSource: &api.SnapshotSourceSpec{
+					Namespace: snapshot.Namespace,
+					Name:      snapshot.Name,
+				},
+			},
+			UpdateStrategy:    mongodb.Spec.UpdateStrategy,
+			TerminationPolicy: api.TerminationPolicyWipeOut,
+		},
+	}
+	if topology := verifier.Spec.ShardTopology; topology != nil {
+		topology.Shard.Storage = nil
+		topology.ConfigServer.Storage = nil
+	}
+	return verifier
+}
+
+// compareDocumentCounts compares the document counts of the collections of the source database with the restored
+// ones, by namespace. Every collection of the source must be restored, and its document count may differ by
+// tolerance percent of the source, as the source keeps changing after the snapshot. It returns the number of
+// collections and of documents restored.
+func compareDocumentCounts(source, restored map[string]int64, tolerance int32) (int32, int64, error) {
+	var documents int64
+	for _, n := range restored {
+		documents += n
+	}
+
+	namespaces := make([]string, 0, len(source))
+	for ns := range source {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+
+	var mismatches []string
+	for _, ns := range namespaces {
+		want := source[ns]
+		got, found := restored[ns]
+		if !found {
+			mismatches = append(mismatches, fmt.Sprintf("collection %v is missing", ns))
+			continue
+		}
+		diff := got - want
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff*100 > want*int64(tolerance) {
+			mismatches = append(mismatches, fmt.Sprintf("collection %v has %v documents, %v in the database", ns, got, want))
+		}
+	}
+	if len(mismatches) > 0 {
+		return int32(len(restored)), documents, errors.New(strings.Join(mismatches, "; "))
+	}
+	return int32(len(restored)), documents, nil
+}
@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"testing"
+)
+
+func TestCompareDocumentCounts(t *testing.T) {
+	source := map[string]int64{"shop.orders": 1000, "shop.users": 20, "shop.carts": 0}
+
+	cases := []struct {
+		name     string
+		restored map[string]int64
+		valid    bool
+	}{
+		{
+			name:     "identical",
+			restored: map[string]int64{"shop.orders": 1000, "shop.users": 20, "shop.carts": 0},
+			valid:    true,
+		},
+		{
+			name:     "within tolerance, and dropped since",
+			restored: map[string]int64{"shop.orders": 910, "shop.users": 22, "shop.carts": 0, "shop.archive": 5},
+			valid:    true,
+		},
+		{
+			name:     "beyond tolerance",
+			restored: map[string]int64{"shop.orders": 0, "shop.users": 20, "shop.carts": 0},
+		},
+		{
+			name:     "missing collection",
+			restored: map[string]int64{"shop.orders": 1000, "shop.carts": 0},
+		},
+	}
+	for _, c := range cases {
+		collections, documents, err := compareDocumentCounts(source, c.restored, 10)
+		if (err == nil) != c.valid {
+			t.Errorf("%s: expected valid %v, got error %v", c.name, c.valid, err)
+		}
+		var want int64
+		for _, n := range c.restored {
+			want += n
+		}
+		if collections != int32(len(c.restored)) || documents != want {
+			t.Errorf("%s: expected %v collections and %v documents, got %v and %v", c.name, len(c.restored), want, collections, documents)
+		}
+	}
+}
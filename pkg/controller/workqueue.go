@@ -1,19 +1,93 @@
 package controller
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/appscode/go/log"
+	core "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
 	core_util "kmodules.xyz/client-go/core/v1"
 	"kmodules.xyz/client-go/tools/queue"
 	"kubedb.dev/apimachinery/apis"
 	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
 	"kubedb.dev/apimachinery/client/clientset/versioned/typed/kubedb/v1alpha1/util"
+	"kubedb.dev/mongodb/pkg/metrics"
+)
+
+const (
+	// readinessBaseDelay and readinessMaxDelay bound the backoff used to requeue a MongoDB
+	// whose StatefulSets or Deployment are not ready yet.
+	readinessBaseDelay = 5 * time.Second
+	readinessMaxDelay  = 2 * time.Minute
 )
 
 func (c *Controller) initWatcher() {
 	c.mgInformer = c.KubedbInformerFactory.Kubedb().V1alpha1().MongoDBs().Informer()
-	c.mgQueue = queue.New("MongoDB", c.MaxNumRequeues, c.NumThreads, c.runMongoDB)
+	c.mgQueue = c.newQueue("MongoDB", c.mgInformer.GetIndexer(), c.runMongoDB)
 	c.mgLister = c.KubedbInformerFactory.Kubedb().V1alpha1().MongoDBs().Lister()
+	c.mgReadinessBackoff = workqueue.NewItemExponentialFailureRateLimiter(readinessBaseDelay, readinessMaxDelay)
 	c.mgInformer.AddEventHandler(queue.NewObservableUpdateHandler(c.mgQueue.GetQueue(), apis.EnableStatusSubresource))
+	// sync the users and roles that are waiting for their MongoDB, once it is running
+	c.mgInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(old, nu interface{}) {
+			if old.(*api.MongoDB).Status.Phase != api.DatabasePhaseRunning && nu.(*api.MongoDB).Status.Phase == api.DatabasePhaseRunning {
+				c.enqueueAccounts(nu.(*api.MongoDB))
+			}
+		},
+	})
+
+	c.mgUserInformer = c.KubedbInformerFactory.Kubedb().V1alpha1().MongoDBUsers().Informer()
+	c.mgUserQueue = c.newQueue("MongoDBUser", c.mgUserInformer.GetIndexer(), c.runMongoDBUser)
+	c.mgUserLister = c.KubedbInformerFactory.Kubedb().V1alpha1().MongoDBUsers().Lister()
+	c.mgUserInformer.AddEventHandler(queue.NewObservableUpdateHandler(c.mgUserQueue.GetQueue(), apis.EnableStatusSubresource))
+
+	c.mgRoleInformer = c.KubedbInformerFactory.Kubedb().V1alpha1().MongoDBRoles().Informer()
+	c.mgRoleQueue = c.newQueue("MongoDBRole", c.mgRoleInformer.GetIndexer(), c.runMongoDBRole)
+	c.mgRoleLister = c.KubedbInformerFactory.Kubedb().V1alpha1().MongoDBRoles().Lister()
+	c.mgRoleInformer.AddEventHandler(queue.NewObservableUpdateHandler(c.mgRoleQueue.GetQueue(), apis.EnableStatusSubresource))
+
+	c.mgRestoreInformer = c.KubedbInformerFactory.Kubedb().V1alpha1().MongoDBRestores().Informer()
+	c.mgRestoreQueue = c.newQueue("MongoDBRestore", c.mgRestoreInformer.GetIndexer(), c.runMongoDBRestore)
+	c.mgRestoreLister = c.KubedbInformerFactory.Kubedb().V1alpha1().MongoDBRestores().Lister()
+	c.mgRestoreInformer.AddEventHandler(queue.NewObservableUpdateHandler(c.mgRestoreQueue.GetQueue(), apis.EnableStatusSubresource))
+
+	// count the snapshots of the MongoDBs, scheduled or not, as they complete
+	c.SnapInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			observeSnapshot(nil, obj.(*api.Snapshot))
+		},
+		UpdateFunc: func(old, nu interface{}) {
+			observeSnapshot(old.(*api.Snapshot), nu.(*api.Snapshot))
+		},
+	})
+}
+
+// newQueue returns the work queue name, that reconciles the objects of store with reconcile and reports
+// the metrics of the reconciles.
+func (c *Controller) newQueue(name string, store cache.KeyGetter, reconcile func(key string) error) *queue.Worker {
+	var w *queue.Worker
+	numRequeues := func(key interface{}) int {
+		return w.GetQueue().NumRequeues(key)
+	}
+	w = queue.New(name, c.MaxNumRequeues, c.NumThreads, metrics.InstrumentReconcile(name, c.MaxNumRequeues, numRequeues, store, reconcile))
+	return w
+}
+
+// observeSnapshot counts nu, once its job has completed, and records when it completed. old is nil for
+// the snapshots listed when the operator starts, that are not counted.
+func observeSnapshot(old, nu *api.Snapshot) {
+	if nu.Status.Phase != api.SnapshotPhaseSucceeded && nu.Status.Phase != api.SnapshotPhaseFailed {
+		return
+	}
+	scheduled := nu.Labels[api.LabelSnapshotScheduled] == "true"
+	if old != nil && old.Status.Phase != nu.Status.Phase {
+		metrics.ObserveSnapshot(nu.Namespace, nu.Spec.DatabaseName, scheduled, string(nu.Status.Phase))
+	}
+	if nu.Status.CompletionTime != nil {
+		metrics.ObserveSnapshotCompletion(nu.Namespace, nu.Spec.DatabaseName, scheduled, string(nu.Status.Phase), nu.Status.CompletionTime.Time)
+	}
 }
 
 func (c *Controller) runMongoDB(key string) error {
@@ -26,11 +100,13 @@ func (c *Controller) runMongoDB(key string) error {
 
 	if !exists {
 		log.Debugf("MongoDB %s does not exist anymore", key)
+		c.mgReadinessBackoff.Forget(key)
 	} else {
 		// Note that you also have to check the uid if you have a local controlled resource, which
 		// is dependent on the actual instance, to detect that a MongoDB was recreated with the same name
 		mongodb := obj.(*api.MongoDB).DeepCopy()
 		if mongodb.DeletionTimestamp != nil {
+			c.mgReadinessBackoff.Forget(key)
 			if core_util.HasFinalizer(mongodb.ObjectMeta, api.GenericKey) {
 				if err := c.terminate(mongodb); err != nil {
 					log.Errorln(err)
@@ -59,3 +135,132 @@ func (c *Controller) runMongoDB(key string) error {
 	}
 	return nil
 }
+
+// workloadNotReadyError is returned when the pods of a StatefulSet or Deployment
+// managed for a MongoDB are not running and ready yet.
+type workloadNotReadyError struct {
+	kind     string
+	name     string
+	ready    int32
+	replicas int32
+}
+
+func (e *workloadNotReadyError) Error() string {
+	return fmt.Sprintf("waiting for %v %v to be ready (%v/%v pods ready)", e.kind, e.name, e.ready, e.replicas)
+}
+
+// replicaSetNotReadyError is returned when the members of a replicaset managed for a MongoDB
+// have not reached PRIMARY or SECONDARY state yet, or the replicaset has no primary.
+type replicaSetNotReadyError struct {
+	replicaSet string
+	reason     string
+}
+
+func (e *replicaSetNotReadyError) Error() string {
+	return fmt.Sprintf("waiting for replicaset %v to be ready: %v", e.replicaSet, e.reason)
+}
+
+// shardDrainingError is returned while a shard removed from spec.shardTopology is being drained.
+type shardDrainingError struct {
+	shard     string
+	chunks    int64
+	databases int64
+}
+
+func (e *shardDrainingError) Error() string {
+	return fmt.Sprintf("waiting for shard %v to drain (%v chunks and %v databases remaining)", e.shard, e.chunks, e.databases)
+}
+
+// snapshotRunningError is returned while removing shards waits for a consistent snapshot, that stops the balancer.
+type snapshotRunningError struct {
+	snapshot string
+}
+
+func (e *snapshotRunningError) Error() string {
+	return fmt.Sprintf("waiting for snapshot %v to complete before draining shards", e.snapshot)
+}
+
+// upgradeInProgressError is returned while a component is being upgraded to a new version.
+type upgradeInProgressError struct {
+	component string
+}
+
+func (e *upgradeInProgressError) Error() string {
+	return fmt.Sprintf("waiting for %v to be upgraded", e.component)
+}
+
+// restartInProgressError is returned while the pods of a StatefulSet are being restarted in order.
+type restartInProgressError struct {
+	statefulSet string
+}
+
+func (e *restartInProgressError) Error() string {
+	return fmt.Sprintf("waiting for the pods of %v to restart", e.statefulSet)
+}
+
+// certificateRotationError is returned while the pods are restarted to load the rotated certificates of secret.
+type certificateRotationError struct {
+	secret string
+	phase  string
+}
+
+func (e *certificateRotationError) Error() string {
+	return fmt.Sprintf("waiting for the pods to load the certificates of secret %v, phase %v", e.secret, e.phase)
+}
+
+// credentialRotationError is returned while the pods are restarted to load the rotated credentials of secret.
+type credentialRotationError struct {
+	secret string
+	phase  string
+}
+
+func (e *credentialRotationError) Error() string {
+	return fmt.Sprintf("waiting for the pods to load the credentials of secret %v, phase %v", e.secret, e.phase)
+}
+
+// certificatePendingError is returned while a certificate request is waiting to be signed by an external issuer.
+type certificatePendingError struct {
+	request string
+	issuer  string
+}
+
+func (e *certificatePendingError) Error() string {
+	return fmt.Sprintf("waiting for certificate request %v to be signed by %v", e.request, e.issuer)
+}
+
+func isWorkloadNotReady(err error) bool {
+	switch err.(type) {
+	case *workloadNotReadyError, *replicaSetNotReadyError, *shardDrainingError, *snapshotRunningError, *upgradeInProgressError, *restartInProgressError, *certificateRotationError,
+		*certificatePendingError, *credentialRotationError:
+		return true
+	}
+	return false
+}
+
+// requeueMongoDB records the reason of waiting in MongoDB status and puts the MongoDB back
+// in the queue after an exponential backoff. This keeps queue workers free while pods are
+// coming up, instead of blocking them until the workloads are ready.
+func (c *Controller) requeueMongoDB(mongodb *api.MongoDB, reason error) error {
+	key, err := cache.MetaNamespaceKeyFunc(mongodb)
+	if err != nil {
+		return err
+	}
+
+	provisioned := newCondition(api.MongoDBConditionProvisioned, core.ConditionFalse, ConditionReasonProvisioning, reason.Error())
+	if mongodb.Status.Reason != reason.Error() || conditionsChanged(mongodb.Status.Conditions, provisioned) {
+		mg, err := util.UpdateMongoDBStatus(c.ExtClient.KubedbV1alpha1(), mongodb, func(in *api.MongoDBStatus) *api.MongoDBStatus {
+			in.Reason = reason.Error()
+			in.Conditions = setCondition(in.Conditions, provisioned)
+			return in
+		}, apis.EnableStatusSubresource)
+		if err != nil {
+			return err
+		}
+		mongodb.Status = mg.Status
+	}
+
+	delay := c.mgReadinessBackoff.When(key)
+	log.Infof("MongoDB %v is not ready yet. Reason: %v. Requeueing after %v", key, reason, delay)
+	c.mgQueue.GetQueue().AddAfter(key, delay)
+	return nil
+}
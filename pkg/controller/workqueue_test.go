@@ -0,0 +1,84 @@
+package controller
+
+import (
+	"errors"
+	"testing"
+
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/workqueue"
+	"kmodules.xyz/client-go/tools/queue"
+	api "kubedb.dev/apimachinery/apis/kubedb/v1alpha1"
+	extFake "kubedb.dev/apimachinery/client/clientset/versioned/fake"
+	amc "kubedb.dev/apimachinery/pkg/controller"
+)
+
+func TestIsWorkloadNotReady(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"workload", &workloadNotReadyError{kind: "StatefulSet", name: "mg", ready: 1, replicas: 3}, true},
+		{"replicaset", &replicaSetNotReadyError{replicaSet: "rs0", reason: "no primary"}, true},
+		{"shard draining", &shardDrainingError{shard: "shard2", chunks: 4}, true},
+		{"snapshot running", &snapshotRunningError{snapshot: "mg-20200101"}, true},
+		{"upgrade", &upgradeInProgressError{component: "mg-shard0"}, true},
+		{"restart", &restartInProgressError{statefulSet: "mg"}, true},
+		{"certificate rotation", &certificateRotationError{secret: "mg-server-cert", phase: "Restarting"}, true},
+		{"certificate pending", &certificatePendingError{request: "mg-server", issuer: "ca"}, true},
+		{"credential rotation", &credentialRotationError{secret: "mg-auth", phase: "Restarting"}, true},
+		{"account pending", &accountPendingError{}, false},
+		{"other", errors.New("connection refused"), false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := isWorkloadNotReady(c.err); got != c.want {
+				t.Errorf("expected %v, got %v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestRequeueMongoDB(t *testing.T) {
+	reason := &workloadNotReadyError{kind: "StatefulSet", name: "mg", ready: 1, replicas: 3}
+	// status already records the reason, so requeueing only backs off
+	mongodb := &api.MongoDB{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "mg",
+			Namespace: "demo",
+		},
+		Status: api.MongoDBStatus{
+			Reason: reason.Error(),
+			Conditions: []api.MongoDBCondition{
+				newCondition(api.MongoDBConditionProvisioned, core.ConditionFalse, ConditionReasonProvisioning, reason.Error()),
+			},
+		},
+	}
+	extClient := extFake.NewSimpleClientset(mongodb)
+	c := &Controller{
+		Controller:         &amc.Controller{ExtClient: extClient},
+		mgQueue:            queue.New("MongoDB", 5, 1, nil),
+		mgReadinessBackoff: workqueue.NewItemExponentialFailureRateLimiter(readinessBaseDelay, readinessMaxDelay),
+	}
+	defer c.mgQueue.GetQueue().ShutDown()
+
+	for i := 1; i <= 3; i++ {
+		if err := c.requeueMongoDB(mongodb, reason); err != nil {
+			t.Fatal(err)
+		}
+		if n := c.mgReadinessBackoff.NumRequeues("demo/mg"); n != i {
+			t.Errorf("expected %v requeues, got %v", i, n)
+		}
+	}
+	if n := len(extClient.Actions()); n != 0 {
+		t.Errorf("expected no status update for an unchanged reason, got %v actions", n)
+	}
+	if delay := c.mgReadinessBackoff.When("demo/mg"); delay != 8*readinessBaseDelay {
+		t.Errorf("expected the backoff to double on every requeue, got %v", delay)
+	}
+	c.mgReadinessBackoff.Forget("demo/mg")
+	if delay := c.mgReadinessBackoff.When("demo/mg"); delay != readinessBaseDelay {
+		t.Errorf("expected the backoff to restart once forgotten, got %v", delay)
+	}
+}
@@ -0,0 +1,140 @@
+// Package dbclient talks to the mongod and mongos servers managed by the operator.
+package dbclient
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/network/connection"
+)
+
+const (
+	adminDatabase  = "admin"
+	defaultTimeout = 10 * time.Second
+)
+
+// Config holds the information required to connect to MongoDB servers.
+type Config struct {
+	// Hosts is the list of host:port of the servers.
+	Hosts []string
+	// Username and Password authenticate against the admin database. Empty Username disables authentication.
+	Username string
+	Password string
+	// TLSConfig is used to connect with TLS. Nil means plain connection.
+	TLSConfig *tls.Config
+	// Direct connects to the first host only, without discovering the rest of the replicaset.
+	Direct bool
+	// Address, if set, is dialed in place of the host of a direct connection. The host still names the server,
+	// whose certificate is verified against it. It reaches a server by IP, whose certificate names a Service.
+	Address string
+	// ReplicaSet is the name of the replicaset to discover, when Direct is false.
+	ReplicaSet string
+	// Timeout bounds connecting and selecting a server. Defaults to 10s.
+	Timeout time.Duration
+}
+
+// Client is a connection to MongoDB servers.
+type Client struct {
+	*mongo.Client
+}
+
+// New connects to the servers described by cfg.
+func New(ctx context.Context, cfg Config) (*Client, error) {
+	if len(cfg.Hosts) == 0 {
+		return nil, errors.New("no host to connect")
+	}
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+
+	opts := options.Client().
+		SetHosts(cfg.Hosts).
+		SetDirect(cfg.Direct).
+		SetConnectTimeout(timeout).
+		SetServerSelectionTimeout(timeout).
+		SetSocketTimeout(timeout)
+	if cfg.Direct {
+		opts.SetHosts(cfg.Hosts[:1])
+	} else if cfg.ReplicaSet != "" {
+		opts.SetReplicaSet(cfg.ReplicaSet)
+	}
+	if cfg.Username != "" {
+		opts.SetAuth(options.Credential{
+			AuthSource: adminDatabase,
+			Username:   cfg.Username,
+			Password:   cfg.Password,
+		})
+	}
+	if cfg.Address != "" {
+		if !cfg.Direct {
+			return nil, errors.New("address is only dialed by a direct connection")
+		}
+		opts.SetDialer(addressDialer(cfg.Address))
+	}
+	if cfg.TLSConfig != nil {
+		opts.SetTLSConfig(cfg.TLSConfig)
+	}
+
+	client, err := mongo.Connect(ctx, opts)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to connect to %v", cfg.Hosts)
+	}
+	return &Client{Client: client}, nil
+}
+
+// Close disconnects the client.
+func (c *Client) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	return c.Disconnect(ctx)
+}
+
+// RunAdminCommand runs cmd against the admin database and decodes the reply into result, if not nil.
+func (c *Client) RunAdminCommand(ctx context.Context, cmd interface{}, result interface{}) error {
+	return c.RunCommand(ctx, adminDatabase, cmd, result)
+}
+
+// RunCommand runs cmd against database db and decodes the reply into result, if not nil.
+func (c *Client) RunCommand(ctx context.Context, db string, cmd interface{}, result interface{}) error {
+	res := c.Database(db).RunCommand(ctx, cmd)
+	if err := res.Err(); err != nil {
+		return err
+	}
+	if result == nil {
+		return nil
+	}
+	return res.Decode(result)
+}
+
+func hasErrorCode(err error, code int32) bool {
+	if ce, ok := err.(mongo.CommandError); ok {
+		return ce.Code == code
+	}
+	return false
+}
+
+// isNetworkError reports whether err is a failure of the connection to the server, rather than its reply.
+// The driver labels the failures of a command sent, and returns connection errors as they are otherwise.
+func isNetworkError(err error) bool {
+	switch e := errors.Cause(err).(type) {
+	case mongo.CommandError:
+		return e.HasErrorLabel("NetworkError")
+	case connection.Error, *connection.Error, connection.NetworkError, *connection.NetworkError:
+		return true
+	}
+	return false
+}
+
+// addressDialer dials its address, whatever address the driver asks for.
+type addressDialer string
+
+func (a addressDialer) DialContext(ctx context.Context, network, _ string) (net.Conn, error) {
+	var d net.Dialer
+	return d.DialContext(ctx, network, string(a))
+}
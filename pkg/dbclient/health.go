@@ -0,0 +1,108 @@
+package dbclient
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Replicaset member states.
+// ref: https://docs.mongodb.com/manual/reference/replica-states/
+const (
+	MemberStatePrimary   = 1
+	MemberStateSecondary = 2
+)
+
+// IsMasterResult is the reply of isMaster command.
+// ref: https://docs.mongodb.com/manual/reference/command/isMaster/
+type IsMasterResult struct {
+	IsMaster  bool     `bson:"ismaster"`
+	Secondary bool     `bson:"secondary"`
+	SetName   string   `bson:"setName,omitempty"`
+	Primary   string   `bson:"primary,omitempty"`
+	Me        string   `bson:"me,omitempty"`
+	Hosts     []string `bson:"hosts,omitempty"`
+	Msg       string   `bson:"msg,omitempty"`
+}
+
+// ReplSetStatus is the reply of replSetGetStatus command.
+// ref: https://docs.mongodb.com/manual/reference/command/replSetGetStatus/
+type ReplSetStatus struct {
+	Set     string          `bson:"set"`
+	MyState int             `bson:"myState"`
+	Members []ReplSetMember `bson:"members"`
+}
+
+// ReplSetMember is the status of a member in replSetGetStatus reply.
+type ReplSetMember struct {
+	ID         int       `bson:"_id"`
+	Name       string    `bson:"name"`
+	Health     float64   `bson:"health"`
+	State      int       `bson:"state"`
+	StateStr   string    `bson:"stateStr"`
+	OptimeDate time.Time `bson:"optimeDate"`
+	Self       bool      `bson:"self,omitempty"`
+}
+
+// IsMaster runs isMaster command.
+func (c *Client) IsMaster(ctx context.Context) (*IsMasterResult, error) {
+	result := &IsMasterResult{}
+	if err := c.RunAdminCommand(ctx, bson.D{{Key: "isMaster", Value: 1}}, result); err != nil {
+		return nil, errors.Wrap(err, "failed to run isMaster")
+	}
+	return result, nil
+}
+
+// ReplSetGetStatus runs replSetGetStatus command.
+func (c *Client) ReplSetGetStatus(ctx context.Context) (*ReplSetStatus, error) {
+	result := &ReplSetStatus{}
+	if err := c.RunAdminCommand(ctx, bson.D{{Key: "replSetGetStatus", Value: 1}}, result); err != nil {
+		return nil, errors.Wrap(err, "failed to run replSetGetStatus")
+	}
+	return result, nil
+}
+
+// Primary returns the primary member, or nil if the replicaset has no primary.
+func (s *ReplSetStatus) Primary() *ReplSetMember {
+	for i := range s.Members {
+		if s.Members[i].State == MemberStatePrimary {
+			return &s.Members[i]
+		}
+	}
+	return nil
+}
+
+// ReplicationLag returns how far member is behind the primary. It returns false if there is no primary.
+func (s *ReplSetStatus) ReplicationLag(member ReplSetMember) (time.Duration, bool) {
+	primary := s.Primary()
+	if primary == nil {
+		return 0, false
+	}
+	lag := primary.OptimeDate.Sub(member.OptimeDate)
+	if lag < 0 {
+		lag = 0
+	}
+	return lag, true
+}
+
+// Healthy reports whether member is reachable from the member that replied.
+func (m ReplSetMember) Healthy() bool {
+	return m.Health == 1
+}
+
+// PodName returns the name of the pod from the host of member, ie, the first label of
+// pod-name.gvr-svc-name.namespace.svc.cluster.local:27017.
+func (m ReplSetMember) PodName() string {
+	return PodName(m.Name)
+}
+
+// PodName returns the name of the pod of a host:port served by a StatefulSet pod.
+func PodName(host string) string {
+	if i := strings.LastIndex(host, ":"); i >= 0 {
+		host = host[:i]
+	}
+	return strings.SplitN(host, ".", 2)[0]
+}
@@ -0,0 +1,244 @@
+package dbclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	opReply = 1
+	opQuery = 2004
+)
+
+// fakeServer speaks enough of the legacy wire protocol (OP_QUERY/OP_REPLY) to stand in for mongod.
+// It reports maxWireVersion 5, so that the driver never switches to OP_MSG.
+type fakeServer struct {
+	ln net.Listener
+	// commands holds the replies by command name. A nil reply closes the connection instead.
+	commands map[string]bson.M
+
+	mu       sync.Mutex
+	received []bson.D
+}
+
+func newFakeServer(t *testing.T, commands map[string]bson.M) *fakeServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeServer{ln: ln, commands: commands}
+	go s.serve()
+	return s
+}
+
+func (s *fakeServer) Addr() string {
+	return s.ln.Addr().String()
+}
+
+func (s *fakeServer) Close() {
+	s.ln.Close()
+}
+
+func (s *fakeServer) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeServer) handle(conn net.Conn) {
+	defer conn.Close()
+	for {
+		header := make([]byte, 16)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		length := int32(binary.LittleEndian.Uint32(header[0:]))
+		requestID := int32(binary.LittleEndian.Uint32(header[4:]))
+		opCode := int32(binary.LittleEndian.Uint32(header[12:]))
+		body := make([]byte, length-16)
+		if _, err := io.ReadFull(conn, body); err != nil {
+			return
+		}
+		if opCode != opQuery {
+			return
+		}
+
+		// flags, fullCollectionName, numberToSkip, numberToReturn, query
+		body = body[4:]
+		body = body[bytes.IndexByte(body, 0)+1:]
+		body = body[8:]
+		var query bson.D
+		if err := bson.Unmarshal(body, &query); err != nil {
+			return
+		}
+		if len(query) > 0 && query[0].Key == "$query" {
+			query = query[0].Value.(bson.D)
+		}
+
+		s.mu.Lock()
+		s.received = append(s.received, query)
+		s.mu.Unlock()
+
+		doc := s.reply(query[0].Key)
+		if doc == nil {
+			return
+		}
+		reply, err := bson.Marshal(doc)
+		if err != nil {
+			return
+		}
+		msg := make([]byte, 36, 36+len(reply))
+		binary.LittleEndian.PutUint32(msg[0:], uint32(36+len(reply)))
+		binary.LittleEndian.PutUint32(msg[8:], uint32(requestID))
+		binary.LittleEndian.PutUint32(msg[12:], opReply)
+		binary.LittleEndian.PutUint32(msg[32:], 1) // numberReturned
+		if _, err := conn.Write(append(msg, reply...)); err != nil {
+			return
+		}
+	}
+}
+
+// lastCommand returns the last command received with name cmd.
+func (s *fakeServer) lastCommand(cmd string) bson.D {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.received) - 1; i >= 0; i-- {
+		if strings.EqualFold(s.received[i][0].Key, cmd) {
+			return s.received[i]
+		}
+	}
+	return nil
+}
+
+func (s *fakeServer) reply(cmd string) bson.M {
+	if reply, ok := s.commands[strings.ToLower(cmd)]; ok {
+		return reply
+	}
+	if strings.ToLower(cmd) == "ismaster" {
+		return bson.M{"ok": 1, "ismaster": true, "maxWireVersion": 5, "minWireVersion": 0}
+	}
+	return bson.M{"ok": 0, "errmsg": "no such command: " + cmd, "code": 59}
+}
+
+func connect(t *testing.T, s *fakeServer) *Client {
+	client, err := New(context.Background(), Config{
+		Hosts:   []string{s.Addr()},
+		Direct:  true,
+		Timeout: 5 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestReplSetGetStatus(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	s := newFakeServer(t, map[string]bson.M{
+		"replsetgetstatus": {
+			"ok":      1,
+			"set":     "rs0",
+			"myState": 2,
+			"members": bson.A{
+				bson.M{"_id": 0, "name": "mg-0.mg-gvr.demo.svc.cluster.local:27017", "health": 1.0, "state": 1, "stateStr": "PRIMARY", "optimeDate": now},
+				bson.M{"_id": 1, "name": "mg-1.mg-gvr.demo.svc.cluster.local:27017", "health": 1.0, "state": 2, "stateStr": "SECONDARY", "optimeDate": now.Add(-3 * time.Second), "self": true},
+				bson.M{"_id": 2, "name": "mg-2.mg-gvr.demo.svc.cluster.local:27017", "health": 0.0, "state": 8, "stateStr": "(not reachable/healthy)"},
+			},
+		},
+	})
+	defer s.Close()
+	client := connect(t, s)
+	defer client.Close()
+
+	status, err := client.ReplSetGetStatus(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Set != "rs0" || len(status.Members) != 3 {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+
+	primary := status.Primary()
+	if primary == nil || primary.PodName() != "mg-0" {
+		t.Fatalf("expected primary mg-0, got %+v", primary)
+	}
+	if lag, ok := status.ReplicationLag(status.Members[1]); !ok || lag != 3*time.Second {
+		t.Errorf("expected lag 3s, got %v (%v)", lag, ok)
+	}
+	if !status.Members[1].Healthy() || status.Members[2].Healthy() {
+		t.Errorf("unexpected health of members: %+v", status.Members)
+	}
+}
+
+func TestReplSetGetStatusWithoutPrimary(t *testing.T) {
+	s := newFakeServer(t, map[string]bson.M{
+		"replsetgetstatus": {
+			"ok":      1,
+			"set":     "rs0",
+			"myState": 2,
+			"members": bson.A{
+				bson.M{"_id": 0, "name": "mg-0.mg-gvr.demo.svc.cluster.local:27017", "health": 1.0, "state": 2, "stateStr": "SECONDARY", "self": true},
+			},
+		},
+	})
+	defer s.Close()
+	client := connect(t, s)
+	defer client.Close()
+
+	status, err := client.ReplSetGetStatus(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Primary() != nil {
+		t.Errorf("expected no primary, got %+v", status.Primary())
+	}
+	if _, ok := status.ReplicationLag(status.Members[0]); ok {
+		t.Errorf("expected unknown lag without primary")
+	}
+}
+
+func TestIsMaster(t *testing.T) {
+	s := newFakeServer(t, nil)
+	defer s.Close()
+	client := connect(t, s)
+	defer client.Close()
+
+	result, err := client.IsMaster(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.IsMaster {
+		t.Errorf("expected ismaster, got %+v", result)
+	}
+
+	// standalone servers don't know about replicasets
+	if _, err := client.ReplSetGetStatus(context.Background()); err == nil {
+		t.Errorf("expected error from replSetGetStatus")
+	}
+}
+
+func TestPodName(t *testing.T) {
+	cases := map[string]string{
+		"mg-0.mg-gvr.demo.svc.cluster.local:27017": "mg-0",
+		"mg-shard0-1.mg-shard0-gvr.demo.svc:27017": "mg-shard0-1",
+		"mg-0": "mg-0",
+	}
+	for host, want := range cases {
+		if got := PodName(host); got != want {
+			t.Errorf("PodName(%q) = %q, want %q", host, got, want)
+		}
+	}
+}
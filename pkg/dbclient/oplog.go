@@ -0,0 +1,26 @@
+package dbclient
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ApplyOps applies the oplog entries ops, in order, with the applyOps command.
+// ref: https://docs.mongodb.com/manual/reference/command/applyOps/
+func (c *Client) ApplyOps(ctx context.Context, ops []bson.Raw) error {
+	if len(ops) == 0 {
+		return nil
+	}
+	var res struct {
+		Applied int `bson:"applied"`
+	}
+	if err := c.RunAdminCommand(ctx, bson.D{{Key: "applyOps", Value: ops}}, &res); err != nil {
+		return errors.Wrap(err, "failed to apply oplog entries")
+	}
+	if res.Applied != len(ops) {
+		return errors.Errorf("applied %d of %d oplog entries", res.Applied, len(ops))
+	}
+	return nil
+}
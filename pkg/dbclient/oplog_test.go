@@ -0,0 +1,37 @@
+package dbclient
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestApplyOps(t *testing.T) {
+	s := newFakeServer(t, map[string]bson.M{
+		"applyops": {"ok": 1, "applied": 1},
+	})
+	defer s.Close()
+	client := connect(t, s)
+	defer client.Close()
+
+	op, err := bson.Marshal(bson.D{{Key: "op", Value: "i"}, {Key: "ns", Value: "shop.orders"}, {Key: "o", Value: bson.D{{Key: "_id", Value: 1}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.ApplyOps(context.Background(), []bson.Raw{op}); err != nil {
+		t.Fatal(err)
+	}
+	cmd := s.lastCommand("applyOps")
+	ops, ok := cmd[0].Value.(bson.A)
+	if !ok || len(ops) != 1 {
+		t.Fatalf("unexpected applyOps command: %v", cmd)
+	}
+	if entry, ok := ops[0].(bson.D); !ok || entry[1].Value != "shop.orders" {
+		t.Errorf("unexpected oplog entry: %v", ops[0])
+	}
+
+	if err := client.ApplyOps(context.Background(), []bson.Raw{op, op}); err == nil {
+		t.Error("expected an error when fewer entries are applied")
+	}
+}
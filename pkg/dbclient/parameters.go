@@ -0,0 +1,33 @@
+package dbclient
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// GetParameters runs getParameter for names, and returns their current values by name.
+// ref: https://docs.mongodb.com/manual/reference/command/getParameter/
+func (c *Client) GetParameters(ctx context.Context, names ...string) (bson.M, error) {
+	cmd := bson.D{{Key: "getParameter", Value: 1}}
+	for _, name := range names {
+		cmd = append(cmd, bson.E{Key: name, Value: 1})
+	}
+	result := bson.M{}
+	if err := c.RunAdminCommand(ctx, cmd, &result); err != nil {
+		return nil, errors.Wrapf(err, "failed to get parameters %v", names)
+	}
+	delete(result, "ok")
+	return result, nil
+}
+
+// SetParameter sets the server parameter name to value at runtime.
+// ref: https://docs.mongodb.com/manual/reference/command/setParameter/
+func (c *Client) SetParameter(ctx context.Context, name string, value interface{}) error {
+	cmd := bson.D{{Key: "setParameter", Value: 1}, {Key: name, Value: value}}
+	if err := c.RunAdminCommand(ctx, cmd, nil); err != nil {
+		return errors.Wrapf(err, "failed to set parameter %v", name)
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package dbclient
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestParameters(t *testing.T) {
+	s := newFakeServer(t, map[string]bson.M{
+		"getparameter": {"ok": 1, "cursorTimeoutMillis": int64(600000), "logLevel": int32(0)},
+		"setparameter": {"ok": 1, "was": int32(0)},
+	})
+	defer s.Close()
+	client := connect(t, s)
+	defer client.Close()
+
+	params, err := client.GetParameters(context.Background(), "cursorTimeoutMillis", "logLevel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(params) != 2 || params["cursorTimeoutMillis"] != int64(600000) {
+		t.Errorf("unexpected parameters: %v", params)
+	}
+	if cmd := s.lastCommand("getParameter"); len(cmd) != 3 || cmd[1].Key != "cursorTimeoutMillis" || cmd[2].Key != "logLevel" {
+		t.Errorf("unexpected getParameter command: %v", cmd)
+	}
+
+	if err := client.SetParameter(context.Background(), "logLevel", 1); err != nil {
+		t.Fatal(err)
+	}
+	if cmd := s.lastCommand("setParameter"); len(cmd) != 2 || cmd[1].Key != "logLevel" {
+		t.Errorf("unexpected setParameter command: %v", cmd)
+	}
+}
@@ -0,0 +1,74 @@
+package dbclient
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ReplSetConfig is the replicaset configuration, as returned by replSetGetConfig.
+// Fields not used by the operator are kept in Extra, so that reconfig does not drop them.
+// ref: https://docs.mongodb.com/manual/reference/replica-configuration/
+type ReplSetConfig struct {
+	ID      string                `bson:"_id"`
+	Version int                   `bson:"version"`
+	Members []ReplSetConfigMember `bson:"members"`
+	Extra   bson.M                `bson:",inline"`
+}
+
+// ReplSetConfigMember is a member of replicaset configuration.
+type ReplSetConfigMember struct {
+	ID       int     `bson:"_id"`
+	Host     string  `bson:"host"`
+	Priority float64 `bson:"priority"`
+	Votes    int     `bson:"votes"`
+	Extra    bson.M  `bson:",inline"`
+}
+
+// ReplSetGetConfig runs replSetGetConfig command.
+func (c *Client) ReplSetGetConfig(ctx context.Context) (*ReplSetConfig, error) {
+	result := struct {
+		Config ReplSetConfig `bson:"config"`
+	}{}
+	if err := c.RunAdminCommand(ctx, bson.D{{Key: "replSetGetConfig", Value: 1}}, &result); err != nil {
+		return nil, errors.Wrap(err, "failed to run replSetGetConfig")
+	}
+	return &result.Config, nil
+}
+
+// ReplSetReconfig applies config to the replicaset, after bumping its version. It must run on the primary.
+func (c *Client) ReplSetReconfig(ctx context.Context, config *ReplSetConfig) error {
+	config.Version++
+	if err := c.RunAdminCommand(ctx, bson.D{{Key: "replSetReconfig", Value: config}}, nil); err != nil {
+		return errors.Wrap(err, "failed to run replSetReconfig")
+	}
+	return nil
+}
+
+// StepDown asks the primary to step down and not to seek re-election for seconds.
+// Primaries before 4.2 close all connections on step down, so network errors are ignored.
+func (c *Client) StepDown(ctx context.Context, seconds int) error {
+	err := c.RunAdminCommand(ctx, bson.D{
+		{Key: "replSetStepDown", Value: seconds},
+		{Key: "secondaryCatchUpPeriodSecs", Value: 10},
+	}, nil)
+	if err != nil && !isNetworkError(err) {
+		return errors.Wrap(err, "failed to run replSetStepDown")
+	}
+	return nil
+}
+
+// RemoveMembers drops the members for which remove returns true, and returns the dropped ones.
+func (cfg *ReplSetConfig) RemoveMembers(remove func(ReplSetConfigMember) bool) []ReplSetConfigMember {
+	var kept, removed []ReplSetConfigMember
+	for _, m := range cfg.Members {
+		if remove(m) {
+			removed = append(removed, m)
+		} else {
+			kept = append(kept, m)
+		}
+	}
+	cfg.Members = kept
+	return removed
+}
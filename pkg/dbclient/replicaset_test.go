@@ -0,0 +1,124 @@
+package dbclient
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/x/network/connection"
+)
+
+func TestReplSetReconfig(t *testing.T) {
+	s := newFakeServer(t, map[string]bson.M{
+		"replsetgetconfig": {
+			"ok": 1,
+			"config": bson.M{
+				"_id":             "rs0",
+				"version":         3,
+				"protocolVersion": int64(1),
+				"members": bson.A{
+					bson.M{"_id": 0, "host": "mg-0.mg-gvr.demo.svc.cluster.local:27017", "priority": 1.0, "votes": 1, "hidden": false},
+					bson.M{"_id": 1, "host": "mg-1.mg-gvr.demo.svc.cluster.local:27017", "priority": 1.0, "votes": 1, "hidden": false},
+					bson.M{"_id": 2, "host": "mg-2.mg-gvr.demo.svc.cluster.local:27017", "priority": 1.0, "votes": 1, "hidden": false},
+				},
+				"settings": bson.M{"chainingAllowed": true},
+			},
+		},
+		"replsetreconfig": {"ok": 1},
+	})
+	defer s.Close()
+	client := connect(t, s)
+	defer client.Close()
+
+	config, err := client.ReplSetGetConfig(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	removed := config.RemoveMembers(func(m ReplSetConfigMember) bool {
+		return PodName(m.Host) == "mg-2"
+	})
+	if len(removed) != 1 || removed[0].ID != 2 {
+		t.Fatalf("unexpected removed members: %+v", removed)
+	}
+	if err := client.ReplSetReconfig(context.Background(), config); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := s.lastCommand("replSetReconfig")
+	if cmd == nil {
+		t.Fatal("replSetReconfig is not received")
+	}
+	var sent struct {
+		Config struct {
+			Version  int      `bson:"version"`
+			Members  []bson.M `bson:"members"`
+			Settings bson.M   `bson:"settings"`
+		} `bson:"replSetReconfig"`
+	}
+	data, err := bson.Marshal(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bson.Unmarshal(data, &sent); err != nil {
+		t.Fatal(err)
+	}
+	if sent.Config.Version != 4 {
+		t.Errorf("expected version 4, got %v", sent.Config.Version)
+	}
+	if len(sent.Config.Members) != 2 {
+		t.Errorf("expected 2 members, got %v", sent.Config.Members)
+	}
+	if _, ok := sent.Config.Members[0]["hidden"]; !ok {
+		t.Errorf("expected unknown member fields to be preserved, got %v", sent.Config.Members[0])
+	}
+	if sent.Config.Settings["chainingAllowed"] != true {
+		t.Errorf("expected settings to be preserved, got %v", sent.Config.Settings)
+	}
+}
+
+func TestStepDown(t *testing.T) {
+	// a primary before 4.2 closes every connection as it steps down, instead of replying.
+	s := newFakeServer(t, map[string]bson.M{"replsetstepdown": nil})
+	defer s.Close()
+	client := connect(t, s)
+	defer client.Close()
+
+	if err := client.StepDown(context.Background(), 60); err != nil {
+		t.Errorf("expected the connection closed by the primary to be ignored, got %v", err)
+	}
+	if cmd := s.lastCommand("replSetStepDown"); cmd == nil {
+		t.Errorf("expected replSetStepDown to be sent")
+	}
+
+	s = newFakeServer(t, map[string]bson.M{
+		"replsetstepdown": {"ok": 0, "errmsg": "not primary so can't step down", "code": 10107},
+	})
+	defer s.Close()
+	client = connect(t, s)
+	defer client.Close()
+	if err := client.StepDown(context.Background(), 60); err == nil {
+		t.Errorf("expected the error of a server that can't step down")
+	}
+}
+
+func TestIsNetworkError(t *testing.T) {
+	cases := []struct {
+		err      error
+		expected bool
+	}{
+		{mongo.CommandError{Labels: []string{"NetworkError"}}, true},
+		{mongo.CommandError{Code: 10107}, false},
+		{connection.Error{ConnectionID: "mg-0"}, true},
+		{&connection.NetworkError{ConnectionID: "mg-0", Wrapped: io.EOF}, true},
+		{errors.Wrap(connection.NetworkError{ConnectionID: "mg-0", Wrapped: io.EOF}, "failed"), true},
+		{io.EOF, false},
+	}
+	for _, c := range cases {
+		if got := isNetworkError(c.err); got != c.expected {
+			t.Errorf("expected %v for %#v, got %v", c.expected, c.err, got)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package dbclient
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// States of a shard in removeShard reply.
+const (
+	RemoveShardStarted   = "started"
+	RemoveShardOngoing   = "ongoing"
+	RemoveShardCompleted = "completed"
+)
+
+// BalancerModeOff is the mode of the balancer, in balancerStatus reply, while it is stopped.
+const BalancerModeOff = "off"
+
+// Shard is a shard of the cluster, as returned by listShards.
+type Shard struct {
+	ID       string `bson:"_id"`
+	Host     string `bson:"host"`
+	State    int    `bson:"state,omitempty"`
+	Draining bool   `bson:"draining,omitempty"`
+}
+
+// RemoveShardResult is the reply of removeShard command.
+// ref: https://docs.mongodb.com/manual/reference/command/removeShard/
+type RemoveShardResult struct {
+	Msg       string `bson:"msg"`
+	State     string `bson:"state"`
+	Remaining struct {
+		Chunks int64 `bson:"chunks"`
+		DBs    int64 `bson:"dbs"`
+	} `bson:"remaining,omitempty"`
+	DBsToMove []string `bson:"dbsToMove,omitempty"`
+}
+
+// ListShards runs listShards command. It must run on a mongos.
+func (c *Client) ListShards(ctx context.Context) ([]Shard, error) {
+	result := struct {
+		Shards []Shard `bson:"shards"`
+	}{}
+	if err := c.RunAdminCommand(ctx, bson.D{{Key: "listShards", Value: 1}}, &result); err != nil {
+		return nil, errors.Wrap(err, "failed to run listShards")
+	}
+	return result.Shards, nil
+}
+
+// AddShard adds the replicaset at dsn, ie, <replset>/<host1>,<host2>, to the cluster. It must run on a mongos.
+func (c *Client) AddShard(ctx context.Context, dsn string) error {
+	if err := c.RunAdminCommand(ctx, bson.D{{Key: "addShard", Value: dsn}}, nil); err != nil {
+		return errors.Wrapf(err, "failed to add shard %v", dsn)
+	}
+	return nil
+}
+
+// RemoveShard starts draining shard, or reports the progress of draining if it is already started.
+// It must run on a mongos.
+func (c *Client) RemoveShard(ctx context.Context, shard string) (*RemoveShardResult, error) {
+	result := &RemoveShardResult{}
+	if err := c.RunAdminCommand(ctx, bson.D{{Key: "removeShard", Value: shard}}, result); err != nil {
+		return nil, errors.Wrapf(err, "failed to remove shard %v", shard)
+	}
+	return result, nil
+}
+
+// MovePrimary moves the primary shard of database to shard. It must run on a mongos.
+func (c *Client) MovePrimary(ctx context.Context, database, shard string) error {
+	if err := c.RunAdminCommand(ctx, bson.D{{Key: "movePrimary", Value: database}, {Key: "to", Value: shard}}, nil); err != nil {
+		return errors.Wrapf(err, "failed to move primary of database %v to shard %v", database, shard)
+	}
+	return nil
+}
+
+// BalancerMode returns the mode of the balancer, ie, full or off. It must run on a mongos.
+func (c *Client) BalancerMode(ctx context.Context) (string, error) {
+	result := struct {
+		Mode string `bson:"mode"`
+	}{}
+	if err := c.RunAdminCommand(ctx, bson.D{{Key: "balancerStatus", Value: 1}}, &result); err != nil {
+		return "", errors.Wrap(err, "failed to get balancer status")
+	}
+	return result.Mode, nil
+}
+
+// BalancerStart enables the balancer, which migrates the chunks of draining shards. It must run on a mongos.
+func (c *Client) BalancerStart(ctx context.Context) error {
+	if err := c.RunAdminCommand(ctx, bson.D{{Key: "balancerStart", Value: 1}}, nil); err != nil {
+		return errors.Wrap(err, "failed to start balancer")
+	}
+	return nil
+}
+
+// BalancerStop disables the balancer and waits for the running chunk migration, if any, to complete.
+// It must run on a mongos.
+func (c *Client) BalancerStop(ctx context.Context) error {
+	if err := c.RunAdminCommand(ctx, bson.D{{Key: "balancerStop", Value: 1}}, nil); err != nil {
+		return errors.Wrap(err, "failed to stop balancer")
+	}
+	return nil
+}
+
+// FlushRouterConfig clears the cached routing table of a mongos, so that it is read again from the config servers.
+func (c *Client) FlushRouterConfig(ctx context.Context) error {
+	if err := c.RunAdminCommand(ctx, bson.D{{Key: "flushRouterConfig", Value: 1}}, nil); err != nil {
+		return errors.Wrap(err, "failed to flush router config")
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package dbclient
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestRemoveShard(t *testing.T) {
+	s := newFakeServer(t, map[string]bson.M{
+		"listshards": {
+			"ok": 1,
+			"shards": bson.A{
+				bson.M{"_id": "shard0", "host": "shard0/mg-shard0-0.mg-shard0-gvr.demo.svc:27017", "state": 1},
+				bson.M{"_id": "shard1", "host": "shard1/mg-shard1-0.mg-shard1-gvr.demo.svc:27017", "state": 1, "draining": true},
+			},
+		},
+		"removeshard": {
+			"ok":        1,
+			"msg":       "draining ongoing",
+			"state":     "ongoing",
+			"remaining": bson.M{"chunks": int64(0), "dbs": int64(1)},
+			"note":      "you need to drop or movePrimary these databases",
+			"dbsToMove": bson.A{"demo"},
+		},
+	})
+	defer s.Close()
+	client := connect(t, s)
+	defer client.Close()
+
+	shards, err := client.ListShards(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shards) != 2 || shards[0].ID != "shard0" || !shards[1].Draining {
+		t.Fatalf("unexpected shards: %+v", shards)
+	}
+
+	result, err := client.RemoveShard(context.Background(), "shard1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.State != RemoveShardOngoing || result.Remaining.Chunks != 0 || result.Remaining.DBs != 1 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if len(result.DBsToMove) != 1 || result.DBsToMove[0] != "demo" {
+		t.Errorf("unexpected dbsToMove: %v", result.DBsToMove)
+	}
+	if cmd := s.lastCommand("removeShard"); cmd == nil || cmd[0].Value != "shard1" {
+		t.Errorf("unexpected removeShard command: %v", cmd)
+	}
+}
+
+func TestBalancer(t *testing.T) {
+	s := newFakeServer(t, map[string]bson.M{
+		"balancerstop":      {"ok": 1},
+		"balancerstart":     {"ok": 1},
+		"balancerstatus":    {"ok": 1, "mode": "off", "inBalancerRound": false, "numBalancerRounds": int64(12)},
+		"flushrouterconfig": {"ok": 1, "flushed": true},
+	})
+	defer s.Close()
+	client := connect(t, s)
+	defer client.Close()
+
+	if err := client.BalancerStop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.BalancerStart(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if mode, err := client.BalancerMode(context.Background()); err != nil || mode != BalancerModeOff {
+		t.Errorf("expected balancer mode %v, got %v, %v", BalancerModeOff, mode, err)
+	}
+	if err := client.FlushRouterConfig(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"balancerStop", "balancerStart", "balancerStatus", "flushRouterConfig"} {
+		if s.lastCommand(name) == nil {
+			t.Errorf("expected %s to run", name)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package dbclient
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// internalDatabases are the databases of the server itself, rather than of the users.
+var internalDatabases = map[string]bool{"admin": true, "config": true, "local": true}
+
+// DocumentCounts returns the number of documents of every collection of the user databases, by namespace.
+// The counts come from the collection metadata, as the count command without a query.
+func (c *Client) DocumentCounts(ctx context.Context) (map[string]int64, error) {
+	databases, err := c.ListDatabaseNames(ctx, bson.D{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list databases")
+	}
+	counts := map[string]int64{}
+	for _, db := range databases {
+		if internalDatabases[db] {
+			continue
+		}
+		collections, err := c.collectionNames(ctx, db)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list collections of database %v", db)
+		}
+		for _, collection := range collections {
+			if strings.HasPrefix(collection, "system.") {
+				continue
+			}
+			var res struct {
+				N int64 `bson:"n"`
+			}
+			if err := c.RunCommand(ctx, db, bson.D{{Key: "count", Value: collection}}, &res); err != nil {
+				return nil, errors.Wrapf(err, "failed to count documents of %v.%v", db, collection)
+			}
+			counts[db+"."+collection] = res.N
+		}
+	}
+	return counts, nil
+}
+
+// collectionNames returns the names of the collections of db, without its views.
+func (c *Client) collectionNames(ctx context.Context, db string) ([]string, error) {
+	cursor, err := c.Database(db).ListCollections(ctx, bson.D{{Key: "type", Value: "collection"}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var names []string
+	for cursor.Next(ctx) {
+		var collection struct {
+			Name string `bson:"name"`
+		}
+		if err := cursor.Decode(&collection); err != nil {
+			return nil, err
+		}
+		names = append(names, collection.Name)
+	}
+	return names, cursor.Err()
+}
@@ -0,0 +1,38 @@
+package dbclient
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestDocumentCounts(t *testing.T) {
+	s := newFakeServer(t, map[string]bson.M{
+		"listdatabases": {
+			"ok":        1,
+			"databases": bson.A{bson.M{"name": "admin"}, bson.M{"name": "shop"}, bson.M{"name": "local"}},
+		},
+		"listcollections": {
+			"ok": 1,
+			"cursor": bson.M{
+				"id":         int64(0),
+				"ns":         "shop.$cmd.listCollections",
+				"firstBatch": bson.A{bson.M{"name": "orders", "type": "collection"}, bson.M{"name": "system.profile", "type": "collection"}},
+			},
+		},
+		"count": {"ok": 1, "n": int64(42)},
+	})
+	defer s.Close()
+	client := connect(t, s)
+	defer client.Close()
+
+	counts, err := client.DocumentCounts(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := map[string]int64{"shop.orders": 42}; !reflect.DeepEqual(counts, expected) {
+		t.Errorf("expected %v, got %v", expected, counts)
+	}
+}
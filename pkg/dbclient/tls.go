@@ -0,0 +1,26 @@
+package dbclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+
+	"github.com/pkg/errors"
+)
+
+// ClientTLSConfig returns a TLS config that trusts caCert and presents the certificate and key in clientPem.
+// The server certificate must be issued for server authentication to the host that is dialed, as the driver
+// verifies it against the name of each host.
+func ClientTLSConfig(caCert, clientPem []byte) (*tls.Config, error) {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caCert) {
+		return nil, errors.New("failed to parse CA certificate")
+	}
+	clientCert, err := tls.X509KeyPair(clientPem, clientPem)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse client PEM")
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{clientCert},
+		RootCAs:      pool,
+	}, nil
+}
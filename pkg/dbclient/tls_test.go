@@ -0,0 +1,149 @@
+package dbclient
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"gomodules.xyz/cert"
+)
+
+type testCA struct {
+	key  *rsa.PrivateKey
+	cert *x509.Certificate
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := cert.NewPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	crt, err := cert.NewSelfSignedCACert(cert.Config{CommonName: "ca"}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{key: key, cert: crt}
+}
+
+// issue returns the PEM of a certificate, along with its key, for dnsNames and usages.
+func (ca *testCA) issue(t *testing.T, cn string, dnsNames []string, usages ...x509.ExtKeyUsage) []byte {
+	key, err := cert.NewPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	crt, err := cert.NewSignedCert(cert.Config{
+		CommonName: cn,
+		AltNames:   cert.AltNames{DNSNames: dnsNames},
+		Usages:     usages,
+	}, key, ca.cert, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return append(cert.EncodeCertPEM(crt), cert.EncodePrivateKeyPEM(key)...)
+}
+
+// handshake dials a server presenting serverPem, as the driver does for host.
+func handshake(t *testing.T, cfg *tls.Config, host string, serverPem []byte) error {
+	serverCert, err := tls.X509KeyPair(serverPem, serverPem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+
+	go func() {
+		server := tls.Server(serverConn, &tls.Config{Certificates: []tls.Certificate{serverCert}})
+		_ = server.Handshake()
+		server.Close()
+	}()
+
+	cfg = cfg.Clone()
+	cfg.ServerName = host
+	return tls.Client(clientConn, cfg).Handshake()
+}
+
+func TestClientTLSConfig(t *testing.T) {
+	ca := newTestCA(t)
+	cfg, err := ClientTLSConfig(cert.EncodeCertPEM(ca.cert), ca.issue(t, "root", nil, x509.ExtKeyUsageClientAuth))
+	if err != nil {
+		t.Fatal(err)
+	}
+	member := "mg-0.mg-gvr.demo.svc"
+
+	if err := handshake(t, cfg, member, ca.issue(t, "mg-0", []string{member}, x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth)); err != nil {
+		t.Errorf("expected the member certificate to be verified, got %v", err)
+	}
+	if err := handshake(t, cfg, member, ca.issue(t, "mg-1", []string{"mg-1.mg-gvr.demo.svc"}, x509.ExtKeyUsageServerAuth)); err == nil {
+		t.Error("expected the certificate of another member to be rejected")
+	}
+	if err := handshake(t, cfg, member, ca.issue(t, "mg-0", []string{member}, x509.ExtKeyUsageClientAuth)); err == nil {
+		t.Error("expected a certificate not issued for server authentication to be rejected")
+	}
+	if err := handshake(t, cfg, member, newTestCA(t).issue(t, "mg-0", []string{member}, x509.ExtKeyUsageServerAuth)); err == nil {
+		t.Error("expected a certificate of another CA to be rejected")
+	}
+}
+
+// newFakeTLSServer returns a fakeServer that presents serverPem.
+func newFakeTLSServer(t *testing.T, serverPem []byte) *fakeServer {
+	serverCert, err := tls.X509KeyPair(serverPem, serverPem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{serverCert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeServer{ln: ln, commands: map[string]bson.M{"ping": {"ok": 1}}}
+	go s.serve()
+	return s
+}
+
+func TestAddress(t *testing.T) {
+	ca := newTestCA(t)
+	cfg, err := ClientTLSConfig(cert.EncodeCertPEM(ca.cert), ca.issue(t, "root", nil, x509.ExtKeyUsageClientAuth))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the pod is dialed by IP, and its certificate is verified against the Service that names it
+	service := "mg.demo.svc"
+	ping := func(s *fakeServer) error {
+		client, err := New(context.Background(), Config{
+			Hosts:     []string{service + ":27017"},
+			Direct:    true,
+			Address:   s.Addr(),
+			TLSConfig: cfg,
+			Timeout:   2 * time.Second,
+		})
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+		return client.RunAdminCommand(context.Background(), bson.D{{Key: "ping", Value: 1}}, nil)
+	}
+
+	s := newFakeTLSServer(t, ca.issue(t, "mg-mongos", []string{service}, x509.ExtKeyUsageServerAuth))
+	defer s.Close()
+	if err := ping(s); err != nil {
+		t.Errorf("expected the server to be reached at %v as %v, got %v", s.Addr(), service, err)
+	}
+	if s.lastCommand("ping") == nil {
+		t.Error("expected ping to run on the server at the address")
+	}
+
+	other := newFakeTLSServer(t, ca.issue(t, "other", []string{"other.demo.svc"}, x509.ExtKeyUsageServerAuth))
+	defer other.Close()
+	if err := ping(other); err == nil {
+		t.Error("expected the certificate of another Service to be rejected")
+	}
+
+	if _, err := New(context.Background(), Config{Hosts: []string{service + ":27017"}, Address: s.Addr()}); err == nil {
+		t.Error("expected the address to be rejected without a direct connection")
+	}
+}
@@ -0,0 +1,18 @@
+package dbclient
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// SetFeatureCompatibilityVersion enables the features of release series version, eg, 3.6, that persist
+// data incompatible with the earlier series. It must run on the primary, or on a mongos in a sharded cluster.
+// ref: https://docs.mongodb.com/manual/reference/command/setFeatureCompatibilityVersion/
+func (c *Client) SetFeatureCompatibilityVersion(ctx context.Context, version string) error {
+	if err := c.RunAdminCommand(ctx, bson.D{{Key: "setFeatureCompatibilityVersion", Value: version}}, nil); err != nil {
+		return errors.Wrapf(err, "failed to set featureCompatibilityVersion to %v", version)
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package dbclient
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	codeUserNotFound int32 = 11
+	codeRoleNotFound int32 = 31
+)
+
+// RoleRef refers to role Role defined in database DB.
+type RoleRef struct {
+	Role string `bson:"role"`
+	DB   string `bson:"db"`
+}
+
+// Resource is a collection of a database, or the cluster if Cluster is set.
+// Empty DB or Collection matches every database or collection.
+// ref: https://docs.mongodb.com/manual/reference/resource-document/
+type Resource struct {
+	DB         string
+	Collection string
+	Cluster    bool
+}
+
+// Privilege grants Actions on Resource.
+type Privilege struct {
+	Resource Resource
+	Actions  []string
+}
+
+func (r Resource) document() bson.D {
+	if r.Cluster {
+		return bson.D{{Key: "cluster", Value: true}}
+	}
+	return bson.D{{Key: "db", Value: r.DB}, {Key: "collection", Value: r.Collection}}
+}
+
+func privilegeDocuments(privileges []Privilege) bson.A {
+	docs := bson.A{}
+	for _, p := range privileges {
+		actions := p.Actions
+		if actions == nil {
+			actions = []string{}
+		}
+		docs = append(docs, bson.D{{Key: "resource", Value: p.Resource.document()}, {Key: "actions", Value: actions}})
+	}
+	return docs
+}
+
+func roleDocuments(roles []RoleRef) []RoleRef {
+	if roles == nil {
+		return []RoleRef{}
+	}
+	return roles
+}
+
+// UserExists reports whether user name exists in database db.
+// ref: https://docs.mongodb.com/manual/reference/command/usersInfo/
+func (c *Client) UserExists(ctx context.Context, db, name string) (bool, error) {
+	var result struct {
+		Users []bson.Raw `bson:"users"`
+	}
+	cmd := bson.D{{Key: "usersInfo", Value: bson.D{{Key: "user", Value: name}, {Key: "db", Value: db}}}}
+	if err := c.RunCommand(ctx, db, cmd, &result); err != nil {
+		return false, errors.Wrapf(err, "failed to get user %v@%v", name, db)
+	}
+	return len(result.Users) > 0, nil
+}
+
+// CreateUser creates user name in database db with password and roles.
+// ref: https://docs.mongodb.com/manual/reference/command/createUser/
+func (c *Client) CreateUser(ctx context.Context, db, name, password string, roles []RoleRef) error {
+	cmd := bson.D{
+		{Key: "createUser", Value: name},
+		{Key: "pwd", Value: password},
+		{Key: "roles", Value: roleDocuments(roles)},
+	}
+	if err := c.RunCommand(ctx, db, cmd, nil); err != nil {
+		return errors.Wrapf(err, "failed to create user %v@%v", name, db)
+	}
+	return nil
+}
+
+// UpdateUser sets password and roles of user name in database db. The roles replace the ones granted before.
+// ref: https://docs.mongodb.com/manual/reference/command/updateUser/
+func (c *Client) UpdateUser(ctx context.Context, db, name, password string, roles []RoleRef) error {
+	cmd := bson.D{
+		{Key: "updateUser", Value: name},
+		{Key: "pwd", Value: password},
+		{Key: "roles", Value: roleDocuments(roles)},
+	}
+	if err := c.RunCommand(ctx, db, cmd, nil); err != nil {
+		return errors.Wrapf(err, "failed to update user %v@%v", name, db)
+	}
+	return nil
+}
+
+// ChangeUserPassword sets password of user name in database db, keeping its roles.
+// ref: https://docs.mongodb.com/manual/reference/command/updateUser/
+func (c *Client) ChangeUserPassword(ctx context.Context, db, name, password string) error {
+	cmd := bson.D{
+		{Key: "updateUser", Value: name},
+		{Key: "pwd", Value: password},
+	}
+	if err := c.RunCommand(ctx, db, cmd, nil); err != nil {
+		return errors.Wrapf(err, "failed to change password of user %v@%v", name, db)
+	}
+	return nil
+}
+
+// IsUserNotFound reports whether err is caused by a user that does not exist.
+func IsUserNotFound(err error) bool {
+	return hasErrorCode(errors.Cause(err), codeUserNotFound)
+}
+
+// DropUser removes user name from database db. A missing user is not an error.
+// ref: https://docs.mongodb.com/manual/reference/command/dropUser/
+func (c *Client) DropUser(ctx context.Context, db, name string) error {
+	cmd := bson.D{{Key: "dropUser", Value: name}}
+	if err := c.RunCommand(ctx, db, cmd, nil); err != nil && !hasErrorCode(err, codeUserNotFound) {
+		return errors.Wrapf(err, "failed to drop user %v@%v", name, db)
+	}
+	return nil
+}
+
+// RoleExists reports whether role name exists in database db.
+// ref: https://docs.mongodb.com/manual/reference/command/rolesInfo/
+func (c *Client) RoleExists(ctx context.Context, db, name string) (bool, error) {
+	var result struct {
+		Roles []bson.Raw `bson:"roles"`
+	}
+	cmd := bson.D{{Key: "rolesInfo", Value: bson.D{{Key: "role", Value: name}, {Key: "db", Value: db}}}}
+	if err := c.RunCommand(ctx, db, cmd, &result); err != nil {
+		return false, errors.Wrapf(err, "failed to get role %v@%v", name, db)
+	}
+	return len(result.Roles) > 0, nil
+}
+
+// CreateRole creates role name in database db with privileges and inherited roles.
+// ref: https://docs.mongodb.com/manual/reference/command/createRole/
+func (c *Client) CreateRole(ctx context.Context, db, name string, privileges []Privilege, roles []RoleRef) error {
+	cmd := bson.D{
+		{Key: "createRole", Value: name},
+		{Key: "privileges", Value: privilegeDocuments(privileges)},
+		{Key: "roles", Value: roleDocuments(roles)},
+	}
+	if err := c.RunCommand(ctx, db, cmd, nil); err != nil {
+		return errors.Wrapf(err, "failed to create role %v@%v", name, db)
+	}
+	return nil
+}
+
+// UpdateRole replaces the privileges and inherited roles of role name in database db.
+// ref: https://docs.mongodb.com/manual/reference/command/updateRole/
+func (c *Client) UpdateRole(ctx context.Context, db, name string, privileges []Privilege, roles []RoleRef) error {
+	cmd := bson.D{
+		{Key: "updateRole", Value: name},
+		{Key: "privileges", Value: privilegeDocuments(privileges)},
+		{Key: "roles", Value: roleDocuments(roles)},
+	}
+	if err := c.RunCommand(ctx, db, cmd, nil); err != nil {
+		return errors.Wrapf(err, "failed to update role %v@%v", name, db)
+	}
+	return nil
+}
+
+// DropRole removes role name from database db. A missing role is not an error.
+// ref: https://docs.mongodb.com/manual/reference/command/dropRole/
+func (c *Client) DropRole(ctx context.Context, db, name string) error {
+	cmd := bson.D{{Key: "dropRole", Value: name}}
+	if err := c.RunCommand(ctx, db, cmd, nil); err != nil && !hasErrorCode(err, codeRoleNotFound) {
+		return errors.Wrapf(err, "failed to drop role %v@%v", name, db)
+	}
+	return nil
+}
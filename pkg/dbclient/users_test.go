@@ -0,0 +1,102 @@
+package dbclient
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestUsers(t *testing.T) {
+	s := newFakeServer(t, map[string]bson.M{
+		"usersinfo":  {"ok": 1, "users": bson.A{bson.M{"user": "app", "db": "shop"}}},
+		"createuser": {"ok": 1},
+		"dropuser":   {"ok": 0, "errmsg": "User 'app@shop' not found", "code": 11},
+	})
+	defer s.Close()
+	client := connect(t, s)
+	defer client.Close()
+	ctx := context.Background()
+
+	exists, err := client.UserExists(ctx, "shop", "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exists {
+		t.Error("expected user app@shop to exist")
+	}
+
+	if err := client.CreateUser(ctx, "shop", "app", "secret", []RoleRef{{Role: "readWrite", DB: "shop"}}); err != nil {
+		t.Fatal(err)
+	}
+	cmd := s.lastCommand("createUser")
+	if len(cmd) != 3 || cmd[0].Value != "app" || cmd[1].Value != "secret" {
+		t.Errorf("unexpected createUser command: %v", cmd)
+	}
+	if roles, ok := cmd[2].Value.(bson.A); !ok || len(roles) != 1 {
+		t.Errorf("unexpected roles: %v", cmd[2].Value)
+	}
+
+	if err := client.DropUser(ctx, "shop", "app"); err != nil {
+		t.Errorf("dropping a missing user should succeed, got %v", err)
+	}
+}
+
+func TestChangeUserPassword(t *testing.T) {
+	s := newFakeServer(t, map[string]bson.M{
+		"updateuser": {"ok": 0, "errmsg": "Could not find user \"root@admin\"", "code": 11},
+	})
+	defer s.Close()
+	client := connect(t, s)
+	defer client.Close()
+
+	err := client.ChangeUserPassword(context.Background(), "admin", "root", "secret")
+	if !IsUserNotFound(err) {
+		t.Errorf("expected user not found, got %v", err)
+	}
+	cmd := s.lastCommand("updateUser")
+	if len(cmd) != 2 || cmd[0].Value != "root" || cmd[1].Key != "pwd" || cmd[1].Value != "secret" {
+		t.Errorf("unexpected updateUser command: %v", cmd)
+	}
+}
+
+func TestRoles(t *testing.T) {
+	s := newFakeServer(t, map[string]bson.M{
+		"rolesinfo":  {"ok": 1, "roles": bson.A{}},
+		"createrole": {"ok": 1},
+		"droprole":   {"ok": 0, "errmsg": "not authorized on admin to execute command", "code": 13},
+	})
+	defer s.Close()
+	client := connect(t, s)
+	defer client.Close()
+	ctx := context.Background()
+
+	exists, err := client.RoleExists(ctx, "admin", "reporting")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Error("expected role reporting@admin not to exist")
+	}
+
+	privileges := []Privilege{
+		{Resource: Resource{DB: "shop"}, Actions: []string{"find"}},
+		{Resource: Resource{Cluster: true}, Actions: []string{"serverStatus"}},
+	}
+	if err := client.CreateRole(ctx, "admin", "reporting", privileges, nil); err != nil {
+		t.Fatal(err)
+	}
+	cmd := s.lastCommand("createRole")
+	docs, ok := cmd[1].Value.(bson.A)
+	if !ok || len(docs) != 2 {
+		t.Fatalf("unexpected privileges: %v", cmd[1].Value)
+	}
+	resource := docs[1].(bson.D)[0].Value.(bson.D)
+	if len(resource) != 1 || resource[0].Key != "cluster" {
+		t.Errorf("unexpected cluster resource: %v", resource)
+	}
+
+	if err := client.DropRole(ctx, "admin", "reporting"); err == nil {
+		t.Error("expected error dropping role without authorization")
+	}
+}
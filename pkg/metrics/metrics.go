@@ -0,0 +1,145 @@
+// Package metrics instruments the operator itself. The metrics are registered in the default Prometheus registry,
+// that the generic API server of the operator serves at /metrics.
+package metrics
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	admission "k8s.io/api/admission/v1beta1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+const (
+	namespace = "kubedb"
+	subsystem = "mongodb_operator"
+
+	// RetryRequeued and RetryDropped are the outcomes of a failed reconcile: the key is requeued until it has
+	// been retried MaxNumRequeues times, then dropped.
+	RetryRequeued = "requeued"
+	RetryDropped  = "dropped"
+)
+
+var (
+	reconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "reconcile_duration_seconds",
+		Help:      "How long reconciling an object took, by work queue and object.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 14),
+	}, []string{"queue", "namespace", "name"})
+
+	reconcileErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "reconcile_errors_total",
+		Help:      "Number of reconciles that failed, by work queue and object.",
+	}, []string{"queue", "namespace", "name"})
+
+	reconcileRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "reconcile_retries_total",
+		Help:      "Number of failed reconciles, by work queue and whether the key was requeued or dropped after MaxNumRequeues retries.",
+	}, []string{"queue", "outcome"})
+
+	snapshots = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "snapshots_total",
+		Help:      "Number of snapshot jobs completed, by database, whether they were taken by the backup schedule, and phase.",
+	}, []string{"namespace", "database", "scheduled", "phase"})
+
+	snapshotCompletion = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "snapshot_last_completion_timestamp_seconds",
+		Help:      "When the last snapshot completed, by database, whether it was taken by the backup schedule, and phase.",
+	}, []string{"namespace", "database", "scheduled", "phase"})
+
+	admissionDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "admission_duration_seconds",
+		Help:      "How long an admission webhook took to review a request, by hook and whether the request was allowed.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 14),
+	}, []string{"hook", "allowed"})
+)
+
+func init() {
+	prometheus.MustRegister(reconcileDuration, reconcileErrors, reconcileRetries, snapshots, snapshotCompletion, admissionDuration)
+	registerWorkqueueMetrics()
+	workqueue.SetProvider(workqueueMetricsProvider{})
+}
+
+// InstrumentReconcile returns reconcile of work queue name, that observes how long each reconcile takes and
+// counts the failed ones. numRequeues returns how many times a key has been retried already; a failed key is
+// dropped once it has been retried maxRetries times. The metrics of an object are dropped once it is no longer
+// in store.
+func InstrumentReconcile(name string, maxRetries int, numRequeues func(key interface{}) int, store cache.KeyGetter,
+	reconcile func(key string) error) func(key string) error {
+	return func(key string) error {
+		ns, objName, _ := cache.SplitMetaNamespaceKey(key)
+		start := time.Now()
+		err := reconcile(key)
+		if err != nil {
+			outcome := RetryRequeued
+			if numRequeues(key) >= maxRetries {
+				outcome = RetryDropped
+			}
+			reconcileRetries.WithLabelValues(name, outcome).Inc()
+		}
+		if _, exists, _ := store.GetByKey(key); !exists {
+			reconcileDuration.DeleteLabelValues(name, ns, objName)
+			reconcileErrors.DeleteLabelValues(name, ns, objName)
+			return err
+		}
+		reconcileDuration.WithLabelValues(name, ns, objName).Observe(time.Since(start).Seconds())
+		if err != nil {
+			reconcileErrors.WithLabelValues(name, ns, objName).Inc()
+		}
+		return err
+	}
+}
+
+// ObserveSnapshot counts a snapshot of database that completed in phase.
+func ObserveSnapshot(ns, database string, scheduled bool, phase string) {
+	snapshots.WithLabelValues(ns, database, strconv.FormatBool(scheduled), phase).Inc()
+}
+
+var (
+	completionMu sync.Mutex
+	// completions are the completion times set in snapshotCompletion, by label values.
+	completions = map[[4]string]time.Time{}
+)
+
+// ObserveSnapshotCompletion records that a snapshot of database completed in phase at t, if it is the last one.
+// Every snapshot is observed, not only the ones that complete while the operator runs, so that the time of
+// the last snapshot is known after the operator restarts.
+func ObserveSnapshotCompletion(ns, database string, scheduled bool, phase string, t time.Time) {
+	key := [4]string{ns, database, strconv.FormatBool(scheduled), phase}
+	completionMu.Lock()
+	defer completionMu.Unlock()
+	if last, ok := completions[key]; ok && !t.After(last) {
+		return
+	}
+	completions[key] = t
+	snapshotCompletion.WithLabelValues(key[:]...).Set(float64(t.Unix()))
+}
+
+// InstrumentAdmission returns admit of admission webhook hook, that observes how long each review takes.
+func InstrumentAdmission(hook string, admit func(*admission.AdmissionRequest) *admission.AdmissionResponse) func(*admission.AdmissionRequest) *admission.AdmissionResponse {
+	return func(req *admission.AdmissionRequest) *admission.AdmissionResponse {
+		start := time.Now()
+		resp := admit(req)
+		allowed := "false"
+		if resp != nil && resp.Allowed {
+			allowed = "true"
+		}
+		admissionDuration.WithLabelValues(hook, allowed).Observe(time.Since(start).Seconds())
+		return resp
+	}
+}
@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func counterValue(t *testing.T, c prometheus.Counter) float64 {
+	var m dto.Metric
+	if err := c.Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetCounter().GetValue()
+}
+
+func TestInstrumentReconcile(t *testing.T) {
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	requeues := 0
+	reconcile := InstrumentReconcile("Test", 2, func(key interface{}) int { return requeues }, store, func(key string) error {
+		return errors.New("failed")
+	})
+
+	// the object is gone, its metrics are not kept
+	if err := reconcile("demo/mg"); err == nil {
+		t.Fatal("expected the error of reconcile")
+	}
+	if n := counterValue(t, reconcileRetries.WithLabelValues("Test", RetryRequeued)); n != 1 {
+		t.Errorf("expected 1 requeued retry, got %v", n)
+	}
+	if reconcileErrors.DeleteLabelValues("Test", "demo", "mg") {
+		t.Error("expected no errors counted for a deleted object")
+	}
+
+	requeues = 2
+	_ = reconcile("demo/mg")
+	if n := counterValue(t, reconcileRetries.WithLabelValues("Test", RetryDropped)); n != 1 {
+		t.Errorf("expected 1 dropped retry, got %v", n)
+	}
+
+	if err := store.Add(&core.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "mg", Namespace: "demo"}}); err != nil {
+		t.Fatal(err)
+	}
+	_ = reconcile("demo/mg")
+	if n := counterValue(t, reconcileErrors.WithLabelValues("Test", "demo", "mg")); n != 1 {
+		t.Errorf("expected 1 error counted for the object, got %v", n)
+	}
+}
@@ -0,0 +1,231 @@
+package oplog
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/appscode/go/log"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"kubedb.dev/mongodb/pkg/dbclient"
+)
+
+const (
+	oplogDatabase   = "local"
+	oplogCollection = "oplog.rs"
+
+	// DefaultChunkInterval is how often a chunk is written, if not set.
+	DefaultChunkInterval = time.Minute
+	// DefaultMaxChunkSize bounds the size of a chunk, if not set.
+	DefaultMaxChunkSize = 16 << 20
+
+	maxAwaitTime = time.Second
+	pollInterval = time.Second
+)
+
+// Archiver tails the oplog of a replicaset and writes it to Store in chunks.
+type Archiver struct {
+	Client     *dbclient.Client
+	Store      *Store
+	ReplicaSet string
+	// Interval is how often a chunk is written.
+	Interval time.Duration
+	// MaxChunkSize bounds the size of a chunk. A chunk is written early when it is full.
+	MaxChunkSize int
+}
+
+// Run archives the oplog until ctx is done. It resumes after the last chunk in the archive, or starts from
+// the oldest entry in the oplog if the archive is empty.
+func (a *Archiver) Run(ctx context.Context) error {
+	if a.Interval <= 0 {
+		a.Interval = DefaultChunkInterval
+	}
+	if a.MaxChunkSize <= 0 {
+		a.MaxChunkSize = DefaultMaxChunkSize
+	}
+
+	last, err := a.resumePoint(ctx)
+	if err != nil {
+		return err
+	}
+	for ctx.Err() == nil {
+		deadline := time.Now().Add(a.Interval)
+		chunk, err := a.archive(ctx, last, deadline)
+		if err != nil {
+			return err
+		}
+		if chunk != nil {
+			last = chunk.Last
+			continue
+		}
+		// the cursor died without an entry, eg, on an empty oplog. Wait instead of spinning.
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Until(deadline)):
+		}
+	}
+	return nil
+}
+
+// resumePoint returns the timestamp of the last archived entry of the replicaset, or zero if there is none.
+func (a *Archiver) resumePoint(ctx context.Context) (primitive.Timestamp, error) {
+	names, err := a.Store.List()
+	if err != nil {
+		return primitive.Timestamp{}, err
+	}
+	chunks := chunksOf(names)[a.ReplicaSet]
+	if len(chunks) == 0 {
+		log.Infof("oplog archive of replicaset %s is empty, starting from the oldest oplog entry", a.ReplicaSet)
+		return primitive.Timestamp{}, nil
+	}
+	last := chunks[len(chunks)-1].Last
+
+	oldest, err := a.oldestEntry(ctx)
+	if err != nil {
+		return primitive.Timestamp{}, err
+	}
+	if before(last, oldest) {
+		log.Warningf("oplog of replicaset %s starts at %v, after the last archived entry at %v. Entries in between are lost.",
+			a.ReplicaSet, timeOf(oldest), timeOf(last))
+	}
+	log.Infof("resuming oplog archive of replicaset %s after %v", a.ReplicaSet, timeOf(last))
+	return last, nil
+}
+
+func (a *Archiver) oplog() *mongo.Collection {
+	return oplogOf(a.Client)
+}
+
+func (a *Archiver) oldestEntry(ctx context.Context) (primitive.Timestamp, error) {
+	return edgeEntry(ctx, a.Client, 1)
+}
+
+func oplogOf(client *dbclient.Client) *mongo.Collection {
+	return client.Database(oplogDatabase).Collection(oplogCollection)
+}
+
+// LatestTimestamp returns the timestamp of the latest entry in the oplog of the replicaset client is connected to.
+func LatestTimestamp(ctx context.Context, client *dbclient.Client) (primitive.Timestamp, error) {
+	return edgeEntry(ctx, client, -1)
+}
+
+// edgeEntry returns the timestamp of the oldest entry of the oplog, for order 1, or of the latest, for order -1.
+func edgeEntry(ctx context.Context, client *dbclient.Client, order int) (primitive.Timestamp, error) {
+	raw, err := oplogOf(client).FindOne(ctx, bson.D{}, options.FindOne().SetSort(bson.D{{Key: "$natural", Value: order}})).DecodeBytes()
+	if err != nil {
+		return primitive.Timestamp{}, errors.Wrap(err, "failed to read the oplog")
+	}
+	t, i := raw.Lookup("ts").Timestamp()
+	return primitive.Timestamp{T: t, I: i}, nil
+}
+
+// ArchiveRange writes the entries after after until until, inclusive, in chunks. It waits for the oplog to reach
+// until first. The last chunk ends at until even if it has no entry at until, so that the archive is known to
+// cover the whole range.
+func (a *Archiver) ArchiveRange(ctx context.Context, after, until primitive.Timestamp) error {
+	if a.MaxChunkSize <= 0 {
+		a.MaxChunkSize = DefaultMaxChunkSize
+	}
+	for {
+		latest, err := LatestTimestamp(ctx, a.Client)
+		if err != nil {
+			return err
+		}
+		if !before(latest, until) {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+	oldest, err := a.oldestEntry(ctx)
+	if err != nil {
+		return err
+	}
+	if after != (primitive.Timestamp{}) && before(after, oldest) {
+		return errors.Errorf("oplog of replicaset %s starts at %v, after %v. Entries in between are lost",
+			a.ReplicaSet, timeOf(oldest), timeOf(after))
+	}
+
+	filter := bson.D{{Key: "ts", Value: bson.D{{Key: "$gt", Value: after}, {Key: "$lte", Value: until}}}}
+	cursor, err := a.oplog().Find(ctx, filter, options.Find().SetOplogReplay(true))
+	if err != nil {
+		return errors.Wrap(err, "failed to read the oplog")
+	}
+	defer cursor.Close(context.Background())
+
+	chunk := Chunk{ReplicaSet: a.ReplicaSet}
+	var buf bytes.Buffer
+	for cursor.Next(ctx) {
+		t, i := cursor.Current.Lookup("ts").Timestamp()
+		if buf.Len() == 0 {
+			chunk.First = primitive.Timestamp{T: t, I: i}
+		}
+		chunk.Last = primitive.Timestamp{T: t, I: i}
+		buf.Write(cursor.Current)
+		if buf.Len() >= a.MaxChunkSize {
+			if err := a.Store.Put(chunk.Name(), buf.Bytes()); err != nil {
+				return err
+			}
+			buf.Reset()
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return errors.Wrap(err, "failed to read the oplog")
+	}
+	if buf.Len() == 0 {
+		chunk.First = until
+	}
+	chunk.Last = until
+	return a.Store.Put(chunk.Name(), buf.Bytes())
+}
+
+// archive writes the entries after last, until deadline or until the chunk is full, as a chunk.
+// It returns nil if there was no entry to archive.
+func (a *Archiver) archive(ctx context.Context, last primitive.Timestamp, deadline time.Time) (*Chunk, error) {
+	tctx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+
+	filter := bson.D{{Key: "ts", Value: bson.D{{Key: "$gt", Value: last}}}}
+	opts := options.Find().
+		SetCursorType(options.TailableAwait).
+		SetOplogReplay(true).
+		SetMaxAwaitTime(maxAwaitTime)
+	cursor, err := a.oplog().Find(tctx, filter, opts)
+	if err != nil {
+		if tctx.Err() != nil {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "failed to tail the oplog")
+	}
+
+	chunk := Chunk{ReplicaSet: a.ReplicaSet}
+	var buf bytes.Buffer
+	for buf.Len() < a.MaxChunkSize && cursor.Next(tctx) {
+		t, i := cursor.Current.Lookup("ts").Timestamp()
+		if buf.Len() == 0 {
+			chunk.First = primitive.Timestamp{T: t, I: i}
+		}
+		chunk.Last = primitive.Timestamp{T: t, I: i}
+		buf.Write(cursor.Current)
+	}
+	if err := cursor.Err(); err != nil && tctx.Err() == nil {
+		_ = cursor.Close(context.Background())
+		return nil, errors.Wrap(err, "failed to tail the oplog")
+	}
+	_ = cursor.Close(context.Background())
+
+	if buf.Len() == 0 {
+		return nil, nil
+	}
+	if err := a.Store.Put(chunk.Name(), buf.Bytes()); err != nil {
+		return nil, err
+	}
+	return &chunk, nil
+}
@@ -0,0 +1,116 @@
+package oplog
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const chunkExtension = ".bson"
+
+// Chunk is a run of consecutive oplog entries of replicaset ReplicaSet, from First to Last inclusive.
+// A chunk is stored as the concatenated BSON documents of its entries.
+type Chunk struct {
+	ReplicaSet string
+	First      primitive.Timestamp
+	Last       primitive.Timestamp
+}
+
+// Name is where the chunk is stored in the archive. Names sort in the order of the chunks.
+func (c Chunk) Name() string {
+	return fmt.Sprintf("%s/%010d.%010d-%010d.%010d%s", c.ReplicaSet, c.First.T, c.First.I, c.Last.T, c.Last.I, chunkExtension)
+}
+
+// ParseChunkName is the inverse of Chunk.Name.
+func ParseChunkName(name string) (Chunk, error) {
+	var c Chunk
+	for i := len(name) - 1; i >= 0; i-- {
+		if name[i] == '/' {
+			c.ReplicaSet, name = name[:i], name[i+1:]
+			break
+		}
+	}
+	if c.ReplicaSet == "" {
+		return c, errors.Errorf("oplog chunk %q has no replicaset", name)
+	}
+	var ext string
+	n, err := fmt.Sscanf(name, "%010d.%010d-%010d.%010d%s", &c.First.T, &c.First.I, &c.Last.T, &c.Last.I, &ext)
+	if err != nil || n != 5 || ext != chunkExtension {
+		return c, errors.Errorf("invalid oplog chunk name %q", name)
+	}
+	return c, nil
+}
+
+// chunksOf parses the names of the chunks in the archive, sorted by time and grouped by replicaset.
+// Items that are not chunks are ignored.
+func chunksOf(names []string) map[string][]Chunk {
+	chunks := map[string][]Chunk{}
+	for _, name := range names {
+		c, err := ParseChunkName(name)
+		if err != nil {
+			continue
+		}
+		chunks[c.ReplicaSet] = append(chunks[c.ReplicaSet], c)
+	}
+	for _, cs := range chunks {
+		sort.Slice(cs, func(i, j int) bool { return before(cs[i].First, cs[j].First) })
+	}
+	return chunks
+}
+
+// selectChunks returns the chunks with the entries from timestamp from until timestamp until, both inclusive.
+// The archive must cover the seconds of the whole range, or of until only if from is zero: replicasets write a
+// no-op entry to the oplog every 10s, so the archive advances even when the database is idle.
+func selectChunks(chunks []Chunk, from, until primitive.Timestamp) ([]Chunk, error) {
+	if len(chunks) == 0 {
+		return nil, errors.New("oplog archive is empty")
+	}
+	if first := chunks[0].First; from != (primitive.Timestamp{}) && first.T > from.T {
+		return nil, errors.Errorf("oplog archive starts at %v, after %v", timeOf(first), timeOf(from))
+	}
+	if last := chunks[len(chunks)-1].Last; last.T < until.T {
+		return nil, errors.Errorf("oplog archive ends at %v, before %v", timeOf(last), timeOf(until))
+	}
+	var selected []Chunk
+	for _, c := range chunks {
+		if !before(c.Last, from) && !before(until, c.First) {
+			selected = append(selected, c)
+		}
+	}
+	return selected, nil
+}
+
+// SplitDocuments splits concatenated BSON documents, like the content of a chunk or a collection dumped by
+// mongodump, into the documents.
+func SplitDocuments(data []byte) ([]bson.Raw, error) {
+	var docs []bson.Raw
+	for len(data) > 0 {
+		if len(data) < 4 {
+			return nil, errors.New("truncated BSON document")
+		}
+		n := int(binary.LittleEndian.Uint32(data))
+		if n < 5 || n > len(data) {
+			return nil, errors.New("truncated BSON document")
+		}
+		doc := bson.Raw(data[:n])
+		if err := doc.Validate(); err != nil {
+			return nil, errors.Wrap(err, "invalid BSON document")
+		}
+		docs = append(docs, doc)
+		data = data[n:]
+	}
+	return docs, nil
+}
+
+func before(a, b primitive.Timestamp) bool {
+	return a.T < b.T || (a.T == b.T && a.I < b.I)
+}
+
+func timeOf(ts primitive.Timestamp) time.Time {
+	return time.Unix(int64(ts.T), 0).UTC()
+}
@@ -0,0 +1,124 @@
+package oplog
+
+import (
+	"math"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestChunkName(t *testing.T) {
+	c := Chunk{ReplicaSet: "rs0", First: primitive.Timestamp{T: 1565000000, I: 1}, Last: primitive.Timestamp{T: 1565000060, I: 12}}
+	name := c.Name()
+	if name != "rs0/1565000000.0000000001-1565000060.0000000012.bson" {
+		t.Errorf("unexpected name %s", name)
+	}
+	parsed, err := ParseChunkName(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed != c {
+		t.Errorf("expected %v, got %v", c, parsed)
+	}
+
+	for _, name := range []string{"1565000000.0000000001-1565000060.0000000012.bson", "rs0/1565000000.0000000001.bson", "rs0/1565000000.0000000001-1565000060.0000000012.gz"} {
+		if _, err := ParseChunkName(name); err == nil {
+			t.Errorf("expected %s to be invalid", name)
+		}
+	}
+}
+
+func TestSelectChunks(t *testing.T) {
+	chunk := func(first, last uint32) Chunk {
+		return Chunk{ReplicaSet: "rs0", First: primitive.Timestamp{T: first, I: 1}, Last: primitive.Timestamp{T: last, I: 1}}
+	}
+	chunks := chunksOf([]string{chunk(160, 220).Name(), chunk(100, 159).Name(), "rs0/README", chunk(221, 280).Name()})["rs0"]
+	if len(chunks) != 3 || chunks[0].First.T != 100 {
+		t.Fatalf("unexpected chunks %v", chunks)
+	}
+
+	cases := []struct {
+		from, until uint32
+		selected    int
+		fail        bool
+	}{
+		{from: 100, until: 280, selected: 3},
+		{from: 170, until: 200, selected: 1},
+		{from: 150, until: 230, selected: 3},
+		{from: 0, until: 200, selected: 2},
+		{from: 90, until: 200, fail: true},
+		{from: 150, until: 300, fail: true},
+	}
+	for _, c := range cases {
+		from := primitive.Timestamp{T: c.from, I: 1}
+		if c.from == 0 {
+			from = primitive.Timestamp{}
+		}
+		selected, err := selectChunks(chunks, from, primitive.Timestamp{T: c.until, I: math.MaxUint32})
+		if c.fail {
+			if err == nil {
+				t.Errorf("[%d, %d]: expected an error", c.from, c.until)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("[%d, %d]: %v", c.from, c.until, err)
+		} else if len(selected) != c.selected {
+			t.Errorf("[%d, %d]: expected %d chunks, got %v", c.from, c.until, c.selected, selected)
+		}
+	}
+}
+
+func TestReplayable(t *testing.T) {
+	marshal := func(doc bson.D) []byte {
+		data, err := bson.Marshal(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	var data []byte
+	for _, doc := range []bson.D{
+		{{Key: "ts", Value: primitive.Timestamp{T: 100, I: 1}}, {Key: "op", Value: "n"}, {Key: "ns", Value: ""}, {Key: "o", Value: bson.D{{Key: "msg", Value: "periodic noop"}}}},
+		{{Key: "ts", Value: primitive.Timestamp{T: 100, I: 2}}, {Key: "op", Value: "u"}, {Key: "ns", Value: "shop.orders"}, {Key: "ui", Value: primitive.Binary{Subtype: 4, Data: make([]byte, 16)}},
+			{Key: "o2", Value: bson.D{{Key: "_id", Value: 1}}}, {Key: "o", Value: bson.D{{Key: "$set", Value: bson.D{{Key: "paid", Value: true}}}}}},
+		{{Key: "ts", Value: primitive.Timestamp{T: 100, I: 3}}, {Key: "op", Value: "i"}, {Key: "ns", Value: "config.system.sessions"}, {Key: "o", Value: bson.D{{Key: "_id", Value: 1}}}},
+	} {
+		data = append(data, marshal(doc)...)
+	}
+
+	entries, err := SplitDocuments(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(entries))
+	}
+	if _, err := SplitDocuments(data[:len(data)-1]); err == nil {
+		t.Error("expected a truncated chunk to fail")
+	}
+
+	var ops []bson.Raw
+	for _, raw := range entries {
+		op, ok, err := replayable(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok {
+			ops = append(ops, op)
+		}
+	}
+	if len(ops) != 1 {
+		t.Fatalf("expected only the update to be replayed, got %v", ops)
+	}
+	if _, err := ops[0].LookupErr("ui"); err == nil {
+		t.Error("expected the collection UUID to be dropped")
+	}
+	if ns := ops[0].Lookup("ns").StringValue(); ns != "shop.orders" {
+		t.Errorf("unexpected namespace %s", ns)
+	}
+	if id := ops[0].Lookup("o2", "_id").Int32(); id != 1 {
+		t.Errorf("unexpected o2 %v", ops[0].Lookup("o2"))
+	}
+}
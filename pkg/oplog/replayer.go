@@ -0,0 +1,157 @@
+package oplog
+
+import (
+	"context"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/appscode/go/log"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"kubedb.dev/mongodb/pkg/dbclient"
+)
+
+// DefaultBatchSize is how many entries are applied at once, if not set.
+const DefaultBatchSize = 100
+
+// Replayer applies the archived oplog of a replicaset to a MongoDB.
+type Replayer struct {
+	Client *dbclient.Client
+	Store  *Store
+	// ReplicaSet whose oplog is replayed. It may be empty if the archive has the oplog of a single replicaset.
+	ReplicaSet string
+	// BatchSize is how many entries are applied at once.
+	BatchSize int
+}
+
+// entry is the part of an oplog entry needed to apply it.
+type entry struct {
+	Op        string   `bson:"op"`
+	Namespace string   `bson:"ns"`
+	Object    bson.Raw `bson:"o"`
+	Object2   bson.Raw `bson:"o2,omitempty"`
+}
+
+// Replay applies the archived entries from time from until time until, both inclusive. Entries between from and
+// the end of the snapshot are already in the restored data; applying them again is safe, as oplog entries are
+// idempotent.
+func (r *Replayer) Replay(ctx context.Context, from, until time.Time) error {
+	// the increments of oplog timestamps start at 1
+	return r.ReplayRange(ctx,
+		primitive.Timestamp{T: uint32(from.Unix()), I: 1},
+		primitive.Timestamp{T: uint32(until.Unix()), I: math.MaxUint32})
+}
+
+// ReplayRange applies the archived entries from timestamp from until timestamp until, both inclusive.
+// A zero from replays the archive from its first entry.
+func (r *Replayer) ReplayRange(ctx context.Context, from, until primitive.Timestamp) error {
+	if r.BatchSize <= 0 {
+		r.BatchSize = DefaultBatchSize
+	}
+	names, err := r.Store.List()
+	if err != nil {
+		return err
+	}
+	archive := chunksOf(names)
+	if r.ReplicaSet == "" {
+		if len(archive) != 1 {
+			return errors.Errorf("oplog archive must have the oplog of a single replicaset, found %d", len(archive))
+		}
+		for rs := range archive {
+			r.ReplicaSet = rs
+		}
+	}
+	chunks, err := selectChunks(archive[r.ReplicaSet], from, until)
+	if err != nil {
+		return errors.Wrapf(err, "replicaset %s", r.ReplicaSet)
+	}
+
+	var batch []bson.Raw
+	flush := func() error {
+		err := r.Client.ApplyOps(ctx, batch)
+		batch = batch[:0]
+		return err
+	}
+	applied := 0
+	for _, c := range chunks {
+		data, err := r.Store.Get(c.Name())
+		if err != nil {
+			return err
+		}
+		entries, err := SplitDocuments(data)
+		if err != nil {
+			return errors.Wrapf(err, "oplog chunk %s", c.Name())
+		}
+		for _, raw := range entries {
+			t, i := raw.Lookup("ts").Timestamp()
+			ts := primitive.Timestamp{T: t, I: i}
+			if before(ts, from) {
+				continue
+			}
+			if before(until, ts) {
+				break
+			}
+			op, ok, err := replayable(raw)
+			if err != nil {
+				return errors.Wrapf(err, "oplog chunk %s", c.Name())
+			}
+			if !ok {
+				continue
+			}
+			// commands, eg, creating an index or dropping a collection, are applied on their own
+			command := raw.Lookup("op").StringValue() == "c"
+			if command {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+			batch = append(batch, op)
+			applied++
+			if command || len(batch) >= r.BatchSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	log.Infof("replayed %d oplog entries of replicaset %s until %v", applied, r.ReplicaSet, timeOf(until))
+	return nil
+}
+
+// replayable strips the oplog entry raw to what applyOps needs, or returns false if it must not be replayed:
+// no-ops, and changes to the internal databases and to the users, roles and keys of the cluster. The UUIDs of
+// the collections are dropped too, as they differ in the restored database.
+func replayable(raw bson.Raw) (bson.Raw, bool, error) {
+	var e entry
+	if err := bson.Unmarshal(raw, &e); err != nil {
+		return nil, false, errors.Wrap(err, "invalid oplog entry")
+	}
+	if e.Op == "n" || skipNamespace(e.Namespace) {
+		return nil, false, nil
+	}
+	doc := bson.D{{Key: "op", Value: e.Op}, {Key: "ns", Value: e.Namespace}, {Key: "o", Value: e.Object}}
+	if e.Object2 != nil {
+		doc = append(doc, bson.E{Key: "o2", Value: e.Object2})
+	}
+	op, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, false, err
+	}
+	return op, true, nil
+}
+
+func skipNamespace(ns string) bool {
+	switch {
+	case strings.HasPrefix(ns, "local."), strings.HasPrefix(ns, "config."):
+		return true
+	case ns == "admin.system.keys", ns == "admin.system.version",
+		ns == "admin.system.users", ns == "admin.system.roles":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+// Package oplog archives the oplog of a replicaset to an object store in chunks, and replays the archived
+// chunks into a MongoDB to recover it to a point in time.
+package oplog
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	otx "github.com/appscode/osm/context"
+	"github.com/pkg/errors"
+	"gomodules.xyz/stow"
+	_ "gomodules.xyz/stow/azure"
+	_ "gomodules.xyz/stow/google"
+	_ "gomodules.xyz/stow/local"
+	_ "gomodules.xyz/stow/s3"
+	_ "gomodules.xyz/stow/swift"
+)
+
+const listPageSize = 100
+
+// Store keeps the oplog chunks under a folder of a bucket.
+type Store struct {
+	container stow.Container
+	prefix    string
+}
+
+// NewStore opens folder prefix of bucket, using the osm configuration at configPath.
+func NewStore(configPath, bucket, prefix string) (*Store, error) {
+	cfg, err := otx.LoadConfig(configPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load osm config %s", configPath)
+	}
+	loc, err := cfg.Dial("")
+	if err != nil {
+		return nil, err
+	}
+	container, err := loc.Container(bucket)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open bucket %s", bucket)
+	}
+	return &Store{container: container, prefix: strings.Trim(prefix, "/")}, nil
+}
+
+// Sub returns the store of the folder under the folder of s.
+func (s *Store) Sub(folder string) *Store {
+	return &Store{container: s.container, prefix: s.path(strings.Trim(folder, "/"))}
+}
+
+func (s *Store) path(name string) string {
+	if s.prefix == "" {
+		return name
+	}
+	return s.prefix + "/" + name
+}
+
+// Put writes data to the item name.
+func (s *Store) Put(name string, data []byte) error {
+	if _, err := s.container.Put(s.path(name), bytes.NewReader(data), int64(len(data)), nil); err != nil {
+		return errors.Wrapf(err, "failed to write %s", s.path(name))
+	}
+	return nil
+}
+
+// Get reads the item name.
+func (s *Store) Get(name string) ([]byte, error) {
+	item, err := s.container.Item(s.path(name))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to find %s", s.path(name))
+	}
+	r, err := item.Open()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open %s", s.path(name))
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// List returns the names of the items in the folder, sorted. A folder that does not exist yet is empty.
+func (s *Store) List() ([]string, error) {
+	prefix := s.path("")
+	var names []string
+	cursor := stow.CursorStart
+	for {
+		items, next, err := s.container.Items(prefix, cursor, listPageSize)
+		if err != nil {
+			if os.IsNotExist(errors.Cause(err)) {
+				return nil, nil
+			}
+			return nil, errors.Wrapf(err, "failed to list %s", prefix)
+		}
+		for _, item := range items {
+			names = append(names, strings.TrimPrefix(item.Name(), prefix))
+		}
+		if stow.IsCursorEnd(next) {
+			break
+		}
+		cursor = next
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,70 @@
+package oplog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	otx "github.com/appscode/osm/context"
+	"gomodules.xyz/stow"
+	"gomodules.xyz/stow/local"
+)
+
+func newLocalStore(t *testing.T, prefix string) (*Store, func()) {
+	dir, err := ioutil.TempDir("", "oplog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &otx.OSMConfig{
+		Contexts: []*otx.Context{{
+			Name:     "local",
+			Provider: local.Kind,
+			Config:   stow.ConfigMap{local.ConfigKeyPath: dir},
+		}},
+		CurrentContext: "local",
+	}
+	configPath := filepath.Join(dir, "config")
+	if err := cfg.Save(configPath); err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewStore(configPath, dir, prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, func() { os.RemoveAll(dir) }
+}
+
+func TestStore(t *testing.T) {
+	s, cleanup := newLocalStore(t, "kubedb/demo/mgo/oplog")
+	defer cleanup()
+
+	names, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 0 {
+		t.Errorf("expected an empty archive, got %v", names)
+	}
+
+	for _, name := range []string{"rs0/b.bson", "rs0/a.bson", "shard1/a.bson"} {
+		if err := s.Put(name, []byte(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	names, err = s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"rs0/a.bson", "rs0/b.bson", "shard1/a.bson"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected %v, got %v", expected, names)
+	}
+	data, err := s.Get("rs0/b.bson")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "rs0/b.bson" {
+		t.Errorf("unexpected content %q", data)
+	}
+}
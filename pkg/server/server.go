@@ -26,6 +26,7 @@ import (
 	"kubedb.dev/apimachinery/pkg/eventer"
 	mgAdmsn "kubedb.dev/mongodb/pkg/admission"
 	"kubedb.dev/mongodb/pkg/controller"
+	"kubedb.dev/mongodb/pkg/metrics"
 )
 
 const (
@@ -118,6 +119,8 @@ func (c completedConfig) New() (*MongoDBServer, error) {
 	if c.OperatorConfig.EnableValidatingWebhook {
 		c.ExtraConfig.AdmissionHooks = append(c.ExtraConfig.AdmissionHooks,
 			&mgAdmsn.MongoDBValidator{},
+			&mgAdmsn.MongoDBUserValidator{},
+			&mgAdmsn.MongoDBRoleValidator{},
 			&snapshot.SnapshotValidator{},
 			&dormantdatabase.DormantDatabaseValidator{},
 			&namespace.NamespaceValidator{
@@ -156,7 +159,7 @@ func (c completedConfig) New() (*MongoDBServer, error) {
 				// just overwrite the groupversion with a random one.  We don't really care or know.
 				apiGroupInfo.PrioritizedVersions = appendUniqueGroupVersion(apiGroupInfo.PrioritizedVersions, admissionVersion)
 
-				admissionReview := admissionreview.NewREST(admissionHook.Admit)
+				admissionReview := admissionreview.NewREST(metrics.InstrumentAdmission(admissionHookName(admissionHook), admissionHook.Admit))
 				v1alpha1storage, ok := apiGroupInfo.VersionedResourcesStorageMap[admissionVersion.Version]
 				if !ok {
 					v1alpha1storage = map[string]rest.Storage{}
@@ -241,6 +244,12 @@ func appendUniqueGroupVersion(slice []schema.GroupVersion, elems ...schema.Group
 	return out
 }
 
+// admissionHookName names hook in its metrics, by the resource it serves.
+func admissionHookName(hook hooks.AdmissionHook) string {
+	gvr, _ := hook.Resource()
+	return fmt.Sprintf("%s.%s", gvr.Resource, gvr.Group)
+}
+
 func postStartHookName(hook hooks.AdmissionHook) string {
 	var ns []string
 	gvr, _ := hook.Resource()
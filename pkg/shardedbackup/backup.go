@@ -0,0 +1,223 @@
+package shardedbackup
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/appscode/go/log"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"kubedb.dev/mongodb/pkg/dbclient"
+	"kubedb.dev/mongodb/pkg/oplog"
+)
+
+const (
+	startFileName    = "start.json"
+	balancerFileName = "balancer"
+	exitExtension    = ".exit"
+	pollInterval     = 5 * time.Second
+)
+
+// ExitFile is the file in the state directory that the dump of replicaset writes its exit code to.
+func ExitFile(stateDir, replicaSet string) string {
+	return filepath.Join(stateDir, replicaSet+exitExtension)
+}
+
+// Backup coordinates the dumps of a sharded cluster. Start runs before the dumps, and Finish alongside them.
+// They share state through the files in StateDir.
+type Backup struct {
+	Mongos       *dbclient.Client
+	ConfigServer Component
+	Shards       []Component
+	Connect      Connector
+	// Store is the folder of the snapshot.
+	Store    *oplog.Store
+	StateDir string
+	// DumpTimeout bounds how long Finish waits for the dumps.
+	DumpTimeout time.Duration
+}
+
+// Start stops the balancer, so that no chunk moves between shards while they are dumped, and records where the
+// oplog of every shard is before the dumps start. The mode of the balancer is recorded first, so that Finish
+// starts the balancer again only if it was running before the backup.
+func (b *Backup) Start(ctx context.Context) (err error) {
+	mode, err := b.Mongos.BalancerMode(ctx)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(b.StateDir, 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(b.StateDir, balancerFileName), []byte(mode), 0644); err != nil {
+		return err
+	}
+	if err := b.Mongos.BalancerStop(ctx); err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			b.restoreBalancer()
+		}
+	}()
+
+	start := map[string]primitive.Timestamp{}
+	for _, shard := range b.Shards {
+		ts, err := b.latestTimestamp(ctx, shard)
+		if err != nil {
+			return err
+		}
+		start[shard.ReplicaSet] = ts
+	}
+	data, err := json.Marshal(start)
+	if err != nil {
+		return err
+	}
+	log.Infof("balancer stopped, dumping %s and %d shards", b.ConfigServer.ReplicaSet, len(b.Shards))
+	return ioutil.WriteFile(filepath.Join(b.StateDir, startFileName), data, 0644)
+}
+
+// Finish waits for the dumps, captures the oplog of every shard from the start of the dumps until the latest
+// cluster time among the shards, and writes the manifest of the snapshot. The balancer is restored to its mode
+// before the backup, whether the backup succeeds or not.
+func (b *Backup) Finish(ctx context.Context) error {
+	defer b.restoreBalancer()
+
+	data, err := ioutil.ReadFile(filepath.Join(b.StateDir, startFileName))
+	if err != nil {
+		return err
+	}
+	start := map[string]primitive.Timestamp{}
+	if err := json.Unmarshal(data, &start); err != nil {
+		return errors.Wrapf(err, "invalid %s", startFileName)
+	}
+	if err := b.waitForDumps(ctx); err != nil {
+		return err
+	}
+
+	// every dump has ended by now, so the latest entry among the shards is after all of them.
+	var clusterTime primitive.Timestamp
+	for _, shard := range b.Shards {
+		ts, err := b.latestTimestamp(ctx, shard)
+		if err != nil {
+			return err
+		}
+		if clusterTime.T < ts.T || (clusterTime.T == ts.T && clusterTime.I < ts.I) {
+			clusterTime = ts
+		}
+	}
+
+	manifest := &Manifest{
+		ClusterTime:  clusterTime,
+		ConfigServer: b.ConfigServer.ReplicaSet,
+	}
+	for _, shard := range b.Shards {
+		from, ok := start[shard.ReplicaSet]
+		if !ok {
+			return errors.Errorf("start of the oplog of shard %s is unknown", shard.ReplicaSet)
+		}
+		if err := b.captureOplog(ctx, shard, from, clusterTime); err != nil {
+			return errors.Wrapf(err, "failed to capture the oplog of shard %s", shard.ReplicaSet)
+		}
+		manifest.Shards = append(manifest.Shards, shard.ReplicaSet)
+	}
+	if err := writeManifest(b.Store, manifest); err != nil {
+		return err
+	}
+	log.Infof("sharded cluster is backed up at cluster time %d.%d", clusterTime.T, clusterTime.I)
+	return nil
+}
+
+// waitForDumps waits for the exit codes of the dumps of the config server and of every shard.
+func (b *Backup) waitForDumps(ctx context.Context) error {
+	if b.DumpTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.DumpTimeout)
+		defer cancel()
+	}
+
+	var failed []string
+	for _, rs := range append([]Component{b.ConfigServer}, b.Shards...) {
+		for {
+			data, err := ioutil.ReadFile(ExitFile(b.StateDir, rs.ReplicaSet))
+			if err == nil {
+				if code := strings.TrimSpace(string(data)); code != "0" {
+					failed = append(failed, rs.ReplicaSet)
+				}
+				break
+			}
+			if !os.IsNotExist(err) {
+				return err
+			}
+			select {
+			case <-ctx.Done():
+				return errors.Wrapf(ctx.Err(), "dump of replicaset %s did not finish", rs.ReplicaSet)
+			case <-time.After(pollInterval):
+			}
+		}
+	}
+	if len(failed) > 0 {
+		return errors.Errorf("dump of replicasets %s failed", strings.Join(failed, ","))
+	}
+	return nil
+}
+
+func (b *Backup) captureOplog(ctx context.Context, shard Component, from, until primitive.Timestamp) error {
+	client, err := b.Connect(ctx, shard.Hosts, shard.ReplicaSet)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	archiver := &oplog.Archiver{
+		Client:     client,
+		Store:      b.Store.Sub(OplogFolder),
+		ReplicaSet: shard.ReplicaSet,
+	}
+	return archiver.ArchiveRange(ctx, from, until)
+}
+
+func (b *Backup) latestTimestamp(ctx context.Context, rs Component) (primitive.Timestamp, error) {
+	client, err := b.Connect(ctx, rs.Hosts, rs.ReplicaSet)
+	if err != nil {
+		return primitive.Timestamp{}, err
+	}
+	defer client.Close()
+	ts, err := oplog.LatestTimestamp(ctx, client)
+	return ts, errors.Wrapf(err, "replicaset %s", rs.ReplicaSet)
+}
+
+// balancerWasRunning reports whether the balancer was running before Start stopped it, as recorded in stateDir.
+func balancerWasRunning(stateDir string) (bool, error) {
+	data, err := ioutil.ReadFile(filepath.Join(stateDir, balancerFileName))
+	if err != nil {
+		return false, errors.Wrap(err, "mode of the balancer before the backup is unknown")
+	}
+	return strings.TrimSpace(string(data)) != dbclient.BalancerModeOff, nil
+}
+
+// restoreBalancer starts the balancer if it was running before the backup, even if the backup is cancelled.
+// A balancer stopped by the user, or by another backup, is left stopped.
+func (b *Backup) restoreBalancer() {
+	running, err := balancerWasRunning(b.StateDir)
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+	if !running {
+		log.Infoln("balancer was stopped before the backup, leaving it stopped")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := b.Mongos.BalancerStart(ctx); err != nil {
+		log.Errorln(err)
+		return
+	}
+	log.Infoln("balancer started")
+}
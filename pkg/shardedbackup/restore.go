@@ -0,0 +1,168 @@
+package shardedbackup
+
+import (
+	"context"
+	"strings"
+
+	"github.com/appscode/go/log"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"kubedb.dev/mongodb/pkg/dbclient"
+	"kubedb.dev/mongodb/pkg/oplog"
+)
+
+const configDatabase = "config"
+
+// metadataCollections are the collections of the config database that describe how the data is sharded.
+// The shards and the settings of the cluster are those of the target cluster.
+var metadataCollections = []string{"databases", "collections", "chunks", "tags"}
+
+// Restore completes the restore of a consistent snapshot into a sharded cluster with the same number of shards,
+// once the dump of every shard is restored into the shard of the same index: it restores the sharding metadata
+// into the config server and replays the captured oplog of every shard until the cluster time of the snapshot.
+type Restore struct {
+	Mongos       *dbclient.Client
+	ConfigServer Component
+	Shards       []Component
+	Connect      Connector
+	// Store is the folder of the snapshot.
+	Store *oplog.Store
+}
+
+func (r *Restore) Run(ctx context.Context) error {
+	manifest, err := readManifest(r.Store)
+	if err != nil {
+		return err
+	}
+	if len(manifest.Shards) != len(r.Shards) {
+		return errors.Errorf("snapshot has %d shards, but the cluster has %d", len(manifest.Shards), len(r.Shards))
+	}
+	shardIDs := make(map[string]string, len(r.Shards))
+	for i, shard := range r.Shards {
+		shardIDs[manifest.Shards[i]] = shard.ReplicaSet
+	}
+
+	if err := r.restoreMetadata(ctx, manifest.ConfigServer, shardIDs); err != nil {
+		return err
+	}
+	for i, shard := range r.Shards {
+		if err := r.replay(ctx, manifest.Shards[i], shard, manifest.ClusterTime); err != nil {
+			return errors.Wrapf(err, "failed to replay the oplog of shard %s into %s", manifest.Shards[i], shard.ReplicaSet)
+		}
+	}
+	if err := r.Mongos.FlushRouterConfig(ctx); err != nil {
+		return err
+	}
+	log.Infof("sharded cluster is restored to cluster time %d.%d", manifest.ClusterTime.T, manifest.ClusterTime.I)
+	return nil
+}
+
+// restoreMetadata upserts the sharding metadata in the dump of the config server, with the shards of the snapshot
+// renamed to the shards of the cluster in shardIDs.
+func (r *Restore) restoreMetadata(ctx context.Context, configServer string, shardIDs map[string]string) error {
+	client, err := r.Connect(ctx, r.ConfigServer.Hosts, r.ConfigServer.ReplicaSet)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	for _, collection := range metadataCollections {
+		data, err := r.Store.Get(configServer + "/" + configDatabase + "/" + collection + ".bson")
+		if err != nil {
+			return err
+		}
+		docs, err := oplog.SplitDocuments(data)
+		if err != nil {
+			return errors.Wrapf(err, "dump of %s.%s", configDatabase, collection)
+		}
+		coll := client.Database(configDatabase).Collection(collection)
+		restored := 0
+		for _, raw := range docs {
+			doc, ok, err := metadataDocument(collection, raw, shardIDs)
+			if err != nil {
+				return errors.Wrapf(err, "dump of %s.%s", configDatabase, collection)
+			}
+			if !ok {
+				continue
+			}
+			filter := bson.D{{Key: "_id", Value: raw.Lookup("_id")}}
+			if _, err := coll.ReplaceOne(ctx, filter, doc, options.Replace().SetUpsert(true)); err != nil {
+				return errors.Wrapf(err, "failed to restore %s.%s", configDatabase, collection)
+			}
+			restored++
+		}
+		log.Infof("restored %d documents of %s.%s", restored, configDatabase, collection)
+	}
+	return nil
+}
+
+// metadataDocument returns the document of the sharding metadata collection with the shards renamed by shardIDs,
+// or false if it describes an internal database of the cluster.
+func metadataDocument(collection string, raw bson.Raw, shardIDs map[string]string) (bson.D, bool, error) {
+	var doc bson.D
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return nil, false, err
+	}
+	var shardField string
+	switch collection {
+	case "databases":
+		if id, _ := raw.Lookup("_id").StringValueOK(); id == "admin" || id == configDatabase {
+			return nil, false, nil
+		}
+		shardField = "primary"
+	case "collections", "chunks", "tags":
+		ns, _ := raw.Lookup("ns").StringValueOK()
+		if collection == "collections" {
+			ns, _ = raw.Lookup("_id").StringValueOK()
+		}
+		if strings.HasPrefix(ns, configDatabase+".") {
+			return nil, false, nil
+		}
+		if collection == "chunks" {
+			shardField = "shard"
+		}
+	}
+	if shardField == "" {
+		return doc, true, nil
+	}
+
+	renamed := false
+	out := make(bson.D, 0, len(doc))
+	for _, e := range doc {
+		if e.Key == shardField {
+			if id, ok := e.Value.(string); ok && shardIDs[id] != "" && shardIDs[id] != id {
+				e.Value = shardIDs[id]
+				renamed = true
+			}
+		}
+		out = append(out, e)
+	}
+	if renamed && collection == "chunks" {
+		// the history of a chunk names the shards it was on
+		for i, e := range out {
+			if e.Key == "history" {
+				out = append(out[:i], out[i+1:]...)
+				break
+			}
+		}
+	}
+	return out, true, nil
+}
+
+func (r *Restore) replay(ctx context.Context, source string, shard Component, until primitive.Timestamp) error {
+	client, err := r.Connect(ctx, shard.Hosts, shard.ReplicaSet)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	replayer := &oplog.Replayer{
+		Client:     client,
+		Store:      r.Store.Sub(OplogFolder),
+		ReplicaSet: source,
+	}
+	// the captured oplog starts before the dump, so it is replayed from its first entry
+	return replayer.ReplayRange(ctx, primitive.Timestamp{}, until)
+}
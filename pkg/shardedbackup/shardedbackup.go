@@ -0,0 +1,70 @@
+// Package shardedbackup takes consistent snapshots of sharded clusters: the config server and every shard are
+// dumped in parallel with the balancer stopped, and the oplog of every shard is captured until a common cluster
+// time. Restoring replays the captured oplog of every shard until that time.
+package shardedbackup
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"kubedb.dev/mongodb/pkg/dbclient"
+	"kubedb.dev/mongodb/pkg/oplog"
+)
+
+const (
+	// ManifestName is the item of the snapshot that describes its layout.
+	ManifestName = "sharded.json"
+	// OplogFolder is the folder of the snapshot with the captured oplog of the shards.
+	OplogFolder = "oplog"
+)
+
+// Component is a replicaset of the cluster, ie, the config server or a shard.
+type Component struct {
+	ReplicaSet string
+	Hosts      []string
+}
+
+// ParseComponent parses a component in the format of a shard DSN, ie, <replicaset>/<host:port>,<host:port>.
+func ParseComponent(dsn string) (Component, error) {
+	parts := strings.SplitN(dsn, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return Component{}, errors.Errorf("invalid replicaset %q. Must be <replicaset>/<host:port>,...", dsn)
+	}
+	return Component{ReplicaSet: parts[0], Hosts: strings.Split(parts[1], ",")}, nil
+}
+
+// Connector connects to the members hosts of replicaset replicaSet.
+type Connector func(ctx context.Context, hosts []string, replicaSet string) (*dbclient.Client, error)
+
+// Manifest describes a consistent snapshot of a sharded cluster.
+type Manifest struct {
+	// ClusterTime is the time the shards are restored to.
+	ClusterTime primitive.Timestamp `json:"clusterTime"`
+	// ConfigServer is the replicaset of the config server. Its dump is in the folder of the same name.
+	ConfigServer string `json:"configServer"`
+	// Shards are the replicasets of the shards in order. Their dumps are in the folders of the same name.
+	Shards []string `json:"shards"`
+}
+
+func readManifest(store *oplog.Store) (*Manifest, error) {
+	data, err := store.Get(ManifestName)
+	if err != nil {
+		return nil, err
+	}
+	manifest := &Manifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, errors.Wrapf(err, "invalid %s", ManifestName)
+	}
+	return manifest, nil
+}
+
+func writeManifest(store *oplog.Store, manifest *Manifest) error {
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	return store.Put(ManifestName, data)
+}
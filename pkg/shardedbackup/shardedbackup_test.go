@@ -0,0 +1,91 @@
+package shardedbackup
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestParseComponent(t *testing.T) {
+	c, err := ParseComponent("shard1/mgo-shard1-0.mgo-shard1-gvr.demo.svc:27017,mgo-shard1-1.mgo-shard1-gvr.demo.svc:27017")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.ReplicaSet != "shard1" || len(c.Hosts) != 2 || c.Hosts[1] != "mgo-shard1-1.mgo-shard1-gvr.demo.svc:27017" {
+		t.Errorf("unexpected component %+v", c)
+	}
+	for _, dsn := range []string{"", "shard1", "shard1/", "/mgo-shard1-0:27017"} {
+		if _, err := ParseComponent(dsn); err == nil {
+			t.Errorf("expected %q to be invalid", dsn)
+		}
+	}
+}
+
+func TestMetadataDocument(t *testing.T) {
+	marshal := func(doc bson.D) bson.Raw {
+		data, err := bson.Marshal(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	shardIDs := map[string]string{"shard0": "sh0", "shard1": "sh1"}
+
+	cases := []struct {
+		collection string
+		doc        bson.D
+		skip       bool
+		field      string
+		value      string
+	}{
+		{collection: "databases", doc: bson.D{{Key: "_id", Value: "shop"}, {Key: "primary", Value: "shard1"}, {Key: "partitioned", Value: true}}, field: "primary", value: "sh1"},
+		{collection: "databases", doc: bson.D{{Key: "_id", Value: "config"}, {Key: "primary", Value: "config"}}, skip: true},
+		{collection: "collections", doc: bson.D{{Key: "_id", Value: "config.system.sessions"}}, skip: true},
+		{collection: "collections", doc: bson.D{{Key: "_id", Value: "shop.orders"}, {Key: "key", Value: bson.D{{Key: "_id", Value: "hashed"}}}}, field: "_id", value: "shop.orders"},
+		{collection: "chunks", doc: bson.D{{Key: "_id", Value: "shop.orders-_id_MinKey"}, {Key: "ns", Value: "shop.orders"}, {Key: "shard", Value: "shard0"},
+			{Key: "history", Value: bson.A{bson.D{{Key: "shard", Value: "shard0"}}}}}, field: "shard", value: "sh0"},
+		{collection: "chunks", doc: bson.D{{Key: "_id", Value: "config.system.sessions-_id_MinKey"}, {Key: "ns", Value: "config.system.sessions"}, {Key: "shard", Value: "shard0"}}, skip: true},
+	}
+	for _, c := range cases {
+		doc, ok, err := metadataDocument(c.collection, marshal(c.doc), shardIDs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok == c.skip {
+			t.Errorf("%s %v: expected skip %v", c.collection, c.doc, c.skip)
+			continue
+		}
+		if c.skip {
+			continue
+		}
+		if value := doc.Map()[c.field]; value != c.value {
+			t.Errorf("%s %v: expected %s %s, got %v", c.collection, c.doc, c.field, c.value, value)
+		}
+		if _, found := doc.Map()["history"]; found {
+			t.Errorf("%s %v: expected the history of the renamed chunk to be dropped", c.collection, c.doc)
+		}
+	}
+}
+
+func TestBalancerWasRunning(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sharded-backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := balancerWasRunning(dir); err == nil {
+		t.Error("expected the mode of the balancer to be unknown before Start")
+	}
+	for mode, want := range map[string]bool{"full": true, "off": false} {
+		if err := ioutil.WriteFile(filepath.Join(dir, balancerFileName), []byte(mode), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if running, err := balancerWasRunning(dir); err != nil || running != want {
+			t.Errorf("mode %s: expected running %v, got %v, %v", mode, want, running, err)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package slowlog
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultMaxLogSize bounds the size of the log file, if not set.
+	DefaultMaxLogSize = 64 << 20
+
+	pollInterval = time.Second
+)
+
+// Shipper follows the log file of mongod. It writes the slow operations to Records as JSON, one per line,
+// and every line of the log to Passthrough.
+type Shipper struct {
+	Path        string
+	Records     io.Writer
+	Passthrough io.Writer
+	// MaxLogSize bounds the size of the log file. Once the whole file has been read, and it is larger, it is
+	// truncated. mongod appends to it, so it goes on writing at the start of the file. The lines written
+	// between the last read and the truncation are lost.
+	MaxLogSize int64
+}
+
+// Run follows the log file until ctx is done. It waits for mongod to create the file, and reopens the file
+// once it is rotated.
+func (s *Shipper) Run(ctx context.Context) error {
+	if s.MaxLogSize <= 0 {
+		s.MaxLogSize = DefaultMaxLogSize
+	}
+	enc := json.NewEncoder(s.Records)
+
+	for ctx.Err() == nil {
+		file, err := os.Open(s.Path)
+		if os.IsNotExist(err) {
+			sleep(ctx, pollInterval)
+			continue
+		} else if err != nil {
+			return errors.Wrapf(err, "failed to open log file %v", s.Path)
+		}
+		err = s.follow(ctx, file, enc)
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// follow reads file until ctx is done, or the log file is rotated.
+func (s *Shipper) follow(ctx context.Context, file *os.File, enc *json.Encoder) error {
+	reader := bufio.NewReader(file)
+	var offset int64
+	var partial string
+	for ctx.Err() == nil {
+		line, err := reader.ReadString('\n')
+		offset += int64(len(line))
+		if err == nil {
+			if err := s.ship(partial+line, enc); err != nil {
+				return err
+			}
+			partial = ""
+			continue
+		}
+		if err != io.EOF {
+			return errors.Wrapf(err, "failed to read log file %v", s.Path)
+		}
+		// a partial line is completed by the next write.
+		partial += line
+
+		info, err := os.Stat(s.Path)
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return errors.Wrapf(err, "failed to stat log file %v", s.Path)
+		}
+		cur, err := file.Stat()
+		if err != nil {
+			return errors.Wrapf(err, "failed to stat log file %v", s.Path)
+		}
+		switch {
+		case !os.SameFile(info, cur):
+			// rotated, the rest of the old file has been read.
+			return nil
+		case info.Size() < offset:
+			// truncated by someone else.
+			if err := s.rewind(file, reader, &offset, &partial); err != nil {
+				return err
+			}
+			continue
+		case partial == "" && offset >= s.MaxLogSize:
+			if err := os.Truncate(s.Path, 0); err != nil {
+				return errors.Wrapf(err, "failed to truncate log file %v", s.Path)
+			}
+			if err := s.rewind(file, reader, &offset, &partial); err != nil {
+				return err
+			}
+			continue
+		}
+		sleep(ctx, pollInterval)
+	}
+	return nil
+}
+
+func (s *Shipper) rewind(file *os.File, reader *bufio.Reader, offset *int64, partial *string) error {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return errors.Wrapf(err, "failed to seek log file %v", s.Path)
+	}
+	reader.Reset(file)
+	*offset, *partial = 0, ""
+	return nil
+}
+
+// ship writes line to Passthrough, and its slow operation, if any, to Records.
+func (s *Shipper) ship(line string, enc *json.Encoder) error {
+	if _, err := io.WriteString(s.Passthrough, line); err != nil {
+		return err
+	}
+	if record, ok := Parse(line); ok {
+		return enc.Encode(record)
+	}
+	return nil
+}
+
+func sleep(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
@@ -0,0 +1,110 @@
+// Package slowlog follows the log of mongod, and writes its slow operations as JSON records, for the log
+// pipelines of the cluster.
+package slowlog
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// slowQueryMessage is the message of a slow operation in the structured log of MongoDB 4.4 and later.
+const slowQueryMessage = "Slow query"
+
+// Record is a slow operation.
+type Record struct {
+	Time      string `json:"time"`
+	Component string `json:"component"`
+	Context   string `json:"context,omitempty"`
+	// Type of the operation, eg, command, update or getmore.
+	Type           string `json:"type,omitempty"`
+	Namespace      string `json:"ns,omitempty"`
+	DurationMillis int64  `json:"durationMillis"`
+	PlanSummary    string `json:"planSummary,omitempty"`
+	// Attr holds the attributes of a structured log entry, that detail the operation.
+	Attr json.RawMessage `json:"attr,omitempty"`
+	// Message holds the line of a text log entry, that details the operation.
+	Message string `json:"message,omitempty"`
+}
+
+type structuredEntry struct {
+	T struct {
+		Date string `json:"$date"`
+	} `json:"t"`
+	C    string          `json:"c"`
+	Ctx  string          `json:"ctx"`
+	Msg  string          `json:"msg"`
+	Attr json.RawMessage `json:"attr"`
+}
+
+type slowQueryAttr struct {
+	Type           string `json:"type"`
+	NS             string `json:"ns"`
+	DurationMillis int64  `json:"durationMillis"`
+	PlanSummary    string `json:"planSummary"`
+}
+
+var (
+	// textEntry matches a slow operation in the text log before MongoDB 4.4: the time, the severity,
+	// the component, the context and the operation, that ends with its duration.
+	textEntry   = regexp.MustCompile(`^(\S+) \w\s+(COMMAND|QUERY|WRITE)\s+\[([^\]]+)\] (.*) (\d+)ms$`)
+	planSummary = regexp.MustCompile(`planSummary: (\S+)`)
+)
+
+// Parse returns the slow operation logged in line, if any. Both the structured log of MongoDB 4.4 and later,
+// and the text log of the earlier versions are parsed.
+func Parse(line string) (*Record, bool) {
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, "{") {
+		return parseStructured(line)
+	}
+	return parseText(line)
+}
+
+func parseStructured(line string) (*Record, bool) {
+	var entry structuredEntry
+	if err := json.Unmarshal([]byte(line), &entry); err != nil || entry.Msg != slowQueryMessage {
+		return nil, false
+	}
+	var attr slowQueryAttr
+	if err := json.Unmarshal(entry.Attr, &attr); err != nil {
+		return nil, false
+	}
+	return &Record{
+		Time:           entry.T.Date,
+		Component:      entry.C,
+		Context:        entry.Ctx,
+		Type:           attr.Type,
+		Namespace:      attr.NS,
+		DurationMillis: attr.DurationMillis,
+		PlanSummary:    attr.PlanSummary,
+		Attr:           entry.Attr,
+	}, true
+}
+
+func parseText(line string) (*Record, bool) {
+	m := textEntry.FindStringSubmatch(line)
+	if m == nil {
+		return nil, false
+	}
+	duration, err := strconv.ParseInt(m[5], 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	record := &Record{
+		Time:           m[1],
+		Component:      m[2],
+		Context:        m[3],
+		DurationMillis: duration,
+		Message:        m[4],
+	}
+	// the operation starts with its type and namespace, eg, "command test.users command: find { ... }".
+	if fields := strings.Fields(m[4]); len(fields) >= 2 {
+		record.Type, record.Namespace = fields[0], fields[1]
+	}
+	if p := planSummary.FindStringSubmatch(m[4]); p != nil {
+		record.PlanSummary = p[1]
+	}
+	return record, true
+}
@@ -0,0 +1,49 @@
+package slowlog
+
+import (
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name string
+		line string
+		want *Record
+	}{
+		{
+			name: "structured slow query",
+			line: `{"t":{"$date":"2020-08-01T10:00:00.000+00:00"},"s":"I","c":"COMMAND","id":51803,"ctx":"conn12","msg":"Slow query","attr":{"type":"command","ns":"test.users","command":{"find":"users"},"planSummary":"COLLSCAN","durationMillis":152}}`,
+			want: &Record{Time: "2020-08-01T10:00:00.000+00:00", Component: "COMMAND", Context: "conn12", Type: "command",
+				Namespace: "test.users", DurationMillis: 152, PlanSummary: "COLLSCAN"},
+		},
+		{
+			name: "structured entry",
+			line: `{"t":{"$date":"2020-08-01T10:00:00.000+00:00"},"s":"I","c":"NETWORK","id":22943,"ctx":"listener","msg":"Connection accepted","attr":{}}`,
+		},
+		{
+			name: "text slow query",
+			line: `2019-08-01T10:00:00.000+0000 I COMMAND  [conn12] command test.users appName: "MongoDB Shell" command: find { find: "users", filter: { age: 30.0 } } planSummary: COLLSCAN keysExamined:0 docsExamined:10000 numYields:78 nreturned:1 reslen:223 protocol:op_msg 152ms`,
+			want: &Record{Time: "2019-08-01T10:00:00.000+0000", Component: "COMMAND", Context: "conn12", Type: "command",
+				Namespace: "test.users", DurationMillis: 152, PlanSummary: "COLLSCAN"},
+		},
+		{
+			name: "text entry",
+			line: `2019-08-01T10:00:00.000+0000 I NETWORK  [listener] connection accepted from 127.0.0.1:53124 #12 (1 connection now open)`,
+		},
+	}
+	for _, c := range cases {
+		got, ok := Parse(c.line)
+		if ok != (c.want != nil) {
+			t.Errorf("%v: expected slow operation %v, got %v", c.name, c.want != nil, ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if got.Time != c.want.Time || got.Component != c.want.Component || got.Context != c.want.Context ||
+			got.Type != c.want.Type || got.Namespace != c.want.Namespace ||
+			got.DurationMillis != c.want.DurationMillis || got.PlanSummary != c.want.PlanSummary {
+			t.Errorf("%v: expected %+v, got %+v", c.name, c.want, got)
+		}
+	}
+}